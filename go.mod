@@ -4,7 +4,9 @@ go 1.14
 
 require (
 	github.com/aws/aws-sdk-go v1.19.0
+	github.com/gorilla/mux v1.7.3
 	github.com/mattermost/mattermost-server/v5 v5.23.0
 	github.com/pkg/errors v0.9.1
+	github.com/stretchr/testify v1.4.0
 	golang.org/x/sys v0.1.0 // indirect
 )
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	actionShowOriginal    = "show_original"
+	actionShowTranslation = "show_translation"
+	actionRetry           = "retry"
+	actionTranslateRest   = "translate_rest"
+)
+
+func getActionURL(action string) string {
+	return fmt.Sprintf("/plugins/%s/api/v1/actions/%s", manifest.Id, action)
+}
+
+func newPostAction(name, action, postID string) *model.PostAction {
+	return &model.PostAction{
+		Name: name,
+		Type: model.POST_ACTION_TYPE_BUTTON,
+		Integration: &model.PostActionIntegration{
+			URL:     getActionURL(action),
+			Context: map[string]interface{}{"post_id": postID},
+		},
+	}
+}
+
+// addShowOriginalAction adds a button showing the original of a translation to its last attachment.
+func addShowOriginalAction(postID string, attachments []*model.SlackAttachment) {
+	last := attachments[len(attachments)-1]
+	last.Actions = append(last.Actions, newPostAction("Show original", actionShowOriginal, postID))
+}
+
+// handlePostAction answers the buttons of translations and originals by showing the other one
+// ephemerally to the user who clicked, by recording the feedback of the user on a translation, or
+// by asking the user for their consent.
+func (p *Plugin) handlePostAction(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	action := mux.Vars(r)["action"]
+
+	var request *model.PostActionIntegrationRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&request); err != nil || request == nil {
+		writeAPIError(w, newInvalidParameterError("request"))
+		return
+	}
+
+	postID, _ := request.Context["post_id"].(string)
+	if len(postID) != 26 {
+		writeAPIError(w, newInvalidParameterError("post_id"))
+		return
+	}
+
+	post, appErr := p.API.GetPost(postID)
+	if appErr != nil {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorPostNotFound, Message: "No post to show", StatusCode: http.StatusBadRequest})
+		return
+	}
+
+	if !p.API.HasPermissionToChannel(userID, post.ChannelId, model.PERMISSION_READ_CHANNEL) {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorForbidden, Message: "Not authorized to read post", StatusCode: http.StatusForbidden})
+		return
+	}
+
+	response := &model.PostActionIntegrationResponse{}
+	switch action {
+	case actionShowOriginal:
+		p.showOriginal(userID, post)
+	case actionShowTranslation:
+		if isNoTranslatePost(post) {
+			writeAPIError(w, &APIErrorResponse{ID: apiErrorOptedOut, Message: "Post is opted out of translation", StatusCode: http.StatusForbidden})
+			return
+		}
+
+		if err := p.showTranslation(r.Context(), userID, post); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+	case actionConsent:
+		if err := p.openConsentDialog(request.TriggerId, ""); err != nil {
+			p.API.LogError("Failed to open consent dialog", "user_id", userID, "err", err.Error())
+			writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to open consent dialog", StatusCode: http.StatusInternalServerError})
+			return
+		}
+	case actionFeedbackGood, actionFeedbackBad:
+		if err := p.recordFeedback(userID, post, action, request.Context); err != nil {
+			p.API.LogError("Failed to save translation feedback", "post_id", post.Id, "err", err.Error())
+			writeAPIError(w, &APIErrorResponse{ID: apiErrorUnableToSave, Message: "Failed to save feedback", StatusCode: http.StatusInternalServerError})
+			return
+		}
+	case actionRetry:
+		postIDs, _ := request.Context["post_ids"].(string)
+		if err := p.retryTranslation(userID, post, strings.Split(postIDs, ",")); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+
+		p.API.DeleteEphemeralPost(userID, request.PostId)
+	case actionTranslateRest:
+		offset, _ := request.Context["offset"].(float64)
+		translationPost, err := p.translateRest(r.Context(), post, int(offset), request.PostId)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+
+		response.Update = translationPost
+	default:
+		writeAPIError(w, newNotFoundError())
+		return
+	}
+
+	resp, _ := json.Marshal(response)
+	w.Write(resp)
+}
+
+// showOriginal shows the original message and attachments of a post ephemerally, along with a
+// button to translate it again.
+func (p *Plugin) showOriginal(userID string, post *model.Post) {
+	var attachments []*model.SlackAttachment
+	if strings.TrimSpace(post.Message) != "" {
+		attachments = append(attachments, &model.SlackAttachment{Text: post.Message})
+	}
+	for _, attachment := range post.Attachments() {
+		copied := *attachment
+		copied.Actions = nil
+		attachments = append(attachments, &copied)
+	}
+
+	if len(attachments) == 0 {
+		attachments = append(attachments, &model.SlackAttachment{})
+	}
+	attachments[0].Pretext = "Original"
+
+	last := attachments[len(attachments)-1]
+	last.Actions = append(last.Actions, newPostAction("Show translation", actionShowTranslation, post.Id))
+
+	p.sendEphemeralTranslation(post, userID, attachments)
+}
+
+// showTranslation translates a post into the target language of the user and shows it ephemerally.
+func (p *Plugin) showTranslation(ctx context.Context, userID string, post *model.Post) *APIErrorResponse {
+	userInfo, apiErr := p.getUserInfo(userID)
+	if apiErr != nil {
+		userInfo = p.NewUserInfo(userID)
+	}
+
+	svc, err := p.getTranslateService()
+	if err != nil {
+		return &APIErrorResponse{ID: apiErrorBadCredentials, Message: "Bad credentials", StatusCode: http.StatusForbidden}
+	}
+
+	content, err := p.translatePostContent(ctx, svc, post, userInfo, false)
+	if err != nil {
+		return newTranslationError(err)
+	}
+
+	if content.isEmpty() {
+		p.API.SendEphemeralPost(userID, &model.Post{
+			UserId:    p.botUserID,
+			ChannelId: post.ChannelId,
+			RootId:    post.RootId,
+			ParentId:  post.RootId,
+			Message:   fmt.Sprintf("This post is already in %s.", languageCodes[userInfo.TargetLanguage]),
+		})
+		return nil
+	}
+
+	attachments := addTranslationHeader(userInfo.withSourceLanguage(content.sourceLanguage), content.message, content.attachments)
+	addShowOriginalAction(post.Id, attachments)
+	p.sendEphemeralTranslation(post, userID, attachments)
+	p.recordTranslationHistory(userID, post, userInfo.SourceLanguage, userInfo.TargetLanguage)
+
+	return nil
+}
+
+// notifyTranslationFailure lets the author of posts know that their translation failed, with a
+// button to retry, as the failure would otherwise only show up in the server log.
+func (p *Plugin) notifyTranslationFailure(posts []*model.Post, userInfo *UserInfo, err error) {
+	// Translations of bot posts aren't requested by any user in particular.
+	if userInfo.UserID == "" {
+		return
+	}
+
+	var postIDs []string
+	for _, post := range posts {
+		postIDs = append(postIDs, post.Id)
+	}
+
+	retry := newPostAction("Retry", actionRetry, posts[0].Id)
+	retry.Integration.Context["post_ids"] = strings.Join(postIDs, ",")
+
+	p.API.SendEphemeralPost(userInfo.UserID, &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: posts[0].ChannelId,
+		RootId:    posts[0].RootId,
+		ParentId:  posts[0].RootId,
+		Props: model.StringInterface{
+			"attachments": []*model.SlackAttachment{{
+				Text:    fmt.Sprintf("Failed to translate your message into %s. `%s`", languageCodes[userInfo.TargetLanguage], err.Error()),
+				Actions: []*model.PostAction{retry},
+			}},
+		},
+	})
+}
+
+// retryTranslation translates posts of the user again after their translation failed.
+func (p *Plugin) retryTranslation(userID string, post *model.Post, postIDs []string) *APIErrorResponse {
+	if post.UserId != userID {
+		return &APIErrorResponse{ID: apiErrorNotAuthor, Message: "Only the author can retry the translation", StatusCode: http.StatusForbidden}
+	}
+
+	userInfo, apiErr := p.getUserInfo(userID)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	if !userInfo.Activated {
+		return &APIErrorResponse{ID: apiErrorNotActivated, Message: "Autotranslation is turned off", StatusCode: http.StatusBadRequest}
+	}
+
+	posts := []*model.Post{post}
+	for _, postID := range postIDs {
+		if postID == post.Id || len(postID) != 26 {
+			continue
+		}
+
+		if other, appErr := p.API.GetPost(postID); appErr == nil && other.UserId == userID && other.ChannelId == post.ChannelId {
+			posts = append(posts, other)
+		}
+	}
+
+	p.translatePosts(posts, userInfo)
+
+	return nil
+}
@@ -2,6 +2,13 @@ package main
 
 import (
 	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	botUsername    = "autotranslate-bot"
+	botDisplayName = "Auto Translate Bot"
 )
 
 // OnActivate is invoked when the plugin is activated.
@@ -17,5 +24,47 @@ func (p *Plugin) OnActivate() error {
 		return errors.Wrap(err, "failed to register commands")
 	}
 
+	botUserID, err := p.Helpers.EnsureBot(&model.Bot{
+		Username:    botUsername,
+		DisplayName: botDisplayName,
+		Description: "Posts translations of messages on behalf of the Autotranslate plugin.",
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to ensure bot account")
+	}
+	p.botUserID = botUserID
+	p.router = p.initializeRouter()
+
+	if err := p.ensureActivatedUsers(); err != nil {
+		return errors.Wrap(err, "failed to index activated users")
+	}
+
+	p.startUsageStatsFlush()
+	p.startDataRetentionCleanup()
+	p.startSpendReports()
+	p.startProviderHealthCheck()
+	p.startTelemetryFlush()
+	p.startFailureAlerts()
+	p.startTracing()
+	p.startQualityRouting()
+
+	return nil
+}
+
+// OnDeactivate is invoked when the plugin is deactivated.
+//
+// Pending bursts of posts are translated right away and usage statistics are saved so that they
+// don't get lost.
+func (p *Plugin) OnDeactivate() error {
+	p.flushAllPostBursts()
+	p.stopUsageStatsFlush()
+	p.stopDataRetentionCleanup()
+	p.stopSpendReports()
+	p.stopProviderHealthCheck()
+	p.stopTelemetryFlush()
+	p.stopFailureAlerts()
+	p.stopTracing()
+	p.stopQualityRouting()
+
 	return nil
 }
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	// activatedUsersKey is the KV key of the index of users with autotranslation turned on, which
+	// spares reading the user info of every member of a channel to translate posts for readers.
+	activatedUsersKey = "activated_users"
+
+	// activatedUsersRetries bounds the attempts to update the index when it changes concurrently.
+	activatedUsersRetries = 5
+
+	// channelMembersCacheTTL is how long the activated members of a channel are reused.
+	channelMembersCacheTTL = time.Minute
+
+	channelMembersPerPage = 200
+	keysPerPage           = 1000
+)
+
+type channelMembersCacheEntry struct {
+	userInfos []*UserInfo
+	expireAt  time.Time
+}
+
+func (p *Plugin) getActivatedUsers() (map[string]bool, error) {
+	data, appErr := p.API.KVGet(activatedUsersKey)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	userIDs := map[string]bool{}
+	if data == nil {
+		return userIDs, nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	for _, userID := range list {
+		userIDs[userID] = true
+	}
+
+	return userIDs, nil
+}
+
+// updateActivatedUsers adds or removes a user from the index of users with autotranslation
+// turned on, comparing the stored value so that concurrent updates are not lost.
+func (p *Plugin) updateActivatedUsers(userID string, activated bool) error {
+	for i := 0; i < activatedUsersRetries; i++ {
+		oldData, appErr := p.API.KVGet(activatedUsersKey)
+		if appErr != nil {
+			return appErr
+		}
+
+		var list []string
+		if oldData != nil {
+			if err := json.Unmarshal(oldData, &list); err != nil {
+				return err
+			}
+		}
+
+		var newList []string
+		for _, id := range list {
+			if id != userID {
+				newList = append(newList, id)
+			}
+		}
+		if activated {
+			newList = append(newList, userID)
+		}
+
+		newData, err := json.Marshal(newList)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(oldData, newData) {
+			return nil
+		}
+
+		ok, appErr := p.API.KVSetWithOptions(activatedUsersKey, newData, model.PluginKVSetOptions{Atomic: true, OldValue: oldData})
+		if appErr != nil {
+			return appErr
+		}
+		if ok {
+			p.clearChannelMembersCache()
+			return nil
+		}
+	}
+
+	return errors.New("activated users changed concurrently")
+}
+
+// ensureActivatedUsers builds the index of users with autotranslation turned on from the stored
+// user infos when it does not exist yet, as for installations predating it.
+func (p *Plugin) ensureActivatedUsers() error {
+	data, appErr := p.API.KVGet(activatedUsersKey)
+	if appErr != nil {
+		return appErr
+	}
+	if data != nil {
+		return nil
+	}
+
+	list := []string{}
+	for page := 0; ; page++ {
+		keys, appErr := p.API.KVList(page, keysPerPage)
+		if appErr != nil {
+			return appErr
+		}
+
+		for _, key := range keys {
+			// User infos are stored under the user ID.
+			if len(key) != 26 {
+				continue
+			}
+			if userInfo, apiErr := p.getUserInfo(key); apiErr == nil && userInfo.Activated {
+				list = append(list, key)
+			}
+		}
+
+		if len(keys) < keysPerPage {
+			break
+		}
+	}
+
+	newData, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+
+	// Users turning autotranslation on meanwhile have created the index already.
+	if _, appErr := p.API.KVSetWithOptions(activatedUsersKey, newData, model.PluginKVSetOptions{Atomic: true, OldValue: nil}); appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
+
+// getActivatedChannelMembers returns the user info of channel members with autotranslation
+// turned on. Only the members found in the index of activated users are looked up, and the
+// result is cached for a while as bot posts tend to come in bursts.
+func (p *Plugin) getActivatedChannelMembers(channelID string) []*UserInfo {
+	p.channelMembersLock.Lock()
+	entry, ok := p.channelMembersCache[channelID]
+	p.channelMembersLock.Unlock()
+	if ok && time.Now().Before(entry.expireAt) {
+		return entry.userInfos
+	}
+
+	activated, err := p.getActivatedUsers()
+	if err != nil {
+		p.API.LogError("Failed to get activated users", "err", err.Error())
+		return nil
+	}
+
+	var userInfos []*UserInfo
+	if len(activated) > 0 {
+		userInfos = p.findActivatedChannelMembers(channelID, activated)
+	}
+
+	p.channelMembersLock.Lock()
+	if p.channelMembersCache == nil {
+		p.channelMembersCache = map[string]channelMembersCacheEntry{}
+	}
+	p.channelMembersCache[channelID] = channelMembersCacheEntry{userInfos: userInfos, expireAt: time.Now().Add(channelMembersCacheTTL)}
+	p.channelMembersLock.Unlock()
+
+	return userInfos
+}
+
+func (p *Plugin) findActivatedChannelMembers(channelID string, activated map[string]bool) []*UserInfo {
+	var userInfos []*UserInfo
+	for page := 0; ; page++ {
+		members, appErr := p.API.GetChannelMembers(channelID, page, channelMembersPerPage)
+		if appErr != nil {
+			p.API.LogError("Failed to get channel members", "channel_id", channelID, "err", appErr.Error())
+			break
+		}
+
+		for _, member := range *members {
+			if !activated[member.UserId] {
+				continue
+			}
+			if userInfo, apiErr := p.getUserInfo(member.UserId); apiErr == nil && userInfo.Activated {
+				userInfos = append(userInfos, userInfo)
+			}
+		}
+
+		if len(*members) < channelMembersPerPage {
+			break
+		}
+	}
+
+	return userInfos
+}
+
+func (p *Plugin) clearChannelMembersCache() {
+	p.channelMembersLock.Lock()
+	p.channelMembersCache = nil
+	p.channelMembersLock.Unlock()
+}
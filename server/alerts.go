@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	failureAlertKeyPrefix = "failurealert_"
+
+	// failureAlertCheckInterval is how often the window of provider requests is checked to be
+	// over.
+	failureAlertCheckInterval = time.Minute
+
+	// minFailureAlertRequests is the number of requests a window needs for its failure rate to
+	// alert admins, so that a single failed request of a quiet window doesn't.
+	minFailureAlertRequests = 10
+
+	// maxFailureAlertErrors bounds the error messages listed in an alert.
+	maxFailureAlertErrors = 3
+
+	defaultFailureAlertThreshold = 25
+	defaultFailureAlertWindow    = 15
+)
+
+// failureWindow counts the requests to translation providers and their errors during a window of
+// time, errors being keyed by message.
+type failureWindow struct {
+	start    time.Time
+	requests int64
+	failures int64
+	errors   map[string]int64
+}
+
+// failureCounter counts the requests to translation providers in the current window.
+type failureCounter struct {
+	lock   sync.Mutex
+	window failureWindow
+}
+
+func (c *failureCounter) observe(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.window.requests++
+	if err == nil {
+		return
+	}
+
+	c.window.failures++
+	if c.window.errors == nil {
+		c.window.errors = map[string]int64{}
+	}
+	c.window.errors[getErrorSummary(err)]++
+}
+
+// rotate returns the current window when it started a window length before now at least, starting
+// a new one. The first window starts on the first rotation.
+func (c *failureCounter) rotate(now time.Time, length time.Duration) (failureWindow, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.window.start.IsZero() {
+		c.window.start = now
+		return failureWindow{}, false
+	}
+	if now.Sub(c.window.start) < length {
+		return failureWindow{}, false
+	}
+
+	window := c.window
+	c.window = failureWindow{start: now}
+
+	return window, true
+}
+
+// getErrorSummary returns the message of an error of a provider without the parts changing with
+// each request, such as the request ID, so that the same errors are counted together.
+func getErrorSummary(err error) string {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return awsErr.Code() + ": " + awsErr.Message()
+	}
+
+	return err.Error()
+}
+
+// getFailureAlert returns the message alerting admins that the failure rate of a window reached
+// the threshold in percent, listing its most frequent errors, or an empty string when it didn't.
+func getFailureAlert(window failureWindow, threshold int, length time.Duration) string {
+	if threshold <= 0 || window.requests < minFailureAlertRequests || window.failures*100 < window.requests*int64(threshold) {
+		return ""
+	}
+
+	messages := make([]string, 0, len(window.errors))
+	for message := range window.errors {
+		messages = append(messages, message)
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		if window.errors[messages[i]] != window.errors[messages[j]] {
+			return window.errors[messages[i]] > window.errors[messages[j]]
+		}
+		return messages[i] < messages[j]
+	})
+	if len(messages) > maxFailureAlertErrors {
+		messages = messages[:maxFailureAlertErrors]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "#### Translation failures\n%d of the %d requests to the translation provider failed (%d%%) over the last %d minutes, reaching the alert threshold of %d%%.\n",
+		window.failures, window.requests, window.failures*100/window.requests, int(length.Minutes()), threshold)
+	b.WriteString("\nMost frequent errors:\n")
+	for _, message := range messages {
+		fmt.Fprintf(&b, "* `%s`: %d\n", message, window.errors[message])
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func getFailureAlertKey(windowStart time.Time) string {
+	return failureAlertKeyPrefix + strconv.FormatInt(windowStart.Unix(), 10)
+}
+
+func (p *Plugin) startFailureAlerts() {
+	p.failureAlertStop = make(chan struct{})
+	stop := p.failureAlertStop
+
+	go func() {
+		ticker := time.NewTicker(failureAlertCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.checkFailureAlert(time.Now())
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (p *Plugin) stopFailureAlerts() {
+	if p.failureAlertStop != nil {
+		close(p.failureAlertStop)
+		p.failureAlertStop = nil
+	}
+}
+
+// checkFailureAlert alerts admins when the failure rate of the requests of this server to the
+// provider reached the threshold set by admins over the window which just ended. Servers of a
+// cluster count their own requests, a single one alerting admins per window.
+func (p *Plugin) checkFailureAlert(now time.Time) {
+	config := p.getConfiguration()
+	length := config.getFailureAlertWindow()
+
+	window, ended := p.failures.rotate(now, length)
+	if !ended {
+		return
+	}
+
+	alert := getFailureAlert(window, config.getFailureAlertThreshold(), length)
+	if alert == "" {
+		return
+	}
+
+	// Windows of the servers of a cluster start at different times, the window containing the end
+	// of theirs being claimed instead.
+	claimed, appErr := p.API.KVSetWithOptions(getFailureAlertKey(now.UTC().Truncate(length)), []byte(strconv.FormatInt(window.failures, 10)), model.PluginKVSetOptions{
+		Atomic:          true,
+		OldValue:        nil,
+		ExpireInSeconds: int64(2 * length.Seconds()),
+	})
+	if appErr != nil {
+		p.API.LogError("Failed to claim failure alert", "err", appErr.Error())
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	p.API.LogWarn("Translation provider failure rate reached the alert threshold", "requests", window.requests, "failures", window.failures)
+	if err := p.notifyAdmins(config.AlertChannel, alert); err != nil {
+		p.API.LogError("Failed to alert admins about translation failures", "err", err.Error())
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailureCounter(t *testing.T) {
+	var counter failureCounter
+	start := time.Date(2020, 6, 12, 10, 0, 0, 0, time.UTC)
+
+	// The first window starts on the first rotation.
+	counter.observe(nil)
+	_, ended := counter.rotate(start, 15*time.Minute)
+	assert.False(t, ended)
+
+	counter.observe(nil)
+	counter.observe(errors.New("timeout"))
+	counter.observe(errors.New("timeout"))
+
+	_, ended = counter.rotate(start.Add(14*time.Minute), 15*time.Minute)
+	assert.False(t, ended)
+
+	window, ended := counter.rotate(start.Add(15*time.Minute), 15*time.Minute)
+	assert.True(t, ended)
+	assert.Equal(t, int64(4), window.requests)
+	assert.Equal(t, int64(2), window.failures)
+	assert.Equal(t, map[string]int64{"timeout": 2}, window.errors)
+
+	window, ended = counter.rotate(start.Add(30*time.Minute), 15*time.Minute)
+	assert.True(t, ended)
+	assert.Equal(t, failureWindow{start: start.Add(15 * time.Minute)}, window)
+}
+
+func TestGetErrorSummary(t *testing.T) {
+	assert.Equal(t, "timeout", getErrorSummary(errors.New("timeout")))
+
+	err := awserr.NewRequestFailure(awserr.New("ThrottlingException", "Rate exceeded", nil), 400, "request1")
+	assert.Equal(t, "ThrottlingException: Rate exceeded", getErrorSummary(err))
+}
+
+func TestGetFailureAlert(t *testing.T) {
+	window := failureWindow{
+		requests: 20,
+		failures: 6,
+		errors: map[string]int64{
+			"ThrottlingException: Rate exceeded": 3,
+			"ServiceUnavailableException: Busy":  1,
+			"InternalServerException: Failure":   1,
+			"UnrecognizedClientException: Token": 1,
+		},
+	}
+
+	assert.Equal(t, "#### Translation failures\n"+
+		"6 of the 20 requests to the translation provider failed (30%) over the last 15 minutes, reaching the alert threshold of 25%.\n"+
+		"\nMost frequent errors:\n"+
+		"* `ThrottlingException: Rate exceeded`: 3\n"+
+		"* `InternalServerException: Failure`: 1\n"+
+		"* `ServiceUnavailableException: Busy`: 1", getFailureAlert(window, 25, 15*time.Minute))
+
+	assert.Equal(t, "", getFailureAlert(window, 31, 15*time.Minute))
+	assert.Equal(t, "", getFailureAlert(window, 0, 15*time.Minute))
+
+	// Windows with few requests don't alert admins, whatever their failure rate.
+	assert.Equal(t, "", getFailureAlert(failureWindow{requests: 2, failures: 2, errors: map[string]int64{"timeout": 2}}, 25, 15*time.Minute))
+}
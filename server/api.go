@@ -1,17 +1,54 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/mattermost/mattermost-server/v5/model"
 	"github.com/mattermost/mattermost-server/v5/plugin"
+)
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/translate"
+// Error IDs of API error responses, which clients may rely on.
+const (
+	apiErrorNoRecordFound           = "no_record_found"
+	apiErrorInvalidParameter        = "invalid_parameter"
+	apiErrorNotAuthorized           = "not_authorized"
+	apiErrorForbidden               = "forbidden"
+	apiErrorNotFound                = "not_found"
+	apiErrorMethodNotAllowed        = "method_not_allowed"
+	apiErrorPostNotFound            = "post_not_found"
+	apiErrorChannelNotFound         = "channel_not_found"
+	apiErrorOptedOut                = "opted_out"
+	apiErrorNotConfigured           = "not_configured"
+	apiErrorTooManyRequests         = "too_many_requests"
+	apiErrorInvalidCSRFToken        = "invalid_csrf_token"
+	apiErrorInternal                = "internal_error"
+	apiErrorBadCredentials          = "bad_credentials"
+	apiErrorUnableToTranslate       = "unable_to_translate"
+	apiErrorUnableToSave            = "unable_to_save"
+	apiErrorUnableToUnmarshal       = "unable_to_unmarshal"
+	apiErrorInvalidUserInfo         = "invalid_user_info"
+	apiErrorInvalidChannelInfo      = "invalid_channel_info"
+	apiErrorInvalidOffset           = "invalid_offset"
+	apiErrorNotActivated            = "not_activated"
+	apiErrorNotAuthor               = "not_author"
+	apiErrorInvalidConfiguration    = "invalid_configuration"
+	apiErrorProviderThrottled       = "provider_throttled"
+	apiErrorProviderUnavailable     = "provider_unavailable"
+	apiErrorProviderNotAllowed      = "provider_not_allowed"
+	apiErrorConsentRequired         = "consent_required"
+	apiErrorInvalidSignature        = "invalid_signature"
+	apiErrorUnsupportedLanguagePair = "unsupported_language_pair"
+	apiErrorTextTooLong             = "text_too_long"
+	apiErrorLanguageNotDetected     = "language_not_detected"
+	apiErrorWrongOutputLanguage     = "wrong_output_language"
+	apiErrorProviderCanceled        = "provider_request_canceled"
+	apiErrorInvalidGlossary         = "invalid_glossary"
+	apiErrorAlreadyTranslated       = "already_translated"
 )
 
 // APIErrorResponse as standard response error
@@ -19,111 +56,331 @@ type APIErrorResponse struct {
 	ID         string `json:"id"`
 	Message    string `json:"message"`
 	StatusCode int    `json:"status_code"`
+	RequestID  string `json:"request_id,omitempty"`
 }
 
 func writeAPIError(w http.ResponseWriter, err *APIErrorResponse) {
+	err.RequestID = getResponseRequestID(w)
 	b, _ := json.Marshal(err)
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(err.StatusCode)
 	w.Write(b)
 }
 
+func newInvalidParameterError(name string) *APIErrorResponse {
+	return &APIErrorResponse{ID: apiErrorInvalidParameter, Message: "Invalid parameter: " + name, StatusCode: http.StatusBadRequest}
+}
+
+func newNotFoundError() *APIErrorResponse {
+	return &APIErrorResponse{ID: apiErrorNotFound, Message: "Not found", StatusCode: http.StatusNotFound}
+}
+
 func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Request) {
-	if err := p.IsValid(); err != nil {
-		http.Error(w, "This plugin is not configured.", http.StatusNotImplemented)
+	requestID := c.RequestId
+	if requestID == "" {
+		requestID = model.NewId()
+	}
+	w.Header().Set(requestIDHeader, requestID)
+
+	// The health endpoint reports the configuration error itself.
+	if err := p.IsValid(); err != nil && r.URL.Path != "/api/health" && r.URL.Path != "/api/v1/health" {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorNotConfigured, Message: "This plugin is not configured.", StatusCode: http.StatusNotImplemented})
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 
-	switch path := r.URL.Path; path {
-	case "/api/go":
-		p.getGo(w, r)
-	case "/api/get_info":
-		p.getInfo(w, r)
-	case "/api/set_info":
-		p.setInfo(w, r)
+	ctx := newRequestContext(r.Context(), requestID)
+	ctx = context.WithValue(ctx, sessionIDContextKey, c.SessionId)
+	ctx = context.WithValue(ctx, sourcePluginIDContextKey, c.SourcePluginId)
+	ctx = context.WithValue(ctx, clientAddressContextKey, c.IpAddress)
+	p.router.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// Formats of translations returned by the API, besides the TranslatedMessage JSON by default.
+const (
+	responseFormatJSON     = "json"
+	responseFormatText     = "text"
+	responseFormatMarkdown = "markdown"
+)
+
+// getResponseFormat returns the format of the translation asked for by the format query
+// parameter, or else by the Accept header, or an empty string when the format is unknown.
+func getResponseFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		switch format {
+		case responseFormatJSON, responseFormatText, responseFormatMarkdown:
+			return format
+		}
+
+		return ""
+	}
+
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "text/plain"):
+		return responseFormatText
+	case strings.Contains(accept, "text/markdown"):
+		return responseFormatMarkdown
+	}
+
+	return responseFormatJSON
+}
+
+// writeTranslatedMessage writes a translation in the given format: the TranslatedMessage JSON,
+// only the translated text, or the translated text below a Markdown header naming the languages.
+func writeTranslatedMessage(w http.ResponseWriter, translated *TranslatedMessage, format string) {
+	switch format {
+	case responseFormatText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(translated.TranslatedText))
+	case responseFormatMarkdown:
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		source := translated.SourceLanguage
+		if translated.DetectedLanguage != "" {
+			source = translated.DetectedLanguage
+		}
+		w.Write([]byte(fmt.Sprintf("**%s**\n\n%s", getTranslationHeader(source, translated.TargetLanguage), translated.TranslatedText)))
 	default:
-		http.NotFound(w, r)
+		resp, _ := json.Marshal(translated)
+		w.Write(resp)
 	}
 }
 
 func (p *Plugin) getGo(w http.ResponseWriter, r *http.Request) {
-	userID := r.Header.Get("Mattermost-User-ID")
-	if userID == "" {
-		http.Error(w, "Not authorized to translate post", http.StatusUnauthorized)
+	postID := getPostIDParam(r)
+	if len(postID) != 26 {
+		writeAPIError(w, newInvalidParameterError("post_id"))
 		return
 	}
 
-	postID := r.URL.Query().Get("post_id")
-	if len(postID) != 26 {
-		http.Error(w, "Invalid parameter: post_id", http.StatusBadRequest)
+	format := getResponseFormat(r)
+	if format == "" {
+		writeAPIError(w, newInvalidParameterError("format"))
 		return
 	}
 
 	source := r.URL.Query().Get("source")
 	if len(source) < 2 || len(source) > 5 {
-		http.Error(w, "Invalid parameter: source", http.StatusBadRequest)
+		writeAPIError(w, newInvalidParameterError("source"))
 		return
 	}
 
 	target := r.URL.Query().Get("target")
 	if len(target) < 2 || len(target) > 5 {
-		http.Error(w, "Invalid parameter: target", http.StatusBadRequest)
+		writeAPIError(w, newInvalidParameterError("target"))
 		return
 	}
 
-	post, err := p.API.GetPost(postID)
-	if err != nil {
-		http.Error(w, "No post to translate", http.StatusBadRequest)
+	provider, apiErr := p.getProviderParam(r)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
 		return
 	}
 
-	configuration := p.getConfiguration()
-	sess := session.Must(session.NewSession())
-	creds := credentials.NewStaticCredentials(configuration.AWSAccessKeyID, configuration.AWSSecretAccessKey, "")
-	_, awsErr := creds.Get()
-	if awsErr != nil {
-		http.Error(w, "Bad credentials", http.StatusForbidden)
+	post, appErr := p.API.GetPost(postID)
+	if appErr != nil {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorPostNotFound, Message: "No post to translate", StatusCode: http.StatusBadRequest})
 		return
 	}
 
-	svc := translate.New(sess, aws.NewConfig().WithCredentials(creds).WithRegion(configuration.AWSRegion))
+	if !p.API.HasPermissionToChannel(r.Header.Get("Mattermost-User-ID"), post.ChannelId, model.PERMISSION_READ_CHANNEL) {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorForbidden, Message: "Not authorized to read post", StatusCode: http.StatusForbidden})
+		return
+	}
 
-	input := translate.TextInput{
-		SourceLanguageCode: &source,
-		TargetLanguageCode: &target,
-		Text:               &post.Message,
+	if isNoTranslatePost(post) {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorOptedOut, Message: "Post is opted out of translation", StatusCode: http.StatusForbidden})
+		return
 	}
 
-	output, awsErr := svc.Text(&input)
-	if awsErr != nil {
-		http.Error(w, awsErr.Error(), http.StatusBadRequest)
+	translated, apiErr := p.translatePostMessage(r.Context(), post, source, target, provider)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
 		return
 	}
+	p.recordTranslationHistory(r.Header.Get("Mattermost-User-ID"), post, source, target)
+	p.trackTranslation(telemetryTriggerOnDemand, "", translated.Provider)
+
+	writeTranslatedMessage(w, translated, format)
+}
+
+// getTranslation returns the translation of a post into the target language without asking the
+// provider, unless the translate query parameter is true and the post has no translation yet.
+func (p *Plugin) getTranslation(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	postID := getPostIDParam(r)
+
+	target := r.URL.Query().Get("target")
+	if target == autoLanguage || languageCodes[target] == "" {
+		writeAPIError(w, newInvalidParameterError("target"))
+		return
+	}
+
+	source := r.URL.Query().Get("source")
+	if source == "" {
+		source = autoLanguage
+	}
+	if languageCodes[source] == "" {
+		writeAPIError(w, newInvalidParameterError("source"))
+		return
+	}
+
+	post, appErr := p.API.GetPost(postID)
+	if appErr != nil {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorPostNotFound, Message: "No post to translate", StatusCode: http.StatusBadRequest})
+		return
+	}
+
+	if !p.API.HasPermissionToChannel(userID, post.ChannelId, model.PERMISSION_READ_CHANNEL) {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorForbidden, Message: "Not authorized to read post", StatusCode: http.StatusForbidden})
+		return
+	}
+
+	translated, err := p.getCachedTranslation(r.Context(), post, target)
+	if err != nil {
+		p.API.LogError("Failed to get cached translation", "post_id", post.Id, "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to get translation", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	if translated == nil {
+		if r.URL.Query().Get("translate") != "true" {
+			writeAPIError(w, &APIErrorResponse{ID: apiErrorNoRecordFound, Message: "No translation found.", StatusCode: http.StatusNotFound})
+			return
+		}
+
+		if isNoTranslatePost(post) {
+			writeAPIError(w, &APIErrorResponse{ID: apiErrorOptedOut, Message: "Post is opted out of translation", StatusCode: http.StatusForbidden})
+			return
+		}
+
+		var apiErr *APIErrorResponse
+		if translated, apiErr = p.translatePostMessage(r.Context(), post, source, target, ""); apiErr != nil {
+			writeAPIError(w, apiErr)
+			return
+		}
+		p.recordTranslationHistory(userID, post, source, target)
+		p.trackTranslation(telemetryTriggerOnDemand, "", translated.Provider)
+	}
+
+	resp, _ := json.Marshal(translated)
+	w.Write(resp)
+}
+
+// translatePostMessage translates the message of a post for the API, reusing the cached
+// translation from the same source language, or from any when the source is auto, and caching the
+// new one otherwise. A forced provider always translates the message again, whatever the provider
+// routes, without caching its translation, as cached translations may come from another provider.
+func (p *Plugin) translatePostMessage(ctx context.Context, post *model.Post, source, target, provider string) (*TranslatedMessage, *APIErrorResponse) {
+	if provider == "" {
+		if cached, err := p.getCachedTranslation(ctx, post, target); err != nil {
+			p.API.LogWarn("Failed to get cached translation", "post_id", post.Id, "err", err.Error())
+		} else if cached != nil && (cached.SourceLanguage == source || source == autoLanguage) {
+			p.traceTranslation(ctx, "cache_hit", "post_id", post.Id, "target", target)
+			return cached, nil
+		}
+		p.traceTranslation(ctx, "cache_miss", "post_id", post.Id, "target", target)
+	}
+
+	if err := p.checkAuthorConsent(post); err != nil {
+		return nil, newTranslationError(err)
+	}
+
+	getService := translationProviders[providerAWS]
+	if provider != "" {
+		getService = translationProviders[provider]
+	}
+
+	svc, err := getService(p)
+	if err != nil {
+		return nil, &APIErrorResponse{ID: apiErrorBadCredentials, Message: "Bad credentials", StatusCode: http.StatusForbidden}
+	}
+
+	ctx = newAuditContext(p.newGlossaryContext(ctx, post.ChannelId), "", post.Id, post.ChannelId)
+	if provider != "" {
+		ctx = newProviderContext(ctx, provider)
+	}
+	translatedText, translatedSource, err := p.translateLongTextWithSource(ctx, svc, source, target, post.Message)
+	if err != nil {
+		return nil, newTranslationError(err)
+	}
+
+	translated := newTranslatedMessage(post, source, target, translatedText)
+	if source == autoLanguage && translatedSource != autoLanguage {
+		translated.DetectedLanguage = translatedSource
+	}
+	if provider != "" {
+		translated.Provider = provider
+		return translated, nil
+	}
+
+	if err := p.cacheTranslation(post, translated); err != nil {
+		p.API.LogWarn("Failed to cache translation", "post_id", post.Id, "err", err.Error())
+	}
+
+	return translated, nil
+}
 
-	translated := TranslatedMessage{
-		ID:             postID + source + target + strconv.FormatInt(post.UpdateAt, 10),
-		PostID:         postID,
+func newTranslatedMessage(post *model.Post, source, target, translatedText string) *TranslatedMessage {
+	return &TranslatedMessage{
+		ID:             post.Id + source + target + strconv.FormatInt(post.UpdateAt, 10),
+		PostID:         post.Id,
 		SourceLanguage: source,
 		SourceText:     post.Message,
 		TargetLanguage: target,
-		TranslatedText: *output.TranslatedText,
+		TranslatedText: translatedText,
 		UpdateAt:       post.UpdateAt,
 	}
+}
 
-	resp, _ := json.Marshal(translated)
+func (p *Plugin) getInfo(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	info, apiErr := p.getUserInfo(userID)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+
+	resp, _ := json.Marshal(info)
 	w.Write(resp)
 }
 
-func (p *Plugin) getInfo(w http.ResponseWriter, r *http.Request) {
+func (p *Plugin) setInfo(w http.ResponseWriter, r *http.Request) {
 	userID := r.Header.Get("Mattermost-User-ID")
-	if userID == "" {
-		// silently return as user is probably not logged in
+
+	var info *UserInfo
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&info); err != nil || info == nil {
+		writeAPIError(w, newInvalidParameterError("info"))
+		return
+	}
+
+	if err := info.IsValid(); err != nil {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInvalidUserInfo, Message: fmt.Sprintf("Invalid info: %s", err.Error()), StatusCode: http.StatusBadRequest})
 		return
 	}
 
-	info, err := p.getUserInfo(userID)
+	if info.UserID != userID {
+		writeAPIError(w, newInvalidParameterError("user mismatch"))
+		return
+	}
+
+	// Without a channel, only the system roles of the user are checked.
+	if info.Activated && !p.canTurnTranslationOn(userID, "") {
+		if current, _ := p.getUserInfo(userID); current == nil || !current.Activated {
+			writeAPIError(w, &APIErrorResponse{ID: apiErrorForbidden, Message: "Not allowed to turn autotranslation on", StatusCode: http.StatusForbidden})
+			return
+		}
+	}
+
+	if info.Activated && !p.hasConsent(userID) {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorConsentRequired, Message: "Consent to translation by the provider is required first", StatusCode: http.StatusForbidden})
+		return
+	}
+
+	err := p.setUserInfo(info)
 	if err != nil {
-		// silently return as user may not have activated the autotranslation
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorUnableToSave, Message: "Failed to set info", StatusCode: http.StatusBadRequest})
 		return
 	}
 
@@ -131,36 +388,174 @@ func (p *Plugin) getInfo(w http.ResponseWriter, r *http.Request) {
 	w.Write(resp)
 }
 
-func (p *Plugin) setInfo(w http.ResponseWriter, r *http.Request) {
+// Language is a language supported for translation
+type Language struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// LanguagesResponse lists the languages supported by a translation provider
+type LanguagesResponse struct {
+	Provider   string      `json:"provider"`
+	AutoDetect bool        `json:"auto_detect"`
+	Languages  []*Language `json:"languages"`
+}
+
+func (p *Plugin) getLanguages(w http.ResponseWriter, r *http.Request) {
+	provider := r.URL.Query().Get("provider")
+	if provider == "" {
+		provider = providerAWS
+	}
+
+	capabilities, ok := translationProviderCapabilities[provider]
+	if !ok {
+		writeAPIError(w, newInvalidParameterError("provider"))
+		return
+	}
+
+	resp, _ := json.Marshal(&LanguagesResponse{
+		Provider:   provider,
+		AutoDetect: capabilities.autoDetect,
+		Languages:  getSupportedLanguages(provider),
+	})
+	w.Write(resp)
+}
+
+// maxDetectRequestBytes bounds the size of language detection requests.
+const maxDetectRequestBytes = 64 * 1024
+
+// maxRequestBytes bounds the size of the bodies of other API requests, such as glossaries of up
+// to maxGlossaryTerms terms.
+const maxRequestBytes = 256 * 1024
+
+// DetectRequest is the text or post whose language to detect
+type DetectRequest struct {
+	Text   string `json:"text"`
+	PostID string `json:"post_id"`
+}
+
+// DetectResponse is the detected language, empty when it can't be told with confidence, along
+// with the detector which told it
+type DetectResponse struct {
+	Language   string  `json:"language"`
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+	Detector   string  `json:"detector"`
+}
+
+func (p *Plugin) detect(w http.ResponseWriter, r *http.Request) {
 	userID := r.Header.Get("Mattermost-User-ID")
-	if userID == "" {
-		http.Error(w, "Not authorized to set info", http.StatusUnauthorized)
+
+	var request *DetectRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxDetectRequestBytes)).Decode(&request); err != nil || request == nil || (request.Text == "" && request.PostID == "") {
+		writeAPIError(w, newInvalidParameterError("text or post_id"))
 		return
 	}
 
-	var info *UserInfo
-	json.NewDecoder(r.Body).Decode(&info)
-	if info == nil {
-		http.Error(w, "Invalid parameter: info", http.StatusBadRequest)
+	ctx := r.Context()
+	text := request.Text
+	if request.PostID != "" {
+		if len(request.PostID) != 26 {
+			writeAPIError(w, newInvalidParameterError("post_id"))
+			return
+		}
+
+		post, appErr := p.API.GetPost(request.PostID)
+		if appErr != nil {
+			writeAPIError(w, &APIErrorResponse{ID: apiErrorPostNotFound, Message: "No post to detect", StatusCode: http.StatusBadRequest})
+			return
+		}
+
+		if !p.API.HasPermissionToChannel(userID, post.ChannelId, model.PERMISSION_READ_CHANNEL) {
+			writeAPIError(w, &APIErrorResponse{ID: apiErrorForbidden, Message: "Not authorized to read post", StatusCode: http.StatusForbidden})
+			return
+		}
+
+		text = post.Message + "\n" + getAttachmentsText(post.Attachments())
+		ctx = newAuditContext(ctx, "", post.Id, post.ChannelId)
+	}
+
+	detected := p.detectTextLanguage(ctx, text)
+	resp, _ := json.Marshal(&DetectResponse{
+		Language:   detected.Language,
+		Name:       languageCodes[detected.Language],
+		Confidence: detected.Confidence,
+		Detector:   detected.Detector,
+	})
+	w.Write(resp)
+}
+
+func (p *Plugin) getStats(w http.ResponseWriter, r *http.Request) {
+	days := defaultStatsDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil || days <= 0 || days > maxStatsDays {
+			writeAPIError(w, newInvalidParameterError("days"))
+			return
+		}
+	}
+
+	// Usage not saved yet is included so that the report is up to date.
+	p.flushUsageStats()
+
+	reports, err := p.getUsageStatsReports(days)
+	if err != nil {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to get stats", StatusCode: http.StatusInternalServerError})
 		return
 	}
 
-	if err := info.IsValid(); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid info: %s", err.Error()), http.StatusBadRequest)
+	resp, _ := json.Marshal(reports)
+	w.Write(resp)
+}
+
+func (p *Plugin) getLanguagePairStatsHandler(w http.ResponseWriter, r *http.Request) {
+	days := defaultStatsDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil || days <= 0 || days > maxStatsDays {
+			writeAPIError(w, newInvalidParameterError("days"))
+			return
+		}
+	}
+
+	// Requests not saved yet are included so that the report is up to date.
+	p.flushPairStats()
+
+	reports, err := p.getLanguagePairStats(days)
+	if err != nil {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to get language pair stats", StatusCode: http.StatusInternalServerError})
 		return
 	}
 
-	if info.UserID != userID {
-		http.Error(w, "Invalid parameter: user mismatch", http.StatusBadRequest)
+	resp, _ := json.Marshal(reports)
+	w.Write(resp)
+}
+
+func (p *Plugin) getLanguageStats(w http.ResponseWriter, r *http.Request) {
+	days := defaultStatsDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil || days <= 0 || days > maxStatsDays {
+			writeAPIError(w, newInvalidParameterError("days"))
+			return
+		}
+	}
+
+	channelID := r.URL.Query().Get("channel_id")
+	if channelID != "" && !model.IsValidId(channelID) {
+		writeAPIError(w, newInvalidParameterError("channel_id"))
 		return
 	}
 
-	err := p.setUserInfo(info)
+	// Languages not saved yet are included so that the report is up to date.
+	p.flushLanguageStats()
+
+	reports, err := p.getChannelLanguageStats(days, channelID)
 	if err != nil {
-		http.Error(w, "Failed to set info", http.StatusBadRequest)
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to get language stats", StatusCode: http.StatusInternalServerError})
 		return
 	}
 
-	resp, _ := json.Marshal(info)
+	resp, _ := json.Marshal(reports)
 	w.Write(resp)
 }
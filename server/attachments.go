@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/translate"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// translateAttachments returns translated copies of the message attachments of a post, as used by
+// webhooks and other integrations which usually carry their content there with an empty message.
+// Interactive actions are not copied over. It returns nil when none of the attachments changed.
+func (p *Plugin) translateAttachments(ctx context.Context, svc *translate.Translate, source, target string, attachments []*model.SlackAttachment) ([]*model.SlackAttachment, error) {
+	changed := false
+	translateField := func(text string) (string, error) {
+		if strings.TrimSpace(text) == "" {
+			return text, nil
+		}
+
+		translated, err := p.translateTextWithContext(ctx, svc, source, target, text)
+		if err != nil {
+			return "", err
+		}
+
+		if translated != text {
+			changed = true
+		}
+
+		return translated, nil
+	}
+
+	var err error
+	translatedAttachments := make([]*model.SlackAttachment, 0, len(attachments))
+	for _, attachment := range attachments {
+		translated := *attachment
+		translated.Actions = nil
+
+		if translated.Fallback, err = translateField(attachment.Fallback); err != nil {
+			return nil, err
+		}
+		if translated.Pretext, err = translateField(attachment.Pretext); err != nil {
+			return nil, err
+		}
+		if translated.Title, err = translateField(attachment.Title); err != nil {
+			return nil, err
+		}
+		if translated.Text, err = translateField(attachment.Text); err != nil {
+			return nil, err
+		}
+
+		translated.Fields = make([]*model.SlackAttachmentField, 0, len(attachment.Fields))
+		for _, field := range attachment.Fields {
+			translatedField := *field
+			if translatedField.Title, err = translateField(field.Title); err != nil {
+				return nil, err
+			}
+			if value, ok := field.Value.(string); ok {
+				if translatedField.Value, err = translateField(value); err != nil {
+					return nil, err
+				}
+			}
+			translated.Fields = append(translated.Fields, &translatedField)
+		}
+
+		translatedAttachments = append(translatedAttachments, &translated)
+	}
+
+	if !changed {
+		return nil, nil
+	}
+
+	return translatedAttachments, nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	auditKeyPrefix = "audit_"
+
+	// auditHourFormat names the hour the entries of a KV key were recorded in, keeping each key to
+	// the translations of an hour.
+	auditHourFormat = "2006-01-02T15"
+
+	auditLogOff    = "off"
+	auditLogStore  = "store"
+	auditLogServer = "server"
+
+	auditOutcomeSuccess = "success"
+	auditOutcomeFailure = "failure"
+
+	// maxAuditEntriesPerHour bounds the entries saved for an hour, as a KV value can't grow
+	// forever. Entries above it are dropped with a warning, still being written to the server log
+	// when the audit log is forwarded there.
+	maxAuditEntriesPerHour = 5000
+
+	defaultAuditLogRetention = 90
+
+	defaultAuditDays = 1
+	maxAuditDays     = 31
+
+	defaultAuditPerPage = 50
+	maxAuditPerPage     = 500
+
+	// maxAuditSaveAttempts bounds the retries of saving entries recorded concurrently.
+	maxAuditSaveAttempts = 5
+)
+
+// auditSubjectContextKey holds the user and post a translation is made for.
+const auditSubjectContextKey contextKey = "audit_subject"
+
+// AuditEntry records a request to translate a text, without the text itself
+type AuditEntry struct {
+	CreateAt       int64  `json:"create_at"`
+	RequestID      string `json:"request_id,omitempty"`
+	UserID         string `json:"user_id,omitempty"`
+	PostID         string `json:"post_id,omitempty"`
+	ChannelID      string `json:"channel_id,omitempty"`
+	SourceLanguage string `json:"source_lang"`
+	TargetLanguage string `json:"target_lang"`
+	Provider       string `json:"provider"`
+	Characters     int    `json:"characters"`
+	Outcome        string `json:"outcome"`
+	Error          string `json:"error,omitempty"`
+}
+
+// AuditLogResponse is a page of the audit entries of the requested days, newest first
+type AuditLogResponse struct {
+	Entries []*AuditEntry `json:"entries"`
+	Page    int           `json:"page"`
+	PerPage int           `json:"per_page"`
+	Total   int           `json:"total"`
+}
+
+type auditSubject struct {
+	userID    string
+	postID    string
+	channelID string
+}
+
+func getAuditKey(hour string) string {
+	return auditKeyPrefix + hour
+}
+
+// newAuditContext returns a context carrying the user and the post translations are made for,
+// empty values keeping the ones carried by ctx already.
+func newAuditContext(ctx context.Context, userID, postID, channelID string) context.Context {
+	subject := auditSubject{}
+	if current := getAuditSubject(ctx); current != nil {
+		subject = *current
+	}
+
+	if userID != "" {
+		subject.userID = userID
+	}
+	if postID != "" {
+		subject.postID = postID
+	}
+	if channelID != "" {
+		subject.channelID = channelID
+	}
+
+	return context.WithValue(ctx, auditSubjectContextKey, &subject)
+}
+
+func getAuditSubject(ctx context.Context) *auditSubject {
+	subject, _ := ctx.Value(auditSubjectContextKey).(*auditSubject)
+	return subject
+}
+
+// recordAudit records a request to the provider in memory, to be saved along with the usage
+// statistics, and writes it to the server log when admins forward the audit log there.
+func (p *Plugin) recordAudit(ctx context.Context, provider, source, target string, characters int, err error) {
+	mode := p.getConfiguration().getAuditLog()
+	if mode == auditLogOff {
+		return
+	}
+
+	entry := &AuditEntry{
+		CreateAt:       model.GetMillis(),
+		RequestID:      getRequestID(ctx),
+		SourceLanguage: source,
+		TargetLanguage: target,
+		Provider:       provider,
+		Characters:     characters,
+		Outcome:        auditOutcomeSuccess,
+	}
+	if subject := getAuditSubject(ctx); subject != nil {
+		entry.UserID = subject.userID
+		entry.PostID = subject.postID
+		entry.ChannelID = subject.channelID
+	}
+	if err != nil {
+		entry.Outcome = auditOutcomeFailure
+		entry.Error = err.Error()
+	}
+
+	if mode == auditLogServer {
+		p.API.LogInfo("Translation audit", "request_id", entry.RequestID, "user_id", entry.UserID, "post_id", entry.PostID, "channel_id", entry.ChannelID,
+			"source_lang", entry.SourceLanguage, "target_lang", entry.TargetLanguage, "provider", entry.Provider, "characters", entry.Characters, "outcome", entry.Outcome)
+	}
+
+	p.auditLock.Lock()
+	p.auditEntries = append(p.auditEntries, entry)
+	p.auditLock.Unlock()
+}
+
+// flushAuditEntries adds the entries recorded in memory to the saved ones of their hour.
+func (p *Plugin) flushAuditEntries() {
+	p.auditLock.Lock()
+	pending := p.auditEntries
+	p.auditEntries = nil
+	p.auditLock.Unlock()
+
+	hours := map[string][]*AuditEntry{}
+	for _, entry := range pending {
+		hour := time.Unix(0, entry.CreateAt*int64(time.Millisecond)).UTC().Format(auditHourFormat)
+		hours[hour] = append(hours[hour], entry)
+	}
+
+	for hour, entries := range hours {
+		if err := p.saveAuditEntries(hour, entries); err != nil {
+			p.API.LogError("Failed to save audit entries", "hour", hour, "entries", len(entries), "err", err.Error())
+		}
+	}
+}
+
+// saveAuditEntries adds entries to the saved ones of an hour with a compare and set, as other
+// cluster nodes may be saving theirs at the same time. Saved entries expire after the retention
+// of the audit log or the data retention, whichever is shorter.
+func (p *Plugin) saveAuditEntries(hour string, entries []*AuditEntry) error {
+	key := getAuditKey(hour)
+	config := p.getConfiguration()
+	expiry := config.getRetentionExpiry(int64(config.getAuditLogRetention() * 24 * 60 * 60))
+	for attempt := 0; attempt < maxAuditSaveAttempts; attempt++ {
+		oldBytes, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return appErr
+		}
+
+		var saved []*AuditEntry
+		if oldBytes != nil {
+			if err := json.Unmarshal(oldBytes, &saved); err != nil {
+				return errors.Wrap(err, "unable to unmarshal audit entries")
+			}
+		}
+
+		if len(saved)+len(entries) > maxAuditEntriesPerHour {
+			if len(saved) >= maxAuditEntriesPerHour {
+				return errors.Errorf("more than %d entries recorded within the hour", maxAuditEntriesPerHour)
+			}
+			p.API.LogWarn("Dropping audit entries above the limit of the hour", "hour", hour, "dropped", len(saved)+len(entries)-maxAuditEntriesPerHour)
+			entries = entries[:maxAuditEntriesPerHour-len(saved)]
+		}
+		saved = append(saved, entries...)
+
+		newBytes, err := json.Marshal(saved)
+		if err != nil {
+			return errors.Wrap(err, "unable to marshal audit entries")
+		}
+
+		updated, appErr := p.API.KVSetWithOptions(key, newBytes, model.PluginKVSetOptions{
+			Atomic:          true,
+			OldValue:        oldBytes,
+			ExpireInSeconds: expiry,
+		})
+		if appErr != nil {
+			return appErr
+		}
+		if updated {
+			return nil
+		}
+	}
+
+	return errors.New("audit entries kept changing concurrently")
+}
+
+// getAuditEntries returns the entries saved for the given number of days up to now, newest first,
+// only keeping the ones of a user or a post if given.
+func (p *Plugin) getAuditEntries(days int, userID, postID string) ([]*AuditEntry, error) {
+	var entries []*AuditEntry
+	now := time.Now().UTC()
+	for i := 0; i < days*24; i++ {
+		saved, err := p.getSavedAuditEntries(now.Add(-time.Duration(i) * time.Hour).Format(auditHourFormat))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range saved {
+			if (userID == "" || entry.UserID == userID) && (postID == "" || entry.PostID == postID) {
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreateAt > entries[j].CreateAt
+	})
+
+	return entries, nil
+}
+
+// getSavedAuditEntries returns the entries saved for an hour, in the order they were recorded.
+func (p *Plugin) getSavedAuditEntries(hour string) ([]*AuditEntry, error) {
+	entriesBytes, appErr := p.API.KVGet(getAuditKey(hour))
+	if appErr != nil {
+		return nil, appErr
+	}
+	if entriesBytes == nil {
+		return nil, nil
+	}
+
+	var saved []*AuditEntry
+	if err := json.Unmarshal(entriesBytes, &saved); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal audit entries")
+	}
+
+	return saved, nil
+}
+
+func (p *Plugin) getAuditLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	days := defaultAuditDays
+	if value := query.Get("days"); value != "" {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil || days <= 0 || days > maxAuditDays {
+			writeAPIError(w, newInvalidParameterError("days"))
+			return
+		}
+	}
+
+	page := 0
+	if value := query.Get("page"); value != "" {
+		var err error
+		if page, err = strconv.Atoi(value); err != nil || page < 0 {
+			writeAPIError(w, newInvalidParameterError("page"))
+			return
+		}
+	}
+
+	perPage := defaultAuditPerPage
+	if value := query.Get("per_page"); value != "" {
+		var err error
+		if perPage, err = strconv.Atoi(value); err != nil || perPage <= 0 || perPage > maxAuditPerPage {
+			writeAPIError(w, newInvalidParameterError("per_page"))
+			return
+		}
+	}
+
+	userID := query.Get("user_id")
+	if userID != "" && !model.IsValidId(userID) {
+		writeAPIError(w, newInvalidParameterError("user_id"))
+		return
+	}
+
+	postID := query.Get("post_id")
+	if postID != "" && !model.IsValidId(postID) {
+		writeAPIError(w, newInvalidParameterError("post_id"))
+		return
+	}
+
+	// Entries not saved yet are included so that the audit log is up to date.
+	p.flushAuditEntries()
+
+	entries, err := p.getAuditEntries(days, userID, postID)
+	if err != nil {
+		p.API.LogError("Failed to get audit entries", "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to get audit log", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	response := &AuditLogResponse{
+		Entries: []*AuditEntry{},
+		Page:    page,
+		PerPage: perPage,
+		Total:   len(entries),
+	}
+	if start := page * perPage; start < len(entries) {
+		end := start + perPage
+		if end > len(entries) {
+			end = len(entries)
+		}
+		response.Entries = entries[start:end]
+	}
+
+	resp, _ := json.Marshal(response)
+	w.Write(resp)
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAuditContext(t *testing.T) {
+	assert.Nil(t, getAuditSubject(context.Background()))
+
+	ctx := newAuditContext(context.Background(), "user1", "", "")
+	assert.Equal(t, &auditSubject{userID: "user1"}, getAuditSubject(ctx))
+
+	postCtx := newAuditContext(ctx, "", "post1", "channel1")
+	assert.Equal(t, &auditSubject{userID: "user1", postID: "post1", channelID: "channel1"}, getAuditSubject(postCtx))
+	assert.Equal(t, &auditSubject{userID: "user1"}, getAuditSubject(ctx))
+}
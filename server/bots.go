@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+func isBotPost(post *model.Post) bool {
+	return post.GetProp("from_bot") == "true" || post.GetProp("from_webhook") == "true"
+}
+
+func normalizeBotName(name string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "@"))
+}
+
+// isTranslatedBot reports whether the user included posts of the bot or webhook with the given
+// username in their autotranslation.
+func (u *UserInfo) isTranslatedBot(username string) bool {
+	for _, name := range u.TranslatedBots {
+		if name == username {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getBotPostUsername returns the username a bot or webhook post is shown with, which for
+// webhooks may be overridden per post.
+func (p *Plugin) getBotPostUsername(post *model.Post) string {
+	if username, ok := post.GetProp("override_username").(string); ok && username != "" {
+		return normalizeBotName(username)
+	}
+
+	user, appErr := p.API.GetUser(post.UserId)
+	if appErr != nil {
+		p.API.LogError("Failed to get post author", "user_id", post.UserId, "err", appErr.Error())
+		return ""
+	}
+
+	return normalizeBotName(user.Username)
+}
+
+// translateBotPost translates a post of a bot or webhook for the channel members who want it, as
+// bots and webhooks have no target language to translate their posts into unlike users. Bots and
+// webhooks included in the configuration get a translation post once per target language of the
+// channel members with autotranslation turned on, while those only included by some users are
+// translated ephemerally for them.
+func (p *Plugin) translateBotPost(post *model.Post) {
+	if post.Type != model.POST_DEFAULT {
+		return
+	}
+
+	username := p.getBotPostUsername(post)
+	if username == "" {
+		return
+	}
+
+	translatedForAll := p.getConfiguration().isTranslatedBot(username)
+
+	userInfosByTarget := map[string][]*UserInfo{}
+	for _, userInfo := range p.getActivatedChannelMembers(post.ChannelId) {
+		if translatedForAll || userInfo.isTranslatedBot(username) {
+			userInfosByTarget[userInfo.TargetLanguage] = append(userInfosByTarget[userInfo.TargetLanguage], userInfo)
+		}
+	}
+
+	if len(userInfosByTarget) == 0 {
+		return
+	}
+
+	if translatedForAll {
+		for target := range userInfosByTarget {
+			p.translatePosts([]*model.Post{post}, &UserInfo{SourceLanguage: autoLanguage, TargetLanguage: target})
+		}
+		return
+	}
+
+	svc, err := p.getTranslateService()
+	if err != nil {
+		p.API.LogError("Failed to get translate service", "err", err.Error())
+		return
+	}
+
+	ctx := newRequestContext(context.Background(), model.NewId())
+	for target, userInfos := range userInfosByTarget {
+		botUserInfo := &UserInfo{SourceLanguage: autoLanguage, TargetLanguage: target}
+		content, err := p.translatePostContent(ctx, svc, post, botUserInfo, true)
+		if err != nil {
+			p.API.LogError("Failed to translate bot post", "request_id", getRequestID(ctx), "post_id", post.Id, "err", err.Error())
+			continue
+		}
+
+		if content.isEmpty() {
+			continue
+		}
+
+		attachments := addTranslationHeader(botUserInfo.withSourceLanguage(content.sourceLanguage), content.message, content.attachments)
+		addShowOriginalAction(post.Id, attachments)
+		for _, userInfo := range userInfos {
+			p.sendEphemeralTranslation(post, userInfo.UserID, attachments)
+		}
+	}
+}
+
+func getTranslatedBotsText(userInfo *UserInfo, configuration *configuration) string {
+	var globalBots []string
+	for _, name := range strings.Split(configuration.TranslatedBots, ",") {
+		if name = normalizeBotName(name); name != "" {
+			globalBots = append(globalBots, "`"+name+"`")
+		}
+	}
+
+	var userBots []string
+	for _, name := range userInfo.TranslatedBots {
+		userBots = append(userBots, "`"+name+"`")
+	}
+
+	if len(globalBots) == 0 {
+		globalBots = []string{"none"}
+	}
+	if len(userBots) == 0 {
+		userBots = []string{"none"}
+	}
+
+	return fmt.Sprintf(
+		"Translated bots and webhooks:\n * Yours: %s\n * For everyone: %s\n",
+		strings.Join(userBots, ", "), strings.Join(globalBots, ", "),
+	)
+}
+
+func (p *Plugin) executeBotsCommand(userInfo *UserInfo, operation, username string) *model.CommandResponse {
+	username = normalizeBotName(username)
+
+	switch operation {
+	case "":
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, getTranslatedBotsText(userInfo, p.getConfiguration()))
+	case "add", "remove":
+		if username == "" {
+			return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "Invalid empty username. Should pass the username of a bot or webhook.")
+		}
+	default:
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("Invalid \"%s\" operation. Should be one of \"add\" or \"remove\".", operation))
+	}
+
+	var translatedBots []string
+	for _, name := range userInfo.TranslatedBots {
+		if name != username {
+			translatedBots = append(translatedBots, name)
+		}
+	}
+	if operation == "add" {
+		translatedBots = append(translatedBots, username)
+	}
+	userInfo.TranslatedBots = translatedBots
+
+	if err := p.setUserInfo(userInfo); err != nil {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("An error occurred setting up translated bots. `%s`", err.Message))
+	}
+
+	return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "Successfully updated!\n"+getTranslatedBotsText(userInfo, p.getConfiguration()))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// maxBurstPosts caps the number of posts translated together, flushing the burst right away.
+const maxBurstPosts = 20
+
+// postBurst collects consecutive posts of an author in a channel to be translated together.
+type postBurst struct {
+	userInfo *UserInfo
+	posts    []*model.Post
+	timer    *time.Timer
+}
+
+func getBurstKey(post *model.Post) string {
+	return post.UserId + post.ChannelId + post.RootId
+}
+
+// canCoalescePost reports whether the translation of a post may be combined with others, which
+// is only the case when translations are delivered as separate posts.
+func (p *Plugin) canCoalescePost(post *model.Post) bool {
+	return p.deliversTranslationPosts(post.ChannelId)
+}
+
+// queuePostBurst adds a post to the burst of its author, postponing the translation until the
+// author hasn't posted in the same channel and thread for the given window.
+func (p *Plugin) queuePostBurst(post *model.Post, userInfo *UserInfo, window time.Duration) {
+	key := getBurstKey(post)
+
+	p.burstsLock.Lock()
+	defer p.burstsLock.Unlock()
+
+	if p.bursts == nil {
+		p.bursts = map[string]*postBurst{}
+	}
+
+	burst, ok := p.bursts[key]
+	if !ok {
+		burst = &postBurst{}
+		burst.timer = time.AfterFunc(window, func() {
+			p.flushPostBurst(key, burst)
+		})
+		p.bursts[key] = burst
+	} else {
+		burst.timer.Reset(window)
+	}
+
+	burst.userInfo = userInfo
+	burst.posts = append(burst.posts, post)
+
+	if len(burst.posts) >= maxBurstPosts {
+		burst.timer.Stop()
+		go p.flushPostBurst(key, burst)
+	}
+}
+
+// flushPostBurst translates the posts of a burst, unless it has already been flushed.
+func (p *Plugin) flushPostBurst(key string, burst *postBurst) {
+	p.burstsLock.Lock()
+	if p.bursts[key] != burst {
+		p.burstsLock.Unlock()
+		return
+	}
+	delete(p.bursts, key)
+	p.burstsLock.Unlock()
+
+	p.translatePosts(burst.posts, burst.userInfo)
+}
+
+// flushAllPostBursts translates all pending bursts without waiting for their window to end.
+func (p *Plugin) flushAllPostBursts() {
+	p.burstsLock.Lock()
+	bursts := p.bursts
+	p.bursts = nil
+	p.burstsLock.Unlock()
+
+	for _, burst := range bursts {
+		burst.timer.Stop()
+		p.translatePosts(burst.posts, burst.userInfo)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+)
+
+func TestQueuePostBurstFlushesAtMaxBurstPosts(t *testing.T) {
+	// The posts are claimed already, so the flushed burst stops short of the provider.
+	claims := make(chan string, maxBurstPosts)
+	api := &plugintest.API{}
+	api.On("KVSetWithOptions", mock.AnythingOfType("string"), mock.Anything, mock.Anything).Return(false, nil).Run(func(args mock.Arguments) {
+		claims <- args.String(0)
+	})
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	p.setConfiguration(&configuration{AWSAccessKeyID: "id", AWSSecretAccessKey: "secret"})
+	require.NoError(t, p.reloadTranslateService())
+
+	userInfo := &UserInfo{UserID: model.NewId(), Activated: true, SourceLanguage: "ko", TargetLanguage: "en"}
+	channelID := model.NewId()
+	queue := func() {
+		p.queuePostBurst(&model.Post{Id: model.NewId(), UserId: userInfo.UserID, ChannelId: channelID, Message: "안녕하세요"}, userInfo, time.Hour)
+	}
+
+	for i := 0; i < maxBurstPosts-1; i++ {
+		queue()
+	}
+
+	p.burstsLock.Lock()
+	assert.Len(t, p.bursts, 1)
+	p.burstsLock.Unlock()
+	assert.Empty(t, claims, "the burst waits for the end of its window")
+
+	queue()
+	for i := 0; i < maxBurstPosts; i++ {
+		select {
+		case <-claims:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "the full burst wasn't flushed")
+		}
+	}
+
+	p.burstsLock.Lock()
+	assert.Empty(t, p.bursts)
+	p.burstsLock.Unlock()
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	translationCacheKeyPrefix = "cache_"
+
+	// translationCacheExpiry is how long translations made through the API are kept, in seconds.
+	translationCacheExpiry = 7 * 24 * 60 * 60
+)
+
+// getTranslationCacheKey returns the key of the cached translation of a revision of a post into a
+// language, hashed to fit the length limit of KV keys.
+func getTranslationCacheKey(post *model.Post, target string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%s", post.Id, post.UpdateAt, target)))
+	return translationCacheKeyPrefix + hex.EncodeToString(sum[:16])
+}
+
+// cacheTranslation saves a translation made through the API, so that clients can fetch it again
+// without another request to the provider. It is encrypted when admins set an encryption key, as
+// it holds the text of the post.
+func (p *Plugin) cacheTranslation(post *model.Post, translated *TranslatedMessage) error {
+	translatedBytes, err := json.Marshal(translated)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal translation")
+	}
+
+	if translatedBytes, err = encryptValue(p.getConfiguration().EncryptionKey, translatedBytes); err != nil {
+		return errors.Wrap(err, "unable to encrypt translation")
+	}
+
+	if _, appErr := p.API.KVSetWithOptions(getTranslationCacheKey(post, translated.TargetLanguage), translatedBytes, model.PluginKVSetOptions{
+		ExpireInSeconds: p.getConfiguration().getRetentionExpiry(translationCacheExpiry),
+	}); appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
+
+// getCachedTranslation returns the translation of the current revision of a post into a language,
+// either cached by the API or delivered in place, or nil when there is none.
+func (p *Plugin) getCachedTranslation(ctx context.Context, post *model.Post, target string) (*TranslatedMessage, error) {
+	_, span := p.startSpan(ctx, "cache_lookup", spanKindInternal, "post_id", post.Id, "target", target)
+	translated, err := p.lookupCachedTranslation(post, target)
+	span.setAttributes("hit", translated != nil)
+	span.finish(err)
+
+	return translated, err
+}
+
+func (p *Plugin) lookupCachedTranslation(post *model.Post, target string) (*TranslatedMessage, error) {
+	translations, _ := post.GetProp(translationsProp).(map[string]interface{})
+	if translation, ok := translations[target].(map[string]interface{}); ok {
+		source, _ := translation["source_language"].(string)
+		text, _ := translation["translated_text"].(string)
+
+		p.metrics.observeCacheLookup(true)
+		return newTranslatedMessage(post, source, target, text), nil
+	}
+
+	if post.GetProp(inPlaceTranslationsProp) != nil {
+		translations, err := p.getPostStoredTranslations(post.Id)
+		if err != nil {
+			return nil, err
+		}
+		if translation := translations[target]; translation != nil {
+			p.metrics.observeCacheLookup(true)
+			return newTranslatedMessage(post, translation.SourceLanguage, target, translation.TranslatedText), nil
+		}
+	}
+
+	translatedBytes, appErr := p.API.KVGet(getTranslationCacheKey(post, target))
+	if appErr != nil {
+		return nil, appErr
+	}
+	if translatedBytes == nil {
+		p.metrics.observeCacheLookup(false)
+		return nil, nil
+	}
+
+	translatedBytes, err := decryptValue(p.getConfiguration().EncryptionKey, translatedBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decrypt translation")
+	}
+
+	var translated *TranslatedMessage
+	if err := json.Unmarshal(translatedBytes, &translated); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal translation")
+	}
+
+	p.metrics.observeCacheLookup(translated != nil)
+	return translated, nil
+}
+
+// flushTranslationCache deletes every cached translation and forgets the state kept in memory
+// about the provider, so that changes made to the provider take effect right away. It returns the
+// number of cached translations deleted.
+func (p *Plugin) flushTranslationCache() (int, error) {
+	// Keys are collected before being deleted, as deleting them would shift the pages.
+	var keys []string
+	for page := 0; ; page++ {
+		pageKeys, appErr := p.API.KVList(page, keysPerPage)
+		if appErr != nil {
+			return 0, appErr
+		}
+
+		for _, key := range pageKeys {
+			if strings.HasPrefix(key, translationCacheKeyPrefix) {
+				keys = append(keys, key)
+			}
+		}
+
+		if len(pageKeys) < keysPerPage {
+			break
+		}
+	}
+
+	p.providerProbeLock.Lock()
+	p.providerProbe = nil
+	p.providerProbeLock.Unlock()
+
+	for i, key := range keys {
+		if appErr := p.API.KVDelete(key); appErr != nil {
+			return i, appErr
+		}
+	}
+
+	return len(keys), nil
+}
+
+// CacheFlushResponse is the number of cached translations deleted by a flush
+type CacheFlushResponse struct {
+	Flushed int `json:"flushed"`
+}
+
+func (p *Plugin) flushCache(w http.ResponseWriter, r *http.Request) {
+	flushed, err := p.flushTranslationCache()
+	if err != nil {
+		p.API.LogError("Failed to flush translation cache", "flushed", flushed, "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to flush translation cache", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	p.API.LogInfo("Flushed translation cache", "user_id", r.Header.Get("Mattermost-User-ID"), "flushed", flushed)
+
+	resp, _ := json.Marshal(&CacheFlushResponse{Flushed: flushed})
+	w.Write(resp)
+}
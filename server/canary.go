@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const defaultCanaryPercent = 10
+
+// ProviderStats is the latency and the ratings of the translations of a provider as reported by
+// the API, to compare providers such as during a canary rollout
+type ProviderStats struct {
+	Provider     string  `json:"provider"`
+	Requests     int64   `json:"requests"`
+	LatencyP50Ms int64   `json:"latency_p50_ms"`
+	LatencyP90Ms int64   `json:"latency_p90_ms"`
+	LatencyP99Ms int64   `json:"latency_p99_ms"`
+	GoodRatings  int64   `json:"good_ratings"`
+	BadRatings   int64   `json:"bad_ratings"`
+	Score        float64 `json:"score"`
+	Canary       bool    `json:"canary"`
+}
+
+// isCanaryTranslation reports whether the translation of a post goes to the canary provider, the
+// same posts always doing so for a percentage, so that the translations of a post and their
+// ratings come from the same provider.
+func isCanaryTranslation(postID string, percent int) bool {
+	if percent <= 0 {
+		return false
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(postID))
+
+	return int(h.Sum32()%100) < percent
+}
+
+// newProviderStats reports the latency of the requests of providers across language pairs along
+// with the ratings of their translations, the providers with the most requests first.
+func newProviderStats(stats *PairStats, feedbacks []*TranslationFeedback, canaryProvider string) []*ProviderStats {
+	reports := map[string]*ProviderStats{}
+	buckets := map[string][]int64{}
+	getReport := func(provider string) *ProviderStats {
+		report, ok := reports[provider]
+		if !ok {
+			report = &ProviderStats{Provider: provider, Canary: provider == canaryProvider}
+			reports[provider] = report
+			buckets[provider] = make([]int64, len(latencyBucketBounds)+1)
+		}
+		return report
+	}
+
+	for pair, usage := range stats.Pairs {
+		provider := strings.SplitN(pair, ":", 2)[0]
+		report := getReport(provider)
+		report.Requests += usage.Requests
+		for i := range buckets[provider] {
+			if i < len(usage.LatencyBuckets) {
+				buckets[provider][i] += usage.LatencyBuckets[i]
+			}
+		}
+	}
+
+	for _, feedback := range feedbacks {
+		report := getReport(feedback.Provider)
+		if feedback.Rating == feedbackRatingGood {
+			report.GoodRatings++
+		} else {
+			report.BadRatings++
+		}
+	}
+
+	list := make([]*ProviderStats, 0, len(reports))
+	for provider, report := range reports {
+		report.LatencyP50Ms = getLatencyPercentile(buckets[provider], report.Requests, 50)
+		report.LatencyP90Ms = getLatencyPercentile(buckets[provider], report.Requests, 90)
+		report.LatencyP99Ms = getLatencyPercentile(buckets[provider], report.Requests, 99)
+		if ratings := report.GoodRatings + report.BadRatings; ratings > 0 {
+			report.Score = float64(report.GoodRatings) / float64(ratings)
+		}
+		list = append(list, report)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Requests != list[j].Requests {
+			return list[i].Requests > list[j].Requests
+		}
+		return list[i].Provider < list[j].Provider
+	})
+
+	return list
+}
+
+// getCanaryProvider returns the canary provider the translation of a post goes to, or an empty
+// provider when it goes to the current one. Translations of text without a post, such as the ones
+// of webhook requests, go to the canary provider at random.
+func (p *Plugin) getCanaryProvider(postID string) string {
+	config := p.getConfiguration()
+	if config.CanaryProvider == "" {
+		return ""
+	}
+
+	if postID == "" {
+		postID = model.NewId()
+	}
+	if !isCanaryTranslation(postID, config.getCanaryPercent()) {
+		return ""
+	}
+
+	return config.CanaryProvider
+}
+
+func (p *Plugin) getProviderStatsHandler(w http.ResponseWriter, r *http.Request) {
+	days := defaultStatsDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil || days <= 0 || days > maxStatsDays {
+			writeAPIError(w, newInvalidParameterError("days"))
+			return
+		}
+	}
+
+	// Requests not saved yet are included so that the report is up to date.
+	p.flushPairStats()
+
+	stats, feedbacks, err := p.getPairStatsAndFeedbacks(days)
+	if err != nil {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to get provider stats", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	resp, _ := json.Marshal(newProviderStats(stats, feedbacks, p.getConfiguration().CanaryProvider))
+	w.Write(resp)
+}
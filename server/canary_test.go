@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsCanaryTranslation(t *testing.T) {
+	canaries := 0
+	for i := 0; i < 1000; i++ {
+		postID := "post" + strconv.Itoa(i)
+		assert.False(t, isCanaryTranslation(postID, 0))
+		assert.True(t, isCanaryTranslation(postID, 100))
+
+		canary := isCanaryTranslation(postID, 10)
+		assert.Equal(t, canary, isCanaryTranslation(postID, 10))
+		if canary {
+			canaries++
+		}
+	}
+
+	assert.InDelta(t, 100, canaries, 40)
+}
+
+func TestNewProviderStats(t *testing.T) {
+	stats := newPairStats("")
+	awsUsage := stats.getUsage(getPairKey(providerAWS, "en", "ja"))
+	awsUsage.Requests = 3
+	awsUsage.LatencyBuckets[1] = 3
+	awsUsage = stats.getUsage(getPairKey(providerAWS, "ja", "en"))
+	awsUsage.Requests = 1
+	awsUsage.LatencyBuckets[4] = 1
+	canaryUsage := stats.getUsage(getPairKey("canary", "en", "ja"))
+	canaryUsage.Requests = 2
+	canaryUsage.LatencyBuckets[0] = 2
+
+	feedbacks := []*TranslationFeedback{
+		{Provider: providerAWS, Rating: feedbackRatingGood},
+		{Provider: providerAWS, Rating: feedbackRatingBad},
+		{Provider: "canary", Rating: feedbackRatingGood},
+	}
+
+	reports := newProviderStats(stats, feedbacks, "canary")
+	require.Len(t, reports, 2)
+
+	assert.Equal(t, &ProviderStats{
+		Provider:     providerAWS,
+		Requests:     4,
+		LatencyP50Ms: 100,
+		LatencyP90Ms: 1000,
+		LatencyP99Ms: 1000,
+		GoodRatings:  1,
+		BadRatings:   1,
+		Score:        0.5,
+	}, reports[0])
+	assert.Equal(t, &ProviderStats{
+		Provider:     "canary",
+		Requests:     2,
+		LatencyP50Ms: 50,
+		LatencyP90Ms: 50,
+		LatencyP99Ms: 50,
+		GoodRatings:  1,
+		Score:        1,
+		Canary:       true,
+	}, reports[1])
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// providerCapabilities are the languages a translation provider translates between, and whether
+// it detects the source language itself.
+type providerCapabilities struct {
+	autoDetect bool
+	languages  map[string]bool
+}
+
+// offlineLanguages are the languages of the models LibreTranslate and Argos Translate come with,
+// which offline gateways are expected to support, out of the languages of Amazon Translate.
+var offlineLanguages = []string{
+	"ar", "az", "ca", "cs", "da", "de", "el", "en", "es", "fa", "fi", "fr", "ga", "he", "hi", "hu",
+	"id", "it", "ja", "ko", "nl", "pl", "pt", "ru", "sk", "sv", "tr", "uk", "zh",
+}
+
+// translationProviderCapabilities are the capabilities of the translation providers, keyed by
+// name, so that language pairs they don't support fail before any request is made. The SDK in use
+// can't list the languages of Amazon Translate, so they come from the static map of language
+// codes, which are the ones it translates between in any pair.
+var translationProviderCapabilities = map[string]*providerCapabilities{
+	providerAWS:     {autoDetect: true, languages: getLanguageSet(languageCodes)},
+	providerOffline: {autoDetect: true, languages: newLanguageSet(offlineLanguages...)},
+}
+
+// getLanguageSet returns the codes of languages keyed by code, without the auto language.
+func getLanguageSet(languages map[string]string) map[string]bool {
+	set := make(map[string]bool, len(languages))
+	for code := range languages {
+		if code != autoLanguage {
+			set[code] = true
+		}
+	}
+
+	return set
+}
+
+// newLanguageSet returns a set of language codes.
+func newLanguageSet(languages ...string) map[string]bool {
+	set := make(map[string]bool, len(languages))
+	for _, language := range languages {
+		set[language] = true
+	}
+
+	return set
+}
+
+// supportsLanguagePair reports whether a provider translates from source to target language,
+// an auto source language requiring the provider to detect it.
+func supportsLanguagePair(provider, source, target string) bool {
+	capabilities, ok := translationProviderCapabilities[provider]
+	if !ok || !capabilities.languages[target] {
+		return false
+	}
+
+	if source == autoLanguage {
+		return capabilities.autoDetect
+	}
+
+	return capabilities.languages[source]
+}
+
+// getLanguagePairProviders returns the providers supporting a language pair, sorted by name.
+func getLanguagePairProviders(source, target string) []string {
+	var providers []string
+	for provider := range translationProviderCapabilities {
+		if supportsLanguagePair(provider, source, target) {
+			providers = append(providers, provider)
+		}
+	}
+	sort.Strings(providers)
+
+	return providers
+}
+
+// unsupportedLanguagePairError is a language pair a provider doesn't support, along with the
+// providers supporting it instead.
+type unsupportedLanguagePairError struct {
+	provider     string
+	source       string
+	target       string
+	alternatives []string
+}
+
+func (e *unsupportedLanguagePairError) Error() string {
+	message := fmt.Sprintf("the %s to %s language pair is not supported by provider %s", e.source, e.target, e.provider)
+	if len(e.alternatives) == 0 {
+		return message + " nor by any other provider"
+	}
+
+	return message + ", try " + strings.Join(e.alternatives, " or ")
+}
+
+// checkLanguagePair returns an error naming the providers to try when a provider doesn't support
+// a language pair.
+func checkLanguagePair(provider, source, target string) error {
+	if supportsLanguagePair(provider, source, target) {
+		return nil
+	}
+
+	var alternatives []string
+	for _, alternative := range getLanguagePairProviders(source, target) {
+		if alternative != provider {
+			alternatives = append(alternatives, alternative)
+		}
+	}
+
+	return &unsupportedLanguagePairError{provider: provider, source: source, target: target, alternatives: alternatives}
+}
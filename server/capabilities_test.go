@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslationProviderCapabilities(t *testing.T) {
+	for provider := range translationProviders {
+		assert.NotNil(t, translationProviderCapabilities[provider], provider)
+	}
+}
+
+func TestOfflineCapabilities(t *testing.T) {
+	for _, language := range offlineLanguages {
+		assert.NotEmpty(t, languageCodes[language], language)
+	}
+
+	assert.NoError(t, checkLanguagePair(providerOffline, "ko", "en"))
+	assert.NoError(t, checkLanguagePair(providerOffline, autoLanguage, "ja"))
+
+	err := checkLanguagePair(providerOffline, "sw", "en")
+	require.Error(t, err)
+	assert.Equal(t, "the sw to en language pair is not supported by provider offline, try aws", err.Error())
+}
+
+func TestCheckLanguagePair(t *testing.T) {
+	defer func(capabilities map[string]*providerCapabilities) {
+		translationProviderCapabilities = capabilities
+	}(translationProviderCapabilities)
+	translationProviderCapabilities = map[string]*providerCapabilities{
+		providerAWS: {autoDetect: true, languages: map[string]bool{"en": true, "ja": true}},
+		"papago":    {languages: map[string]bool{"en": true, "ja": true, "ko": true}},
+	}
+
+	assert.NoError(t, checkLanguagePair(providerAWS, "en", "ja"))
+	assert.NoError(t, checkLanguagePair(providerAWS, autoLanguage, "ja"))
+	assert.NoError(t, checkLanguagePair("papago", "ko", "ja"))
+
+	err := checkLanguagePair(providerAWS, "ko", "ja")
+	require.Error(t, err)
+	assert.Equal(t, "the ko to ja language pair is not supported by provider aws, try papago", err.Error())
+	assert.Equal(t, apiErrorUnsupportedLanguagePair, newTranslationError(err).ID)
+
+	err = checkLanguagePair("papago", autoLanguage, "en")
+	require.Error(t, err)
+	assert.Equal(t, "the auto to en language pair is not supported by provider papago, try aws", err.Error())
+
+	err = checkLanguagePair("deepl", "en", "xx")
+	require.Error(t, err)
+	assert.Equal(t, "the en to xx language pair is not supported by provider deepl nor by any other provider", err.Error())
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	channelInfoKeyPrefix = "channel_info_"
+
+	fileDeliveryAttach = "attach"
+	fileDeliveryThread = "thread"
+
+	deliveryModePost   = "post"
+	deliveryModeProps  = "props"
+	deliveryModeThread = "thread"
+	deliveryModeMerge  = "merge"
+
+	// Translations rewriting or annotating the original post before it is committed.
+	deliveryModeRewrite  = "rewrite"
+	deliveryModeAnnotate = "annotate"
+)
+
+// ChannelInfo is a collection of fields for channel-level translation settings
+type ChannelInfo struct {
+	ChannelID      string `json:"channel_id"`
+	DeliveryMode   string `json:"delivery_mode"`
+	TranslateFiles bool   `json:"translate_files"`
+	FileDelivery   string `json:"file_delivery"`
+
+	// VerifyTranslations tells whether translations are translated back to flag inaccurate ones,
+	// such as in channels with customers.
+	VerifyTranslations bool `json:"verify_translations"`
+}
+
+// NewChannelInfo returns new channel info
+func (p *Plugin) NewChannelInfo(channelID string) *ChannelInfo {
+	return &ChannelInfo{
+		ChannelID:    channelID,
+		DeliveryMode: deliveryModePost,
+		FileDelivery: fileDeliveryAttach,
+	}
+}
+
+// IsValid validates channel information
+func (c *ChannelInfo) IsValid() error {
+	if c.ChannelID == "" || len(c.ChannelID) != 26 {
+		return fmt.Errorf("Invalid: channel_id field")
+	}
+
+	switch c.DeliveryMode {
+	case "", deliveryModePost, deliveryModeProps, deliveryModeThread, deliveryModeMerge, deliveryModeRewrite, deliveryModeAnnotate:
+	default:
+		return fmt.Errorf("Invalid: delivery_mode must be one of \"%s\", \"%s\", \"%s\", \"%s\", \"%s\" or \"%s\"", deliveryModePost, deliveryModeProps, deliveryModeThread, deliveryModeMerge, deliveryModeRewrite, deliveryModeAnnotate)
+	}
+
+	if c.FileDelivery != fileDeliveryAttach && c.FileDelivery != fileDeliveryThread {
+		return fmt.Errorf("Invalid: file_delivery must be either \"%s\" or \"%s\"", fileDeliveryAttach, fileDeliveryThread)
+	}
+
+	return nil
+}
+
+// canManageChannel reports whether a user may change the translation settings of a channel, which
+// apply to every member, being reserved to channel and system admins.
+func (p *Plugin) canManageChannel(userID, channelID string) bool {
+	channel, appErr := p.API.GetChannel(channelID)
+	if appErr != nil {
+		return false
+	}
+
+	permission := model.PERMISSION_MANAGE_PUBLIC_CHANNEL_PROPERTIES
+	if channel.Type != model.CHANNEL_OPEN {
+		permission = model.PERMISSION_MANAGE_PRIVATE_CHANNEL_PROPERTIES
+	}
+
+	return p.API.HasPermissionToChannel(userID, channelID, permission)
+}
+
+func (p *Plugin) getChannelInfo(channelID string) (*ChannelInfo, *APIErrorResponse) {
+	var channelInfo ChannelInfo
+
+	if infoBytes, err := p.API.KVGet(channelInfoKeyPrefix + channelID); err != nil || infoBytes == nil {
+		return nil, &APIErrorResponse{ID: apiErrorNoRecordFound, Message: "No record found.", StatusCode: http.StatusBadRequest}
+	} else if err := json.Unmarshal(infoBytes, &channelInfo); err != nil {
+		return nil, &APIErrorResponse{ID: apiErrorUnableToUnmarshal, Message: "Unable to unmarshal json.", StatusCode: http.StatusBadRequest}
+	}
+
+	return &channelInfo, nil
+}
+
+func (p *Plugin) setChannelInfo(channelInfo *ChannelInfo) *APIErrorResponse {
+	if err := channelInfo.IsValid(); err != nil {
+		return &APIErrorResponse{ID: apiErrorInvalidChannelInfo, Message: err.Error(), StatusCode: http.StatusBadRequest}
+	}
+
+	jsonChannelInfo, err := json.Marshal(channelInfo)
+	if err != nil {
+		return &APIErrorResponse{ID: apiErrorUnableToUnmarshal, Message: "Unable to marshal json.", StatusCode: http.StatusBadRequest}
+	}
+
+	if err := p.API.KVSet(channelInfoKeyPrefix+channelInfo.ChannelID, jsonChannelInfo); err != nil {
+		return &APIErrorResponse{ID: apiErrorUnableToSave, Message: "Unable to save channel info.", StatusCode: http.StatusBadRequest}
+	}
+
+	return nil
+}
+
+func (c *ChannelInfo) getTranslateFilesString() string {
+	translateFiles := "off"
+	if c.TranslateFiles {
+		translateFiles = "on"
+	}
+
+	return translateFiles
+}
+
+func (c *ChannelInfo) getVerifyTranslationsString() string {
+	if c.VerifyTranslations {
+		return "on"
+	}
+
+	return "off"
+}
+
+// getDeliveryMode returns the delivery mode of translations, defaulting to a separate post for
+// channels saved before delivery modes were introduced.
+func (c *ChannelInfo) getDeliveryMode() string {
+	if c.DeliveryMode == "" {
+		return deliveryModePost
+	}
+
+	return c.DeliveryMode
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -22,11 +25,25 @@ This plugin is powered by Amazon Translate which is a text translation service t
 
 * |/autotranslate on| - Add an option to translate a post with the default setting of Auto as source and English as target.
 * |/autotranslate off| - Remove an option to translate a post
-* |/autotranslate info| - Show user info on this plugin
+* |/autotranslate info| - Show user info on this plugin, with language settings suggested from the language of your messages
 * |/autotranslate source [value]| - Update your autotranslation source
   * |value| can be any of the [supported language codes](https://docs.aws.amazon.com/translate/latest/dg/what-is.html) or "auto" to automatically detect language used.
 * |/autotranslate target [value]| - Update your autotranslation target
   * |value| can be any of the [supported language codes](https://docs.aws.amazon.com/translate/latest/dg/what-is.html).
+* |/autotranslate private [on|off]| - Show or update whether your messages in private channels, direct messages and group messages are translated, off by default and only when allowed by system admins
+* |/autotranslate usage| - Show your recent translations
+* |/autotranslate status| - Show the translation settings of the current channel and the languages of its messages over the last 7 days and the health of the translation provider, along with the channels with the most messages for system admins
+* |/autotranslate leaderboard [days]| - Show the channels and users with the most characters translated over the last 7 days or the given number of days, for system admins
+* |/autotranslate detect [text]| - Show the language of a text as detected by the configured language detector
+* |/autotranslate cache flush| - Delete the cached translations, such as after changing the provider, for system admins
+* |/autotranslate bots [add|remove] [username]| - List or update the bots and webhooks whose posts are translated for you, such as |rssbot| or |jira|
+* |/autotranslate files [value]| - Update translation of .txt, .md and .csv attachments in the current channel, for channel admins
+  * |value| can be "on", "off", "attach" to re-attach translated files or "thread" to reply with the translation in a thread.
+* |/autotranslate glossary [team|channel] [add|remove] [term]| - List or update the terms of the team or of the current channel kept as is when translating, such as product names, for team or channel admins
+  * |/autotranslate glossary [team|channel] add [term] = [language code] [translation]| fixes the translation of a term into a language instead.
+* |/autotranslate verify [on|off]| - Update whether translations in the current channel are translated back to flag inaccurate ones, for channel admins
+* |/autotranslate delivery [value]| - Update how translations are delivered in the current channel, for channel admins
+  * |value| can be "post" to post translations next to the original, "props" to store them in the original post to be shown in place, "thread" to reply with translations in the thread of the original, "merge" to append them to the original post, "rewrite" to replace messages with their translation before they are posted or "annotate" to append translations to messages before they are posted.
 * |Language codes|: See [AWS Translate supported languages](https://docs.aws.amazon.com/translate/latest/dg/what-is.html)
   `
 
@@ -117,7 +134,7 @@ func (p *Plugin) registerCommands() error {
 		DisplayName:      "Autotranslate",
 		Description:      "Mattermost Autotranslation Plugin",
 		AutoComplete:     true,
-		AutoCompleteDesc: "Available commands: info, on, off, source, target, help",
+		AutoCompleteDesc: "Available commands: info, on, off, source, target, private, usage, status, leaderboard, detect, bots, files, verify, glossary, delivery, cache, help",
 		AutoCompleteHint: "[command]",
 	}); err != nil {
 		return errors.Wrap(err, "failed to register autotranslate command")
@@ -136,13 +153,27 @@ func getCommandResponse(responseType, text string) *model.CommandResponse {
 	}
 }
 
+// getPrivateChannelsText tells a user whether their messages in private channels, direct messages
+// and group messages are translated.
+func getPrivateChannelsText(userInfo *UserInfo, configuration *configuration) string {
+	switch {
+	case !userInfo.TranslatePrivateChannels:
+		return "Your messages in private channels, direct messages and group messages are not translated. Try `/autotranslate private on` to translate them."
+	case configuration.getPrivateChannelProviders() == privateChannelProvidersOff:
+		return "Your messages in private channels, direct messages and group messages are translated once system admins allow it."
+	}
+
+	return "Your messages in private channels, direct messages and group messages are translated."
+}
+
 func setUserInfoCommandResponse(userInfo *UserInfo, err *APIErrorResponse, action string) (*model.CommandResponse, *model.AppError) {
 	var actionMapping = map[string]interface{}{
-		"source": "setting up language source of autotranslation plugin",
-		"target": "setting up language target of autotranslation plugin",
-		"on":     "turning on the autotranslation plugin",
-		"off":    "turning off the autotranslation plugin",
-		"info":   "getting user information",
+		"source":  "setting up language source of autotranslation plugin",
+		"target":  "setting up language target of autotranslation plugin",
+		"on":      "turning on the autotranslation plugin",
+		"off":     "turning off the autotranslation plugin",
+		"info":    "getting user information",
+		"private": "setting up translation of private channels",
 	}
 
 	if err != nil {
@@ -194,6 +225,27 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, text), nil
 	}
 
+	switch action {
+	case "files":
+		return p.executeFilesCommand(args, param), nil
+	case "delivery":
+		return p.executeDeliveryCommand(args, param), nil
+	case "verify":
+		return p.executeVerifyCommand(args, param), nil
+	case "usage":
+		return p.executeUsageCommand(args), nil
+	case "status":
+		return p.executeStatusCommand(args), nil
+	case "leaderboard":
+		return p.executeLeaderboardCommand(args, param), nil
+	case "glossary":
+		return p.executeGlossaryCommand(args, split[2:]), nil
+	case "cache":
+		return p.executeCacheCommand(args, param), nil
+	case "detect":
+		return p.executeDetectCommand(args, strings.Join(split[2:], " ")), nil
+	}
+
 	userInfo, err := p.getUserInfo(args.UserId)
 	if userInfo == nil && action != "on" {
 		text = "No record found. Try `/autotranslate on` to enable."
@@ -201,21 +253,57 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 	}
 
 	switch action {
+	case "bots":
+		if userInfo == nil {
+			return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "No record found. If not yet turned on for the first time, try `/autotranslate on` to enable."), nil
+		}
+
+		username := ""
+		if len(split) > 3 {
+			username = split[3]
+		}
+
+		return p.executeBotsCommand(userInfo, param, username), nil
 	case "info":
 		text = fmt.Sprintf(
 			"Your autotranslation plugin settings:\n * Active: `%s`\n * Language: `source: %s`, `target: %s`\n",
 			userInfo.getActivatedString(), languageCodes[userInfo.SourceLanguage], languageCodes[userInfo.TargetLanguage],
 		)
+		if suggestion := p.getLanguageSuggestion(userInfo); suggestion != "" {
+			text += "\n" + suggestion
+		}
 		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, text), nil
 	case "on":
-		if userInfo == nil {
+		if !p.canTurnTranslationOn(args.UserId, args.ChannelId) {
+			return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "You aren't allowed to turn autotranslation on. Ask a system admin for one of the roles allowed to."), nil
+		}
+
+		// Users who haven't consented yet are turned on once they agree in the consent dialog.
+		if !p.hasConsent(args.UserId) {
+			if err := p.openConsentDialog(args.TriggerId, consentDialogStateActivate); err != nil {
+				return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("An error occurred asking for your consent. `%s`", err.Error())), nil
+			}
+			return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "Your messages are sent to the translation provider once you agree in the consent dialog."), nil
+		}
+
+		firstTime := userInfo == nil
+		if firstTime {
 			userInfo = p.NewUserInfo(args.UserId)
 		} else {
 			userInfo.Activated = true
 		}
 
 		err = p.setUserInfo(userInfo)
-		return setUserInfoCommandResponse(userInfo, err, action)
+		response, appErr := setUserInfoCommandResponse(userInfo, err, action)
+
+		// Users turning autotranslation on for the first time are told what suits the language
+		// they usually write in.
+		if firstTime && err == nil {
+			if suggestion := p.getLanguageSuggestion(userInfo); suggestion != "" {
+				response.Text += "\n" + suggestion
+			}
+		}
+		return response, appErr
 	case "off":
 		if userInfo == nil {
 			return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "No record found. If not yet turned on for the first time, try `/autotranslate on` to enable. Otherwise, your record is lost for unknown reason."), nil
@@ -260,8 +348,416 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 		userInfo.TargetLanguage = param
 		err = p.setUserInfo(userInfo)
 		return setUserInfoCommandResponse(userInfo, err, action)
+	case "private":
+		switch param {
+		case "":
+			return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, getPrivateChannelsText(userInfo, p.getConfiguration())), nil
+		case "on", "off":
+			userInfo.TranslatePrivateChannels = param == "on"
+		default:
+			return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("Invalid \"%s\" value. Should be \"on\" or \"off\".", param)), nil
+		}
+
+		if err = p.setUserInfo(userInfo); err != nil {
+			return setUserInfoCommandResponse(userInfo, err, action)
+		}
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "Successfully updated!\n"+getPrivateChannelsText(userInfo, p.getConfiguration())), nil
 	default:
 		text = "###### Mattermost Autotranslate Plugin - Slash Command Help\n" + strings.Replace(commandHelp, "|", "`", -1)
 		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, text), nil
 	}
 }
+
+func getChannelInfoText(channelInfo *ChannelInfo) string {
+	return fmt.Sprintf(
+		"Translation settings of this channel:\n * Delivery: `%s`\n * File translation: `%s`, `delivery: %s`\n * Verification: `%s`\n",
+		channelInfo.getDeliveryMode(), channelInfo.getTranslateFilesString(), channelInfo.FileDelivery, channelInfo.getVerifyTranslationsString(),
+	)
+}
+
+func (p *Plugin) executeDeliveryCommand(args *model.CommandArgs, param string) *model.CommandResponse {
+	channelInfo, _ := p.getChannelInfo(args.ChannelId)
+	if channelInfo == nil {
+		channelInfo = p.NewChannelInfo(args.ChannelId)
+	}
+
+	switch param {
+	case "":
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, getChannelInfoText(channelInfo))
+	case deliveryModePost, deliveryModeProps, deliveryModeThread, deliveryModeMerge, deliveryModeRewrite, deliveryModeAnnotate:
+		channelInfo.DeliveryMode = param
+	default:
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("Invalid \"%s\" delivery mode. Should be one of \"post\", \"props\", \"thread\", \"merge\", \"rewrite\" or \"annotate\".", param))
+	}
+
+	// The delivery mode changes how every member sees translations, so only admins decide.
+	if !p.canManageChannel(args.UserId, args.ChannelId) {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "Only channel admins can change translation delivery of this channel.")
+	}
+
+	if !p.canTurnTranslationOn(args.UserId, args.ChannelId) {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "You aren't allowed to change translation of this channel. Ask a system admin for one of the roles allowed to.")
+	}
+
+	if err := p.setChannelInfo(channelInfo); err != nil {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("An error occurred setting up translation delivery of this channel. `%s`", err.Message))
+	}
+
+	return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "Successfully updated!\n"+getChannelInfoText(channelInfo))
+}
+
+func (p *Plugin) executeFilesCommand(args *model.CommandArgs, param string) *model.CommandResponse {
+	channelInfo, _ := p.getChannelInfo(args.ChannelId)
+	if channelInfo == nil {
+		channelInfo = p.NewChannelInfo(args.ChannelId)
+	}
+
+	switch param {
+	case "":
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, getChannelInfoText(channelInfo))
+	case "on", "off":
+		channelInfo.TranslateFiles = param == "on"
+	case fileDeliveryAttach, fileDeliveryThread:
+		channelInfo.TranslateFiles = true
+		channelInfo.FileDelivery = param
+	default:
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("Invalid \"%s\" value. Should be one of \"on\", \"off\", \"attach\" or \"thread\".", param))
+	}
+
+	// Translated files are uploaded to the provider for every member, so only admins decide.
+	if !p.canManageChannel(args.UserId, args.ChannelId) {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "Only channel admins can change file translation of this channel.")
+	}
+
+	// Turning file translation off is left to every channel admin.
+	if channelInfo.TranslateFiles && !p.canTurnTranslationOn(args.UserId, args.ChannelId) {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "You aren't allowed to turn file translation of this channel on. Ask a system admin for one of the roles allowed to.")
+	}
+
+	if err := p.setChannelInfo(channelInfo); err != nil {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("An error occurred setting up file translation of this channel. `%s`", err.Message))
+	}
+
+	return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "Successfully updated!\n"+getChannelInfoText(channelInfo))
+}
+
+func (p *Plugin) executeVerifyCommand(args *model.CommandArgs, param string) *model.CommandResponse {
+	channelInfo, _ := p.getChannelInfo(args.ChannelId)
+	if channelInfo == nil {
+		channelInfo = p.NewChannelInfo(args.ChannelId)
+	}
+
+	switch param {
+	case "":
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, getChannelInfoText(channelInfo))
+	case "on", "off":
+		channelInfo.VerifyTranslations = param == "on"
+	default:
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("Invalid \"%s\" value. Should be either \"on\" or \"off\".", param))
+	}
+
+	// Verification doubles the requests to the provider for every member, so only admins decide.
+	if !p.canManageChannel(args.UserId, args.ChannelId) {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "Only channel admins can change translation verification of this channel.")
+	}
+
+	if err := p.setChannelInfo(channelInfo); err != nil {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("An error occurred setting up translation verification of this channel. `%s`", err.Message))
+	}
+
+	return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "Successfully updated!\n"+getChannelInfoText(channelInfo))
+}
+
+// executeGlossaryCommand lists the glossaries of the current channel and its team, or adds or
+// removes a term of one of them, params being the fields following "glossary".
+func (p *Plugin) executeGlossaryCommand(args *model.CommandArgs, params []string) *model.CommandResponse {
+	channel, appErr := p.API.GetChannel(args.ChannelId)
+	if appErr != nil {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("An error occurred getting this channel. `%s`", appErr.Error()))
+	}
+
+	scopes := []string{glossaryScopeTeam, glossaryScopeChannel}
+	if len(params) > 0 {
+		if params[0] != glossaryScopeTeam && params[0] != glossaryScopeChannel {
+			return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("Invalid \"%s\" glossary. Should be \"team\" or \"channel\".", params[0]))
+		}
+		scopes = params[:1]
+	}
+
+	if len(params) < 2 {
+		text := ""
+		for _, scope := range scopes {
+			id := channel.Id
+			if scope == glossaryScopeTeam {
+				if channel.TeamId == "" {
+					continue
+				}
+				id = channel.TeamId
+			}
+
+			glossary := p.getGlossary(scope, id)
+			if glossary == nil || len(glossary.Terms) == 0 {
+				text += fmt.Sprintf("No terms in the glossary of this %s.\n", scope)
+				continue
+			}
+			text += fmt.Sprintf("Glossary of this %s:\n%s\n", scope, getGlossaryText(glossary))
+		}
+
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, text)
+	}
+
+	scope := params[0]
+	id := channel.Id
+	if scope == glossaryScopeTeam {
+		if channel.TeamId == "" {
+			return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "This channel belongs to no team. Use the glossary of the channel instead.")
+		}
+		id = channel.TeamId
+	}
+
+	term := strings.Join(params[2:], " ")
+	language := ""
+	translation := ""
+	if i := strings.Index(term, " = "); i >= 0 && params[1] == "add" {
+		fields := strings.Fields(term[i+3:])
+		term = strings.TrimSpace(term[:i])
+		if len(fields) < 2 {
+			return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "Invalid translation. Should be a language code followed by the translation of the term, such as `= ja 翻訳`.")
+		}
+
+		language = fields[0]
+		translation = strings.Join(fields[1:], " ")
+		if language == autoLanguage || languageCodes[language] == "" {
+			return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("Invalid \"%s\" language. Should pass a valid language code.", language))
+		}
+	}
+
+	if err := (&GlossaryTerm{Term: term}).IsValid(); err != nil {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("Invalid term: %s.", err.Error()))
+	}
+
+	// Glossaries apply to every translation in their team or channel, so only admins decide.
+	if !p.canManageGlossary(args.UserId, scope, id) {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("Only %s admins can change the glossary of this %s.", scope, scope))
+	}
+
+	switch params[1] {
+	case "add":
+		if err := p.addGlossaryTerm(scope, id, term, language, translation); err != nil {
+			return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("An error occurred updating the glossary of this %s. `%s`", scope, err.Error()))
+		}
+	case "remove":
+		removed, err := p.removeGlossaryTerm(scope, id, term)
+		if err != nil {
+			return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("An error occurred updating the glossary of this %s. `%s`", scope, err.Error()))
+		}
+		if !removed {
+			return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("`%s` is not in the glossary of this %s.", term, scope))
+		}
+	default:
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("Invalid \"%s\" action. Should be \"add\" or \"remove\".", params[1]))
+	}
+
+	return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("Successfully updated! Cached translations keep their former terms until the cache is flushed.\nGlossary of this %s:\n%s", scope, getGlossaryText(p.getGlossary(scope, id))))
+}
+
+// maxStatusCommandChannels bounds the channels listed to system admins by the status command.
+const maxStatusCommandChannels = 5
+
+func (p *Plugin) executeStatusCommand(args *model.CommandArgs) *model.CommandResponse {
+	channelInfo, _ := p.getChannelInfo(args.ChannelId)
+	if channelInfo == nil {
+		channelInfo = p.NewChannelInfo(args.ChannelId)
+	}
+
+	text := getChannelInfoText(channelInfo)
+
+	// Languages not saved yet are included so that the status is up to date.
+	p.flushLanguageStats()
+
+	channelStats, err := p.getChannelLanguageStats(defaultStatsDays, args.ChannelId)
+	if err != nil {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("An error occurred getting the languages of this channel. `%s`", err.Error()))
+	}
+
+	if len(channelStats) == 0 {
+		text += fmt.Sprintf("\nNo languages detected in this channel over the last %d days.\n", defaultStatsDays)
+	} else {
+		text += fmt.Sprintf("\nLanguages of the %d messages of this channel over the last %d days: %s\n", channelStats[0].Messages, defaultStatsDays, getChannelLanguagesText(channelStats[0]))
+	}
+
+	// The errors of the provider and the languages of other channels are only shown to system
+	// admins, who decide where translation is enabled.
+	isAdmin := p.API.HasPermissionTo(args.UserId, model.PERMISSION_MANAGE_SYSTEM)
+
+	health, err := p.getProviderHealth()
+	if err != nil || health == nil {
+		health = p.getLastProviderProbe()
+	}
+	text += getProviderHealthText(health, isAdmin)
+
+	if !isAdmin {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, text)
+	}
+
+	allStats, err := p.getChannelLanguageStats(defaultStatsDays, "")
+	if err != nil || len(allStats) == 0 {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, text)
+	}
+
+	if len(allStats) > maxStatusCommandChannels {
+		allStats = allStats[:maxStatusCommandChannels]
+	}
+
+	text += "\nChannels with the most messages:\n"
+	for _, stats := range allStats {
+		name := stats.ChannelID
+		if channel, appErr := p.API.GetChannel(stats.ChannelID); appErr == nil {
+			name = "~" + channel.Name
+		}
+
+		text += fmt.Sprintf(" * %s: %d messages, %s\n", name, stats.Messages, getChannelLanguagesText(stats))
+	}
+
+	return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, text)
+}
+
+// getProviderHealthText describes the health of the translation provider, along with its last
+// error when detailed.
+func getProviderHealthText(health *ProviderProbe, detailed bool) string {
+	if health == nil {
+		return "\nTranslation provider: not checked yet.\n"
+	}
+
+	checkedAt := time.Unix(0, health.CheckedAt*int64(time.Millisecond)).UTC().Format("2006-01-02 15:04 MST")
+	if health.Reachable {
+		return fmt.Sprintf("\nTranslation provider: available, answering in %d ms when checked at %s.\n", health.LatencyMs, checkedAt)
+	}
+
+	failingSince := time.Unix(0, health.FailingSince*int64(time.Millisecond)).UTC().Format("2006-01-02 15:04 MST")
+	text := fmt.Sprintf("\nTranslation provider: unavailable since %s, last checked at %s.\n", failingSince, checkedAt)
+	if detailed && health.Error != "" {
+		text += fmt.Sprintf("Last error: `%s`\n", health.Error)
+	}
+
+	return text
+}
+
+// maxUsageCommandEntries bounds the recent translations listed by the usage command.
+const maxUsageCommandEntries = 10
+
+func (p *Plugin) executeUsageCommand(args *model.CommandArgs) *model.CommandResponse {
+	entries, err := p.getTranslationHistory(args.UserId)
+	if err != nil {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("An error occurred getting your translations. `%s`", err.Error()))
+	}
+
+	if len(entries) == 0 {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "No translations made for you yet.")
+	}
+
+	recent := entries
+	if len(recent) > maxUsageCommandEntries {
+		recent = recent[:maxUsageCommandEntries]
+	}
+	p.addPostLinks(args.UserId, recent)
+
+	text := fmt.Sprintf("You have %d recent translations. The latest ones are:\n", len(entries))
+	for _, entry := range recent {
+		post := entry.PostID
+		if entry.PostLink != "" {
+			post = fmt.Sprintf("[%s](%s)", entry.PostID, entry.PostLink)
+		}
+
+		text += fmt.Sprintf(" * %s `%s` %s\n", time.Unix(0, entry.CreateAt*int64(time.Millisecond)).UTC().Format("2006-01-02 15:04 MST"), getTranslationHeader(entry.SourceLanguage, entry.TargetLanguage), post)
+	}
+
+	return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, text)
+}
+
+func (p *Plugin) executeLeaderboardCommand(args *model.CommandArgs, param string) *model.CommandResponse {
+	if !p.API.HasPermissionTo(args.UserId, model.PERMISSION_MANAGE_SYSTEM) {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "Only system admins can show the translation leaderboard.")
+	}
+
+	days := defaultStatsDays
+	if param != "" {
+		var err error
+		if days, err = strconv.Atoi(param); err != nil || days <= 0 || days > maxStatsDays {
+			return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("Invalid \"%s\" value. Should be a number of days from 1 to %d.", param, maxStatsDays))
+		}
+	}
+
+	// Translations not saved yet are included so that the leaderboard is up to date.
+	p.flushVolumeStats()
+
+	leaderboard, err := p.getLeaderboard(days, defaultLeaderboardLimit)
+	if err != nil {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("An error occurred getting the translation leaderboard. `%s`", err.Error()))
+	}
+
+	return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, getLeaderboardText(leaderboard))
+}
+
+// getLeaderboardText lists the channels and users of a leaderboard, with their IDs when they
+// no longer exist.
+func getLeaderboardText(leaderboard *Leaderboard) string {
+	if len(leaderboard.Channels) == 0 && len(leaderboard.Users) == 0 {
+		return fmt.Sprintf("No messages were translated over the last %d days.", leaderboard.Days)
+	}
+
+	text := fmt.Sprintf("Channels with the most characters translated over the last %d days:\n", leaderboard.Days)
+	for _, entry := range leaderboard.Channels {
+		name := entry.ID
+		if entry.Name != "" {
+			name = "~" + entry.Name
+		}
+		text += fmt.Sprintf(" * %s: %d characters in %d requests\n", name, entry.Characters, entry.Requests)
+	}
+
+	text += fmt.Sprintf("\nUsers with the most characters translated over the last %d days:\n", leaderboard.Days)
+	for _, entry := range leaderboard.Users {
+		name := entry.ID
+		if entry.Name != "" {
+			name = "@" + entry.Name
+		}
+		text += fmt.Sprintf(" * %s: %d characters in %d requests\n", name, entry.Characters, entry.Requests)
+	}
+
+	return strings.TrimSuffix(text, "\n")
+}
+
+func (p *Plugin) executeCacheCommand(args *model.CommandArgs, param string) *model.CommandResponse {
+	if param != "flush" {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("Invalid \"%s\" value. Should be \"flush\".", param))
+	}
+
+	if !p.API.HasPermissionTo(args.UserId, model.PERMISSION_MANAGE_SYSTEM) {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "Only system admins can flush the translation cache.")
+	}
+
+	flushed, err := p.flushTranslationCache()
+	if err != nil {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("An error occurred flushing the translation cache after deleting %d cached translations. `%s`", flushed, err.Error()))
+	}
+
+	return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("Successfully flushed the translation cache, deleting %d cached translations.", flushed))
+}
+
+func (p *Plugin) executeDetectCommand(args *model.CommandArgs, text string) *model.CommandResponse {
+	if strings.TrimSpace(text) == "" {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, "Add the text whose language to detect, such as `/autotranslate detect Bonjour tout le monde`.")
+	}
+
+	detected := p.detectTextLanguage(newAuditContext(context.Background(), args.UserId, "", args.ChannelId), text)
+	if detected.Language == "" {
+		return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("The language of this text can't be told by the %s detector.", detected.Detector))
+	}
+
+	name := languageCodes[detected.Language]
+	if name == "" {
+		name = detected.Language
+	}
+
+	return getCommandResponse(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, fmt.Sprintf("Detected `%s` with %.0f%% confidence by the %s detector.", name, detected.Confidence*100, detected.Detector))
+}
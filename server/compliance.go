@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	mappingsFormatJSON = "json"
+	mappingsFormatCSV  = "csv"
+
+	channelPostsPerPage = 200
+)
+
+// TranslationMapping associates a translation with the post it translates, for compliance
+// exports and legal requests. Translations delivered in the original post have no post of their
+// own.
+type TranslationMapping struct {
+	OriginalPostID    string `json:"original_post_id"`
+	TranslationPostID string `json:"translation_post_id,omitempty"`
+	ChannelID         string `json:"channel_id"`
+	SourceLanguage    string `json:"source_lang"`
+	TargetLanguage    string `json:"target_lang"`
+	DeliveryMode      string `json:"delivery_mode"`
+	CreateAt          int64  `json:"create_at"`
+	DeleteAt          int64  `json:"delete_at,omitempty"`
+}
+
+// getTranslationMappings returns the translations a post holds or is, read from the props the
+// plugin sets on translation posts and on posts translated in place.
+func getTranslationMappings(post *model.Post, botUserID string) []*TranslationMapping {
+	source, _ := post.GetProp(translationSourceLanguageProp).(string)
+	target, _ := post.GetProp(translationTargetLanguageProp).(string)
+
+	if originalPostID, ok := post.GetProp(translationSourcePostIDProp).(string); ok && post.UserId == botUserID {
+		deliveryMode := deliveryModePost
+		if post.RootId != "" {
+			deliveryMode = deliveryModeThread
+		}
+
+		return []*TranslationMapping{{
+			OriginalPostID:    originalPostID,
+			TranslationPostID: post.Id,
+			ChannelID:         post.ChannelId,
+			SourceLanguage:    source,
+			TargetLanguage:    target,
+			DeliveryMode:      deliveryMode,
+			CreateAt:          post.CreateAt,
+			DeleteAt:          post.DeleteAt,
+		}}
+	}
+
+	var mappings []*TranslationMapping
+	if original, ok := post.GetProp(originalMessageProp).(string); ok {
+		deliveryMode := deliveryModeMerge
+		if post.GetProp(interceptedProp) != nil {
+			deliveryMode = deliveryModeAnnotate
+			if !strings.HasPrefix(post.Message, original+mergedTranslationSeparator) {
+				deliveryMode = deliveryModeRewrite
+			}
+		}
+
+		mappings = append(mappings, &TranslationMapping{
+			OriginalPostID: post.Id,
+			ChannelID:      post.ChannelId,
+			SourceLanguage: source,
+			TargetLanguage: target,
+			DeliveryMode:   deliveryMode,
+			CreateAt:       post.UpdateAt,
+			DeleteAt:       post.DeleteAt,
+		})
+	}
+
+	translations, _ := post.GetProp(translationsProp).(map[string]interface{})
+	targets := make([]string, 0, len(translations))
+	for target := range translations {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	for _, target := range targets {
+		translation, _ := translations[target].(map[string]interface{})
+		source, _ := translation["source_language"].(string)
+		mappings = append(mappings, &TranslationMapping{
+			OriginalPostID: post.Id,
+			ChannelID:      post.ChannelId,
+			SourceLanguage: source,
+			TargetLanguage: target,
+			DeliveryMode:   deliveryModeProps,
+			CreateAt:       post.UpdateAt,
+			DeleteAt:       post.DeleteAt,
+		})
+	}
+
+	return mappings
+}
+
+// getStoredTranslationMappings returns the translations of a post delivered in place and kept in
+// the KV store.
+func getStoredTranslationMappings(post *model.Post, translations map[string]*StoredTranslation) []*TranslationMapping {
+	targets := make([]string, 0, len(translations))
+	for target := range translations {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	var mappings []*TranslationMapping
+	for _, target := range targets {
+		mappings = append(mappings, &TranslationMapping{
+			OriginalPostID: post.Id,
+			ChannelID:      post.ChannelId,
+			SourceLanguage: translations[target].SourceLanguage,
+			TargetLanguage: target,
+			DeliveryMode:   deliveryModeProps,
+			CreateAt:       translations[target].CreateAt,
+			DeleteAt:       post.DeleteAt,
+		})
+	}
+
+	return mappings
+}
+
+// getChannelTranslationMappings returns the translations of the posts of a channel created or
+// updated from since to until, oldest first. A zero until means up to now. The posts of the
+// channel are paged through from the newest one on, as a post created before since may have been
+// translated in place after it, and failing to read a page fails the export rather than leaving
+// translations out of it. Deleted posts can't be paged through by plugins, so they aren't listed.
+func (p *Plugin) getChannelTranslationMappings(channelID string, since, until int64) ([]*TranslationMapping, error) {
+	mappings := []*TranslationMapping{}
+
+	postList, appErr := p.API.GetPostsForChannel(channelID, 0, channelPostsPerPage)
+	for {
+		if appErr != nil {
+			return nil, appErr
+		}
+
+		for _, post := range postList.ToSlice() {
+			postMappings := getTranslationMappings(post, p.botUserID)
+			if post.GetProp(inPlaceTranslationsProp) != nil {
+				translations, err := p.getPostStoredTranslations(post.Id)
+				if err != nil {
+					return nil, err
+				}
+				postMappings = append(postMappings, getStoredTranslationMappings(post, translations)...)
+			}
+
+			for _, mapping := range postMappings {
+				if mapping.CreateAt >= since && (until == 0 || mapping.CreateAt <= until) {
+					mappings = append(mappings, mapping)
+				}
+			}
+		}
+
+		if len(postList.Order) < channelPostsPerPage {
+			break
+		}
+
+		// The oldest post of a page is the cursor of the next one, so that posts created
+		// meanwhile don't shift the pages.
+		postList, appErr = p.API.GetPostsBefore(channelID, postList.Order[len(postList.Order)-1], 0, channelPostsPerPage)
+	}
+
+	sort.SliceStable(mappings, func(i, j int) bool {
+		return mappings[i].CreateAt < mappings[j].CreateAt
+	})
+
+	return mappings, nil
+}
+
+func formatTranslationMappingsCSV(mappings []*TranslationMapping) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write([]string{"original_post_id", "translation_post_id", "channel_id", "source_lang", "target_lang", "delivery_mode", "create_at", "delete_at"}); err != nil {
+		return nil, err
+	}
+
+	for _, mapping := range mappings {
+		if err := writer.Write([]string{
+			mapping.OriginalPostID,
+			mapping.TranslationPostID,
+			mapping.ChannelID,
+			mapping.SourceLanguage,
+			mapping.TargetLanguage,
+			mapping.DeliveryMode,
+			strconv.FormatInt(mapping.CreateAt, 10),
+			strconv.FormatInt(mapping.DeleteAt, 10),
+		}); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+
+	return buf.Bytes(), writer.Error()
+}
+
+func (p *Plugin) getTranslationMappingsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	channelID := query.Get("channel_id")
+	if !model.IsValidId(channelID) {
+		writeAPIError(w, newInvalidParameterError("channel_id"))
+		return
+	}
+
+	var since, until int64
+	if value := query.Get("since"); value != "" {
+		var err error
+		if since, err = strconv.ParseInt(value, 10, 64); err != nil || since < 0 {
+			writeAPIError(w, newInvalidParameterError("since"))
+			return
+		}
+	}
+	if value := query.Get("until"); value != "" {
+		var err error
+		if until, err = strconv.ParseInt(value, 10, 64); err != nil || until < since {
+			writeAPIError(w, newInvalidParameterError("until"))
+			return
+		}
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = mappingsFormatJSON
+	}
+	if format != mappingsFormatJSON && format != mappingsFormatCSV {
+		writeAPIError(w, newInvalidParameterError("format"))
+		return
+	}
+
+	if _, appErr := p.API.GetChannel(channelID); appErr != nil {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorChannelNotFound, Message: "Channel not found", StatusCode: http.StatusNotFound})
+		return
+	}
+
+	mappings, err := p.getChannelTranslationMappings(channelID, since, until)
+	if err != nil {
+		p.API.LogError("Failed to get translation mappings", "channel_id", channelID, "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to get translation mappings", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	if format == mappingsFormatJSON {
+		resp, _ := json.Marshal(mappings)
+		w.Write(resp)
+		return
+	}
+
+	data, err := formatTranslationMappingsCSV(mappings)
+	if err != nil {
+		p.API.LogError("Failed to export translation mappings", "channel_id", channelID, "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to export translation mappings", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"translation-mappings-%s.csv\"", channelID))
+	w.Write(data)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+)
+
+func TestGetTranslationMappings(t *testing.T) {
+	botUserID := model.NewId()
+	originalID := model.NewId()
+	channelID := model.NewId()
+
+	newPost := func(userID, rootID, message string, props map[string]interface{}) *model.Post {
+		post := &model.Post{Id: model.NewId(), UserId: userID, ChannelId: channelID, RootId: rootID, Message: message, CreateAt: 1000, UpdateAt: 2000}
+		for key, value := range props {
+			post.AddProp(key, value)
+		}
+		return post
+	}
+
+	t.Run("translation post", func(t *testing.T) {
+		post := newPost(botUserID, "", "", map[string]interface{}{
+			translationSourcePostIDProp:   originalID,
+			translationSourceLanguageProp: "ko",
+			translationTargetLanguageProp: "en",
+		})
+
+		assert.Equal(t, []*TranslationMapping{{
+			OriginalPostID:    originalID,
+			TranslationPostID: post.Id,
+			ChannelID:         channelID,
+			SourceLanguage:    "ko",
+			TargetLanguage:    "en",
+			DeliveryMode:      deliveryModePost,
+			CreateAt:          1000,
+		}}, getTranslationMappings(post, botUserID))
+	})
+
+	t.Run("translation post in thread", func(t *testing.T) {
+		post := newPost(botUserID, originalID, "", map[string]interface{}{translationSourcePostIDProp: originalID})
+
+		mappings := getTranslationMappings(post, botUserID)
+		assert.Len(t, mappings, 1)
+		assert.Equal(t, deliveryModeThread, mappings[0].DeliveryMode)
+	})
+
+	t.Run("source post prop set by another user", func(t *testing.T) {
+		post := newPost(model.NewId(), "", "", map[string]interface{}{translationSourcePostIDProp: originalID})
+
+		assert.Empty(t, getTranslationMappings(post, botUserID))
+	})
+
+	t.Run("translations in props", func(t *testing.T) {
+		post := newPost(model.NewId(), "", "안녕하세요", map[string]interface{}{
+			translationsProp: map[string]interface{}{
+				"ja": map[string]interface{}{"source_language": "ko", "translated_text": "こんにちは"},
+				"en": map[string]interface{}{"source_language": "ko", "translated_text": "Hello"},
+			},
+		})
+
+		mappings := getTranslationMappings(post, botUserID)
+		assert.Len(t, mappings, 2)
+		assert.Equal(t, &TranslationMapping{OriginalPostID: post.Id, ChannelID: channelID, SourceLanguage: "ko", TargetLanguage: "en", DeliveryMode: deliveryModeProps, CreateAt: 2000}, mappings[0])
+		assert.Equal(t, "ja", mappings[1].TargetLanguage)
+	})
+
+	for name, test := range map[string]struct {
+		message     string
+		intercepted bool
+		expected    string
+	}{
+		"merged":    {message: formatMergedTranslation("안녕하세요", "ko", "en", "Hello"), expected: deliveryModeMerge},
+		"annotated": {message: formatMergedTranslation("안녕하세요", "ko", "en", "Hello"), intercepted: true, expected: deliveryModeAnnotate},
+		"rewritten": {message: "Hello", intercepted: true, expected: deliveryModeRewrite},
+	} {
+		t.Run(name, func(t *testing.T) {
+			props := map[string]interface{}{
+				originalMessageProp:           "안녕하세요",
+				translationSourceLanguageProp: "ko",
+				translationTargetLanguageProp: "en",
+			}
+			if test.intercepted {
+				props[interceptedProp] = true
+			}
+			post := newPost(model.NewId(), "", test.message, props)
+
+			assert.Equal(t, []*TranslationMapping{{
+				OriginalPostID: post.Id,
+				ChannelID:      channelID,
+				SourceLanguage: "ko",
+				TargetLanguage: "en",
+				DeliveryMode:   test.expected,
+				CreateAt:       2000,
+			}}, getTranslationMappings(post, botUserID))
+		})
+	}
+
+	t.Run("untranslated post", func(t *testing.T) {
+		assert.Empty(t, getTranslationMappings(newPost(model.NewId(), "", "Hello", nil), botUserID))
+	})
+}
+
+func TestGetStoredTranslationMappings(t *testing.T) {
+	post := &model.Post{Id: model.NewId(), ChannelId: model.NewId(), DeleteAt: 3000}
+
+	assert.Equal(t, []*TranslationMapping{
+		{OriginalPostID: post.Id, ChannelID: post.ChannelId, SourceLanguage: "ko", TargetLanguage: "en", DeliveryMode: deliveryModeProps, CreateAt: 2000, DeleteAt: 3000},
+		{OriginalPostID: post.Id, ChannelID: post.ChannelId, SourceLanguage: "ko", TargetLanguage: "ja", DeliveryMode: deliveryModeProps, CreateAt: 1000, DeleteAt: 3000},
+	}, getStoredTranslationMappings(post, map[string]*StoredTranslation{
+		"ja": {SourceLanguage: "ko", TranslatedText: "こんにちは", CreateAt: 1000},
+		"en": {SourceLanguage: "ko", TranslatedText: "Hello", CreateAt: 2000},
+	}))
+}
+
+func TestFormatTranslationMappingsCSV(t *testing.T) {
+	data, err := formatTranslationMappingsCSV([]*TranslationMapping{
+		{OriginalPostID: "original", TranslationPostID: "translation", ChannelID: "channel", SourceLanguage: "ko", TargetLanguage: "en", DeliveryMode: deliveryModePost, CreateAt: 1000},
+		{OriginalPostID: "original", ChannelID: "channel", SourceLanguage: "ko", TargetLanguage: "ja", DeliveryMode: deliveryModeProps, CreateAt: 2000, DeleteAt: 3000},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "original_post_id,translation_post_id,channel_id,source_lang,target_lang,delivery_mode,create_at,delete_at\n"+
+		"original,translation,channel,ko,en,post,1000,0\n"+
+		"original,,channel,ko,ja,props,2000,3000\n", string(data))
+}
+
+func TestGetChannelTranslationMappings(t *testing.T) {
+	botUserID := model.NewId()
+	channelID := model.NewId()
+
+	newPostList := func(count int, createAt int64) *model.PostList {
+		postList := model.NewPostList()
+		for i := 0; i < count; i++ {
+			post := &model.Post{Id: model.NewId(), UserId: botUserID, ChannelId: channelID, CreateAt: createAt - int64(i)}
+			post.AddProp(translationSourcePostIDProp, model.NewId())
+			postList.AddPost(post)
+			postList.AddOrder(post.Id)
+		}
+		return postList
+	}
+
+	t.Run("pages through all posts", func(t *testing.T) {
+		firstPage := newPostList(channelPostsPerPage, 10000)
+		lastPage := newPostList(2, 100)
+
+		api := &plugintest.API{}
+		api.On("GetPostsForChannel", channelID, 0, channelPostsPerPage).Return(firstPage, nil)
+		api.On("GetPostsBefore", channelID, firstPage.Order[channelPostsPerPage-1], 0, channelPostsPerPage).Return(lastPage, nil)
+
+		p := &Plugin{botUserID: botUserID}
+		p.SetAPI(api)
+
+		mappings, err := p.getChannelTranslationMappings(channelID, 0, 0)
+		require.NoError(t, err)
+		assert.Len(t, mappings, channelPostsPerPage+2)
+		assert.Equal(t, int64(99), mappings[0].CreateAt)
+
+		mappings, err = p.getChannelTranslationMappings(channelID, 100, 9999)
+		require.NoError(t, err)
+		assert.Len(t, mappings, channelPostsPerPage)
+	})
+
+	t.Run("failed page", func(t *testing.T) {
+		firstPage := newPostList(channelPostsPerPage, 10000)
+
+		api := &plugintest.API{}
+		api.On("GetPostsForChannel", channelID, 0, channelPostsPerPage).Return(firstPage, nil)
+		api.On("GetPostsBefore", channelID, firstPage.Order[channelPostsPerPage-1], 0, channelPostsPerPage).Return(nil, model.NewAppError("GetPostsBefore", "app.post.get_posts_before.app_error", nil, "", 500))
+
+		p := &Plugin{botUserID: botUserID}
+		p.SetAPI(api)
+
+		_, err := p.getChannelTranslationMappings(channelID, 0, 0)
+		assert.Error(t, err)
+	})
+}
@@ -2,8 +2,15 @@ package main
 
 import (
 	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
 )
 
 // configuration captures the plugin's external configuration as exposed in the Mattermost server
@@ -24,18 +31,288 @@ type configuration struct {
 	// AWS region with "us-east-1" as default
 	AWSRegion string
 
+	// URL of the Amazon Translate endpoint, such as a VPC endpoint, with the regional one as default
+	AWSEndpoint string
+
+	// Whether the AWS endpoint is an on-premises gateway, which private channels may use when
+	// only local providers are allowed there
+	AWSEndpointLocal bool
+
+	// URL of an on-premises Amazon Translate compatible endpoint translating messages while the
+	// provider is unreachable, no offline fallback taking place when empty
+	OfflineEndpoint string
+
+	// PEM certificates of the CAs trusted by the AWS clients on top of the system ones
+	AWSCACertificates string
+
+	// PEM certificate and key the AWS clients authenticate with for mutual TLS
+	AWSClientCertificate string
+	AWSClientKey         string
+
+	// URL of the proxy requests outside of the Mattermost server go through, with the proxy of
+	// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables as default
+	OutboundProxy string
+
+	// Comma-separated hosts, domains and IP ranges reached without the outbound proxy
+	OutboundNoProxy string
+
+	// Whether the messages of users with autotranslation turned on are translated automatically
+	TranslateMessages bool
+
+	// Maximum size in KB of a text file attachment to translate with "100" as default
+	FileTranslationMaxSize string
+
+	// Window in seconds within which consecutive posts of a user are translated together
+	CoalesceWindow string
+
+	// Length in bytes above which only the first part of a message is translated right away
+	ProgressiveTranslationThreshold string
+
+	// Time in milliseconds a message may be held back to be translated before it is posted
+	InterceptionTimeout string
+
+	// Whether translation posts notify the users mentioned in the original post again
+	MentionNotifications bool
+
+	// Whether translation posts include translations of the posts linked to by permalinks
+	TranslatePermalinks bool
+
+	// Whether the names of users, channels, teams and custom emojis are translated like other words
+	TranslateTeamNames bool
+
+	// Regular expressions matching text kept as is when translating, one per line
+	ProtectedPatterns string
+
+	// Whether email addresses, payment card numbers and phone numbers are masked from providers
+	RedactPersonalData bool
+
+	// Whether translations are checked to be written in their target language, being translated
+	// again when they aren't
+	CheckOutputLanguage bool
+
+	// Providers allowed to translate the messages of private channels and direct messages, with
+	// "any" as default
+	PrivateChannelProviders string
+
+	// Providers translating language pairs, one route per line such as ko:ja=aws, the first
+	// matching route applying and Amazon Translate translating other pairs
+	ProviderRoutes string
+
+	// Whether language pairs are routed to the provider whose translations of them are rated best
+	QualityRouting bool
+
+	// Number of ratings a provider needs for a language pair over the last 30 days to be compared
+	// by quality routing with "20" as default
+	QualityRoutingMinRatings string
+
+	// Provider a share of translations is sent to while the others go to the current providers,
+	// no canary rollout taking place when empty
+	CanaryProvider string
+
+	// Percentage of translations sent to the canary provider with "10" as default
+	CanaryPercent string
+
+	// Provider translating messages of at least the long message threshold, such as a cheaper or
+	// local one, long messages going to the routed providers like others when empty
+	LongMessageProvider string
+
+	// Number of characters from which messages go to the long message provider with "1000" as
+	// default
+	LongMessageThreshold string
+
+	// Provider translating back the translations of channels with verification on, also
+	// translating them again on failed verification, with "aws" as default
+	VerificationProvider string
+
+	// Percentage of similarity between a message and its translation translated back below which
+	// the translation fails verification with "50" as default
+	VerificationThreshold string
+
+	// Whether translations failing verification are flagged, or translated again by the
+	// verification provider first, with "flag" as default
+	VerificationFailure string
+
+	// Whether requests to the provider are recorded in the audit log with "off" as default
+	AuditLog string
+
+	// Number of days entries of the audit log are kept with "90" as default
+	AuditLogRetention string
+
+	// Number of days cached translations, delivered translation keys and audit entries are kept
+	// at most, with no limit other than their own expiry when empty or zero
+	DataRetention string
+
+	// Whether the data of deactivated users is deleted by the daily cleanup
+	DeleteDeactivatedUsersData bool
+
+	// Whether users acknowledge that their messages are sent to the provider before they are
+	RequireConsent bool
+
+	// Version of the consent users acknowledge, changing it asking every user to consent again
+	ConsentVersion string
+
+	// Comma-separated roles allowed to turn autotranslation and the translation of channels on,
+	// every user being allowed when empty
+	TranslationRoles string
+
+	// Whether the steps of every translation are logged along with their request ID
+	DebugLogging bool
+
+	// Comma-separated prices in USD per million characters of providers, such as aws=15, with
+	// "aws=15" as default
+	ProviderPrices string
+
+	// Whether the estimated spend of the previous month per team is reported every month
+	SpendReports bool
+
+	// ID of the channel monthly spend reports are posted in, system admins being sent them by
+	// direct message when empty
+	SpendReportChannel string
+
+	// ID of the channel alerts about the translation provider are posted in, system admins being
+	// sent them by direct message when empty
+	AlertChannel string
+
+	// Failure rate in percent of the requests to the provider over the failure alert window at
+	// which admins are alerted with "25" as default, zero turning failure alerts off
+	FailureAlertThreshold string
+
+	// Length in minutes of the windows the failure rate of the provider is checked over with "15"
+	// as default
+	FailureAlertWindow string
+
+	// Whether the use of features, such as automatic and on-demand translations, is sent as
+	// telemetry when the diagnostics of the server are on
+	EnableTelemetry bool
+
+	// Number of seconds after which a request to the provider is logged as slow with "5" as
+	// default, zero turning the warnings off
+	SlowTranslationThreshold string
+
+	// Whether users waiting for a translation longer than the slow translation threshold are told
+	// with an ephemeral message
+	NotifySlowTranslations bool
+
+	// Exporter of the spans of translations, "off", "otlp" or "log", with "off" as default
+	TracingExporter string
+
+	// URL of the OTLP/HTTP receiver spans are exported to
+	TracingEndpoint string
+
+	// Comma-separated name=value headers sent along with the spans exported over OTLP
+	TracingHeaders string
+
+	// Comma-separated usernames of bots and webhooks whose posts are translated
+	TranslatedBots string
+
+	// Detector of the language of messages with "local" as default
+	LanguageDetector string
+
+	// Confidence in percent a detected language must reach for messages to be translated
+	// automatically from auto with "50" as default
+	DetectionConfidenceThreshold string
+
+	// Secret of servers trusted to call the HTTP API on behalf of users
+	APISharedSecret string
+
+	// Token of systems calling the translation webhook
+	WebhookToken string
+
+	// Comma or newline separated secrets systems sign webhook requests with, any of them being
+	// accepted so that they can be rotated. The webhook is disabled when neither the token nor a
+	// secret is set.
+	WebhookSigningSecrets string
+
+	// Secret the key encrypting translations stored in the KV store is derived from, which are
+	// stored in plain text when empty
+	EncryptionKey string
+
+	// Maximum number of requests per minute of a user to the HTTP API with "60" as default
+	UserRateLimit string
+
+	// Maximum number of requests per minute from a client address to the HTTP API with "300" as default
+	AddressRateLimit string
+
 	// disable plugin
 	disabled bool
+
+	// secretsErr is why the settings referring to a secret store couldn't be read.
+	secretsErr error
+
+	// protectedPatterns are the compiled Protected Patterns, so that they aren't compiled again for
+	// each translation.
+	protectedPatterns []*regexp.Regexp
 }
 
 // Clone deep copies the configuration. Your implementation may only require a shallow copy if
 // your configuration has no reference types.
 func (c *configuration) Clone() *configuration {
 	return &configuration{
-		AWSAccessKeyID:     c.AWSAccessKeyID,
-		AWSSecretAccessKey: c.AWSSecretAccessKey,
-		AWSRegion:          c.AWSRegion,
-		disabled:           c.disabled,
+		AWSAccessKeyID:                  c.AWSAccessKeyID,
+		AWSSecretAccessKey:              c.AWSSecretAccessKey,
+		AWSRegion:                       c.AWSRegion,
+		AWSEndpoint:                     c.AWSEndpoint,
+		AWSEndpointLocal:                c.AWSEndpointLocal,
+		OfflineEndpoint:                 c.OfflineEndpoint,
+		AWSCACertificates:               c.AWSCACertificates,
+		AWSClientCertificate:            c.AWSClientCertificate,
+		AWSClientKey:                    c.AWSClientKey,
+		OutboundProxy:                   c.OutboundProxy,
+		OutboundNoProxy:                 c.OutboundNoProxy,
+		TranslateMessages:               c.TranslateMessages,
+		FileTranslationMaxSize:          c.FileTranslationMaxSize,
+		CoalesceWindow:                  c.CoalesceWindow,
+		ProgressiveTranslationThreshold: c.ProgressiveTranslationThreshold,
+		InterceptionTimeout:             c.InterceptionTimeout,
+		MentionNotifications:            c.MentionNotifications,
+		TranslatePermalinks:             c.TranslatePermalinks,
+		TranslateTeamNames:              c.TranslateTeamNames,
+		ProtectedPatterns:               c.ProtectedPatterns,
+		RedactPersonalData:              c.RedactPersonalData,
+		CheckOutputLanguage:             c.CheckOutputLanguage,
+		PrivateChannelProviders:         c.PrivateChannelProviders,
+		ProviderRoutes:                  c.ProviderRoutes,
+		QualityRouting:                  c.QualityRouting,
+		QualityRoutingMinRatings:        c.QualityRoutingMinRatings,
+		CanaryProvider:                  c.CanaryProvider,
+		CanaryPercent:                   c.CanaryPercent,
+		LongMessageProvider:             c.LongMessageProvider,
+		LongMessageThreshold:            c.LongMessageThreshold,
+		VerificationProvider:            c.VerificationProvider,
+		VerificationThreshold:           c.VerificationThreshold,
+		VerificationFailure:             c.VerificationFailure,
+		AuditLog:                        c.AuditLog,
+		AuditLogRetention:               c.AuditLogRetention,
+		DataRetention:                   c.DataRetention,
+		DeleteDeactivatedUsersData:      c.DeleteDeactivatedUsersData,
+		RequireConsent:                  c.RequireConsent,
+		ConsentVersion:                  c.ConsentVersion,
+		TranslationRoles:                c.TranslationRoles,
+		DebugLogging:                    c.DebugLogging,
+		ProviderPrices:                  c.ProviderPrices,
+		SpendReports:                    c.SpendReports,
+		SpendReportChannel:              c.SpendReportChannel,
+		AlertChannel:                    c.AlertChannel,
+		FailureAlertThreshold:           c.FailureAlertThreshold,
+		FailureAlertWindow:              c.FailureAlertWindow,
+		EnableTelemetry:                 c.EnableTelemetry,
+		SlowTranslationThreshold:        c.SlowTranslationThreshold,
+		NotifySlowTranslations:          c.NotifySlowTranslations,
+		TracingExporter:                 c.TracingExporter,
+		TracingEndpoint:                 c.TracingEndpoint,
+		TracingHeaders:                  c.TracingHeaders,
+		TranslatedBots:                  c.TranslatedBots,
+		LanguageDetector:                c.LanguageDetector,
+		DetectionConfidenceThreshold:    c.DetectionConfidenceThreshold,
+		APISharedSecret:                 c.APISharedSecret,
+		WebhookToken:                    c.WebhookToken,
+		WebhookSigningSecrets:           c.WebhookSigningSecrets,
+		EncryptionKey:                   c.EncryptionKey,
+		UserRateLimit:                   c.UserRateLimit,
+		AddressRateLimit:                c.AddressRateLimit,
+		disabled:                        c.disabled,
+		secretsErr:                      c.secretsErr,
+		protectedPatterns:               c.protectedPatterns,
 	}
 }
 
@@ -75,13 +352,31 @@ func (p *Plugin) setConfiguration(configuration *configuration) {
 
 // OnConfigurationChange is invoked when configuration changes may have been made.
 func (p *Plugin) OnConfigurationChange() error {
+	if err := p.loadConfiguration(); err != nil {
+		return err
+	}
+
+	// The provider keeps working with the previous configuration until the new one is fixed.
+	if err := p.reloadTranslateService(); err != nil {
+		p.API.LogError("Failed to apply the translation provider configuration, keeping the previous one", "err", err.Error())
+	}
+
+	return nil
+}
+
+// loadConfiguration loads the configuration from the Mattermost server configuration and makes it
+// the active one, reading the settings referring to a secret store again.
+func (p *Plugin) loadConfiguration() error {
 	configuration := p.getConfiguration().Clone()
+	configuration.secretsErr = nil
 
 	// Load the public configuration fields from the Mattermost server configuration.
 	if loadConfigErr := p.API.LoadPluginConfiguration(configuration); loadConfigErr != nil {
 		return errors.Wrap(loadConfigErr, "failed to load plugin configuration")
 	}
 
+	configuration.resolveSecrets()
+	configuration.protectedPatterns = compileProtectedPatterns(configuration.ProtectedPatterns)
 	p.setConfiguration(configuration)
 
 	return nil
@@ -97,18 +392,567 @@ func (p *Plugin) setEnabled(enabled bool) {
 
 // IsValid validates plugin configuration
 func (p *Plugin) IsValid() error {
-	configuration := p.getConfiguration()
-	if configuration.AWSAccessKeyID == "" {
+	return p.getConfiguration().IsValid()
+}
+
+// IsValid validates a configuration, such as a new one before it is applied
+func (c *configuration) IsValid() error {
+	if c.secretsErr != nil {
+		return c.secretsErr
+	}
+
+	if c.AWSAccessKeyID == "" {
 		return fmt.Errorf("Must have AWS Access Key ID")
 	}
 
-	if configuration.AWSSecretAccessKey == "" {
+	if c.AWSSecretAccessKey == "" {
 		return fmt.Errorf("Must have AWS Secret Access Key")
 	}
 
-	if configuration.AWSRegion == "" {
-		configuration.AWSRegion = "us-east-1"
+	if c.AWSRegion == "" {
+		c.AWSRegion = defaultAWSRegion
+	}
+
+	if c.AWSEndpoint != "" {
+		if endpoint, err := url.Parse(c.AWSEndpoint); err != nil || (endpoint.Scheme != "https" && endpoint.Scheme != "http") || endpoint.Host == "" {
+			return fmt.Errorf("AWS endpoint must be an http or https URL")
+		}
+	}
+
+	if c.OfflineEndpoint != "" {
+		if endpoint, err := url.Parse(c.OfflineEndpoint); err != nil || (endpoint.Scheme != "https" && endpoint.Scheme != "http") || endpoint.Host == "" {
+			return fmt.Errorf("Offline endpoint must be an http or https URL")
+		}
+	}
+
+	if (c.AWSClientCertificate == "") != (c.AWSClientKey == "") {
+		return fmt.Errorf("AWS client certificate and key must be set together")
+	}
+
+	if _, err := newProviderTLSConfig(c); err != nil {
+		return err
+	}
+
+	if c.OutboundProxy != "" {
+		if _, err := parseProxyURL(c.OutboundProxy); err != nil {
+			return err
+		}
+	}
+
+	if c.FileTranslationMaxSize != "" {
+		if size, err := strconv.Atoi(c.FileTranslationMaxSize); err != nil || size <= 0 {
+			return fmt.Errorf("File translation max size must be a positive number")
+		}
+	}
+
+	if c.CoalesceWindow != "" {
+		if window, err := strconv.Atoi(c.CoalesceWindow); err != nil || window < 0 {
+			return fmt.Errorf("Coalesce window must be zero or a positive number")
+		}
+	}
+
+	if c.ProgressiveTranslationThreshold != "" {
+		if threshold, err := strconv.Atoi(c.ProgressiveTranslationThreshold); err != nil || threshold < 0 {
+			return fmt.Errorf("Progressive translation threshold must be zero or a positive number")
+		}
+	}
+
+	if c.InterceptionTimeout != "" {
+		if timeout, err := strconv.Atoi(c.InterceptionTimeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("Interception timeout must be a positive number")
+		}
+	}
+
+	for _, line := range strings.Split(c.ProtectedPatterns, "\n") {
+		expr := strings.TrimSpace(line)
+		if expr == "" {
+			continue
+		}
+
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("Protected pattern %s is not a valid regular expression: %s", expr, err.Error())
+		}
+		if pattern.MatchString("") {
+			return fmt.Errorf("Protected pattern %s must not match empty text", expr)
+		}
+	}
+
+	switch c.LanguageDetector {
+	case "", detectorLocal, detectorProvider, detectorComprehend:
+	default:
+		return fmt.Errorf("Language detector must be %s, %s or %s", detectorLocal, detectorProvider, detectorComprehend)
+	}
+
+	if c.DetectionConfidenceThreshold != "" {
+		if threshold, err := strconv.Atoi(c.DetectionConfidenceThreshold); err != nil || threshold < 0 || threshold > 100 {
+			return fmt.Errorf("Detection confidence threshold must be a number from 0 to 100")
+		}
+	}
+
+	switch c.PrivateChannelProviders {
+	case "", privateChannelProvidersAny, privateChannelProvidersLocal, privateChannelProvidersOff:
+	default:
+		return fmt.Errorf("Private channel providers must be %s, %s or %s", privateChannelProvidersAny, privateChannelProvidersLocal, privateChannelProvidersOff)
+	}
+
+	if _, err := parseProviderRoutes(c.ProviderRoutes); err != nil {
+		return err
+	}
+
+	if c.QualityRoutingMinRatings != "" {
+		if minRatings, err := strconv.Atoi(c.QualityRoutingMinRatings); err != nil || minRatings <= 0 {
+			return fmt.Errorf("Quality routing minimum ratings must be a positive number")
+		}
+	}
+
+	if c.CanaryProvider != "" {
+		if _, ok := translationProviders[c.CanaryProvider]; !ok {
+			return fmt.Errorf("Canary provider must be a known provider")
+		}
+	}
+
+	if c.CanaryPercent != "" {
+		if percent, err := strconv.Atoi(c.CanaryPercent); err != nil || percent < 0 || percent > 100 {
+			return fmt.Errorf("Canary percent must be a number from 0 to 100")
+		}
+	}
+
+	if c.LongMessageProvider != "" {
+		if _, ok := translationProviders[c.LongMessageProvider]; !ok {
+			return fmt.Errorf("Long message provider must be a known provider")
+		}
+	}
+
+	if c.LongMessageThreshold != "" {
+		if threshold, err := strconv.Atoi(c.LongMessageThreshold); err != nil || threshold <= 0 {
+			return fmt.Errorf("Long message threshold must be a positive number")
+		}
+	}
+
+	if c.VerificationProvider != "" {
+		if _, ok := translationProviders[c.VerificationProvider]; !ok {
+			return fmt.Errorf("Verification provider must be a known provider")
+		}
+	}
+
+	if c.VerificationThreshold != "" {
+		if threshold, err := strconv.Atoi(c.VerificationThreshold); err != nil || threshold < 0 || threshold > 100 {
+			return fmt.Errorf("Verification threshold must be a number from 0 to 100")
+		}
+	}
+
+	switch c.VerificationFailure {
+	case "", verificationFailureFlag, verificationFailureRetry:
+	default:
+		return fmt.Errorf("Verification failure must be %s or %s", verificationFailureFlag, verificationFailureRetry)
+	}
+
+	switch c.AuditLog {
+	case "", auditLogOff, auditLogStore, auditLogServer:
+	default:
+		return fmt.Errorf("Audit log must be %s, %s or %s", auditLogOff, auditLogStore, auditLogServer)
+	}
+
+	if c.AuditLogRetention != "" {
+		if retention, err := strconv.Atoi(c.AuditLogRetention); err != nil || retention <= 0 {
+			return fmt.Errorf("Audit log retention must be a positive number")
+		}
+	}
+
+	if c.DataRetention != "" {
+		if retention, err := strconv.Atoi(c.DataRetention); err != nil || retention < 0 {
+			return fmt.Errorf("Data retention must be zero or a positive number")
+		}
+	}
+
+	if _, err := parseProviderPrices(c.ProviderPrices); err != nil {
+		return err
+	}
+
+	if c.SpendReportChannel != "" && !model.IsValidId(c.SpendReportChannel) {
+		return fmt.Errorf("Spend report channel must be the ID of a channel")
+	}
+
+	if c.AlertChannel != "" && !model.IsValidId(c.AlertChannel) {
+		return fmt.Errorf("Alert channel must be the ID of a channel")
+	}
+
+	if c.FailureAlertThreshold != "" {
+		if threshold, err := strconv.Atoi(c.FailureAlertThreshold); err != nil || threshold < 0 || threshold > 100 {
+			return fmt.Errorf("Failure alert threshold must be a number from 0 to 100")
+		}
+	}
+
+	if c.FailureAlertWindow != "" {
+		if window, err := strconv.Atoi(c.FailureAlertWindow); err != nil || window <= 0 {
+			return fmt.Errorf("Failure alert window must be a positive number")
+		}
+	}
+
+	if c.SlowTranslationThreshold != "" {
+		if threshold, err := strconv.Atoi(c.SlowTranslationThreshold); err != nil || threshold < 0 {
+			return fmt.Errorf("Slow translation threshold must be zero or a positive number")
+		}
+	}
+
+	switch c.TracingExporter {
+	case "", tracingExporterOff, tracingExporterLog:
+	case tracingExporterOTLP:
+		if endpoint, err := url.Parse(c.TracingEndpoint); err != nil || (endpoint.Scheme != "https" && endpoint.Scheme != "http") || endpoint.Host == "" {
+			return fmt.Errorf("Tracing endpoint must be an http or https URL")
+		}
+	default:
+		return fmt.Errorf("Tracing exporter must be %s, %s or %s", tracingExporterOff, tracingExporterOTLP, tracingExporterLog)
+	}
+
+	if _, err := parseTracingHeaders(c.TracingHeaders); err != nil {
+		return err
+	}
+
+	if c.UserRateLimit != "" {
+		if limit, err := strconv.Atoi(c.UserRateLimit); err != nil || limit < 0 {
+			return fmt.Errorf("User rate limit must be zero or a positive number")
+		}
+	}
+
+	if c.AddressRateLimit != "" {
+		if limit, err := strconv.Atoi(c.AddressRateLimit); err != nil || limit < 0 {
+			return fmt.Errorf("Address rate limit must be zero or a positive number")
+		}
 	}
 
 	return nil
 }
+
+// getFileTranslationMaxSize returns the maximum size in bytes of a file attachment to translate.
+func (c *configuration) getFileTranslationMaxSize() int64 {
+	size, err := strconv.Atoi(c.FileTranslationMaxSize)
+	if err != nil || size <= 0 {
+		size = defaultFileTranslationMaxSize
+	}
+
+	return int64(size) * 1024
+}
+
+// getCoalesceWindow returns the window within which consecutive posts of a user are translated
+// together, zero meaning each post is translated on its own.
+func (c *configuration) getCoalesceWindow() time.Duration {
+	window, err := strconv.Atoi(c.CoalesceWindow)
+	if err != nil || window < 0 {
+		return 0
+	}
+
+	return time.Duration(window) * time.Second
+}
+
+// getProgressiveTranslationThreshold returns the length in bytes above which only the first part
+// of a message is translated right away, zero meaning messages are always translated in full.
+func (c *configuration) getProgressiveTranslationThreshold() int {
+	threshold, err := strconv.Atoi(c.ProgressiveTranslationThreshold)
+	if err != nil || threshold < 0 {
+		return 0
+	}
+
+	return threshold
+}
+
+// getInterceptionTimeout returns how long a message may be held back to be translated before it
+// is posted.
+func (c *configuration) getInterceptionTimeout() time.Duration {
+	timeout, err := strconv.Atoi(c.InterceptionTimeout)
+	if err != nil || timeout <= 0 {
+		timeout = defaultInterceptionTimeout
+	}
+
+	return time.Duration(timeout) * time.Millisecond
+}
+
+// getLanguageDetector returns the name of the detector of the language of messages.
+func (c *configuration) getLanguageDetector() string {
+	if c.LanguageDetector == "" {
+		return defaultLanguageDetector
+	}
+
+	return c.LanguageDetector
+}
+
+// getPrivateChannelProviders returns which providers may translate the messages of private
+// channels and direct messages.
+func (c *configuration) getPrivateChannelProviders() string {
+	if c.PrivateChannelProviders == "" {
+		return privateChannelProvidersAny
+	}
+
+	return c.PrivateChannelProviders
+}
+
+// getProviderRoutes returns the routes of language pairs to providers. Invalid routes are ignored,
+// as the server saves them all the same, IsValid only failing the requests to the HTTP API until
+// they are fixed.
+func (c *configuration) getProviderRoutes() []*providerRoute {
+	routes, err := parseProviderRoutes(c.ProviderRoutes)
+	if err != nil {
+		return nil
+	}
+
+	return routes
+}
+
+// getQualityRoutingMinRatings returns the number of ratings a provider needs for a language pair to
+// be compared by quality routing.
+func (c *configuration) getQualityRoutingMinRatings() int {
+	if c.QualityRoutingMinRatings == "" {
+		return defaultQualityRoutingMinRatings
+	}
+
+	minRatings, err := strconv.Atoi(c.QualityRoutingMinRatings)
+	if err != nil || minRatings <= 0 {
+		return defaultQualityRoutingMinRatings
+	}
+
+	return minRatings
+}
+
+// getCanaryPercent returns the percentage of translations sent to the canary provider.
+func (c *configuration) getCanaryPercent() int {
+	if c.CanaryPercent == "" {
+		return defaultCanaryPercent
+	}
+
+	percent, err := strconv.Atoi(c.CanaryPercent)
+	if err != nil || percent < 0 || percent > 100 {
+		return defaultCanaryPercent
+	}
+
+	return percent
+}
+
+// getLongMessageThreshold returns the number of characters from which messages go to the long
+// message provider.
+func (c *configuration) getLongMessageThreshold() int {
+	if c.LongMessageThreshold == "" {
+		return defaultLongMessageThreshold
+	}
+
+	threshold, err := strconv.Atoi(c.LongMessageThreshold)
+	if err != nil || threshold <= 0 {
+		return defaultLongMessageThreshold
+	}
+
+	return threshold
+}
+
+// getVerificationProvider returns the provider translating back translations to verify them.
+func (c *configuration) getVerificationProvider() string {
+	if c.VerificationProvider == "" {
+		return providerAWS
+	}
+
+	return c.VerificationProvider
+}
+
+// getVerificationThreshold returns the percentage of round-trip similarity below which
+// translations fail verification.
+func (c *configuration) getVerificationThreshold() int {
+	if c.VerificationThreshold == "" {
+		return defaultVerificationThreshold
+	}
+
+	threshold, err := strconv.Atoi(c.VerificationThreshold)
+	if err != nil || threshold < 0 || threshold > 100 {
+		return defaultVerificationThreshold
+	}
+
+	return threshold
+}
+
+// getVerificationFailure returns what happens to translations failing verification.
+func (c *configuration) getVerificationFailure() string {
+	if c.VerificationFailure == "" {
+		return verificationFailureFlag
+	}
+
+	return c.VerificationFailure
+}
+
+// getConsentVersion returns the version of the consent users acknowledge.
+func (c *configuration) getConsentVersion() string {
+	return strings.TrimSpace(c.ConsentVersion)
+}
+
+// getDetectionProvider returns the provider the detector of the language of messages asks when
+// the local detection isn't confident, or an empty string when it only detects locally.
+func (c *configuration) getDetectionProvider() string {
+	switch c.getLanguageDetector() {
+	case detectorProvider:
+		return providerAWS
+	case detectorComprehend:
+		return detectorComprehend
+	}
+
+	return ""
+}
+
+// getDetectionConfidenceThreshold returns the confidence between 0 and 1 a detected language must
+// reach for messages to be translated automatically from auto, zero meaning they always are.
+func (c *configuration) getDetectionConfidenceThreshold() float64 {
+	if c.DetectionConfidenceThreshold == "" {
+		return defaultDetectionConfidenceThreshold / 100.0
+	}
+
+	threshold, err := strconv.Atoi(c.DetectionConfidenceThreshold)
+	if err != nil || threshold < 0 || threshold > 100 {
+		return defaultDetectionConfidenceThreshold / 100.0
+	}
+
+	return float64(threshold) / 100
+}
+
+// getAuditLog returns whether requests to the provider are recorded in the audit log, and whether
+// they are also written to the server log.
+func (c *configuration) getAuditLog() string {
+	if c.AuditLog == "" {
+		return auditLogOff
+	}
+
+	return c.AuditLog
+}
+
+// getAuditLogRetention returns the number of days entries of the audit log are kept.
+func (c *configuration) getAuditLogRetention() int {
+	retention, err := strconv.Atoi(c.AuditLogRetention)
+	if err != nil || retention <= 0 {
+		return defaultAuditLogRetention
+	}
+
+	return retention
+}
+
+// getDataRetention returns the number of days translation data is kept at most, zero meaning no
+// limit.
+func (c *configuration) getDataRetention() int {
+	retention, err := strconv.Atoi(c.DataRetention)
+	if err != nil || retention < 0 {
+		return 0
+	}
+
+	return retention
+}
+
+// getFailureAlertThreshold returns the failure rate in percent of the requests to the provider at
+// which admins are alerted, zero meaning they aren't.
+func (c *configuration) getFailureAlertThreshold() int {
+	if c.FailureAlertThreshold == "" {
+		return defaultFailureAlertThreshold
+	}
+
+	threshold, err := strconv.Atoi(c.FailureAlertThreshold)
+	if err != nil || threshold < 0 {
+		return defaultFailureAlertThreshold
+	}
+
+	return threshold
+}
+
+// getFailureAlertWindow returns the length of the windows the failure rate of the provider is
+// checked over.
+func (c *configuration) getFailureAlertWindow() time.Duration {
+	window, err := strconv.Atoi(c.FailureAlertWindow)
+	if err != nil || window <= 0 {
+		window = defaultFailureAlertWindow
+	}
+
+	return time.Duration(window) * time.Minute
+}
+
+// getTracingExporter returns where the spans of translations are exported, if anywhere.
+func (c *configuration) getTracingExporter() string {
+	if c.TracingExporter == "" {
+		return tracingExporterOff
+	}
+
+	return c.TracingExporter
+}
+
+// getSlowTranslationThreshold returns how long a request to the provider lasts before it is slow,
+// zero meaning requests are never slow.
+func (c *configuration) getSlowTranslationThreshold() time.Duration {
+	if c.SlowTranslationThreshold == "" {
+		return defaultSlowTranslationThreshold * time.Second
+	}
+
+	threshold, err := strconv.Atoi(c.SlowTranslationThreshold)
+	if err != nil || threshold < 0 {
+		return defaultSlowTranslationThreshold * time.Second
+	}
+
+	return time.Duration(threshold) * time.Second
+}
+
+// getUserRateLimit returns the maximum number of requests per minute of a user to the HTTP API,
+// zero meaning no limit.
+func (c *configuration) getUserRateLimit() int {
+	if c.UserRateLimit == "" {
+		return defaultUserRateLimit
+	}
+
+	limit, err := strconv.Atoi(c.UserRateLimit)
+	if err != nil || limit < 0 {
+		return defaultUserRateLimit
+	}
+
+	return limit
+}
+
+// getAddressRateLimit returns the maximum number of requests per minute from a client address to
+// the HTTP API, zero meaning no limit.
+func (c *configuration) getAddressRateLimit() int {
+	if c.AddressRateLimit == "" {
+		return defaultAddressRateLimit
+	}
+
+	limit, err := strconv.Atoi(c.AddressRateLimit)
+	if err != nil || limit < 0 {
+		return defaultAddressRateLimit
+	}
+
+	return limit
+}
+
+// getProtectedPatterns returns the regular expressions matching text kept as is when translating,
+// compiled when the configuration was loaded.
+func (c *configuration) getProtectedPatterns() []*regexp.Regexp {
+	return c.protectedPatterns
+}
+
+// compileProtectedPatterns compiles the Protected Patterns setting. Invalid patterns are skipped,
+// as the server saves them all the same, IsValid only failing the requests to the HTTP API until
+// they are fixed.
+func compileProtectedPatterns(value string) []*regexp.Regexp {
+	var patterns []*regexp.Regexp
+	for _, line := range strings.Split(value, "\n") {
+		expr := strings.TrimSpace(line)
+		if expr == "" {
+			continue
+		}
+
+		if pattern, err := regexp.Compile(expr); err == nil && !pattern.MatchString("") {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	return patterns
+}
+
+// isTranslatedBot reports whether posts of the bot or webhook with the given username are
+// translated for every user with autotranslation turned on.
+func (c *configuration) isTranslatedBot(username string) bool {
+	for _, name := range strings.Split(c.TranslatedBots, ",") {
+		if normalizeBotName(name) == username {
+			return true
+		}
+	}
+
+	return false
+}
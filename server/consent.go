@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	consentKeyPrefix = "consent_"
+
+	actionConsent = "consent"
+
+	consentDialogCallbackID = "consent"
+	consentDialogAgree      = "agree"
+
+	// consentDialogStateActivate tells the consent dialog to turn autotranslation on once the
+	// user agreed, as it was opened by /autotranslate on.
+	consentDialogStateActivate = "activate"
+
+	// consentPromptInterval is how often users posting without their consent are reminded of it.
+	consentPromptInterval = time.Hour
+)
+
+// errConsentRequired reports a translation refused as the author of the message hasn't consented
+// to their messages being sent to the provider.
+var errConsentRequired = errors.New("the author of the message has not consented to its translation by the provider")
+
+// UserConsent is the acknowledgement of a user that their messages are sent to the translation
+// provider.
+type UserConsent struct {
+	UserID    string `json:"user_id"`
+	Version   string `json:"version"`
+	ConsentAt int64  `json:"consent_at"`
+}
+
+// ConsentStatus tells a user whether their consent is required, and whether they gave the current
+// version of it.
+type ConsentStatus struct {
+	Required  bool   `json:"required"`
+	Version   string `json:"version"`
+	Consented bool   `json:"consented"`
+	ConsentAt int64  `json:"consent_at,omitempty"`
+}
+
+func getConsentKey(userID string) string {
+	return consentKeyPrefix + userID
+}
+
+func getConsentURL() string {
+	return fmt.Sprintf("/plugins/%s/api/v1/consent", manifest.Id)
+}
+
+// isConsentCurrent reports whether a consent, which may be nil, is of the version users
+// acknowledge, any consent being current when it isn't required.
+func (c *configuration) isConsentCurrent(consent *UserConsent) bool {
+	return !c.RequireConsent || (consent != nil && consent.Version == c.getConsentVersion())
+}
+
+func (p *Plugin) getUserConsent(userID string) (*UserConsent, error) {
+	data, appErr := p.API.KVGet(getConsentKey(userID))
+	if appErr != nil {
+		return nil, appErr
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var consent *UserConsent
+	if err := json.Unmarshal(data, &consent); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal consent")
+	}
+
+	return consent, nil
+}
+
+// recordConsent records that a user consented to the current version of the consent now.
+func (p *Plugin) recordConsent(userID string) (*UserConsent, error) {
+	consent := &UserConsent{
+		UserID:    userID,
+		Version:   p.getConfiguration().getConsentVersion(),
+		ConsentAt: model.GetMillis(),
+	}
+
+	data, err := json.Marshal(consent)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal consent")
+	}
+
+	if appErr := p.API.KVSet(getConsentKey(userID), data); appErr != nil {
+		return nil, appErr
+	}
+
+	return consent, nil
+}
+
+// hasConsent reports whether the messages of a user may be sent to the provider. Users whose
+// consent can't be read haven't consented, as they may not have.
+func (p *Plugin) hasConsent(userID string) bool {
+	config := p.getConfiguration()
+	if !config.RequireConsent {
+		return true
+	}
+
+	consent, err := p.getUserConsent(userID)
+	if err != nil {
+		p.API.LogWarn("Failed to get consent", "user_id", userID, "err", err.Error())
+		return false
+	}
+
+	return config.isConsentCurrent(consent)
+}
+
+// checkAuthorConsent returns errConsentRequired when the author of a post hasn't consented to its
+// translation. Posts of bots, webhooks and other plugins have no author to consent.
+func (p *Plugin) checkAuthorConsent(post *model.Post) error {
+	if post.UserId == p.botUserID || isBotPost(post) || p.hasConsent(post.UserId) {
+		return nil
+	}
+
+	return errConsentRequired
+}
+
+// getConsentDialog returns the dialog users acknowledge that their messages are sent to the
+// provider with.
+func (p *Plugin) getConsentDialog(state string) model.Dialog {
+	config := p.getConfiguration()
+
+	destination := fmt.Sprintf("Amazon Translate in the %s AWS region", config.AWSRegion)
+	if config.AWSEndpoint != "" {
+		destination = fmt.Sprintf("the translation service at %s", config.AWSEndpoint)
+	}
+
+	return model.Dialog{
+		CallbackId: consentDialogCallbackID,
+		Title:      "Consent to translation",
+		IntroductionText: fmt.Sprintf(
+			"To be translated, your messages and the text files you attach are sent to %s. None of them are sent before you agree.",
+			destination,
+		),
+		Elements: []model.DialogElement{{
+			DisplayName: "Agreement",
+			Name:        consentDialogAgree,
+			Type:        "bool",
+			Placeholder: "I agree that my messages are sent to the translation provider.",
+		}},
+		SubmitLabel: "Agree",
+		State:       state,
+	}
+}
+
+// openConsentDialog asks a user for their consent in the dialog opened by the given trigger.
+func (p *Plugin) openConsentDialog(triggerID, state string) error {
+	if appErr := p.API.OpenInteractiveDialog(model.OpenDialogRequest{
+		TriggerId: triggerID,
+		URL:       getConsentURL(),
+		Dialog:    p.getConsentDialog(state),
+	}); appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
+
+// promptConsent reminds a user who posted a message without their consent that it isn't
+// translated, with a button opening the consent dialog, at most once per consentPromptInterval.
+func (p *Plugin) promptConsent(post *model.Post) {
+	now := time.Now()
+
+	p.consentPromptsLock.Lock()
+	if p.consentPrompts == nil {
+		p.consentPrompts = map[string]time.Time{}
+	}
+	if now.Sub(p.consentPrompts[post.UserId]) < consentPromptInterval {
+		p.consentPromptsLock.Unlock()
+		return
+	}
+	p.consentPrompts[post.UserId] = now
+	p.consentPromptsLock.Unlock()
+
+	p.API.SendEphemeralPost(post.UserId, &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: post.ChannelId,
+		RootId:    post.RootId,
+		ParentId:  post.RootId,
+		Props: model.StringInterface{
+			"attachments": []*model.SlackAttachment{{
+				Text:    "Your messages aren't translated until you consent to them being sent to the translation provider.",
+				Actions: []*model.PostAction{newPostAction("Review consent", actionConsent, post.Id)},
+			}},
+		},
+	})
+}
+
+func (p *Plugin) getConsent(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+
+	consent, err := p.getUserConsent(userID)
+	if err != nil {
+		p.API.LogError("Failed to get consent", "user_id", userID, "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to get consent", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	config := p.getConfiguration()
+	status := &ConsentStatus{
+		Required:  config.RequireConsent,
+		Version:   config.getConsentVersion(),
+		Consented: config.isConsentCurrent(consent),
+	}
+	if consent != nil {
+		status.ConsentAt = consent.ConsentAt
+	}
+
+	resp, _ := json.Marshal(status)
+	w.Write(resp)
+}
+
+// submitConsentDialog records the consent of a user who agreed in the consent dialog, turning
+// autotranslation on for them when they asked for it.
+func (p *Plugin) submitConsentDialog(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+
+	var request *model.SubmitDialogRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&request); err != nil || request == nil {
+		writeAPIError(w, newInvalidParameterError("request"))
+		return
+	}
+
+	if request.Cancelled {
+		w.Write([]byte("{}"))
+		return
+	}
+
+	if agreed, _ := request.Submission[consentDialogAgree].(bool); !agreed {
+		resp, _ := json.Marshal(&model.SubmitDialogResponse{
+			Errors: map[string]string{consentDialogAgree: "Your messages can't be translated without your agreement."},
+		})
+		w.Write(resp)
+		return
+	}
+
+	consent, err := p.recordConsent(userID)
+	if err != nil {
+		p.API.LogError("Failed to save consent", "user_id", userID, "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorUnableToSave, Message: "Failed to save consent", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	p.API.LogInfo("User consented to translation", "user_id", userID, "version", consent.Version)
+
+	if request.State == consentDialogStateActivate && p.canTurnTranslationOn(userID, request.ChannelId) {
+		p.activateAfterConsent(userID, request.ChannelId)
+	}
+
+	w.Write([]byte("{}"))
+}
+
+// activateAfterConsent turns autotranslation on for a user who agreed in the consent dialog opened
+// by /autotranslate on, telling them in the channel they ran it in.
+func (p *Plugin) activateAfterConsent(userID, channelID string) {
+	userInfo, apiErr := p.getUserInfo(userID)
+	if apiErr != nil {
+		userInfo = p.NewUserInfo(userID)
+	}
+	userInfo.Activated = true
+
+	response, _ := setUserInfoCommandResponse(userInfo, p.setUserInfo(userInfo), "on")
+	if channelID == "" {
+		return
+	}
+
+	p.API.SendEphemeralPost(userID, &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: channelID,
+		Message:   response.Text,
+	})
+}
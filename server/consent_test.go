@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsConsentCurrent(t *testing.T) {
+	consent := &UserConsent{UserID: "user", Version: "2024-01", ConsentAt: 1}
+
+	for name, test := range map[string]struct {
+		config   *configuration
+		consent  *UserConsent
+		expected bool
+	}{
+		"not required without consent":   {config: &configuration{}, expected: true},
+		"required without consent":       {config: &configuration{RequireConsent: true}, expected: false},
+		"required without version":       {config: &configuration{RequireConsent: true}, consent: &UserConsent{UserID: "user", ConsentAt: 1}, expected: true},
+		"current version":                {config: &configuration{RequireConsent: true, ConsentVersion: "2024-01"}, consent: consent, expected: true},
+		"current version with spaces":    {config: &configuration{RequireConsent: true, ConsentVersion: " 2024-01 "}, consent: consent, expected: true},
+		"version changed":                {config: &configuration{RequireConsent: true, ConsentVersion: "2024-06"}, consent: consent, expected: false},
+		"version changed but not needed": {config: &configuration{ConsentVersion: "2024-06"}, consent: consent, expected: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.config.isConsentCurrent(test.consent))
+		})
+	}
+}
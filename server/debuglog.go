@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"unicode/utf8"
+)
+
+// maxLoggedTextRunes bounds the text logged about a translation, so that debug logs show what was
+// translated without holding whole messages.
+const maxLoggedTextRunes = 80
+
+// redactForLog returns the beginning of a text for debug logs, with mentions and personal data
+// replaced by placeholders whether or not admins require them to be redacted from providers.
+func redactForLog(text string) string {
+	ph := &placeholders{}
+	text = ph.redactPersonalData(ph.maskMentions(text))
+
+	if utf8.RuneCountInString(text) <= maxLoggedTextRunes {
+		return text
+	}
+
+	runes := []rune(text)
+	return string(runes[:maxLoggedTextRunes]) + "…"
+}
+
+// traceTranslation logs a step of the lifecycle of a translation, from the hook receiving a post
+// to the translation being posted, when admins turned debug logging on. Steps carry the request
+// ID of ctx, correlating the steps of a translation with each other and with its errors. They are
+// logged at the info level, so that the server log level doesn't need to be lowered to see them.
+func (p *Plugin) traceTranslation(ctx context.Context, step string, keyValuePairs ...interface{}) {
+	if !p.getConfiguration().DebugLogging {
+		return
+	}
+
+	keyValuePairs = append([]interface{}{"request_id", getRequestID(ctx), "step", step}, keyValuePairs...)
+	p.API.LogInfo("Translation trace", keyValuePairs...)
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactForLog(t *testing.T) {
+	assert.Equal(t, "Hello", redactForLog("Hello"))
+
+	redacted := redactForLog("@alice, mail bob@example.com or call +1 415 555 0100")
+	assert.NotContains(t, redacted, "alice")
+	assert.NotContains(t, redacted, "bob@example.com")
+	assert.NotContains(t, redacted, "555 0100")
+
+	long := redactForLog(strings.Repeat("é", maxLoggedTextRunes+10))
+	assert.Equal(t, maxLoggedTextRunes+1, utf8.RuneCountInString(long))
+	assert.True(t, strings.HasSuffix(long, "…"))
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	// translationsProp holds the translations delivered in place into posts before they were
+	// kept in the KV store.
+	translationsProp    = "autotranslate_translations"
+	originalMessageProp = "autotranslate_original_message"
+
+	// inPlaceTranslationsProp marks the posts of channels delivering translations in place.
+	inPlaceTranslationsProp = "autotranslate_in_place"
+
+	storedTranslationsKeyPrefix = "posttranslations_"
+
+	// maxStoreTranslationAttempts bounds the retries of storing the translation of a post while
+	// translations of the post into other languages are stored concurrently.
+	maxStoreTranslationAttempts = 5
+
+	// mergedTranslationSeparator separates the original message of a post from the translation
+	// merged into it.
+	mergedTranslationSeparator = "\n\n---\n"
+
+	wsEventTranslationUpdate = "translation_update"
+)
+
+// deliverTranslation delivers the translation of a post according to the delivery mode of its
+// channel, reporting whether it was delivered. Posting a separate translation post is the default
+// and the fallback of every mode.
+func (p *Plugin) deliverTranslation(post *model.Post, userInfo *UserInfo, translatedMessage string, translatedAttachments []*model.SlackAttachment) (delivered bool) {
+	deliveryMode := deliveryModePost
+	if channelInfo, _ := p.getChannelInfo(post.ChannelId); channelInfo != nil {
+		deliveryMode = channelInfo.getDeliveryMode()
+	}
+
+	defer func() {
+		if delivered {
+			p.trackTranslation(telemetryTriggerAuto, deliveryMode, providerAWS)
+		}
+	}()
+
+	text := translatedMessage
+	if text == "" {
+		text = getAttachmentsText(translatedAttachments)
+	}
+
+	switch deliveryMode {
+	case deliveryModeProps:
+		err := p.storeTranslation(post, userInfo.SourceLanguage, userInfo.TargetLanguage, text)
+		if err == nil {
+			return true
+		}
+		p.API.LogError("Failed to store translation", "post_id", post.Id, "err", err.Error())
+	case deliveryModeMerge:
+		appErr := p.mergeTranslation(post.Id, userInfo.SourceLanguage, userInfo.TargetLanguage, text)
+		if appErr == nil {
+			return true
+		}
+		p.API.LogError("Failed to merge translation into post", "post_id", post.Id, "err", appErr.Error())
+	case deliveryModeThread:
+		return p.createTranslationPost(post, userInfo, getThreadRootID(post), translatedMessage, translatedAttachments) != nil
+	}
+
+	return p.createTranslationPost(post, userInfo, post.RootId, translatedMessage, translatedAttachments) != nil
+}
+
+// deliversTranslationPosts reports whether translations are delivered as separate posts in a
+// channel, rather than stored in or merged into the original post.
+func (p *Plugin) deliversTranslationPosts(channelID string) bool {
+	channelInfo, _ := p.getChannelInfo(channelID)
+	if channelInfo == nil {
+		return true
+	}
+
+	deliveryMode := channelInfo.getDeliveryMode()
+	return deliveryMode == deliveryModePost || deliveryMode == deliveryModeThread
+}
+
+// createTranslationPost posts a translation, returning the created post or nil on failure.
+func (p *Plugin) createTranslationPost(post *model.Post, userInfo *UserInfo, rootID, translatedMessage string, translatedAttachments []*model.SlackAttachment) *model.Post {
+	translatedAttachments = addTranslationHeader(userInfo, translatedMessage, translatedAttachments)
+
+	// Mentions of the original post already notified their users, so the translation only
+	// notifies them again when configured to.
+	if !p.getConfiguration().MentionNotifications {
+		for _, attachment := range translatedAttachments {
+			attachment.Pretext = silenceMentions(attachment.Pretext)
+			attachment.Text = silenceMentions(attachment.Text)
+		}
+	}
+
+	addShowOriginalAction(post.Id, translatedAttachments)
+	addFeedbackActions(post.Id, userInfo, p.getPairProvider(post.Id, userInfo.SourceLanguage, userInfo.TargetLanguage, utf8.RuneCountInString(post.Message)), translatedAttachments)
+
+	translationPost := p.newTranslationPost(post, userInfo, rootID)
+	model.ParseSlackAttachment(translationPost, translatedAttachments)
+
+	translationPost, appErr := p.API.CreatePost(translationPost)
+	if appErr != nil {
+		p.API.LogError("Failed to create translation post", "post_id", post.Id, "err", appErr.Error())
+		return nil
+	}
+
+	return translationPost
+}
+
+// StoredTranslation is a translation of a post delivered in place, toggled by clients between the
+// original and the translation
+type StoredTranslation struct {
+	SourceLanguage string `json:"source_language"`
+	TranslatedText string `json:"translated_text"`
+	CreateAt       int64  `json:"create_at"`
+}
+
+func getStoredTranslationsKey(postID string) string {
+	return storedTranslationsKeyPrefix + postID
+}
+
+// markInPlaceTranslationPost marks a post about to be posted in a channel delivering translations
+// in place, so that clients know to fetch its translations, which are kept in the KV store rather
+// than in the post to leave it untouched.
+func (p *Plugin) markInPlaceTranslationPost(post *model.Post) *model.Post {
+	if isNoTranslatePost(post) {
+		return post
+	}
+
+	if channelInfo, _ := p.getChannelInfo(post.ChannelId); channelInfo != nil && channelInfo.getDeliveryMode() == deliveryModeProps {
+		post.AddProp(inPlaceTranslationsProp, true)
+	}
+
+	return post
+}
+
+// getStoredTranslations returns the translations of a post delivered in place, keyed by target
+// language, decrypted from the value stored in the KV store.
+func (p *Plugin) getStoredTranslations(value []byte) (map[string]*StoredTranslation, error) {
+	translations := map[string]*StoredTranslation{}
+	if value == nil {
+		return translations, nil
+	}
+
+	decrypted, err := decryptValue(p.getConfiguration().EncryptionKey, value)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decrypt stored translations")
+	}
+
+	if err := json.Unmarshal(decrypted, &translations); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal stored translations")
+	}
+
+	return translations, nil
+}
+
+// getPostStoredTranslations returns the translations of a post delivered in place, keyed by target
+// language.
+func (p *Plugin) getPostStoredTranslations(postID string) (map[string]*StoredTranslation, error) {
+	value, appErr := p.API.KVGet(getStoredTranslationsKey(postID))
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return p.getStoredTranslations(value)
+}
+
+// storeTranslation saves a translation delivered in place in the KV store, keyed by target
+// language, so that clients can toggle between the original and the translation. Translations
+// into other languages may be stored at the same time, so they are only replaced if they didn't
+// change.
+func (p *Plugin) storeTranslation(post *model.Post, source, target, text string) error {
+	key := getStoredTranslationsKey(post.Id)
+	for attempt := 0; attempt < maxStoreTranslationAttempts; attempt++ {
+		oldBytes, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return appErr
+		}
+
+		// Translations stored with a former encryption key can't be read anymore, so they are
+		// replaced.
+		translations, err := p.getStoredTranslations(oldBytes)
+		if err != nil {
+			p.API.LogWarn("Replacing unreadable stored translations", "post_id", post.Id, "err", err.Error())
+			translations = map[string]*StoredTranslation{}
+		}
+		translations[target] = &StoredTranslation{SourceLanguage: source, TranslatedText: text, CreateAt: model.GetMillis()}
+
+		newBytes, err := json.Marshal(translations)
+		if err != nil {
+			return errors.Wrap(err, "unable to marshal stored translations")
+		}
+		if newBytes, err = encryptValue(p.getConfiguration().EncryptionKey, newBytes); err != nil {
+			return errors.Wrap(err, "unable to encrypt stored translations")
+		}
+
+		updated, appErr := p.API.KVCompareAndSet(key, oldBytes, newBytes)
+		if appErr != nil {
+			return appErr
+		}
+		if updated {
+			p.API.PublishWebSocketEvent(
+				wsEventTranslationUpdate,
+				map[string]interface{}{
+					"post_id":         post.Id,
+					"source_language": source,
+					"target_language": target,
+					"translated_text": text,
+				},
+				&model.WebsocketBroadcast{ChannelId: post.ChannelId},
+			)
+
+			return nil
+		}
+	}
+
+	return errors.New("stored translations kept changing concurrently")
+}
+
+// mergeTranslation appends a translation to the message of the translated post below a separator,
+// keeping the original message in props so that it can be restored. Posts merged with a
+// translation already keep their original message, while the message of others is the original.
+func (p *Plugin) mergeTranslation(postID, source, target, text string) *model.AppError {
+	post, appErr := p.API.GetPost(postID)
+	if appErr != nil {
+		return appErr
+	}
+
+	original, ok := post.GetProp(originalMessageProp).(string)
+	if !ok || (post.Message != original && !strings.HasPrefix(post.Message, original+mergedTranslationSeparator)) {
+		original = post.Message
+		post.AddProp(originalMessageProp, original)
+	}
+
+	post.Message = formatMergedTranslation(original, source, target, text)
+	post.AddProp(translationSourceLanguageProp, source)
+	post.AddProp(translationTargetLanguageProp, target)
+
+	_, appErr = p.API.UpdatePost(post)
+	return appErr
+}
+
+func formatMergedTranslation(original, source, target, text string) string {
+	return truncateMessage(fmt.Sprintf("%s%s*%s*\n%s", original, mergedTranslationSeparator, getTranslationHeader(source, target), text))
+}
+
+// refreshOriginalMessage keeps the original message kept in the props of a post merged with its
+// translation up to date when the post is edited, so that the original shown and restored isn't
+// the one before the edit. The translation merged below the original is told apart by comparing
+// the edited message with the one before, and dropped when the original was edited, to be merged
+// again once the edit is committed. Updates leaving the original as is, such as the plugin
+// merging a translation, and rewritten posts, whose message is the translation, are left alone,
+// while annotated posts keep their translation as edits aren't intercepted.
+func refreshOriginalMessage(newPost, oldPost *model.Post) *model.Post {
+	original, ok := oldPost.GetProp(originalMessageProp).(string)
+	if !ok || newPost.Message == oldPost.Message {
+		return newPost
+	}
+
+	if oldPost.Message != original && !strings.HasPrefix(oldPost.Message, original+mergedTranslationSeparator) {
+		return newPost
+	}
+
+	if newPost.Message == original || strings.HasPrefix(newPost.Message, original+mergedTranslationSeparator) {
+		return newPost
+	}
+
+	edited := newPost.Message
+	if merged := strings.TrimPrefix(oldPost.Message, original); merged != "" && strings.HasSuffix(edited, merged) {
+		edited = strings.TrimSuffix(edited, merged)
+	}
+
+	newPost.AddProp(originalMessageProp, edited)
+	if newPost.GetProp(interceptedProp) == nil {
+		newPost.Message = edited
+	}
+
+	return newPost
+}
+
+// translateEditedMergedPost translates again the posts of channels merging translations into
+// posts whose original message was edited, which refreshOriginalMessage left without their stale
+// translation.
+func (p *Plugin) translateEditedMergedPost(newPost, oldPost *model.Post) {
+	original, ok := newPost.GetProp(originalMessageProp).(string)
+	if !ok || newPost.Message != original || newPost.Message == oldPost.Message || newPost.GetProp(interceptedProp) != nil {
+		return
+	}
+
+	if !p.getConfiguration().TranslateMessages || isNoTranslatePost(newPost) || !p.shouldTranslatePost(newPost) || !hasTranslatableText(newPost.Message) {
+		return
+	}
+
+	channelInfo, _ := p.getChannelInfo(newPost.ChannelId)
+	if channelInfo == nil || channelInfo.getDeliveryMode() != deliveryModeMerge || !p.isProviderAllowed(newPost.ChannelId, providerAWS) {
+		return
+	}
+
+	userInfo, apiErr := p.getUserInfo(newPost.UserId)
+	if apiErr != nil || !userInfo.Activated || !p.isTranslatedForAuthor(newPost.ChannelId, userInfo) || !p.hasConsent(newPost.UserId) {
+		return
+	}
+
+	p.translatePosts([]*model.Post{newPost}, userInfo)
+}
+
+// addTranslationHeader returns the attachments of a translation post, starting with one holding
+// the translated message if any, with the translation header in the pretext of the first one.
+func addTranslationHeader(userInfo *UserInfo, translatedMessage string, translatedAttachments []*model.SlackAttachment) []*model.SlackAttachment {
+	header := getTranslationHeader(userInfo.SourceLanguage, userInfo.TargetLanguage)
+	if translatedMessage != "" {
+		return append([]*model.SlackAttachment{{Pretext: header, Text: translatedMessage}}, translatedAttachments...)
+	}
+
+	if translatedAttachments[0].Pretext != "" {
+		translatedAttachments[0].Pretext = header + "\n" + translatedAttachments[0].Pretext
+	} else {
+		translatedAttachments[0].Pretext = header
+	}
+
+	return translatedAttachments
+}
+
+func getAttachmentsText(attachments []*model.SlackAttachment) string {
+	var parts []string
+	for _, attachment := range attachments {
+		for _, text := range []string{attachment.Pretext, attachment.Title, attachment.Text} {
+			if strings.TrimSpace(text) != "" {
+				parts = append(parts, text)
+			}
+		}
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// getThreadRootID returns the ID of the thread a post belongs to, starting a new thread on the
+// post itself when it isn't a reply.
+func getThreadRootID(post *model.Post) string {
+	if post.RootId != "" {
+		return post.RootId
+	}
+
+	return post.Id
+}
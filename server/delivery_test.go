@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+)
+
+func TestRefreshOriginalMessage(t *testing.T) {
+	merged := formatMergedTranslation("Hola", "es", "en", "Hello")
+	translation := merged[len("Hola"):]
+
+	for name, test := range map[string]struct {
+		oldMessage       string
+		newMessage       string
+		intercepted      bool
+		expectedMessage  string
+		expectedOriginal string
+	}{
+		"translation merged":     {oldMessage: "Hola", newMessage: merged, expectedMessage: merged, expectedOriginal: "Hola"},
+		"translation edited":     {oldMessage: merged, newMessage: "Hola" + mergedTranslationSeparator + "Hi", expectedMessage: "Hola" + mergedTranslationSeparator + "Hi", expectedOriginal: "Hola"},
+		"original edited":        {oldMessage: merged, newMessage: "Hola amigos" + translation, expectedMessage: "Hola amigos", expectedOriginal: "Hola amigos"},
+		"whole message replaced": {oldMessage: merged, newMessage: "Adiós", expectedMessage: "Adiós", expectedOriginal: "Adiós"},
+		"annotated post edited":  {oldMessage: merged, newMessage: "Hola amigos" + translation, intercepted: true, expectedMessage: "Hola amigos" + translation, expectedOriginal: "Hola amigos"},
+		"rewritten post edited":  {oldMessage: "Hello", newMessage: "Hello friends", intercepted: true, expectedMessage: "Hello friends", expectedOriginal: "Hola"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			oldPost := &model.Post{Message: test.oldMessage}
+			oldPost.AddProp(originalMessageProp, "Hola")
+			newPost := &model.Post{Message: test.newMessage}
+			newPost.AddProp(originalMessageProp, "Hola")
+			if test.intercepted {
+				oldPost.AddProp(interceptedProp, true)
+				newPost.AddProp(interceptedProp, true)
+			}
+
+			newPost = refreshOriginalMessage(newPost, oldPost)
+			assert.Equal(t, test.expectedMessage, newPost.Message)
+			assert.Equal(t, test.expectedOriginal, newPost.GetProp(originalMessageProp))
+		})
+	}
+}
+
+func TestDeliverTranslationFallback(t *testing.T) {
+	userInfo := &UserInfo{UserID: model.NewId(), Activated: true, SourceLanguage: "ko", TargetLanguage: "en"}
+	appErr := model.NewAppError("KVGet", "plugin.kv.get.app_error", nil, "", http.StatusInternalServerError)
+
+	setup := func(deliveryMode string) (*plugintest.API, *Plugin, *model.Post) {
+		post := &model.Post{Id: model.NewId(), UserId: userInfo.UserID, ChannelId: model.NewId(), Message: "안녕하세요"}
+		channelInfo, _ := json.Marshal(&ChannelInfo{ChannelID: post.ChannelId, DeliveryMode: deliveryMode})
+
+		api := &plugintest.API{}
+		api.On("KVGet", channelInfoKeyPrefix+post.ChannelId).Return(channelInfo, nil)
+
+		p := &Plugin{botUserID: model.NewId()}
+		p.SetAPI(api)
+		p.setConfiguration(&configuration{})
+
+		return api, p, post
+	}
+
+	isTranslationPostOf := func(post *model.Post, rootID string) interface{} {
+		return mock.MatchedBy(func(translationPost *model.Post) bool {
+			return translationPost.GetProp(translationSourcePostIDProp) == post.Id && translationPost.RootId == rootID
+		})
+	}
+
+	t.Run("stored translation falls back to a translation post", func(t *testing.T) {
+		api, p, post := setup(deliveryModeProps)
+		api.On("KVGet", getStoredTranslationsKey(post.Id)).Return(nil, appErr)
+		api.On("LogError", "Failed to store translation", "post_id", post.Id, "err", mock.Anything)
+		api.On("CreatePost", isTranslationPostOf(post, "")).Return(&model.Post{Id: model.NewId()}, nil)
+
+		assert.True(t, p.deliverTranslation(post, userInfo, "Hello", nil))
+		api.AssertExpectations(t)
+	})
+
+	t.Run("merged translation falls back to a translation post", func(t *testing.T) {
+		api, p, post := setup(deliveryModeMerge)
+		api.On("GetPost", post.Id).Return(nil, appErr)
+		api.On("LogError", "Failed to merge translation into post", "post_id", post.Id, "err", mock.Anything)
+		api.On("CreatePost", isTranslationPostOf(post, "")).Return(&model.Post{Id: model.NewId()}, nil)
+
+		assert.True(t, p.deliverTranslation(post, userInfo, "Hello", nil))
+		api.AssertNotCalled(t, "UpdatePost", mock.Anything)
+		api.AssertExpectations(t)
+	})
+
+	t.Run("thread mode replies to the post", func(t *testing.T) {
+		api, p, post := setup(deliveryModeThread)
+		api.On("CreatePost", isTranslationPostOf(post, post.Id)).Return(&model.Post{Id: model.NewId()}, nil)
+
+		assert.True(t, p.deliverTranslation(post, userInfo, "Hello", nil))
+		api.AssertExpectations(t)
+	})
+
+	t.Run("failed translation post isn't delivered", func(t *testing.T) {
+		api, p, post := setup(deliveryModePost)
+		api.On("CreatePost", isTranslationPostOf(post, "")).Return(nil, appErr)
+		api.On("LogError", "Failed to create translation post", "post_id", post.Id, "err", mock.Anything)
+
+		assert.False(t, p.deliverTranslation(post, userInfo, "Hello", nil))
+		api.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"regexp"
+	"unicode"
+)
+
+// scriptLanguages maps Unicode scripts written by a single supported language to its code.
+var scriptLanguages = []struct {
+	script   *unicode.RangeTable
+	language string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Thai, "th"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Armenian, "hy"},
+	{unicode.Georgian, "ka"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Bengali, "bn"},
+	{unicode.Gujarati, "gu"},
+	{unicode.Gurmukhi, "pa"},
+	{unicode.Tamil, "ta"},
+	{unicode.Telugu, "te"},
+	{unicode.Kannada, "kn"},
+	{unicode.Malayalam, "ml"},
+	{unicode.Sinhala, "si"},
+	{unicode.Ethiopic, "am"},
+}
+
+// detectLanguage makes a cheap guess of the language of text from the scripts it is written in
+// and, for the Latin script, from the trigrams of its words. It returns an empty string when the
+// language can't be told apart with confidence.
+func detectLanguage(text string) string {
+	language, _ := detectLanguageConfidence(text)
+	return language
+}
+
+// detectLanguageConfidence guesses the language of text like detectLanguage, along with the
+// confidence of the guess between 0 and 1, being the share of letters written in the script of
+// the language, lowered by the likelihood of other languages for the Latin script.
+func detectLanguageConfidence(text string) (string, float64) {
+	text = untranslatablePattern.ReplaceAllString(text, " ")
+
+	letters := 0
+	kana := 0
+	han := 0
+	latin := 0
+	scripts := make([]int, len(scriptLanguages))
+
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		default:
+			for i, scriptLanguage := range scriptLanguages {
+				if unicode.Is(scriptLanguage.script, r) {
+					scripts[i]++
+					break
+				}
+			}
+		}
+	}
+
+	if letters == 0 {
+		return "", 0
+	}
+
+	share := func(count int) float64 {
+		return float64(count) / float64(letters)
+	}
+
+	switch {
+	case kana > 0 && (kana+han)*2 > letters:
+		return "ja", share(kana + han)
+	case han*2 > letters:
+		return "zh", share(han)
+	case latin*2 > letters:
+		language, confidence := detectNgramLanguage(text)
+		return language, confidence * share(latin)
+	}
+
+	for i, count := range scripts {
+		if count*2 > letters {
+			return scriptLanguages[i].language, share(count)
+		}
+	}
+
+	return "", 0
+}
+
+// untranslatablePattern matches the parts of messages which are never translated: links,
+// mentions, channel references, emojis and inline code.
+var untranslatablePattern = regexp.MustCompile("https?://\\S+|[@~][\\w.\\-]+|:[\\w+\\-]+:|`[^`]*`")
+
+// hasTranslatableText reports whether text has any words to translate, so that messages made only
+// of links, mentions or emojis never reach the provider.
+func hasTranslatableText(text string) bool {
+	for _, r := range untranslatablePattern.ReplaceAllString(text, " ") {
+		if unicode.IsLetter(r) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectLanguageConfidence(t *testing.T) {
+	for name, tc := range map[string]struct {
+		text          string
+		language      string
+		minConfidence float64
+		maxConfidence float64
+	}{
+		"korean": {
+			text:          "안녕하세요, 만나서 반갑습니다",
+			language:      "ko",
+			minConfidence: 1,
+			maxConfidence: 1,
+		},
+		"japanese": {
+			text:          "今日はとても良い天気ですね",
+			language:      "ja",
+			minConfidence: 1,
+			maxConfidence: 1,
+		},
+		"chinese": {
+			text:          "我们明天在会议室见面",
+			language:      "zh",
+			minConfidence: 1,
+			maxConfidence: 1,
+		},
+		"mixed scripts lower the confidence": {
+			text:          "안녕하세요 반갑습니다 hi",
+			language:      "ko",
+			minConfidence: 0.8,
+			maxConfidence: 0.85,
+		},
+		"english": {
+			text:          "The meeting is moved to the afternoon because of the holiday",
+			language:      "en",
+			minConfidence: 0.3,
+			maxConfidence: 1,
+		},
+		"too short to tell": {
+			text: "ok",
+		},
+		"no letters": {
+			text: "12:30 :) 👍",
+		},
+		"unknown script": {
+			text: "Привет, как дела?",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			language, confidence := detectLanguageConfidence(tc.text)
+			assert.Equal(t, tc.language, language)
+			assert.GreaterOrEqual(t, confidence, tc.minConfidence)
+			assert.LessOrEqual(t, confidence, tc.maxConfidence)
+		})
+	}
+}
+
+func TestDetectedLanguageReaches(t *testing.T) {
+	threshold := (&configuration{}).getDetectionConfidenceThreshold()
+	for name, tc := range map[string]struct {
+		text      string
+		threshold float64
+		reaches   bool
+	}{
+		"short message": {
+			text:      "ok",
+			threshold: threshold,
+			reaches:   false,
+		},
+		"ambiguous word": {
+			text:      "si",
+			threshold: threshold,
+			reaches:   false,
+		},
+		"sentence": {
+			text:      "Nous allons nous retrouver demain matin dans la salle de réunion",
+			threshold: threshold,
+			reaches:   true,
+		},
+		"script": {
+			text:      "안녕하세요",
+			threshold: threshold,
+			reaches:   true,
+		},
+		"no threshold": {
+			text:      "ok",
+			threshold: 0,
+			reaches:   true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			language, confidence := detectLanguageConfidence(tc.text)
+			detected := &DetectedLanguage{Language: language, Confidence: confidence}
+			assert.Equal(t, tc.reaches, detected.reaches(tc.threshold))
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/service/comprehend"
+	"github.com/aws/aws-sdk-go/service/translate"
+)
+
+// Names of the language detectors, as chosen with the Language Detector setting.
+const (
+	detectorLocal      = "local"
+	detectorProvider   = "provider"
+	detectorComprehend = "comprehend"
+
+	defaultLanguageDetector = detectorLocal
+)
+
+// minDetectionConfidence is the confidence from which a detected language is trusted, both to
+// stop asking other detectors and to translate from it instead of asking the provider to detect it.
+const minDetectionConfidence = 0.8
+
+// defaultDetectionConfidenceThreshold is the confidence in percent a detected language must reach
+// for messages to be translated automatically from auto, which short messages such as "ok" or "si"
+// don't, as they mean something in many languages.
+const defaultDetectionConfidenceThreshold = 50
+
+// maxDetectTextBytes bounds the text sent to detectors which charge for it, its beginning being
+// enough to tell its language.
+const maxDetectTextBytes = 1000
+
+// DetectedLanguage is the language of a text as guessed by a detector, with an empty language
+// when it can't be told
+type DetectedLanguage struct {
+	Language   string  `json:"language"`
+	Confidence float64 `json:"confidence"`
+	Detector   string  `json:"detector"`
+}
+
+// isConfident reports whether the detected language can be relied on.
+func (d *DetectedLanguage) isConfident() bool {
+	return d.Language != "" && d.Confidence >= minDetectionConfidence
+}
+
+// reaches reports whether the detected language is confident enough for the given threshold, any
+// detection reaching a zero threshold, even when the language couldn't be told.
+func (d *DetectedLanguage) reaches(threshold float64) bool {
+	if threshold <= 0 {
+		return true
+	}
+
+	return d.Language != "" && d.Confidence >= threshold
+}
+
+// LanguageDetector guesses the language of texts, such as to skip the ones already written in the
+// target language, or to translate from the language of a text rather than from auto.
+type LanguageDetector interface {
+	// Name identifies the detector in detection results.
+	Name() string
+
+	// Detect returns the language of text along with the confidence of the guess between 0 and 1.
+	Detect(ctx context.Context, text string) (*DetectedLanguage, error)
+}
+
+// localDetector guesses languages from their scripts and frequent words without any request,
+// which is free but can't tell apart many languages.
+type localDetector struct{}
+
+func (localDetector) Name() string {
+	return detectorLocal
+}
+
+func (localDetector) Detect(ctx context.Context, text string) (*DetectedLanguage, error) {
+	language, confidence := detectLanguageConfidence(text)
+	return &DetectedLanguage{Language: language, Confidence: confidence, Detector: detectorLocal}, nil
+}
+
+// providerDetector asks the translation provider for the language it detects when translating
+// into English, which is charged like any translation.
+type providerDetector struct {
+	p *Plugin
+}
+
+func (providerDetector) Name() string {
+	return detectorProvider
+}
+
+func (d providerDetector) Detect(ctx context.Context, text string) (*DetectedLanguage, error) {
+	svc, err := d.p.getTranslateService()
+	if err != nil {
+		return nil, err
+	}
+
+	text = d.p.redactForProvider(splitText(text, maxDetectTextBytes)[0].text)
+	source := autoLanguage
+	target := enLanguage
+	input := translate.TextInput{
+		SourceLanguageCode: &source,
+		TargetLanguageCode: &target,
+		Text:               &text,
+	}
+
+	start := time.Now()
+	output, err := svc.TextWithContext(ctx, &input)
+	d.p.recordUsage(ctx, providerAWS, utf8.RuneCountInString(text), time.Since(start), err)
+	if err != nil {
+		return nil, newProviderError(ctx, err)
+	}
+
+	// The provider doesn't tell how confident it is, but is trusted over local guesses.
+	language := ""
+	if output.SourceLanguageCode != nil {
+		language = *output.SourceLanguageCode
+	}
+
+	return &DetectedLanguage{Language: language, Confidence: 1, Detector: detectorProvider}, nil
+}
+
+// comprehendDetector asks Amazon Comprehend for the dominant language of texts, which tells how
+// confident it is and is cheaper than translating them.
+type comprehendDetector struct {
+	p *Plugin
+}
+
+func (comprehendDetector) Name() string {
+	return detectorComprehend
+}
+
+func (d comprehendDetector) Detect(ctx context.Context, text string) (*DetectedLanguage, error) {
+	svc, err := d.p.getComprehendService()
+	if err != nil {
+		return nil, err
+	}
+
+	text = d.p.redactForProvider(splitText(text, maxDetectTextBytes)[0].text)
+	output, err := svc.DetectDominantLanguageWithContext(ctx, &comprehend.DetectDominantLanguageInput{Text: &text})
+	if err != nil {
+		return nil, newProviderError(ctx, err)
+	}
+
+	detected := &DetectedLanguage{Detector: detectorComprehend}
+	for _, language := range output.Languages {
+		if language.LanguageCode == nil || language.Score == nil || *language.Score <= detected.Confidence {
+			continue
+		}
+
+		detected.Language = *language.LanguageCode
+		detected.Confidence = *language.Score
+	}
+
+	return detected, nil
+}
+
+// chainDetector asks its detectors in turn until one is confident, returning the most confident
+// guess otherwise. Cheaper detectors come first.
+type chainDetector []LanguageDetector
+
+func (c chainDetector) Name() string {
+	var names []string
+	for _, detector := range c {
+		names = append(names, detector.Name())
+	}
+
+	return strings.Join(names, ",")
+}
+
+func (c chainDetector) Detect(ctx context.Context, text string) (*DetectedLanguage, error) {
+	var best *DetectedLanguage
+	var lastErr error
+	for _, detector := range c {
+		detected, err := detector.Detect(ctx, text)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if best == nil || detected.Confidence > best.Confidence {
+			best = detected
+		}
+		if best.isConfident() {
+			break
+		}
+	}
+
+	if best == nil {
+		return nil, lastErr
+	}
+
+	return best, nil
+}
+
+// getLanguageDetector returns the language detector chosen in the configuration.
+func (p *Plugin) getLanguageDetector() LanguageDetector {
+	switch p.getConfiguration().getLanguageDetector() {
+	case detectorProvider:
+		return chainDetector{localDetector{}, providerDetector{p}}
+	case detectorComprehend:
+		return chainDetector{localDetector{}, comprehendDetector{p}}
+	default:
+		return localDetector{}
+	}
+}
+
+// detectTextLanguage guesses the language of text with the configured detector. Failures are only
+// logged, the language being unknown then, as detection mustn't get in the way of translating.
+func (p *Plugin) detectTextLanguage(ctx context.Context, text string) *DetectedLanguage {
+	detector := p.getLanguageDetector()
+
+	// Texts of channels the detection provider may not receive are only detected locally, as are
+	// texts whose context doesn't tell what they come from.
+	if provider := p.getConfiguration().getDetectionProvider(); provider != "" {
+		if subject := getAuditSubject(ctx); subject == nil || !p.isProviderAllowed(subject.channelID, provider) {
+			detector = localDetector{}
+		}
+	}
+	detected, err := detector.Detect(ctx, text)
+	if err != nil {
+		p.API.LogWarn("Failed to detect language", "request_id", getRequestID(ctx), "detector", detector.Name(), "err", err.Error())
+		return &DetectedLanguage{Detector: detector.Name()}
+	}
+
+	return detected
+}
+
+// resolveSourceLanguage returns the language to translate text from, which is its detected
+// language when the source is auto and the detection is confident, sparing the provider from
+// detecting it again.
+func resolveSourceLanguage(source string, detected *DetectedLanguage) string {
+	if source != autoLanguage || !detected.isConfident() || languageCodes[detected.Language] == "" {
+		return source
+	}
+
+	return detected.Language
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"runtime"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	// maxRecentErrors bounds the errors of translation providers kept for the diagnostics.
+	maxRecentErrors = 20
+
+	redactedSetting  = "[redacted]"
+	redactedPassword = "xxxxx"
+)
+
+// diagnosticsSecretSettings are the settings holding credentials, redacted from the diagnostics
+// when set. Settings referring to a secret store are redacted as well, as the secrets read from
+// the store replace them in the effective configuration.
+var diagnosticsSecretSettings = []string{
+	"AWSAccessKeyID",
+	"AWSSecretAccessKey",
+	"AWSClientKey",
+	"TracingHeaders",
+	"APISharedSecret",
+	"WebhookToken",
+	"WebhookSigningSecrets",
+	"EncryptionKey",
+}
+
+// diagnosticsURLSettings are the settings holding URLs, whose password is redacted from the
+// diagnostics.
+var diagnosticsURLSettings = []string{
+	"AWSEndpoint",
+	"OfflineEndpoint",
+	"OutboundProxy",
+	"TracingEndpoint",
+}
+
+// RecentError is an error of a translation provider kept for the diagnostics
+type RecentError struct {
+	CreateAt int64  `json:"create_at"`
+	Provider string `json:"provider"`
+	Message  string `json:"message"`
+}
+
+// errorLog keeps the last errors of translation providers on this server.
+type errorLog struct {
+	lock   sync.Mutex
+	errors []*RecentError
+}
+
+func (l *errorLog) add(entry *RecentError) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.errors = append(l.errors, entry)
+	if len(l.errors) > maxRecentErrors {
+		l.errors = l.errors[len(l.errors)-maxRecentErrors:]
+	}
+}
+
+// list returns the errors kept, the most recent first.
+func (l *errorLog) list() []*RecentError {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	list := make([]*RecentError, 0, len(l.errors))
+	for i := len(l.errors) - 1; i >= 0; i-- {
+		list = append(list, l.errors[i])
+	}
+
+	return list
+}
+
+// DiagnosticsVersions is the version of the plugin and of what it runs on
+type DiagnosticsVersions struct {
+	Plugin string `json:"plugin"`
+	Server string `json:"server"`
+	Go     string `json:"go"`
+}
+
+// DiagnosticsQueues is the work waiting on this server
+type DiagnosticsQueues struct {
+	PendingPosts        int   `json:"pending_posts"`
+	PendingAuditEntries int   `json:"pending_audit_entries"`
+	PendingSpans        int   `json:"pending_spans"`
+	DroppedSpans        int64 `json:"dropped_spans"`
+}
+
+// Diagnostics is what support needs to know about the plugin to investigate an issue, without
+// its secrets
+type Diagnostics struct {
+	CreateAt           int64                  `json:"create_at"`
+	Versions           *DiagnosticsVersions   `json:"versions"`
+	ConfigurationValid bool                   `json:"configuration_valid"`
+	ConfigurationError string                 `json:"configuration_error,omitempty"`
+	Configuration      map[string]interface{} `json:"configuration"`
+	Provider           *ProviderProbe         `json:"provider,omitempty"`
+	RecentErrors       []*RecentError         `json:"recent_errors"`
+	Queues             *DiagnosticsQueues     `json:"queues"`
+}
+
+// getRedactedConfiguration returns the settings of a configuration keyed by name, without the
+// secrets and the passwords of URLs.
+func getRedactedConfiguration(c *configuration) (map[string]interface{}, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal configuration")
+	}
+
+	settings := map[string]interface{}{}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal configuration")
+	}
+
+	for _, name := range diagnosticsSecretSettings {
+		if value, ok := settings[name].(string); ok && value != "" {
+			settings[name] = redactedSetting
+		}
+	}
+
+	for _, name := range diagnosticsURLSettings {
+		value, ok := settings[name].(string)
+		if !ok || value == "" {
+			continue
+		}
+
+		u, err := url.Parse(value)
+		if err != nil {
+			// An invalid URL may still hold a password, which can't be told apart.
+			settings[name] = redactedSetting
+			continue
+		}
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), redactedPassword)
+			settings[name] = u.String()
+		}
+	}
+
+	return settings, nil
+}
+
+// recordError keeps an error of a translation provider for the diagnostics.
+func (p *Plugin) recordError(provider string, err error) {
+	p.recentErrors.add(&RecentError{
+		CreateAt: model.GetMillis(),
+		Provider: provider,
+		Message:  err.Error(),
+	})
+}
+
+// countPendingAuditEntries returns the number of audit entries waiting to be saved.
+func (p *Plugin) countPendingAuditEntries() int {
+	p.auditLock.Lock()
+	defer p.auditLock.Unlock()
+
+	return len(p.auditEntries)
+}
+
+// getDiagnostics assembles the diagnostics of this server. The provider isn't probed, the health
+// saved by the last background probe being reported instead.
+func (p *Plugin) getDiagnostics() (*Diagnostics, error) {
+	settings, err := getRedactedConfiguration(p.getConfiguration())
+	if err != nil {
+		return nil, err
+	}
+
+	diagnostics := &Diagnostics{
+		CreateAt: model.GetMillis(),
+		Versions: &DiagnosticsVersions{
+			Plugin: manifest.Version,
+			Server: p.API.GetServerVersion(),
+			Go:     runtime.Version(),
+		},
+		ConfigurationValid: true,
+		Configuration:      settings,
+		RecentErrors:       p.recentErrors.list(),
+		Queues: &DiagnosticsQueues{
+			PendingPosts:        p.countPendingPosts(),
+			PendingAuditEntries: p.countPendingAuditEntries(),
+		},
+	}
+
+	if configErr := p.IsValid(); configErr != nil {
+		diagnostics.ConfigurationValid = false
+		diagnostics.ConfigurationError = configErr.Error()
+	}
+
+	diagnostics.Provider, err = p.getProviderHealth()
+	if err != nil || diagnostics.Provider == nil {
+		diagnostics.Provider = p.getLastProviderProbe()
+	}
+
+	diagnostics.Queues.PendingSpans, diagnostics.Queues.DroppedSpans = p.spans.count()
+
+	return diagnostics, nil
+}
+
+func (p *Plugin) getDiagnosticsHandler(w http.ResponseWriter, r *http.Request) {
+	diagnostics, err := p.getDiagnostics()
+	if err != nil {
+		p.API.LogError("Failed to get diagnostics", "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to get diagnostics", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	resp, _ := json.MarshalIndent(diagnostics, "", "  ")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"autotranslate-diagnostics.json\"")
+	w.Write(resp)
+}
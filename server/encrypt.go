@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// encryptedValuePrefix marks the values of the KV store encrypted with AES-256-GCM, telling them
+// apart from the ones stored in plain text before an encryption key was set.
+var encryptedValuePrefix = []byte("enc1:")
+
+// encryptionKeyContext is hashed along with the encryption key, so that the derived key is only
+// ever used by the plugin even if the secret is shared with other systems.
+const encryptionKeyContext = "mattermost-plugin-autotranslate/kv"
+
+// newValueCipher returns the AES-256-GCM cipher of the key derived from a secret.
+func newValueCipher(secret string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(encryptionKeyContext + ":" + secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create cipher")
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// encryptValue encrypts a value to be stored with the key derived from a secret, the value being
+// stored as is when there is no secret. Only the values holding the text of messages, the cached
+// translations and the translations delivered in place, are encrypted, as credentials are read from
+// the configuration and never written to the KV store.
+func encryptValue(secret string, value []byte) ([]byte, error) {
+	if secret == "" {
+		return value, nil
+	}
+
+	aead, err := newValueCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrap(err, "unable to generate nonce")
+	}
+
+	encrypted := append([]byte{}, encryptedValuePrefix...)
+	encrypted = append(encrypted, nonce...)
+	return aead.Seal(encrypted, nonce, value, nil), nil
+}
+
+// decryptValue decrypts a value stored by encryptValue. Values stored in plain text are returned
+// as is, while values encrypted with another secret, such as before it was regenerated, can't be
+// read anymore.
+func decryptValue(secret string, value []byte) ([]byte, error) {
+	if !bytes.HasPrefix(value, encryptedValuePrefix) {
+		return value, nil
+	}
+	if secret == "" {
+		return nil, errors.New("value is encrypted but no encryption key is set")
+	}
+
+	aead, err := newValueCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := value[len(encryptedValuePrefix):]
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("encrypted value is too short")
+	}
+
+	decrypted, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decrypt value, which may be encrypted with a former key")
+	}
+
+	return decrypted, nil
+}
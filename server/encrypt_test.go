@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptValue(t *testing.T) {
+	value := []byte(`{"translated_text":"Bonjour"}`)
+
+	stored, err := encryptValue("", value)
+	require.NoError(t, err)
+	assert.Equal(t, value, stored)
+
+	encrypted, err := encryptValue("secret", value)
+	require.NoError(t, err)
+	assert.NotContains(t, string(encrypted), "Bonjour")
+
+	again, err := encryptValue("secret", value)
+	require.NoError(t, err)
+	assert.NotEqual(t, encrypted, again)
+
+	decrypted, err := decryptValue("secret", encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, value, decrypted)
+
+	plain, err := decryptValue("secret", value)
+	require.NoError(t, err)
+	assert.Equal(t, value, plain)
+
+	_, err = decryptValue("other secret", encrypted)
+	assert.Error(t, err)
+
+	_, err = decryptValue("", encrypted)
+	assert.Error(t, err)
+
+	_, err = decryptValue("secret", encryptedValuePrefix)
+	assert.Error(t, err)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxExportDays bounds the date range of an export, a year being enough for chargeback and
+// capacity planning.
+const maxExportDays = 366
+
+// parseExportRange returns the first and last days of the date range of an export, both included,
+// from the from and to parameters. The range defaults to the current month up to today.
+func parseExportRange(from, to string, now time.Time) (time.Time, time.Time, *APIErrorResponse) {
+	now = now.UTC()
+	last := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if to != "" {
+		var err error
+		if last, err = time.Parse(statsDayFormat, to); err != nil {
+			return time.Time{}, time.Time{}, newInvalidParameterError("to")
+		}
+	}
+
+	first := time.Date(last.Year(), last.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if from != "" {
+		var err error
+		if first, err = time.Parse(statsDayFormat, from); err != nil || first.After(last) {
+			return time.Time{}, time.Time{}, newInvalidParameterError("from")
+		}
+	}
+
+	if last.Sub(first) >= maxExportDays*24*time.Hour {
+		return time.Time{}, time.Time{}, newInvalidParameterError("from")
+	}
+
+	return first, last, nil
+}
+
+// formatUsageStatsCSV returns the usage statistics of providers as CSV, one row per provider and
+// day, with their cost estimated from the prices of providers per million characters.
+func formatUsageStatsCSV(stats []*UsageStats, prices map[string]float64) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write([]string{"day", "provider", "requests", "errors", "characters", "estimated_cost", "currency", "latency_p50_ms", "latency_p90_ms", "latency_p99_ms"}); err != nil {
+		return nil, err
+	}
+
+	for _, s := range stats {
+		var cost string
+		if price, ok := prices[s.Provider]; ok {
+			cost = strconv.FormatFloat(float64(s.Characters)*price/1000000, 'f', 2, 64)
+		}
+
+		report := s.toReport()
+		if err := writer.Write([]string{
+			report.Day,
+			report.Provider,
+			strconv.FormatInt(report.Requests, 10),
+			strconv.FormatInt(report.Errors, 10),
+			strconv.FormatInt(report.Characters, 10),
+			cost,
+			spendCurrency,
+			strconv.FormatInt(report.LatencyP50Ms, 10),
+			strconv.FormatInt(report.LatencyP90Ms, 10),
+			strconv.FormatInt(report.LatencyP99Ms, 10),
+		}); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+
+	return buf.Bytes(), writer.Error()
+}
+
+func formatAuditEntriesCSV(entries []*AuditEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write([]string{"create_at", "request_id", "user_id", "post_id", "channel_id", "provider", "source_lang", "target_lang", "characters", "outcome", "error"}); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if err := writer.Write([]string{
+			strconv.FormatInt(entry.CreateAt, 10),
+			entry.RequestID,
+			entry.UserID,
+			entry.PostID,
+			entry.ChannelID,
+			entry.Provider,
+			entry.SourceLanguage,
+			entry.TargetLanguage,
+			strconv.Itoa(entry.Characters),
+			entry.Outcome,
+			entry.Error,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+
+	return buf.Bytes(), writer.Error()
+}
+
+// getUsageStatsBetween returns the saved usage statistics of every provider from the first to
+// the last day, oldest first, skipping days without any request.
+func (p *Plugin) getUsageStatsBetween(first, last time.Time) ([]*UsageStats, error) {
+	var stats []*UsageStats
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		for _, provider := range usageProviders {
+			saved, err := p.getSavedUsageStats(provider, day.Format(statsDayFormat))
+			if err != nil {
+				return nil, err
+			}
+			if saved != nil {
+				stats = append(stats, saved)
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// getAuditEntriesBetween returns the entries saved from the first to the last day, oldest first.
+// Entries older than the retention of the audit log are gone.
+func (p *Plugin) getAuditEntriesBetween(first, last time.Time) ([]*AuditEntry, error) {
+	var entries []*AuditEntry
+	end := last.AddDate(0, 0, 1)
+	for hour := first; hour.Before(end); hour = hour.Add(time.Hour) {
+		saved, err := p.getSavedAuditEntries(hour.Format(auditHourFormat))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, saved...)
+	}
+
+	return entries, nil
+}
+
+func writeCSVExport(w http.ResponseWriter, name string, first, last time.Time, data []byte) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-%s-%s.csv\"", name, first.Format(statsDayFormat), last.Format(statsDayFormat)))
+	w.Write(data)
+}
+
+func (p *Plugin) exportUsageStats(w http.ResponseWriter, r *http.Request) {
+	first, last, apiErr := parseExportRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"), time.Now())
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+
+	// Usage not saved yet is included so that the export is up to date.
+	p.flushUsageStats()
+
+	stats, err := p.getUsageStatsBetween(first, last)
+	if err != nil {
+		p.API.LogError("Failed to get usage statistics", "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to get stats", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	data, err := formatUsageStatsCSV(stats, p.getConfiguration().getProviderPrices())
+	if err != nil {
+		p.API.LogError("Failed to export usage statistics", "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to export stats", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	writeCSVExport(w, "translation-usage", first, last, data)
+}
+
+func (p *Plugin) exportAuditLog(w http.ResponseWriter, r *http.Request) {
+	first, last, apiErr := parseExportRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"), time.Now())
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+
+	// Entries not saved yet are included so that the export is up to date.
+	p.flushAuditEntries()
+
+	entries, err := p.getAuditEntriesBetween(first, last)
+	if err != nil {
+		p.API.LogError("Failed to get audit entries", "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to get audit log", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	data, err := formatAuditEntriesCSV(entries)
+	if err != nil {
+		p.API.LogError("Failed to export audit entries", "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to export audit log", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	writeCSVExport(w, "translation-audit", first, last, data)
+}
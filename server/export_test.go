@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExportRange(t *testing.T) {
+	now := time.Date(2020, 6, 12, 15, 4, 5, 0, time.UTC)
+	day := func(month time.Month, day int) time.Time {
+		return time.Date(2020, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	for name, test := range map[string]struct {
+		from, to    string
+		first, last time.Time
+		invalid     string
+	}{
+		"current month by default": {first: day(6, 1), last: day(6, 12)},
+		"month of to":              {to: "2020-05-20", first: day(5, 1), last: day(5, 20)},
+		"range":                    {from: "2020-01-15", to: "2020-03-31", first: day(1, 15), last: day(3, 31)},
+		"single day":               {from: "2020-06-01", to: "2020-06-01", first: day(6, 1), last: day(6, 1)},
+		"invalid to":               {to: "2020-06", invalid: "to"},
+		"invalid from":             {from: "06/01/2020", invalid: "from"},
+		"from after to":            {from: "2020-06-02", to: "2020-06-01", invalid: "from"},
+		"range too long":           {from: "2019-06-12", to: "2020-06-12", invalid: "from"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			first, last, apiErr := parseExportRange(test.from, test.to, now)
+			if test.invalid != "" {
+				require.NotNil(t, apiErr)
+				assert.Equal(t, "Invalid parameter: "+test.invalid, apiErr.Message)
+				return
+			}
+			require.Nil(t, apiErr)
+			assert.Equal(t, test.first, first)
+			assert.Equal(t, test.last, last)
+		})
+	}
+}
+
+func TestFormatUsageStatsCSV(t *testing.T) {
+	stats := newUsageStats(providerAWS, "2020-06-01")
+	stats.Requests = 10
+	stats.Errors = 1
+	stats.Characters = 200000
+	stats.LatencyBuckets[1] = 9
+	stats.LatencyBuckets[3] = 1
+
+	data, err := formatUsageStatsCSV([]*UsageStats{stats, newUsageStats("deepl", "2020-06-02")}, map[string]float64{providerAWS: 15})
+	assert.NoError(t, err)
+	assert.Equal(t, "day,provider,requests,errors,characters,estimated_cost,currency,latency_p50_ms,latency_p90_ms,latency_p99_ms\n"+
+		"2020-06-01,aws,10,1,200000,3.00,USD,100,100,500\n"+
+		"2020-06-02,deepl,0,0,0,,USD,0,0,0\n", string(data))
+}
+
+func TestFormatAuditEntriesCSV(t *testing.T) {
+	data, err := formatAuditEntriesCSV([]*AuditEntry{
+		{CreateAt: 1000, RequestID: "request", UserID: "user", PostID: "post", ChannelID: "channel", SourceLanguage: "ko", TargetLanguage: "en", Provider: providerAWS, Characters: 12, Outcome: auditOutcomeSuccess},
+		{CreateAt: 2000, SourceLanguage: "auto", TargetLanguage: "ja", Provider: providerAWS, Characters: 5, Outcome: auditOutcomeFailure, Error: "throttled, try again"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "create_at,request_id,user_id,post_id,channel_id,provider,source_lang,target_lang,characters,outcome,error\n"+
+		"1000,request,user,post,channel,aws,ko,en,12,success,\n"+
+		"2000,,,,,aws,auto,ja,5,failure,\"throttled, try again\"\n", string(data))
+}
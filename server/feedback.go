@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	feedbackKeyPrefix = "feedback_"
+
+	actionFeedbackGood = "feedback_good"
+	actionFeedbackBad  = "feedback_bad"
+
+	feedbackRatingGood = "good"
+	feedbackRatingBad  = "bad"
+
+	// feedbackProvider is the provider translations are rated for when the button rating them
+	// doesn't tell, such as the ones posted before translations were routed to providers.
+	feedbackProvider = "aws"
+)
+
+// TranslationFeedback is the rating of the translation of a post by a user, to compare providers
+// and language pairs
+type TranslationFeedback struct {
+	PostID         string `json:"post_id"`
+	UserID         string `json:"user_id"`
+	Provider       string `json:"provider"`
+	SourceLanguage string `json:"source_lang"`
+	TargetLanguage string `json:"target_lang"`
+	Rating         string `json:"rating"`
+	CreateAt       int64  `json:"create_at"`
+}
+
+// getFeedbackKey returns the key of the feedback of a user on the translation of a post, so that
+// rating a translation again replaces the previous rating, hashed to fit the length limit of KV
+// keys.
+func getFeedbackKey(postID, userID string) string {
+	sum := sha256.Sum256([]byte(postID + ":" + userID))
+	return feedbackKeyPrefix + hex.EncodeToString(sum[:16])
+}
+
+// addFeedbackActions adds buttons rating a translation made by a provider to its last attachment.
+func addFeedbackActions(postID string, userInfo *UserInfo, provider string, attachments []*model.SlackAttachment) {
+	last := attachments[len(attachments)-1]
+	for _, action := range []*model.PostAction{
+		newPostAction(":thumbsup:", actionFeedbackGood, postID),
+		newPostAction(":thumbsdown:", actionFeedbackBad, postID),
+	} {
+		action.Integration.Context["source_lang"] = userInfo.SourceLanguage
+		action.Integration.Context["target_lang"] = userInfo.TargetLanguage
+		action.Integration.Context["provider"] = provider
+		last.Actions = append(last.Actions, action)
+	}
+}
+
+// recordFeedback saves the rating of the translation of a post by a user from the context of the
+// button clicked, and thanks the user ephemerally.
+func (p *Plugin) recordFeedback(userID string, post *model.Post, action string, context map[string]interface{}) error {
+	feedback := &TranslationFeedback{
+		PostID:   post.Id,
+		UserID:   userID,
+		Provider: feedbackProvider,
+		Rating:   feedbackRatingGood,
+		CreateAt: model.GetMillis(),
+	}
+	feedback.SourceLanguage, _ = context["source_lang"].(string)
+	feedback.TargetLanguage, _ = context["target_lang"].(string)
+	if provider, _ := context["provider"].(string); provider != "" {
+		feedback.Provider = provider
+	}
+	if action == actionFeedbackBad {
+		feedback.Rating = feedbackRatingBad
+	}
+
+	data, err := json.Marshal(feedback)
+	if err != nil {
+		return err
+	}
+
+	if appErr := p.API.KVSet(getFeedbackKey(post.Id, userID), data); appErr != nil {
+		return appErr
+	}
+	p.metrics.observeFeedback(feedback.Provider, feedback.SourceLanguage, feedback.TargetLanguage, feedback.Rating)
+
+	p.API.SendEphemeralPost(userID, &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: post.ChannelId,
+		RootId:    post.RootId,
+		ParentId:  post.RootId,
+		Message:   fmt.Sprintf("Thanks for your feedback on this translation into %s.", languageCodes[feedback.TargetLanguage]),
+	})
+
+	return nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/service/translate"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	defaultFileTranslationMaxSize = 100
+
+	// maxTranslateTextBytes is the size limit of a single Amazon Translate request.
+	maxTranslateTextBytes = 5000
+)
+
+var translatableFileExtensions = map[string]bool{
+	"txt": true,
+	"md":  true,
+	"csv": true,
+}
+
+// translatePostFiles translates the text file attachments of a post when file translation is
+// enabled for its channel, either re-attaching translated files or replying with their content.
+func (p *Plugin) translatePostFiles(svc *translate.Translate, post *model.Post, userInfo *UserInfo) {
+	if len(post.FileIds) == 0 {
+		return
+	}
+
+	channelInfo, apiErr := p.getChannelInfo(post.ChannelId)
+	if apiErr != nil || !channelInfo.TranslateFiles {
+		return
+	}
+
+	maxSize := p.getConfiguration().getFileTranslationMaxSize()
+	ctx := newAuditContext(p.newGlossaryContext(context.Background(), post.ChannelId), userInfo.UserID, post.Id, post.ChannelId)
+
+	var fileIDs []string
+	var sections []string
+	for _, fileID := range post.FileIds {
+		fileInfo, appErr := p.API.GetFileInfo(fileID)
+		if appErr != nil {
+			p.API.LogError("Failed to get file info", "file_id", fileID, "err", appErr.Error())
+			continue
+		}
+
+		extension := strings.ToLower(fileInfo.Extension)
+		if !translatableFileExtensions[extension] {
+			continue
+		}
+
+		if fileInfo.Size > maxSize {
+			p.API.LogDebug("Skipping file exceeding the translation size limit", "file_id", fileID, "size", fileInfo.Size)
+			continue
+		}
+
+		data, appErr := p.API.GetFile(fileID)
+		if appErr != nil {
+			p.API.LogError("Failed to get file", "file_id", fileID, "err", appErr.Error())
+			continue
+		}
+
+		translated, err := p.translateFileContent(ctx, svc, extension, userInfo.SourceLanguage, userInfo.TargetLanguage, string(data))
+		if err != nil {
+			p.API.LogError("Failed to translate file", "file_id", fileID, "err", err.Error())
+			continue
+		}
+
+		if channelInfo.FileDelivery == fileDeliveryThread {
+			sections = append(sections, formatFileTranslation(fileInfo.Name, extension, translated))
+			continue
+		}
+
+		uploaded, appErr := p.API.UploadFile([]byte(translated), post.ChannelId, getTranslatedFileName(fileInfo.Name, userInfo.TargetLanguage))
+		if appErr != nil {
+			p.API.LogError("Failed to upload translated file", "file_id", fileID, "err", appErr.Error())
+			continue
+		}
+		fileIDs = append(fileIDs, uploaded.Id)
+	}
+
+	if len(fileIDs) == 0 && len(sections) == 0 {
+		return
+	}
+
+	rootID := post.RootId
+	if channelInfo.FileDelivery == fileDeliveryThread {
+		rootID = getThreadRootID(post)
+	}
+
+	translationPost := p.newTranslationPost(post, userInfo, rootID)
+	attachment := &model.SlackAttachment{
+		Pretext: getTranslationHeader(userInfo.SourceLanguage, userInfo.TargetLanguage),
+	}
+
+	if channelInfo.FileDelivery == fileDeliveryThread {
+		attachment.Text = truncateMessage(strings.Join(sections, "\n\n"))
+	} else {
+		translationPost.FileIds = fileIDs
+	}
+	model.ParseSlackAttachment(translationPost, []*model.SlackAttachment{attachment})
+
+	if _, appErr := p.API.CreatePost(translationPost); appErr != nil {
+		p.API.LogError("Failed to create file translation post", "post_id", post.Id, "err", appErr.Error())
+	}
+}
+
+func (p *Plugin) translateFileContent(ctx context.Context, svc *translate.Translate, extension, source, target, content string) (string, error) {
+	if extension == "csv" {
+		return p.translateCSV(ctx, svc, source, target, content)
+	}
+
+	return p.translateLongText(ctx, svc, source, target, content)
+}
+
+// translateLongText translates text of any length by splitting it into request-sized chunks, the
+// request ID of ctx following every request to the provider.
+func (p *Plugin) translateLongText(ctx context.Context, svc *translate.Translate, source, target, text string) (string, error) {
+	translated, _, err := p.translateLongTextWithSource(ctx, svc, source, target, text)
+	return translated, err
+}
+
+// translateLongTextWithSource translates text like translateLongText, also returning the language
+// it was translated from, which is the one detected in its first chunk when the source is auto.
+func (p *Plugin) translateLongTextWithSource(ctx context.Context, svc *translate.Translate, source, target, text string) (string, string, error) {
+	ctx = newMessageSizeContext(ctx, text)
+	translatedSource := source
+	chunks := splitText(text, maxTranslateTextBytes)
+	for i, chunk := range chunks {
+		if strings.TrimSpace(chunk.text) == "" {
+			continue
+		}
+
+		translated, chunkSource, err := p.translateTextWithSource(ctx, svc, source, target, chunk.text)
+		if err != nil {
+			return "", "", err
+		}
+		chunks[i].text = translated
+
+		if translatedSource == autoLanguage {
+			translatedSource = chunkSource
+		}
+	}
+
+	return joinChunks(chunks), translatedSource, nil
+}
+
+// translateCSV translates each textual cell of a CSV document, leaving its structure intact.
+func (p *Plugin) translateCSV(ctx context.Context, svc *translate.Translate, source, target, content string) (string, error) {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return "", err
+	}
+
+	translations := map[string]string{}
+	for _, record := range records {
+		for i, field := range record {
+			if strings.TrimSpace(field) == "" || isNumeric(field) {
+				continue
+			}
+
+			translated, ok := translations[field]
+			if !ok {
+				if translated, err = p.translateTextWithContext(ctx, svc, source, target, field); err != nil {
+					return "", err
+				}
+				translations[field] = translated
+			}
+			record[i] = translated
+		}
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.WriteAll(records); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// textChunk is a part of a text translated on its own.
+type textChunk struct {
+	// separator joins the chunk to the previous one: a line break between lines, or the
+	// whitespace a line too long was cut at, if any.
+	separator string
+	text      string
+}
+
+// splitText splits text on line boundaries into chunks no longer than limit bytes. Lines longer
+// than the limit are cut at the end of a sentence or else of a word, and only at a rune boundary
+// as a last resort.
+func splitText(text string, limit int) []textChunk {
+	var chunks []textChunk
+	open := false
+	for i, line := range strings.Split(text, "\n") {
+		separator := ""
+		if i > 0 {
+			separator = "\n"
+		}
+
+		for len(line) > limit {
+			end, next := cutLongLine(line, limit)
+			chunks = append(chunks, textChunk{separator: separator, text: line[:end]})
+			open = false
+			separator = line[end:next]
+			line = line[next:]
+		}
+
+		if open && len(chunks[len(chunks)-1].text)+len(separator)+len(line) <= limit {
+			chunks[len(chunks)-1].text += separator + line
+			continue
+		}
+
+		chunks = append(chunks, textChunk{separator: separator, text: line})
+		open = true
+	}
+
+	return chunks
+}
+
+// cutLongLine returns where to cut a line longer than limit bytes, the text up to end being the
+// first part, and the text from next on the rest, the whitespace in between separating them.
+func cutLongLine(line string, limit int) (end, next int) {
+	sentenceEnd, sentenceNext := 0, 0
+	wordEnd, wordNext := 0, 0
+
+	var previous rune
+	for i, r := range line {
+		if i > limit {
+			break
+		}
+
+		switch {
+		case i == 0:
+		case unicode.IsSpace(r):
+			if strings.ContainsRune(".!?", previous) {
+				sentenceEnd, sentenceNext = i, i+utf8.RuneLen(r)
+			}
+			wordEnd, wordNext = i, i+utf8.RuneLen(r)
+		case strings.ContainsRune("。！？", previous):
+			// Sentences in scripts without spaces end without any.
+			sentenceEnd, sentenceNext = i, i
+		}
+		previous = r
+	}
+
+	if sentenceEnd > 0 {
+		return sentenceEnd, sentenceNext
+	}
+	if wordEnd > 0 {
+		return wordEnd, wordNext
+	}
+
+	end = limit
+	for end > 0 && !utf8.RuneStart(line[end]) {
+		end--
+	}
+
+	return end, end
+}
+
+// joinChunks joins the chunks of a text back together.
+func joinChunks(chunks []textChunk) string {
+	var text strings.Builder
+	for _, chunk := range chunks {
+		text.WriteString(chunk.separator)
+		text.WriteString(chunk.text)
+	}
+
+	return text.String()
+}
+
+func isNumeric(value string) bool {
+	_, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return err == nil
+}
+
+func getTranslatedFileName(name, target string) string {
+	extension := filepath.Ext(name)
+	return strings.TrimSuffix(name, extension) + "." + target + extension
+}
+
+func formatFileTranslation(name, extension, translated string) string {
+	if extension == "md" {
+		return fmt.Sprintf("**%s**\n%s", name, translated)
+	}
+
+	return fmt.Sprintf("**%s**\n```\n%s\n```", name, translated)
+}
+
+// truncateMessage shortens text to fit into a single post.
+func truncateMessage(text string) string {
+	const suffix = "\n\n*(truncated)*"
+
+	if utf8.RuneCountInString(text) <= model.POST_MESSAGE_MAX_RUNES_V2 {
+		return text
+	}
+
+	runes := []rune(text)
+	return string(runes[:model.POST_MESSAGE_MAX_RUNES_V2-utf8.RuneCountInString(suffix)]) + suffix
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitText(t *testing.T) {
+	t.Run("lines fitting together", func(t *testing.T) {
+		chunks := splitText("Hello.\nWorld.", 20)
+		assert.Equal(t, []textChunk{{text: "Hello.\nWorld."}}, chunks)
+	})
+
+	t.Run("lines split apart", func(t *testing.T) {
+		chunks := splitText("First line.\nSecond line.", 15)
+		assert.Equal(t, []textChunk{{text: "First line."}, {separator: "\n", text: "Second line."}}, chunks)
+	})
+
+	t.Run("long line cut at the end of a sentence", func(t *testing.T) {
+		chunks := splitText("One two. Three four five", 20)
+		assert.Equal(t, []textChunk{{text: "One two."}, {separator: " ", text: "Three four five"}}, chunks)
+	})
+
+	t.Run("long line cut at the end of a word", func(t *testing.T) {
+		chunks := splitText("One two three four five", 15)
+		assert.Equal(t, []textChunk{{text: "One two three"}, {separator: " ", text: "four five"}}, chunks)
+	})
+
+	t.Run("long line of a script without spaces", func(t *testing.T) {
+		chunks := splitText("今日は晴れ。明日は雨", 20)
+		assert.Equal(t, []textChunk{{text: "今日は晴れ。"}, {text: "明日は雨"}}, chunks)
+	})
+
+	t.Run("long word cut at a rune boundary", func(t *testing.T) {
+		chunks := splitText("ééééé", 5)
+		assert.Equal(t, []textChunk{{text: "éé"}, {text: "éé"}, {text: "é"}}, chunks)
+	})
+}
+
+func TestJoinChunks(t *testing.T) {
+	text := strings.Repeat("A sentence of a few words. ", 500) + "\n\n" + strings.Repeat("x", 12000) + "\nThe end."
+
+	chunks := splitText(text, maxTranslateTextBytes)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, len(chunk.text), maxTranslateTextBytes)
+	}
+	assert.Equal(t, text, joinChunks(chunks))
+}
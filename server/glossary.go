@@ -0,0 +1,520 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	glossaryKeyPrefix = "glossary_"
+
+	glossaryScopeTeam    = "team"
+	glossaryScopeChannel = "channel"
+
+	// maxGlossaryTerms bounds the terms of a glossary, as each of them is looked for in every
+	// text translated in its scope.
+	maxGlossaryTerms = 200
+
+	// maxGlossarySaveAttempts bounds the retries of saving a glossary updated concurrently.
+	maxGlossarySaveAttempts = 5
+)
+
+type glossaryContextKey struct{}
+
+var errGlossaryFull = fmt.Errorf("the glossary can't have more than %d terms", maxGlossaryTerms)
+
+// GlossaryTerm is a term whose translation is fixed, such as a product name or internal jargon
+type GlossaryTerm struct {
+	Term string `json:"term"`
+
+	// Translations of the term keyed by target language, the term being kept as is when
+	// translating into any other language
+	Translations map[string]string `json:"translations,omitempty"`
+}
+
+// Glossary is the list of terms of a team or a channel
+type Glossary struct {
+	Terms []*GlossaryTerm `json:"terms"`
+}
+
+func getGlossaryKey(scope, id string) string {
+	return glossaryKeyPrefix + scope + "_" + id
+}
+
+// getTerm returns the term matching the given one regardless of case, if any.
+func (g *Glossary) getTerm(term string) *GlossaryTerm {
+	if g == nil {
+		return nil
+	}
+
+	for _, glossaryTerm := range g.Terms {
+		if strings.EqualFold(glossaryTerm.Term, term) {
+			return glossaryTerm
+		}
+	}
+
+	return nil
+}
+
+// merge returns the terms of both glossaries, the terms of other overriding those of g.
+func (g *Glossary) merge(other *Glossary) *Glossary {
+	merged := &Glossary{}
+	if other != nil {
+		merged.Terms = append(merged.Terms, other.Terms...)
+	}
+	if g != nil {
+		for _, term := range g.Terms {
+			if other.getTerm(term.Term) == nil {
+				merged.Terms = append(merged.Terms, term)
+			}
+		}
+	}
+
+	return merged
+}
+
+// maskTerms replaces the terms of a glossary found in text with placeholders, restored as their
+// translation into target if any, or as they were written otherwise. Terms are matched regardless
+// of case and as whole words, longer terms first so that they win over the terms they contain.
+func (ph *placeholders) maskTerms(glossary *Glossary, target, text string) string {
+	if glossary == nil || len(glossary.Terms) == 0 {
+		return text
+	}
+
+	terms := make([]*GlossaryTerm, len(glossary.Terms))
+	copy(terms, glossary.Terms)
+	sort.SliceStable(terms, func(i, j int) bool {
+		return len(terms[i].Term) > len(terms[j].Term)
+	})
+
+	for _, term := range terms {
+		pattern, err := regexp.Compile(`(?i)` + regexp.QuoteMeta(term.Term))
+		if err != nil {
+			continue
+		}
+
+		var masked strings.Builder
+		last := 0
+		for _, loc := range pattern.FindAllStringIndex(text, -1) {
+			if !isWordBoundary(text, loc[0], true) || !isWordBoundary(text, loc[1], false) {
+				continue
+			}
+
+			value := text[loc[0]:loc[1]]
+			if translation := term.Translations[target]; translation != "" {
+				value = translation
+			}
+
+			masked.WriteString(text[last:loc[0]])
+			masked.WriteString(ph.add(value))
+			last = loc[1]
+		}
+		masked.WriteString(text[last:])
+		text = masked.String()
+	}
+
+	return text
+}
+
+// isWordBoundary reports whether a term found in text starting or ending at index i stands as a
+// word of its own. Scripts written without spaces, such as Japanese or Chinese, have no boundaries
+// to look for.
+func isWordBoundary(text string, i int, start bool) bool {
+	var inside, outside rune
+	if start {
+		if i == 0 {
+			return true
+		}
+		inside, _ = utf8.DecodeRuneInString(text[i:])
+		outside, _ = utf8.DecodeLastRuneInString(text[:i])
+	} else {
+		if i == len(text) {
+			return true
+		}
+		inside, _ = utf8.DecodeLastRuneInString(text[:i])
+		outside, _ = utf8.DecodeRuneInString(text[i:])
+	}
+
+	isWord := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+	}
+	isUnspaced := func(r rune) bool {
+		return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai)
+	}
+
+	return !isWord(inside) || !isWord(outside) || isUnspaced(inside) || isUnspaced(outside)
+}
+
+// newGlossaryContext returns a context carrying the glossary applied to the texts of a channel,
+// made of the terms of its team and its own, which override them, along with the vocabulary of its
+// team unless names are to be translated.
+func (p *Plugin) newGlossaryContext(ctx context.Context, channelID string) context.Context {
+	channel, appErr := p.API.GetChannel(channelID)
+	if appErr != nil {
+		return ctx
+	}
+
+	var teamGlossary *Glossary
+	if channel.TeamId != "" {
+		teamGlossary = p.getGlossary(glossaryScopeTeam, channel.TeamId)
+		if !p.getConfiguration().TranslateTeamNames {
+			ctx = context.WithValue(ctx, vocabularyContextKey{}, p.getTeamVocabulary(channel.TeamId))
+		}
+	}
+
+	glossary := teamGlossary.merge(p.getGlossary(glossaryScopeChannel, channelID))
+	if len(glossary.Terms) == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, glossaryContextKey{}, glossary)
+}
+
+// getContextGlossary returns the glossary carried by ctx, if any.
+func getContextGlossary(ctx context.Context) *Glossary {
+	glossary, _ := ctx.Value(glossaryContextKey{}).(*Glossary)
+	return glossary
+}
+
+// getGlossary returns the glossary of a team or channel, which is nil when it has none or it
+// can't be read, as glossaries mustn't get in the way of translating.
+func (p *Plugin) getGlossary(scope, id string) *Glossary {
+	glossaryBytes, appErr := p.API.KVGet(getGlossaryKey(scope, id))
+	if appErr != nil || glossaryBytes == nil {
+		return nil
+	}
+
+	glossary := &Glossary{}
+	if err := json.Unmarshal(glossaryBytes, glossary); err != nil {
+		p.API.LogWarn("Failed to unmarshal glossary", "scope", scope, "id", id, "err", err.Error())
+		return nil
+	}
+
+	return glossary
+}
+
+// updateGlossary applies update to the glossary of a team or channel with a compare and set, as
+// other admins may be updating it at the same time.
+func (p *Plugin) updateGlossary(scope, id string, update func(glossary *Glossary) error) error {
+	key := getGlossaryKey(scope, id)
+	for attempt := 0; attempt < maxGlossarySaveAttempts; attempt++ {
+		oldBytes, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return appErr
+		}
+
+		glossary := &Glossary{}
+		if oldBytes != nil {
+			if err := json.Unmarshal(oldBytes, glossary); err != nil {
+				return errors.Wrap(err, "unable to unmarshal glossary")
+			}
+		}
+
+		if err := update(glossary); err != nil {
+			return err
+		}
+
+		newBytes, err := json.Marshal(glossary)
+		if err != nil {
+			return errors.Wrap(err, "unable to marshal glossary")
+		}
+
+		updated, appErr := p.API.KVCompareAndSet(key, oldBytes, newBytes)
+		if appErr != nil {
+			return appErr
+		}
+		if updated {
+			return nil
+		}
+	}
+
+	return errors.New("glossary kept changing concurrently")
+}
+
+// addGlossaryTerm adds a term to a glossary, or a translation to a term already in it. An empty
+// language keeps the term as is in every language.
+func (p *Plugin) addGlossaryTerm(scope, id, term, language, translation string) error {
+	return p.updateGlossary(scope, id, func(glossary *Glossary) error {
+		glossaryTerm := glossary.getTerm(term)
+		if glossaryTerm == nil {
+			if len(glossary.Terms) >= maxGlossaryTerms {
+				return errGlossaryFull
+			}
+
+			glossaryTerm = &GlossaryTerm{Term: term}
+			glossary.Terms = append(glossary.Terms, glossaryTerm)
+		}
+
+		if language != "" {
+			if glossaryTerm.Translations == nil {
+				glossaryTerm.Translations = map[string]string{}
+			}
+			glossaryTerm.Translations[language] = translation
+		}
+
+		return nil
+	})
+}
+
+// removeGlossaryTerm removes a term from a glossary, reporting whether it was in it.
+func (p *Plugin) removeGlossaryTerm(scope, id, term string) (bool, error) {
+	removed := false
+	err := p.updateGlossary(scope, id, func(glossary *Glossary) error {
+		removed = false
+		for i, glossaryTerm := range glossary.Terms {
+			if strings.EqualFold(glossaryTerm.Term, term) {
+				glossary.Terms = append(glossary.Terms[:i], glossary.Terms[i+1:]...)
+				removed = true
+				break
+			}
+		}
+
+		return nil
+	})
+
+	return removed, err
+}
+
+// getGlossaryText lists the terms of a glossary along with their translations.
+func getGlossaryText(glossary *Glossary) string {
+	if glossary == nil || len(glossary.Terms) == 0 {
+		return " * No terms"
+	}
+
+	var lines []string
+	for _, term := range glossary.Terms {
+		var translations []string
+		for language, translation := range term.Translations {
+			translations = append(translations, fmt.Sprintf("%s: `%s`", language, translation))
+		}
+		sort.Strings(translations)
+
+		line := fmt.Sprintf(" * `%s`", term.Term)
+		if len(translations) > 0 {
+			line += " → " + strings.Join(translations, ", ")
+		} else {
+			line += " kept as is"
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// canManageGlossary reports whether a user may change the glossary of a team or channel, which
+// applies to every translation in it, being reserved to its admins.
+func (p *Plugin) canManageGlossary(userID, scope, id string) bool {
+	if scope == glossaryScopeTeam {
+		return p.API.HasPermissionToTeam(userID, id, model.PERMISSION_MANAGE_TEAM)
+	}
+
+	return p.canManageChannel(userID, id)
+}
+
+// canReadGlossary reports whether a user may see the glossary of a team or channel, which its
+// members may.
+func (p *Plugin) canReadGlossary(userID, scope, id string) bool {
+	if scope == glossaryScopeTeam {
+		return p.API.HasPermissionToTeam(userID, id, model.PERMISSION_VIEW_TEAM)
+	}
+
+	return p.API.HasPermissionToChannel(userID, id, model.PERMISSION_READ_CHANNEL)
+}
+
+// IsValid validates a glossary term
+func (t *GlossaryTerm) IsValid() error {
+	// Terms without letters could match the placeholders masking other terms.
+	if strings.IndexFunc(t.Term, unicode.IsLetter) < 0 {
+		return fmt.Errorf("term must contain at least one letter")
+	}
+
+	for language, translation := range t.Translations {
+		if language == autoLanguage || languageCodes[language] == "" {
+			return fmt.Errorf("translations must be keyed by a supported target language code")
+		}
+		if strings.TrimSpace(translation) == "" {
+			return fmt.Errorf("translations must not be empty")
+		}
+	}
+
+	return nil
+}
+
+// IsValid validates a glossary
+func (g *Glossary) IsValid() error {
+	if len(g.Terms) > maxGlossaryTerms {
+		return fmt.Errorf("glossary can't have more than %d terms", maxGlossaryTerms)
+	}
+
+	seen := map[string]bool{}
+	for _, term := range g.Terms {
+		if term == nil {
+			return fmt.Errorf("terms must not be null")
+		}
+		if err := term.IsValid(); err != nil {
+			return fmt.Errorf("%s: %s", term.Term, err.Error())
+		}
+
+		key := strings.ToLower(term.Term)
+		if seen[key] {
+			return fmt.Errorf("%s: term is listed twice", term.Term)
+		}
+		seen[key] = true
+	}
+
+	return nil
+}
+
+// getGlossaryParams returns the scope and ID of the glossary of a request, writing an error when
+// the user may not read it, or may not change it for requests changing it.
+func (p *Plugin) getGlossaryParams(w http.ResponseWriter, r *http.Request, manage bool) (string, string, bool) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	scope := mux.Vars(r)["scope"]
+	id := mux.Vars(r)["id"]
+
+	if manage && !p.canManageGlossary(userID, scope, id) {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorForbidden, Message: "Only " + scope + " admins can change this glossary", StatusCode: http.StatusForbidden})
+		return "", "", false
+	}
+
+	if !manage && !p.canReadGlossary(userID, scope, id) {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorForbidden, Message: "Only " + scope + " members can read this glossary", StatusCode: http.StatusForbidden})
+		return "", "", false
+	}
+
+	return scope, id, true
+}
+
+func (p *Plugin) writeGlossary(w http.ResponseWriter, scope, id string) {
+	glossary := p.getGlossary(scope, id)
+	if glossary == nil {
+		glossary = &Glossary{}
+	}
+	if glossary.Terms == nil {
+		glossary.Terms = []*GlossaryTerm{}
+	}
+
+	resp, _ := json.Marshal(glossary)
+	w.Write(resp)
+}
+
+func (p *Plugin) getGlossaryHandler(w http.ResponseWriter, r *http.Request) {
+	scope, id, ok := p.getGlossaryParams(w, r, false)
+	if !ok {
+		return
+	}
+
+	p.writeGlossary(w, scope, id)
+}
+
+// setGlossaryHandler replaces a whole glossary, such as when syncing it from a terminology system.
+func (p *Plugin) setGlossaryHandler(w http.ResponseWriter, r *http.Request) {
+	scope, id, ok := p.getGlossaryParams(w, r, true)
+	if !ok {
+		return
+	}
+
+	var glossary *Glossary
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&glossary); err != nil || glossary == nil {
+		writeAPIError(w, newInvalidParameterError("glossary"))
+		return
+	}
+
+	if err := glossary.IsValid(); err != nil {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInvalidGlossary, Message: fmt.Sprintf("Invalid glossary: %s", err.Error()), StatusCode: http.StatusBadRequest})
+		return
+	}
+
+	if err := p.updateGlossary(scope, id, func(saved *Glossary) error {
+		saved.Terms = glossary.Terms
+		return nil
+	}); err != nil {
+		p.API.LogError("Failed to save glossary", "scope", scope, "id", id, "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorUnableToSave, Message: "Failed to save glossary", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	p.writeGlossary(w, scope, id)
+}
+
+// addGlossaryTermHandler adds a term to a glossary, or replaces the translations of a term
+// already in it.
+func (p *Plugin) addGlossaryTermHandler(w http.ResponseWriter, r *http.Request) {
+	scope, id, ok := p.getGlossaryParams(w, r, true)
+	if !ok {
+		return
+	}
+
+	var term *GlossaryTerm
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&term); err != nil || term == nil {
+		writeAPIError(w, newInvalidParameterError("term"))
+		return
+	}
+
+	if err := term.IsValid(); err != nil {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInvalidGlossary, Message: fmt.Sprintf("Invalid term: %s", err.Error()), StatusCode: http.StatusBadRequest})
+		return
+	}
+
+	err := p.updateGlossary(scope, id, func(glossary *Glossary) error {
+		if existing := glossary.getTerm(term.Term); existing != nil {
+			*existing = *term
+			return nil
+		}
+
+		if len(glossary.Terms) >= maxGlossaryTerms {
+			return errGlossaryFull
+		}
+		glossary.Terms = append(glossary.Terms, term)
+		return nil
+	})
+	if err == errGlossaryFull {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInvalidGlossary, Message: err.Error(), StatusCode: http.StatusBadRequest})
+		return
+	}
+	if err != nil {
+		p.API.LogError("Failed to save glossary", "scope", scope, "id", id, "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorUnableToSave, Message: "Failed to save glossary", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	p.writeGlossary(w, scope, id)
+}
+
+func (p *Plugin) removeGlossaryTermHandler(w http.ResponseWriter, r *http.Request) {
+	scope, id, ok := p.getGlossaryParams(w, r, true)
+	if !ok {
+		return
+	}
+
+	term := r.URL.Query().Get("term")
+	if term == "" {
+		writeAPIError(w, newInvalidParameterError("term"))
+		return
+	}
+
+	removed, err := p.removeGlossaryTerm(scope, id, term)
+	if err != nil {
+		p.API.LogError("Failed to save glossary", "scope", scope, "id", id, "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorUnableToSave, Message: "Failed to save glossary", StatusCode: http.StatusInternalServerError})
+		return
+	}
+	if !removed {
+		writeAPIError(w, newNotFoundError())
+		return
+	}
+
+	p.writeGlossary(w, scope, id)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaceholdersMaskTerms(t *testing.T) {
+	glossary := &Glossary{Terms: []*GlossaryTerm{
+		{Term: "Mattermost"},
+		{Term: "Mattermost Boards", Translations: map[string]string{"ja": "Boards"}},
+		{Term: "PR", Translations: map[string]string{"ja": "プルリク"}},
+		{Term: "会議"},
+	}}
+
+	for name, tc := range map[string]struct {
+		text     string
+		target   string
+		expected string
+		values   []string
+	}{
+		"kept as is": {
+			text:     "Install mattermost today",
+			target:   "ja",
+			expected: "Install {{0}} today",
+			values:   []string{"mattermost"},
+		},
+		"longest term first": {
+			text:     "Mattermost Boards and Mattermost",
+			target:   "ja",
+			expected: "{{0}} and {{1}}",
+			values:   []string{"Boards", "Mattermost"},
+		},
+		"fixed translation": {
+			text:     "Review my PR, please",
+			target:   "ja",
+			expected: "Review my {{0}}, please",
+			values:   []string{"プルリク"},
+		},
+		"no translation into target": {
+			text:     "Review my PR, please",
+			target:   "fr",
+			expected: "Review my {{0}}, please",
+			values:   []string{"PR"},
+		},
+		"whole words only": {
+			text:     "A PRIMARY concern",
+			target:   "ja",
+			expected: "A PRIMARY concern",
+		},
+		"unspaced scripts": {
+			text:     "明日の会議です",
+			target:   "en",
+			expected: "明日の{{0}}です",
+			values:   []string{"会議"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ph := &placeholders{}
+			assert.Equal(t, tc.expected, ph.maskTerms(glossary, tc.target, tc.text))
+			assert.Equal(t, tc.values, ph.values)
+		})
+	}
+}
+
+func TestGlossaryMerge(t *testing.T) {
+	team := &Glossary{Terms: []*GlossaryTerm{{Term: "Boards"}, {Term: "PR"}}}
+	channel := &Glossary{Terms: []*GlossaryTerm{{Term: "pr", Translations: map[string]string{"ja": "プルリク"}}}}
+
+	merged := team.merge(channel)
+	assert.Len(t, merged.Terms, 2)
+	assert.Equal(t, "プルリク", merged.getTerm("PR").Translations["ja"])
+	assert.NotNil(t, merged.getTerm("boards"))
+
+	var none *Glossary
+	assert.Len(t, none.merge(nil).Terms, 0)
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/translate"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	// providerProbeInterval is how long the result of a provider probe is reused, so that
+	// frequent health checks don't turn into as many requests to the provider.
+	providerProbeInterval = 5 * time.Minute
+
+	providerProbeTimeout = 5 * time.Second
+
+	// providerHealthKey holds the result of the last background probe of any server of the
+	// cluster, along with how long the provider has been failing.
+	providerHealthKey = "provider_health"
+
+	// providerHealthAlertFailures is the number of consecutive failed background probes after
+	// which admins are alerted, so that a single failed probe doesn't alert them.
+	providerHealthAlertFailures = 3
+
+	// maxProviderHealthSaveAttempts bounds the retries of saving the provider health updated
+	// concurrently by other cluster nodes.
+	maxProviderHealthSaveAttempts = 5
+
+	healthStatusOK          = "ok"
+	healthStatusUnavailable = "unavailable"
+)
+
+// ProviderProbe is the result of checking that the translation provider can be reached with the
+// configured credentials
+type ProviderProbe struct {
+	Provider  string `json:"provider"`
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+	CheckedAt int64  `json:"checked_at"`
+
+	// ConsecutiveFailures and FailingSince tell how long the provider has been failing its probes.
+	ConsecutiveFailures int   `json:"consecutive_failures,omitempty"`
+	FailingSince        int64 `json:"failing_since,omitempty"`
+}
+
+// HealthResponse is the health of the plugin as reported to load balancers and monitoring, without
+// any error text, as it is reported without authentication. System admins find the errors in the
+// diagnostics.
+type HealthResponse struct {
+	Status             string         `json:"status"`
+	Version            string         `json:"version"`
+	ConfigurationValid bool           `json:"configuration_valid"`
+	PendingPosts       int            `json:"pending_posts"`
+	Provider           *ProviderProbe `json:"provider,omitempty"`
+}
+
+// newHealthResponse returns the health of the plugin from the error of its configuration, if any,
+// and from the last provider probe. The error of the probe is left out, as errors of the provider
+// may name accounts and credentials.
+func newHealthResponse(configErr error, probe *ProviderProbe, pendingPosts int) *HealthResponse {
+	health := &HealthResponse{
+		Status:             healthStatusOK,
+		Version:            manifest.Version,
+		ConfigurationValid: configErr == nil,
+		PendingPosts:       pendingPosts,
+	}
+
+	if configErr != nil {
+		health.Status = healthStatusUnavailable
+		return health
+	}
+
+	if probe != nil {
+		provider := *probe
+		provider.Error = ""
+		health.Provider = &provider
+		if !provider.Reachable {
+			health.Status = healthStatusUnavailable
+		}
+	}
+
+	return health
+}
+
+// getHealth reports the health of the plugin without authentication, answering with a service
+// unavailable status when it can't translate, so that load balancers can act on the status code.
+func (p *Plugin) getHealth(w http.ResponseWriter, r *http.Request) {
+	var probe *ProviderProbe
+	configErr := p.IsValid()
+	if configErr == nil {
+		probe = p.getProviderProbe()
+	}
+
+	health := newHealthResponse(configErr, probe, p.countPendingPosts())
+
+	resp, _ := json.Marshal(health)
+	if health.Status != healthStatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(resp)
+}
+
+// countPendingPosts returns the number of posts waiting to be translated along with the next
+// posts of their author.
+func (p *Plugin) countPendingPosts() int {
+	p.burstsLock.Lock()
+	defer p.burstsLock.Unlock()
+
+	count := 0
+	for _, burst := range p.bursts {
+		count += len(burst.posts)
+	}
+
+	return count
+}
+
+// getProviderProbe returns the result of the last provider probe, probing the provider again
+// when it is older than the probe interval. Concurrent health checks wait for the same probe.
+func (p *Plugin) getProviderProbe() *ProviderProbe {
+	p.providerProbeLock.Lock()
+	defer p.providerProbeLock.Unlock()
+
+	if p.providerProbe != nil && time.Since(time.Unix(0, p.providerProbe.CheckedAt*int64(time.Millisecond))) < providerProbeInterval {
+		return p.providerProbe
+	}
+
+	p.providerProbe = nextProviderHealth(p.providerProbe, p.probeProvider())
+
+	return p.providerProbe
+}
+
+// probeProvider checks that Amazon Translate accepts the configured credentials by listing
+// terminologies, which isn't billed like translating text.
+func (p *Plugin) probeProvider() *ProviderProbe {
+	probe := &ProviderProbe{Provider: providerAWS, CheckedAt: model.GetMillis()}
+
+	svc, err := p.getTranslateService()
+	if err != nil {
+		probe.Error = err.Error()
+		return probe
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), providerProbeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	_, err = svc.ListTerminologiesWithContext(ctx, &translate.ListTerminologiesInput{MaxResults: aws.Int64(1)})
+	probe.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		p.API.LogWarn("Failed to probe the translation provider", "provider", providerAWS, "err", err.Error())
+		probe.Error = err.Error()
+		return probe
+	}
+
+	probe.Reachable = true
+
+	return probe
+}
+
+// nextProviderHealth returns the provider health following a previous one, which may be nil, after
+// a probe, counting the consecutive failures of the provider.
+func nextProviderHealth(previous, probe *ProviderProbe) *ProviderProbe {
+	health := *probe
+	health.ConsecutiveFailures = 0
+	health.FailingSince = 0
+	if probe.Reachable {
+		return &health
+	}
+
+	health.ConsecutiveFailures = 1
+	health.FailingSince = probe.CheckedAt
+	if previous != nil && !previous.Reachable {
+		health.ConsecutiveFailures = previous.ConsecutiveFailures + 1
+		health.FailingSince = previous.FailingSince
+	}
+
+	return &health
+}
+
+// getProviderHealthAlert returns the message alerting admins that the provider started failing
+// for long enough or recovered afterwards, or an empty string when nothing changed for them.
+func getProviderHealthAlert(previous, health *ProviderProbe) string {
+	failingSince := time.Unix(0, health.FailingSince*int64(time.Millisecond)).UTC().Format("2006-01-02 15:04 MST")
+
+	switch {
+	case health.ConsecutiveFailures == providerHealthAlertFailures:
+		return fmt.Sprintf("#### Translation provider unavailable\nThe translation provider %s has been failing since %s, so messages aren't translated. Last error: `%s`", health.Provider, failingSince, health.Error)
+	case health.Reachable && previous != nil && previous.ConsecutiveFailures >= providerHealthAlertFailures:
+		return fmt.Sprintf("#### Translation provider recovered\nThe translation provider %s is reachable again after failing %d consecutive checks.", health.Provider, previous.ConsecutiveFailures)
+	}
+
+	return ""
+}
+
+func (p *Plugin) startProviderHealthCheck() {
+	p.providerHealthStop = make(chan struct{})
+	stop := p.providerHealthStop
+
+	go func() {
+		ticker := time.NewTicker(providerProbeInterval)
+		defer ticker.Stop()
+
+		for {
+			p.runProviderHealthCheck()
+
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (p *Plugin) stopProviderHealthCheck() {
+	if p.providerHealthStop != nil {
+		close(p.providerHealthStop)
+		p.providerHealthStop = nil
+	}
+}
+
+// runProviderHealthCheck probes the provider in the background, so that health checks and the
+// status command answer without waiting for the provider, and records the result in the provider
+// health shared by the servers of a cluster, alerting admins when the provider keeps failing and
+// when it recovers.
+func (p *Plugin) runProviderHealthCheck() {
+	if p.IsValid() != nil {
+		return
+	}
+
+	probe := p.probeProvider()
+
+	previous, health, err := p.saveProviderHealth(probe)
+	if err != nil {
+		p.API.LogError("Failed to save provider health", "err", err.Error())
+		health = nextProviderHealth(p.getLastProviderProbe(), probe)
+	}
+
+	p.providerProbeLock.Lock()
+	p.providerProbe = health
+	p.providerProbeLock.Unlock()
+
+	// Alerts are only sent by the server which saved the change, so that admins aren't alerted
+	// by every server of a cluster.
+	if err != nil {
+		return
+	}
+
+	if alert := getProviderHealthAlert(previous, health); alert != "" {
+		if !health.Reachable && p.getConfiguration().OfflineEndpoint != "" {
+			alert += "\nMessages are translated by the offline provider in the meantime."
+		}
+		if err := p.notifyAdmins(p.getConfiguration().AlertChannel, alert); err != nil {
+			p.API.LogError("Failed to alert admins about the provider health", "err", err.Error())
+		}
+	}
+}
+
+// getLastProviderProbe returns the result of the last provider probe of this server, or nil when
+// the provider wasn't probed since it was configured.
+func (p *Plugin) getLastProviderProbe() *ProviderProbe {
+	p.providerProbeLock.Lock()
+	defer p.providerProbeLock.Unlock()
+
+	return p.providerProbe
+}
+
+// getProviderHealth returns the provider health saved by the last background probe of any server
+// of the cluster, or nil when the provider wasn't probed yet.
+func (p *Plugin) getProviderHealth() (*ProviderProbe, error) {
+	data, appErr := p.API.KVGet(providerHealthKey)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var health *ProviderProbe
+	if err := json.Unmarshal(data, &health); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal provider health")
+	}
+
+	return health, nil
+}
+
+// saveProviderHealth records the result of a probe in the saved provider health with a compare
+// and set, as other cluster nodes may be probing at the same time, returning the previous and the
+// new provider health.
+func (p *Plugin) saveProviderHealth(probe *ProviderProbe) (*ProviderProbe, *ProviderProbe, error) {
+	for attempt := 0; attempt < maxProviderHealthSaveAttempts; attempt++ {
+		oldBytes, appErr := p.API.KVGet(providerHealthKey)
+		if appErr != nil {
+			return nil, nil, appErr
+		}
+
+		var previous *ProviderProbe
+		if oldBytes != nil {
+			if err := json.Unmarshal(oldBytes, &previous); err != nil {
+				return nil, nil, errors.Wrap(err, "unable to unmarshal provider health")
+			}
+		}
+
+		health := nextProviderHealth(previous, probe)
+		newBytes, err := json.Marshal(health)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "unable to marshal provider health")
+		}
+
+		updated, appErr := p.API.KVCompareAndSet(providerHealthKey, oldBytes, newBytes)
+		if appErr != nil {
+			return nil, nil, appErr
+		}
+		if updated {
+			return previous, health, nil
+		}
+	}
+
+	return nil, nil, errors.New("provider health kept changing concurrently")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextProviderHealth(t *testing.T) {
+	failed := &ProviderProbe{Provider: providerAWS, Error: "timeout", CheckedAt: 1000}
+	health := nextProviderHealth(nil, failed)
+	assert.Equal(t, 1, health.ConsecutiveFailures)
+	assert.Equal(t, int64(1000), health.FailingSince)
+
+	health = nextProviderHealth(health, &ProviderProbe{Provider: providerAWS, Error: "timeout", CheckedAt: 2000})
+	assert.Equal(t, 2, health.ConsecutiveFailures)
+	assert.Equal(t, int64(1000), health.FailingSince)
+	assert.Equal(t, int64(2000), health.CheckedAt)
+
+	health = nextProviderHealth(health, &ProviderProbe{Provider: providerAWS, Reachable: true, CheckedAt: 3000})
+	assert.Equal(t, &ProviderProbe{Provider: providerAWS, Reachable: true, CheckedAt: 3000}, health)
+
+	// The probe isn't changed, as it may be kept as the last one of the server.
+	assert.Equal(t, 0, failed.ConsecutiveFailures)
+}
+
+func TestGetProviderHealthAlert(t *testing.T) {
+	var previous *ProviderProbe
+	var alerts []string
+	for i := 0; i < providerHealthAlertFailures+1; i++ {
+		health := nextProviderHealth(previous, &ProviderProbe{Provider: providerAWS, Error: "UnrecognizedClientException", CheckedAt: int64(i + 1)})
+		if alert := getProviderHealthAlert(previous, health); alert != "" {
+			alerts = append(alerts, alert)
+		}
+		previous = health
+	}
+
+	// Admins are alerted once when the provider keeps failing.
+	assert.Len(t, alerts, 1)
+	assert.Contains(t, alerts[0], "Translation provider unavailable")
+	assert.Contains(t, alerts[0], "UnrecognizedClientException")
+
+	recovered := nextProviderHealth(previous, &ProviderProbe{Provider: providerAWS, Reachable: true})
+	assert.Contains(t, getProviderHealthAlert(previous, recovered), "Translation provider recovered")
+	assert.Equal(t, "", getProviderHealthAlert(recovered, nextProviderHealth(recovered, &ProviderProbe{Provider: providerAWS, Reachable: true})))
+
+	// A short failure recovered before admins were alerted isn't worth telling them.
+	short := nextProviderHealth(nil, &ProviderProbe{Provider: providerAWS, Error: "timeout"})
+	assert.Equal(t, "", getProviderHealthAlert(short, nextProviderHealth(short, &ProviderProbe{Provider: providerAWS, Reachable: true})))
+}
+
+func TestGetProviderHealthText(t *testing.T) {
+	assert.Equal(t, "\nTranslation provider: not checked yet.\n", getProviderHealthText(nil, true))
+
+	available := &ProviderProbe{Provider: providerAWS, Reachable: true, LatencyMs: 120, CheckedAt: 1592000000000}
+	assert.Equal(t, "\nTranslation provider: available, answering in 120 ms when checked at 2020-06-12 22:13 UTC.\n", getProviderHealthText(available, false))
+
+	unavailable := &ProviderProbe{Provider: providerAWS, Error: "timeout", CheckedAt: 1592000000000, FailingSince: 1591999400000, ConsecutiveFailures: 3}
+	assert.Equal(t, "\nTranslation provider: unavailable since 2020-06-12 22:03 UTC, last checked at 2020-06-12 22:13 UTC.\n", getProviderHealthText(unavailable, false))
+	assert.Equal(t, "\nTranslation provider: unavailable since 2020-06-12 22:03 UTC, last checked at 2020-06-12 22:13 UTC.\nLast error: `timeout`\n", getProviderHealthText(unavailable, true))
+}
+
+func TestNewHealthResponse(t *testing.T) {
+	health := newHealthResponse(errors.New("Failed to resolve AWS secret key from vault:secret/data/translate"), nil, 2)
+	assert.Equal(t, healthStatusUnavailable, health.Status)
+	assert.False(t, health.ConfigurationValid)
+	assert.Equal(t, 2, health.PendingPosts)
+	assert.Nil(t, health.Provider)
+
+	probe := &ProviderProbe{Provider: providerAWS, Error: "AccessDeniedException: arn:aws:iam::123456789012:user/translate", CheckedAt: 1000}
+	health = newHealthResponse(nil, probe, 0)
+	assert.Equal(t, healthStatusUnavailable, health.Status)
+	assert.True(t, health.ConfigurationValid)
+	assert.Equal(t, &ProviderProbe{Provider: providerAWS, CheckedAt: 1000}, health.Provider)
+
+	// The probe isn't changed, as it is kept as the last one of the server.
+	assert.NotEmpty(t, probe.Error)
+
+	health = newHealthResponse(nil, &ProviderProbe{Provider: providerAWS, Reachable: true}, 0)
+	assert.Equal(t, healthStatusOK, health.Status)
+
+	resp, err := json.Marshal(newHealthResponse(nil, probe, 0))
+	require.NoError(t, err)
+	assert.NotContains(t, string(resp), "123456789012")
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	historyKeyPrefix = "history_"
+
+	// maxHistoryEntries caps the translations kept in the history of a user, dropping the oldest.
+	maxHistoryEntries = 200
+
+	defaultHistoryPerPage = 20
+	maxHistoryPerPage     = 100
+
+	// maxHistorySaveAttempts bounds the retries of saving a history updated concurrently.
+	maxHistorySaveAttempts = 5
+)
+
+// TranslationHistoryEntry is a translation of a post made for a user
+type TranslationHistoryEntry struct {
+	PostID         string `json:"post_id"`
+	ChannelID      string `json:"channel_id"`
+	PostLink       string `json:"post_link,omitempty"`
+	SourceLanguage string `json:"source_lang"`
+	TargetLanguage string `json:"target_lang"`
+	CreateAt       int64  `json:"create_at"`
+}
+
+// TranslationHistoryResponse is a page of the translation history of a user, newest first
+type TranslationHistoryResponse struct {
+	Entries []*TranslationHistoryEntry `json:"entries"`
+	Page    int                        `json:"page"`
+	PerPage int                        `json:"per_page"`
+	Total   int                        `json:"total"`
+}
+
+func getHistoryKey(userID string) string {
+	return historyKeyPrefix + userID
+}
+
+// recordTranslationHistory adds a translation to the history of a user with a compare and set, as
+// other translations of the user may be recorded at the same time. Failures are only logged, as
+// the history mustn't get in the way of translating.
+func (p *Plugin) recordTranslationHistory(userID string, post *model.Post, source, target string) {
+	if userID == "" {
+		return
+	}
+
+	entry := &TranslationHistoryEntry{
+		PostID:         post.Id,
+		ChannelID:      post.ChannelId,
+		SourceLanguage: source,
+		TargetLanguage: target,
+		CreateAt:       model.GetMillis(),
+	}
+
+	if err := p.addTranslationHistoryEntry(userID, entry); err != nil {
+		p.API.LogError("Failed to record translation history", "user_id", userID, "post_id", post.Id, "err", err.Error())
+	}
+}
+
+func (p *Plugin) addTranslationHistoryEntry(userID string, entry *TranslationHistoryEntry) error {
+	key := getHistoryKey(userID)
+	for attempt := 0; attempt < maxHistorySaveAttempts; attempt++ {
+		oldBytes, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return appErr
+		}
+
+		var entries []*TranslationHistoryEntry
+		if oldBytes != nil {
+			if err := json.Unmarshal(oldBytes, &entries); err != nil {
+				return errors.Wrap(err, "unable to unmarshal translation history")
+			}
+		}
+
+		entries = append([]*TranslationHistoryEntry{entry}, entries...)
+		if len(entries) > maxHistoryEntries {
+			entries = entries[:maxHistoryEntries]
+		}
+
+		newBytes, err := json.Marshal(entries)
+		if err != nil {
+			return errors.Wrap(err, "unable to marshal translation history")
+		}
+
+		updated, appErr := p.API.KVCompareAndSet(key, oldBytes, newBytes)
+		if appErr != nil {
+			return appErr
+		}
+		if updated {
+			return nil
+		}
+	}
+
+	return errors.New("translation history kept changing concurrently")
+}
+
+// purgeTranslationHistory drops the entries of a translation history made before cutoff, deleting
+// the history when none is left, and reports whether it was deleted. Translations may be recorded
+// meanwhile, so the history is only replaced if it didn't change.
+func (p *Plugin) purgeTranslationHistory(key string, cutoff int64) (bool, error) {
+	for attempt := 0; attempt < maxHistorySaveAttempts; attempt++ {
+		oldBytes, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return false, appErr
+		}
+		if oldBytes == nil {
+			return false, nil
+		}
+
+		var entries []*TranslationHistoryEntry
+		if err := json.Unmarshal(oldBytes, &entries); err != nil {
+			return false, errors.Wrap(err, "unable to unmarshal translation history")
+		}
+
+		// Entries are newest first.
+		kept := len(entries)
+		for i, entry := range entries {
+			if entry.CreateAt < cutoff {
+				kept = i
+				break
+			}
+		}
+		if kept == len(entries) {
+			return false, nil
+		}
+
+		if kept == 0 {
+			deleted, deleteErr := p.API.KVCompareAndDelete(key, oldBytes)
+			if deleteErr != nil {
+				return false, deleteErr
+			}
+			if deleted {
+				return true, nil
+			}
+			continue
+		}
+
+		newBytes, err := json.Marshal(entries[:kept])
+		if err != nil {
+			return false, errors.Wrap(err, "unable to marshal translation history")
+		}
+
+		updated, appErr := p.API.KVCompareAndSet(key, oldBytes, newBytes)
+		if appErr != nil {
+			return false, appErr
+		}
+		if updated {
+			return false, nil
+		}
+	}
+
+	return false, errors.New("translation history kept changing concurrently")
+}
+
+// getTranslationHistory returns the translations made for a user, newest first.
+func (p *Plugin) getTranslationHistory(userID string) ([]*TranslationHistoryEntry, error) {
+	historyBytes, appErr := p.API.KVGet(getHistoryKey(userID))
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	entries := []*TranslationHistoryEntry{}
+	if historyBytes == nil {
+		return entries, nil
+	}
+
+	if err := json.Unmarshal(historyBytes, &entries); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal translation history")
+	}
+
+	return entries, nil
+}
+
+// addPostLinks sets the permalinks of history entries, linking posts of direct and group
+// messages through the first team of the user, as their channels belong to no team.
+func (p *Plugin) addPostLinks(userID string, entries []*TranslationHistoryEntry) {
+	siteURL := p.API.GetConfig().ServiceSettings.SiteURL
+	if siteURL == nil || *siteURL == "" {
+		return
+	}
+
+	teamNames := map[string]string{}
+	getTeamName := func(channelID string) string {
+		if name, ok := teamNames[channelID]; ok {
+			return name
+		}
+
+		name := ""
+		if channel, appErr := p.API.GetChannel(channelID); appErr == nil && channel.TeamId != "" {
+			if team, appErr := p.API.GetTeam(channel.TeamId); appErr == nil {
+				name = team.Name
+			}
+		} else if teams, appErr := p.API.GetTeamsForUser(userID); appErr == nil && len(teams) > 0 {
+			name = teams[0].Name
+		}
+		teamNames[channelID] = name
+
+		return name
+	}
+
+	for _, entry := range entries {
+		if teamName := getTeamName(entry.ChannelID); teamName != "" {
+			entry.PostLink = fmt.Sprintf("%s/%s/pl/%s", strings.TrimSuffix(*siteURL, "/"), teamName, entry.PostID)
+		}
+	}
+}
+
+func (p *Plugin) getHistory(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+
+	page := 0
+	if value := r.URL.Query().Get("page"); value != "" {
+		var err error
+		if page, err = strconv.Atoi(value); err != nil || page < 0 {
+			writeAPIError(w, newInvalidParameterError("page"))
+			return
+		}
+	}
+
+	perPage := defaultHistoryPerPage
+	if value := r.URL.Query().Get("per_page"); value != "" {
+		var err error
+		if perPage, err = strconv.Atoi(value); err != nil || perPage <= 0 || perPage > maxHistoryPerPage {
+			writeAPIError(w, newInvalidParameterError("per_page"))
+			return
+		}
+	}
+
+	entries, err := p.getTranslationHistory(userID)
+	if err != nil {
+		p.API.LogError("Failed to get translation history", "user_id", userID, "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to get translation history", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	response := &TranslationHistoryResponse{
+		Entries: []*TranslationHistoryEntry{},
+		Page:    page,
+		PerPage: perPage,
+		Total:   len(entries),
+	}
+	if start := page * perPage; start < len(entries) {
+		end := start + perPage
+		if end > len(entries) {
+			end = len(entries)
+		}
+		response.Entries = entries[start:end]
+	}
+	p.addPostLinks(userID, response.Entries)
+
+	resp, _ := json.Marshal(response)
+	w.Write(resp)
+}
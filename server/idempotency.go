@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	translationKeyPrefix = "translation_"
+
+	// translationKeyExpiry is how long in seconds a delivered translation is remembered.
+	translationKeyExpiry = 7 * 24 * 60 * 60
+)
+
+// getTranslationKey returns the idempotency key of the translation of a revision of a post into a
+// language pair, hashed to fit the length limit of KV keys.
+func getTranslationKey(post *model.Post, source, target string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%s:%s", post.Id, post.UpdateAt, source, target)))
+	return translationKeyPrefix + hex.EncodeToString(sum[:16])
+}
+
+// claimTranslation atomically records that the translation of a post is being made, and
+// reports whether it wasn't already, so that hook retries, other cluster nodes or rapid edits
+// don't deliver the same translation twice. Translations are delivered when the KV store fails,
+// as a duplicate is better than a missing translation. The time of the claim is stored, so that
+// it can be purged once older than the data retention.
+func (p *Plugin) claimTranslation(post *model.Post, source, target string) bool {
+	claimedAt := []byte(strconv.FormatInt(model.GetMillis(), 10))
+	claimed, appErr := p.API.KVSetWithOptions(getTranslationKey(post, source, target), claimedAt, model.PluginKVSetOptions{
+		Atomic:          true,
+		OldValue:        nil,
+		ExpireInSeconds: p.getConfiguration().getRetentionExpiry(translationKeyExpiry),
+	})
+	if appErr != nil {
+		p.API.LogError("Failed to claim translation", "post_id", post.Id, "err", appErr.Error())
+		return true
+	}
+
+	return claimed
+}
+
+// releaseTranslation forgets the claim of the translation of a post which failed to be translated
+// or delivered, so that it isn't left untranslated for good.
+func (p *Plugin) releaseTranslation(post *model.Post, source, target string) {
+	if appErr := p.API.KVDelete(getTranslationKey(post, source, target)); appErr != nil {
+		p.API.LogError("Failed to release translation", "post_id", post.Id, "err", appErr.Error())
+	}
+}
+
+// getTranslateRestKey returns the idempotency key of the translation of the rest of a long post
+// from an offset into a target language, sharing the prefix of translation keys to be purged
+// along with them.
+func getTranslateRestKey(postID string, offset int, target string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("rest:%s:%d:%s", postID, offset, target)))
+	return translationKeyPrefix + hex.EncodeToString(sum[:16])
+}
+
+// claimTranslateRest atomically records that the rest of a long post is being translated, and
+// reports whether it wasn't already, so that clicking Translate rest several times, or from
+// several clients, only posts the translation once. Unlike the claims of posts, failing to claim
+// it denies the translation, as the button can be clicked again.
+func (p *Plugin) claimTranslateRest(postID string, offset int, target string) bool {
+	claimedAt := []byte(strconv.FormatInt(model.GetMillis(), 10))
+	claimed, appErr := p.API.KVSetWithOptions(getTranslateRestKey(postID, offset, target), claimedAt, model.PluginKVSetOptions{
+		Atomic:          true,
+		OldValue:        nil,
+		ExpireInSeconds: p.getConfiguration().getRetentionExpiry(translationKeyExpiry),
+	})
+	if appErr != nil {
+		p.API.LogError("Failed to claim translation of the rest of post", "post_id", postID, "err", appErr.Error())
+		return false
+	}
+
+	return claimed
+}
+
+// releaseTranslateRest forgets the claim of the translation of the rest of a long post which
+// failed, so that it can be translated again.
+func (p *Plugin) releaseTranslateRest(postID string, offset int, target string) {
+	if appErr := p.API.KVDelete(getTranslateRestKey(postID, offset, target)); appErr != nil {
+		p.API.LogError("Failed to release translation of the rest of post", "post_id", postID, "err", appErr.Error())
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+)
+
+func TestClaimTranslationWhenKVStoreFails(t *testing.T) {
+	post := &model.Post{Id: model.NewId(), UpdateAt: model.GetMillis()}
+	appErr := model.NewAppError("KVSetWithOptions", "plugin.kv.set.app_error", nil, "", http.StatusInternalServerError)
+
+	api := &plugintest.API{}
+	api.On("KVSetWithOptions", mock.AnythingOfType("string"), mock.Anything, mock.Anything).Return(false, appErr)
+	api.On("LogError", "Failed to claim translation", "post_id", post.Id, "err", appErr.Error())
+	api.On("LogError", "Failed to claim translation of the rest of post", "post_id", post.Id, "err", appErr.Error())
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	p.setConfiguration(&configuration{})
+
+	assert.True(t, p.claimTranslation(post, "ko", "en"), "a duplicate is better than a missing translation")
+	assert.False(t, p.claimTranslateRest(post.Id, 10, "en"), "the button can be clicked again")
+}
+
+func TestTranslatePostsReleasesClaimWhenDeliveryFails(t *testing.T) {
+	provider, requests := newTestProvider("Hello")
+	defer provider.Close()
+
+	userInfo := &UserInfo{UserID: model.NewId(), Activated: true, SourceLanguage: "ko", TargetLanguage: "en"}
+	post := &model.Post{Id: model.NewId(), UserId: userInfo.UserID, ChannelId: model.NewId(), Message: "안녕하세요", UpdateAt: model.GetMillis()}
+	claimKey := getTranslationKey(post, userInfo.SourceLanguage, userInfo.TargetLanguage)
+	appErr := model.NewAppError("CreatePost", "app.post.save.app_error", nil, "", http.StatusInternalServerError)
+
+	api := &plugintest.API{}
+	api.On("KVSetWithOptions", claimKey, mock.Anything, mock.Anything).Return(true, nil)
+	api.On("GetChannel", post.ChannelId).Return(nil, model.NewAppError("GetChannel", "app.channel.get.existing.app_error", nil, "", http.StatusNotFound))
+	api.On("KVGet", channelInfoKeyPrefix+post.ChannelId).Return(nil, nil)
+	api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(nil, appErr)
+	api.On("LogError", "Failed to create translation post", "post_id", post.Id, "err", appErr.Error())
+	api.On("KVDelete", claimKey).Return(nil)
+
+	p := &Plugin{botUserID: model.NewId()}
+	p.SetAPI(api)
+	p.setConfiguration(&configuration{AWSAccessKeyID: "id", AWSSecretAccessKey: "secret", AWSEndpoint: provider.URL})
+	require.NoError(t, p.reloadTranslateService())
+
+	p.translatePosts([]*model.Post{post}, userInfo)
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+	api.AssertExpectations(t)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/translate"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+func hasPostActions(post *model.Post) bool {
+	for _, attachment := range post.Attachments() {
+		if len(attachment.Actions) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// translateInteractivePost sends channel members with autotranslation turned on an ephemeral
+// translation of a post of a bot with interactive actions, such as polls of other plugins,
+// including the labels of its buttons and options so that they know which one to pick.
+func (p *Plugin) translateInteractivePost(post *model.Post) {
+	userInfosByTarget := map[string][]*UserInfo{}
+	for _, userInfo := range p.getActivatedChannelMembers(post.ChannelId) {
+		if userInfo.UserID != post.UserId {
+			userInfosByTarget[userInfo.TargetLanguage] = append(userInfosByTarget[userInfo.TargetLanguage], userInfo)
+		}
+	}
+
+	if len(userInfosByTarget) == 0 {
+		return
+	}
+
+	svc, err := p.getTranslateService()
+	if err != nil {
+		p.API.LogError("Failed to get translate service", "err", err.Error())
+		return
+	}
+
+	ctx := newAuditContext(p.newGlossaryContext(context.Background(), post.ChannelId), "", post.Id, post.ChannelId)
+	detected := p.detectTextLanguage(ctx, post.Message+"\n"+getAttachmentsText(post.Attachments())).Language
+	for target, userInfos := range userInfosByTarget {
+		if detected == target {
+			continue
+		}
+
+		attachments, err := p.translateInteractiveContent(ctx, svc, target, post)
+		if err != nil {
+			p.API.LogError("Failed to translate interactive post", "post_id", post.Id, "err", err.Error())
+			continue
+		}
+
+		for _, userInfo := range userInfos {
+			p.sendEphemeralTranslation(post, userInfo.UserID, attachments)
+		}
+	}
+}
+
+// translateInteractiveContent translates the message and attachments of a post into target,
+// listing the labels of its actions and their options next to their translation.
+func (p *Plugin) translateInteractiveContent(ctx context.Context, svc *translate.Translate, target string, post *model.Post) ([]*model.SlackAttachment, error) {
+	var attachments []*model.SlackAttachment
+
+	translatedMessage := ""
+	if strings.TrimSpace(post.Message) != "" {
+		translated, err := p.translateLongText(ctx, svc, autoLanguage, target, post.Message)
+		if err != nil {
+			return nil, err
+		}
+		translatedMessage = translated
+	}
+
+	for _, attachment := range post.Attachments() {
+		translatedAttachments, err := p.translateAttachments(ctx, svc, autoLanguage, target, []*model.SlackAttachment{attachment})
+		if err != nil {
+			return nil, err
+		}
+
+		translated := attachment
+		if len(translatedAttachments) > 0 {
+			translated = translatedAttachments[0]
+		} else {
+			copied := *attachment
+			translated = &copied
+		}
+		translated.Actions = nil
+
+		var labels []string
+		for _, action := range attachment.Actions {
+			label, err := p.translateLabel(ctx, svc, target, action.Name)
+			if err != nil {
+				return nil, err
+			}
+			labels = append(labels, "* "+label)
+
+			for _, option := range action.Options {
+				label, err := p.translateLabel(ctx, svc, target, option.Text)
+				if err != nil {
+					return nil, err
+				}
+				labels = append(labels, "  * "+label)
+			}
+		}
+
+		if len(labels) > 0 {
+			translated.Text = strings.TrimSpace(translated.Text + "\n\n" + strings.Join(labels, "\n"))
+		}
+		attachments = append(attachments, translated)
+	}
+
+	if translatedMessage == "" && len(attachments) == 0 {
+		return nil, nil
+	}
+
+	attachments = addTranslationHeader(&UserInfo{SourceLanguage: autoLanguage, TargetLanguage: target}, translatedMessage, attachments)
+	addShowOriginalAction(post.Id, attachments)
+
+	return attachments, nil
+}
+
+// sendEphemeralTranslation shows the translation of a post to a single user.
+func (p *Plugin) sendEphemeralTranslation(post *model.Post, userID string, attachments []*model.SlackAttachment) {
+	ephemeralPost := &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: post.ChannelId,
+		RootId:    post.RootId,
+		ParentId:  post.RootId,
+	}
+	ephemeralPost.AddProp(translationSourcePostIDProp, post.Id)
+	model.ParseSlackAttachment(ephemeralPost, attachments)
+
+	p.API.SendEphemeralPost(userID, ephemeralPost)
+}
+
+// translateLabel returns the label of an action or option followed by its translation.
+func (p *Plugin) translateLabel(ctx context.Context, svc *translate.Translate, target, label string) (string, error) {
+	if strings.TrimSpace(label) == "" {
+		return label, nil
+	}
+
+	translated, err := p.translateTextWithContext(ctx, svc, autoLanguage, target, label)
+	if err != nil {
+		return "", err
+	}
+
+	if translated == label {
+		return fmt.Sprintf("`%s`", label), nil
+	}
+
+	return fmt.Sprintf("`%s` → %s", label, translated), nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	// interceptedProp marks a post translated before it was committed, which needs no further translation.
+	interceptedProp = "autotranslate_intercepted"
+
+	// interceptTimedOutProp marks a post whose translation took longer than the interception
+	// timeout, which isn't translated again after it is committed.
+	interceptTimedOutProp = "autotranslate_intercept_timed_out"
+)
+
+const defaultInterceptionTimeout = 2000
+
+var errInterceptionTimeout = errors.New("translation took longer than the interception timeout")
+
+// interceptProps are the props marking how a post was intercepted, which decide whether it is
+// translated after it is committed, so that only the plugin may set them.
+var interceptProps = []string{interceptedProp, interceptTimedOutProp}
+
+// stripInterceptProps removes the intercept props clients sent along with a new post, so that
+// they can't keep it from being translated.
+func stripInterceptProps(post *model.Post) *model.Post {
+	for _, prop := range interceptProps {
+		delete(post.Props, prop)
+	}
+
+	return post
+}
+
+// keepInterceptProps sets the intercept props of an edited post back to the ones the plugin set
+// when it was posted, whatever the client sent.
+func keepInterceptProps(newPost, oldPost *model.Post) *model.Post {
+	for _, prop := range interceptProps {
+		if value := oldPost.GetProp(prop); value != nil {
+			newPost.AddProp(prop, value)
+		} else {
+			delete(newPost.Props, prop)
+		}
+	}
+
+	return newPost
+}
+
+// interceptPost translates a new post before it is committed in channels whose translations
+// rewrite or annotate the original message. Edits aren't intercepted, as the message being edited
+// is the translation already. Posts which fail to be translated are left untouched, to be
+// translated after they are posted as in the post mode, except for those taking longer than the
+// interception timeout: their translation is abandoned, but the provider may process it anyway,
+// so they are posted untranslated rather than translated twice.
+func (p *Plugin) interceptPost(post *model.Post) *model.Post {
+	// Posts of bots, including our own, are never rewritten so that translations can't loop.
+	if post.UserId == p.botUserID || post.Type != model.POST_DEFAULT || isBotPost(post) || isNoTranslatePost(post) {
+		return post
+	}
+
+	// Messages needing several requests to the provider are translated after they are posted.
+	if strings.TrimSpace(post.Message) == "" || len(post.Message) > maxTranslateTextBytes {
+		return post
+	}
+
+	channelInfo, _ := p.getChannelInfo(post.ChannelId)
+	if channelInfo == nil {
+		return post
+	}
+
+	deliveryMode := channelInfo.getDeliveryMode()
+	if deliveryMode != deliveryModeRewrite && deliveryMode != deliveryModeAnnotate {
+		return post
+	}
+
+	if !p.getConfiguration().TranslateMessages || !p.isProviderAllowed(post.ChannelId, providerAWS) {
+		return post
+	}
+
+	userInfo, apiErr := p.getUserInfo(post.UserId)
+	if apiErr != nil || !userInfo.Activated || !hasTranslatableText(post.Message) || !p.isTranslatedForAuthor(post.ChannelId, userInfo) || !p.hasConsent(post.UserId) {
+		return post
+	}
+
+	translated, err := p.translateBeforePosting(post, userInfo)
+	if err == errInterceptionTimeout {
+		p.API.LogWarn("Failed to translate post before posting, posting it untranslated", "channel_id", post.ChannelId, "err", err.Error())
+		post.AddProp(interceptTimedOutProp, true)
+		return post
+	}
+	if err != nil {
+		p.API.LogError("Failed to translate post before posting, translating it after", "channel_id", post.ChannelId, "err", err.Error())
+		return post
+	}
+
+	if translated == post.Message {
+		return post
+	}
+
+	post.AddProp(interceptedProp, true)
+	post.AddProp(originalMessageProp, post.Message)
+	post.AddProp(translationSourceLanguageProp, userInfo.SourceLanguage)
+	post.AddProp(translationTargetLanguageProp, userInfo.TargetLanguage)
+
+	if deliveryMode == deliveryModeRewrite {
+		post.Message = translated
+	} else {
+		post.Message = formatMergedTranslation(post.Message, userInfo.SourceLanguage, userInfo.TargetLanguage, translated)
+	}
+
+	return post
+}
+
+// translateBeforePosting detects the language of a new post and translates it into the target
+// language of its author, returning its message unchanged when it needs no translation. Both the
+// detection and the translation may ask the provider, so they share the interception timeout,
+// abandoning the requests once it expires as the post waits for them to be committed.
+func (p *Plugin) translateBeforePosting(post *model.Post, userInfo *UserInfo) (string, error) {
+	// The post has no ID before it is posted, so only its author and channel are known.
+	ctx, cancel := context.WithTimeout(newAuditContext(context.Background(), post.UserId, "", post.ChannelId), p.getConfiguration().getInterceptionTimeout())
+	defer cancel()
+
+	detected := p.detectPostLanguage(ctx, post, userInfo, post.Message)
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", errInterceptionTimeout
+	}
+	if detected.Language == userInfo.TargetLanguage || p.isUncertainDetection(ctx, post, userInfo, detected, true) {
+		return post.Message, nil
+	}
+
+	svc, err := p.getTranslateService()
+	if err != nil {
+		return "", err
+	}
+
+	translated, err := p.translateTextWithContext(p.newGlossaryContext(ctx, post.ChannelId), svc, userInfo.SourceLanguage, userInfo.TargetLanguage, post.Message)
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", errInterceptionTimeout
+	}
+
+	return translated, err
+}
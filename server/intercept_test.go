@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+)
+
+// newSlowProvider returns a server answering requests to the provider only after seconds,
+// counting them.
+func newSlowProvider() (*httptest.Server, chan struct{}) {
+	requests := make(chan struct{}, 10)
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- struct{}{}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+
+	return provider, requests
+}
+
+// newSlowProviderConfiguration returns a configuration detecting languages with the provider at
+// providerURL, waiting 100ms for it to translate intercepted posts.
+func newSlowProviderConfiguration(providerURL string) *configuration {
+	return &configuration{
+		AWSAccessKeyID:      "id",
+		AWSSecretAccessKey:  "secret",
+		AWSEndpoint:         providerURL,
+		LanguageDetector:    detectorProvider,
+		InterceptionTimeout: "100",
+		TranslateMessages:   true,
+	}
+}
+
+func TestTranslateBeforePostingWithSlowDetector(t *testing.T) {
+	provider, requests := newSlowProvider()
+	defer provider.Close()
+
+	p := &Plugin{}
+	p.setConfiguration(newSlowProviderConfiguration(provider.URL))
+	require.NoError(t, p.reloadTranslateService())
+
+	// The message is too short for the local detector, so the provider is asked.
+	post := &model.Post{UserId: model.NewId(), ChannelId: model.NewId(), Message: "ok"}
+	userInfo := &UserInfo{UserID: post.UserId, Activated: true, SourceLanguage: "ko", TargetLanguage: "en"}
+
+	start := time.Now()
+	_, err := p.translateBeforePosting(post, userInfo)
+	assert.Equal(t, errInterceptionTimeout, err)
+	assert.True(t, time.Since(start) < time.Second, "the detection is abandoned at the interception timeout")
+	assert.Len(t, requests, 1, "only the detection reached the provider")
+}
+
+func TestInterceptPostTimeout(t *testing.T) {
+	provider, requests := newSlowProvider()
+	defer provider.Close()
+
+	userInfo := &UserInfo{UserID: model.NewId(), Activated: true, SourceLanguage: "ko", TargetLanguage: "en", TranslatePrivateChannels: true}
+	userInfoBytes, _ := json.Marshal(userInfo)
+	post := &model.Post{UserId: userInfo.UserID, ChannelId: model.NewId(), Message: "ok"}
+	channelInfoBytes, _ := json.Marshal(&ChannelInfo{ChannelID: post.ChannelId, DeliveryMode: deliveryModeRewrite})
+
+	api := &plugintest.API{}
+	api.On("KVGet", channelInfoKeyPrefix+post.ChannelId).Return(channelInfoBytes, nil)
+	api.On("KVGet", userInfo.UserID).Return(userInfoBytes, nil)
+	api.On("LogWarn", "Failed to translate post before posting, posting it untranslated", "channel_id", post.ChannelId, "err", errInterceptionTimeout.Error())
+
+	p := &Plugin{botUserID: model.NewId()}
+	p.SetAPI(api)
+	p.setConfiguration(newSlowProviderConfiguration(provider.URL))
+	require.NoError(t, p.reloadTranslateService())
+
+	post = p.interceptPost(post)
+	assert.Equal(t, "ok", post.Message, "the post is posted untranslated")
+	assert.Equal(t, true, post.GetProp(interceptTimedOutProp), "the post isn't translated again once posted")
+	assert.Nil(t, post.GetProp(interceptedProp))
+	assert.Len(t, requests, 1)
+	api.AssertExpectations(t)
+	api.AssertNotCalled(t, "CreatePost", mock.Anything)
+}
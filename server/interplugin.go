@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/http"
+)
+
+// sourcePluginIDContextKey holds the ID of the plugin which sent a request through the server, as
+// other plugins do with PluginHTTP.
+const sourcePluginIDContextKey contextKey = "source_plugin_id"
+
+// withSourcePlugin only lets requests of other plugins through, which the server marks with the ID
+// of the plugin sending them, so that other plugins can translate text without implementing
+// providers themselves. These requests aren't rate limited, as they all come from the server.
+func (p *Plugin) withSourcePlugin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sourcePluginID, _ := r.Context().Value(sourcePluginIDContextKey).(string)
+		if sourcePluginID == "" {
+			writeAPIError(w, &APIErrorResponse{ID: apiErrorNotAuthorized, Message: "Not authorized", StatusCode: http.StatusUnauthorized})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
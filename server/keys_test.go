@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestKVKeyLength checks that the KV keys built from the longest IDs and values fit the length
+// limit of KV keys, as longer keys fail to be saved.
+func TestKVKeyLength(t *testing.T) {
+	id := model.NewId()
+
+	for name, key := range map[string]string{
+		"channel info": channelInfoKeyPrefix + id,
+		"cache":        getTranslationCacheKey(&model.Post{Id: id, UpdateAt: model.GetMillis()}, "zh-TW"),
+		"feedback":     getFeedbackKey(id, id),
+		"history":      getHistoryKey(id),
+		"translation":  getTranslationKey(&model.Post{Id: id, UpdateAt: model.GetMillis()}, autoLanguage, "zh-TW"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.LessOrEqual(t, utf8.RuneCountInString(key), model.KEY_VALUE_KEY_MAX_RUNES, key)
+		})
+	}
+}
+
+func TestKVKeyUniqueness(t *testing.T) {
+	postID := model.NewId()
+	userID := model.NewId()
+	post := &model.Post{Id: postID, UpdateAt: 1}
+	edited := &model.Post{Id: postID, UpdateAt: 2}
+
+	for name, tc := range map[string]struct {
+		key   string
+		other string
+	}{
+		"feedback of another user": {
+			key:   getFeedbackKey(postID, userID),
+			other: getFeedbackKey(postID, model.NewId()),
+		},
+		"feedback on another post": {
+			key:   getFeedbackKey(postID, userID),
+			other: getFeedbackKey(model.NewId(), userID),
+		},
+		"translation of another revision": {
+			key:   getTranslationKey(post, "en", "ko"),
+			other: getTranslationKey(edited, "en", "ko"),
+		},
+		"cached translation of another revision": {
+			key:   getTranslationCacheKey(post, "ko"),
+			other: getTranslationCacheKey(edited, "ko"),
+		},
+		"cached translation into another language": {
+			key:   getTranslationCacheKey(post, "ko"),
+			other: getTranslationCacheKey(post, "ja"),
+		},
+		"translation into another language": {
+			key:   getTranslationKey(post, "en", "ko"),
+			other: getTranslationKey(post, "en", "ja"),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.NotEqual(t, tc.key, tc.other)
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	languageProfileKeyPrefix = "langprofile_"
+
+	// languageProfileDecay weighs down the languages of earlier messages with each new message, so
+	// that the profile follows a user who changes language within a few dozen messages.
+	languageProfileDecay = 0.95
+
+	// minLanguageProfileWeight is the weight below which a language is dropped from a profile,
+	// keeping profiles to the few languages a user actually writes in.
+	minLanguageProfileWeight = 0.05
+
+	// minLanguageProfileMessages is the number of messages a profile must be learned from before
+	// its typical language is relied on.
+	minLanguageProfileMessages = 10
+
+	// minTypicalLanguageShare is the share of the weights a language must have to be the typical
+	// language of a user, users mixing languages having none.
+	minTypicalLanguageShare = 0.6
+
+	// maxLanguageProfileSaveAttempts bounds the retries of saving a profile updated concurrently.
+	maxLanguageProfileSaveAttempts = 5
+)
+
+// LanguageProfile is a rolling profile of the languages a user writes in, as detected in their
+// latest messages. Its fields are kept short, as a profile is saved after each message.
+type LanguageProfile struct {
+	// Weights of the languages of the latest messages, those of earlier messages decaying
+	Weights map[string]float64 `json:"w"`
+
+	// Number of messages the profile was learned from
+	Messages int `json:"n"`
+}
+
+func getLanguageProfileKey(userID string) string {
+	return languageProfileKeyPrefix + userID
+}
+
+// learn weighs down the languages of earlier messages and adds the language of a new one.
+func (lp *LanguageProfile) learn(language string) {
+	if lp.Weights == nil {
+		lp.Weights = map[string]float64{}
+	}
+
+	for code, weight := range lp.Weights {
+		weight *= languageProfileDecay
+		if weight < minLanguageProfileWeight {
+			delete(lp.Weights, code)
+			continue
+		}
+
+		// Three decimals are plenty and keep the saved profile small.
+		lp.Weights[code] = math.Round(weight*1000) / 1000
+	}
+
+	lp.Weights[language]++
+	lp.Messages++
+}
+
+// getTypicalLanguage returns the language most messages of the user are written in along with its
+// share of the weights, or an empty language when the profile can't tell yet.
+func (lp *LanguageProfile) getTypicalLanguage() (string, float64) {
+	if lp == nil || lp.Messages < minLanguageProfileMessages {
+		return "", 0
+	}
+
+	typical := ""
+	total := 0.0
+	for code, weight := range lp.Weights {
+		total += weight
+		if typical == "" || weight > lp.Weights[typical] || (weight == lp.Weights[typical] && code < typical) {
+			typical = code
+		}
+	}
+
+	if typical == "" {
+		return "", 0
+	}
+
+	share := lp.Weights[typical] / total
+	if share < minTypicalLanguageShare {
+		return "", 0
+	}
+
+	return typical, share
+}
+
+// support raises the confidence of a detection that isn't confident when it guessed the typical
+// language of the user, as a short message of a user writing mostly in Spanish is most likely
+// written in Spanish too. Other detections are returned as is.
+func (lp *LanguageProfile) support(detected *DetectedLanguage) *DetectedLanguage {
+	if detected.Language == "" || detected.isConfident() {
+		return detected
+	}
+
+	typical, share := lp.getTypicalLanguage()
+	if typical != detected.Language {
+		return detected
+	}
+
+	return &DetectedLanguage{
+		Language:   detected.Language,
+		Confidence: 1 - (1-detected.Confidence)*(1-share),
+		Detector:   detected.Detector,
+	}
+}
+
+// learnUserLanguage adds the language detected in a post to the profile of its author. Failures
+// are only logged, as profiles mustn't get in the way of translating.
+func (p *Plugin) learnUserLanguage(post *model.Post, language string) {
+	if err := p.addLanguageProfileMessage(post.UserId, language); err != nil {
+		p.API.LogError("Failed to update language profile", "user_id", post.UserId, "post_id", post.Id, "err", err.Error())
+	}
+}
+
+func (p *Plugin) addLanguageProfileMessage(userID, language string) error {
+	key := getLanguageProfileKey(userID)
+	for attempt := 0; attempt < maxLanguageProfileSaveAttempts; attempt++ {
+		oldBytes, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return appErr
+		}
+
+		profile := &LanguageProfile{}
+		if oldBytes != nil {
+			if err := json.Unmarshal(oldBytes, profile); err != nil {
+				return errors.Wrap(err, "unable to unmarshal language profile")
+			}
+		}
+
+		profile.learn(language)
+
+		newBytes, err := json.Marshal(profile)
+		if err != nil {
+			return errors.Wrap(err, "unable to marshal language profile")
+		}
+
+		updated, appErr := p.API.KVCompareAndSet(key, oldBytes, newBytes)
+		if appErr != nil {
+			return appErr
+		}
+		if updated {
+			return nil
+		}
+	}
+
+	return errors.New("language profile kept changing concurrently")
+}
+
+// getLanguageProfile returns the language profile of a user, which is nil when the user has none
+// yet or it can't be read.
+func (p *Plugin) getLanguageProfile(userID string) *LanguageProfile {
+	profileBytes, appErr := p.API.KVGet(getLanguageProfileKey(userID))
+	if appErr != nil || profileBytes == nil {
+		return nil
+	}
+
+	profile := &LanguageProfile{}
+	if err := json.Unmarshal(profileBytes, profile); err != nil {
+		p.API.LogWarn("Failed to unmarshal language profile", "user_id", userID, "err", err.Error())
+		return nil
+	}
+
+	return profile
+}
+
+// getLanguageSuggestion suggests language settings to a user from the typical language of their
+// messages, such as when turning autotranslation on for the first time, or returns an empty text
+// when the settings already suit them.
+func (p *Plugin) getLanguageSuggestion(userInfo *UserInfo) string {
+	typical, _ := p.getLanguageProfile(userInfo.UserID).getTypicalLanguage()
+	if typical == "" || languageCodes[typical] == "" {
+		return ""
+	}
+
+	if typical == userInfo.TargetLanguage {
+		return fmt.Sprintf("Your messages are mostly written in %s, which is your target language, so they won't be translated. Set another target language with `/autotranslate target [value]`.", languageCodes[typical])
+	}
+
+	if userInfo.SourceLanguage == autoLanguage {
+		return fmt.Sprintf("Your messages are mostly written in %s. Set it as your source language with `/autotranslate source %s` to translate them without detecting their language.", languageCodes[typical], typical)
+	}
+
+	return ""
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLanguageProfile(t *testing.T) {
+	t.Run("too few messages", func(t *testing.T) {
+		profile := &LanguageProfile{}
+		for i := 0; i < minLanguageProfileMessages-1; i++ {
+			profile.learn("es")
+		}
+
+		language, _ := profile.getTypicalLanguage()
+		assert.Equal(t, "", language)
+	})
+
+	t.Run("typical language", func(t *testing.T) {
+		profile := &LanguageProfile{}
+		for i := 0; i < 20; i++ {
+			profile.learn("es")
+		}
+		profile.learn("en")
+
+		language, share := profile.getTypicalLanguage()
+		assert.Equal(t, "es", language)
+		assert.True(t, share > 0.9)
+	})
+
+	t.Run("latest messages weigh most", func(t *testing.T) {
+		profile := &LanguageProfile{}
+		for i := 0; i < 50; i++ {
+			profile.learn("es")
+		}
+		for i := 0; i < 150; i++ {
+			profile.learn("fr")
+		}
+
+		language, _ := profile.getTypicalLanguage()
+		assert.Equal(t, "fr", language)
+		assert.NotContains(t, profile.Weights, "es")
+	})
+
+	t.Run("mixed languages", func(t *testing.T) {
+		profile := &LanguageProfile{}
+		for i := 0; i < 20; i++ {
+			profile.learn("es")
+			profile.learn("en")
+		}
+
+		language, _ := profile.getTypicalLanguage()
+		assert.Equal(t, "", language)
+	})
+
+	t.Run("no profile", func(t *testing.T) {
+		var profile *LanguageProfile
+		detected := &DetectedLanguage{Language: "es", Confidence: 0.4}
+		assert.Equal(t, detected, profile.support(detected))
+	})
+
+	t.Run("support", func(t *testing.T) {
+		profile := &LanguageProfile{}
+		for i := 0; i < 20; i++ {
+			profile.learn("es")
+		}
+
+		assert.True(t, profile.support(&DetectedLanguage{Language: "es", Confidence: 0.4}).isConfident())
+		assert.False(t, profile.support(&DetectedLanguage{Language: "pt", Confidence: 0.4}).isConfident())
+		assert.Equal(t, "", profile.support(&DetectedLanguage{}).Language)
+	})
+}
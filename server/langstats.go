@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const languageStatsKeyPrefix = "langstats_"
+
+// LanguageStats counts the messages of users detected in each language per channel during a day,
+// keyed by channel ID and then by language code
+type LanguageStats struct {
+	Day      string                      `json:"day"`
+	Channels map[string]map[string]int64 `json:"channels"`
+}
+
+func newLanguageStats(day string) *LanguageStats {
+	return &LanguageStats{
+		Day:      day,
+		Channels: map[string]map[string]int64{},
+	}
+}
+
+func (s *LanguageStats) add(other *LanguageStats) {
+	for channelID, languages := range other.Channels {
+		if s.Channels[channelID] == nil {
+			s.Channels[channelID] = map[string]int64{}
+		}
+		for language, messages := range languages {
+			s.Channels[channelID][language] += messages
+		}
+	}
+}
+
+// LanguageShare is the number and share of the messages of a channel detected in a language
+type LanguageShare struct {
+	Language string  `json:"language"`
+	Messages int64   `json:"messages"`
+	Share    float64 `json:"share"`
+}
+
+// ChannelLanguageStats is the distribution of the languages detected in the messages of a channel
+// as reported by the API, most frequent language first
+type ChannelLanguageStats struct {
+	ChannelID string           `json:"channel_id"`
+	Messages  int64            `json:"messages"`
+	Languages []*LanguageShare `json:"languages"`
+}
+
+func newChannelLanguageStats(channelID string, languages map[string]int64) *ChannelLanguageStats {
+	stats := &ChannelLanguageStats{
+		ChannelID: channelID,
+		Languages: []*LanguageShare{},
+	}
+	for language, messages := range languages {
+		stats.Messages += messages
+		stats.Languages = append(stats.Languages, &LanguageShare{Language: language, Messages: messages})
+	}
+
+	for _, share := range stats.Languages {
+		share.Share = float64(share.Messages) / float64(stats.Messages)
+	}
+
+	sort.Slice(stats.Languages, func(i, j int) bool {
+		if stats.Languages[i].Messages != stats.Languages[j].Messages {
+			return stats.Languages[i].Messages > stats.Languages[j].Messages
+		}
+		return stats.Languages[i].Language < stats.Languages[j].Language
+	})
+
+	return stats
+}
+
+func getLanguageStatsKey(day string) string {
+	return languageStatsKeyPrefix + day
+}
+
+// recordPostLanguage detects the language of a post of a user locally, as recording it mustn't be
+// charged by a provider, and counts it for the channel and the language profile of its author.
+// Messages whose language isn't detected confidently aren't counted.
+func (p *Plugin) recordPostLanguage(post *model.Post) {
+	if !hasTranslatableText(post.Message) {
+		return
+	}
+
+	language, confidence := detectLanguageConfidence(post.Message)
+	if language == "" || confidence < minDetectionConfidence {
+		return
+	}
+
+	p.recordChannelLanguage(post.ChannelId, language)
+	p.learnUserLanguage(post, language)
+}
+
+// recordChannelLanguage counts a message of a channel detected in a language in memory, to be saved
+// along with the usage statistics.
+func (p *Plugin) recordChannelLanguage(channelID, language string) {
+	day := time.Now().UTC().Format(statsDayFormat)
+
+	p.languageStatsLock.Lock()
+	defer p.languageStatsLock.Unlock()
+
+	if p.languageStats == nil {
+		p.languageStats = map[string]*LanguageStats{}
+	}
+
+	stats, ok := p.languageStats[day]
+	if !ok {
+		stats = newLanguageStats(day)
+		p.languageStats[day] = stats
+	}
+
+	if stats.Channels[channelID] == nil {
+		stats.Channels[channelID] = map[string]int64{}
+	}
+	stats.Channels[channelID][language]++
+}
+
+// flushLanguageStats adds the languages counted in memory to the saved ones.
+func (p *Plugin) flushLanguageStats() {
+	p.languageStatsLock.Lock()
+	pending := p.languageStats
+	p.languageStats = nil
+	p.languageStatsLock.Unlock()
+
+	for day, stats := range pending {
+		if err := p.saveLanguageStats(stats); err != nil {
+			p.API.LogError("Failed to save language statistics", "day", day, "err", err.Error())
+		}
+	}
+}
+
+// saveLanguageStats adds statistics to the saved ones with a compare and set, as other cluster
+// nodes may be saving theirs at the same time.
+func (p *Plugin) saveLanguageStats(stats *LanguageStats) error {
+	key := getLanguageStatsKey(stats.Day)
+	for attempt := 0; attempt < maxStatsSaveAttempts; attempt++ {
+		oldBytes, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return appErr
+		}
+
+		saved := newLanguageStats(stats.Day)
+		if oldBytes != nil {
+			if err := json.Unmarshal(oldBytes, saved); err != nil {
+				return errors.Wrap(err, "unable to unmarshal language statistics")
+			}
+		}
+		saved.add(stats)
+
+		newBytes, err := json.Marshal(saved)
+		if err != nil {
+			return errors.Wrap(err, "unable to marshal language statistics")
+		}
+
+		updated, appErr := p.API.KVCompareAndSet(key, oldBytes, newBytes)
+		if appErr != nil {
+			return appErr
+		}
+		if updated {
+			return nil
+		}
+	}
+
+	return errors.New("language statistics kept changing concurrently")
+}
+
+// getChannelLanguageStats returns the distribution of the languages detected in the messages of
+// each channel for the given number of days up to today, or of the given channel only, channels
+// with the most messages first.
+func (p *Plugin) getChannelLanguageStats(days int, channelID string) ([]*ChannelLanguageStats, error) {
+	total := newLanguageStats("")
+	today := time.Now().UTC()
+	for i := 0; i < days; i++ {
+		day := today.AddDate(0, 0, -i).Format(statsDayFormat)
+		statsBytes, appErr := p.API.KVGet(getLanguageStatsKey(day))
+		if appErr != nil {
+			return nil, appErr
+		}
+		if statsBytes == nil {
+			continue
+		}
+
+		stats := newLanguageStats(day)
+		if err := json.Unmarshal(statsBytes, stats); err != nil {
+			return nil, errors.Wrap(err, "unable to unmarshal language statistics")
+		}
+		total.add(stats)
+	}
+
+	reports := []*ChannelLanguageStats{}
+	for id, languages := range total.Channels {
+		if channelID == "" || id == channelID {
+			reports = append(reports, newChannelLanguageStats(id, languages))
+		}
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Messages != reports[j].Messages {
+			return reports[i].Messages > reports[j].Messages
+		}
+		return reports[i].ChannelID < reports[j].ChannelID
+	})
+
+	return reports, nil
+}
+
+// getChannelLanguagesText describes the distribution of the languages of a channel, such as
+// "Japanese `60%`, English `40%`".
+func getChannelLanguagesText(stats *ChannelLanguageStats) string {
+	var shares []string
+	for _, share := range stats.Languages {
+		name := languageCodes[share.Language]
+		if name == "" {
+			name = share.Language
+		}
+		shares = append(shares, fmt.Sprintf("%s `%.0f%%`", name, share.Share*100))
+	}
+
+	return strings.Join(shares, ", ")
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	volumeKeyPrefix = "volume_"
+
+	defaultLeaderboardLimit = 10
+	maxLeaderboardLimit     = 100
+)
+
+// Volume is the number of successful requests to translation providers and their characters
+type Volume struct {
+	Requests   int64 `json:"requests"`
+	Characters int64 `json:"characters"`
+}
+
+// VolumeStats is the volume of translations per channel and per user during a day, keyed by ID
+type VolumeStats struct {
+	Day      string             `json:"day"`
+	Channels map[string]*Volume `json:"channels"`
+	Users    map[string]*Volume `json:"users"`
+}
+
+func newVolumeStats(day string) *VolumeStats {
+	return &VolumeStats{
+		Day:      day,
+		Channels: map[string]*Volume{},
+		Users:    map[string]*Volume{},
+	}
+}
+
+func addVolume(volumes map[string]*Volume, id string, requests, characters int64) {
+	volume, ok := volumes[id]
+	if !ok {
+		volume = &Volume{}
+		volumes[id] = volume
+	}
+	volume.Requests += requests
+	volume.Characters += characters
+}
+
+func (s *VolumeStats) add(other *VolumeStats) {
+	for id, volume := range other.Channels {
+		addVolume(s.Channels, id, volume.Requests, volume.Characters)
+	}
+	for id, volume := range other.Users {
+		addVolume(s.Users, id, volume.Requests, volume.Characters)
+	}
+}
+
+// LeaderboardEntry is the volume of translations of a channel or a user as reported by the API,
+// named with the channel name or the username
+type LeaderboardEntry struct {
+	ID         string `json:"id"`
+	Name       string `json:"name,omitempty"`
+	Requests   int64  `json:"requests"`
+	Characters int64  `json:"characters"`
+}
+
+// Leaderboard is the channels and users with the most characters translated over a period
+type Leaderboard struct {
+	Days     int                 `json:"days"`
+	Channels []*LeaderboardEntry `json:"channels"`
+	Users    []*LeaderboardEntry `json:"users"`
+}
+
+func getVolumeStatsKey(day string) string {
+	return volumeKeyPrefix + day
+}
+
+// getTopVolumes returns the entries with the most characters translated, then the most requests,
+// at most limit of them.
+func getTopVolumes(volumes map[string]*Volume, limit int) []*LeaderboardEntry {
+	entries := make([]*LeaderboardEntry, 0, len(volumes))
+	for id, volume := range volumes {
+		entries = append(entries, &LeaderboardEntry{ID: id, Requests: volume.Requests, Characters: volume.Characters})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Characters != entries[j].Characters {
+			return entries[i].Characters > entries[j].Characters
+		}
+		if entries[i].Requests != entries[j].Requests {
+			return entries[i].Requests > entries[j].Requests
+		}
+		return entries[i].ID < entries[j].ID
+	})
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries
+}
+
+// newLeaderboard returns the channels and users with the most characters translated.
+func newLeaderboard(stats *VolumeStats, days, limit int) *Leaderboard {
+	return &Leaderboard{
+		Days:     days,
+		Channels: getTopVolumes(stats.Channels, limit),
+		Users:    getTopVolumes(stats.Users, limit),
+	}
+}
+
+// recordVolume counts a successful request to a provider for the channel and the user of ctx in
+// memory, to be saved along with the usage statistics. Translations not made in a channel or for
+// a user, such as of webhook requests, are only counted for the other one.
+func (p *Plugin) recordVolume(ctx context.Context, characters int) {
+	subject := getAuditSubject(ctx)
+	if subject == nil || (subject.channelID == "" && subject.userID == "") {
+		return
+	}
+	day := time.Now().UTC().Format(statsDayFormat)
+
+	p.volumeLock.Lock()
+	defer p.volumeLock.Unlock()
+
+	if p.volume == nil {
+		p.volume = map[string]*VolumeStats{}
+	}
+
+	stats, ok := p.volume[day]
+	if !ok {
+		stats = newVolumeStats(day)
+		p.volume[day] = stats
+	}
+
+	if subject.channelID != "" {
+		addVolume(stats.Channels, subject.channelID, 1, int64(characters))
+	}
+	if subject.userID != "" {
+		addVolume(stats.Users, subject.userID, 1, int64(characters))
+	}
+}
+
+// flushVolumeStats adds the volume counted in memory to the saved one.
+func (p *Plugin) flushVolumeStats() {
+	p.volumeLock.Lock()
+	pending := p.volume
+	p.volume = nil
+	p.volumeLock.Unlock()
+
+	for day, stats := range pending {
+		if err := p.saveVolumeStats(stats); err != nil {
+			p.API.LogError("Failed to save translation volume", "day", day, "err", err.Error())
+		}
+	}
+}
+
+// saveVolumeStats adds statistics to the saved ones with a compare and set, as other cluster
+// nodes may be saving theirs at the same time.
+func (p *Plugin) saveVolumeStats(stats *VolumeStats) error {
+	key := getVolumeStatsKey(stats.Day)
+	for attempt := 0; attempt < maxStatsSaveAttempts; attempt++ {
+		oldBytes, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return appErr
+		}
+
+		saved := newVolumeStats(stats.Day)
+		if oldBytes != nil {
+			if err := json.Unmarshal(oldBytes, saved); err != nil {
+				return errors.Wrap(err, "unable to unmarshal translation volume")
+			}
+		}
+		saved.add(stats)
+
+		newBytes, err := json.Marshal(saved)
+		if err != nil {
+			return errors.Wrap(err, "unable to marshal translation volume")
+		}
+
+		updated, appErr := p.API.KVCompareAndSet(key, oldBytes, newBytes)
+		if appErr != nil {
+			return appErr
+		}
+		if updated {
+			return nil
+		}
+	}
+
+	return errors.New("translation volume kept changing concurrently")
+}
+
+// removeVolumeUsers removes the users whose data is deleted from the volume of a key with a
+// compare and set, as volume may be saved at the same time. The volume of channels is kept.
+func (p *Plugin) removeVolumeUsers(key string, userIDs map[string]bool) (bool, error) {
+	for attempt := 0; attempt < maxStatsSaveAttempts; attempt++ {
+		oldBytes, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return false, appErr
+		}
+		if oldBytes == nil {
+			return false, nil
+		}
+
+		stats := newVolumeStats("")
+		if err := json.Unmarshal(oldBytes, stats); err != nil {
+			return false, errors.Wrap(err, "unable to unmarshal translation volume")
+		}
+
+		removed := false
+		for userID := range stats.Users {
+			if userIDs[userID] {
+				delete(stats.Users, userID)
+				removed = true
+			}
+		}
+		if !removed {
+			return false, nil
+		}
+
+		newBytes, err := json.Marshal(stats)
+		if err != nil {
+			return false, errors.Wrap(err, "unable to marshal translation volume")
+		}
+
+		updated, appErr := p.API.KVCompareAndSet(key, oldBytes, newBytes)
+		if appErr != nil {
+			return false, appErr
+		}
+		if updated {
+			return true, nil
+		}
+	}
+
+	return false, errors.New("translation volume kept changing concurrently")
+}
+
+// getLeaderboard returns the channels and users with the most characters translated for the
+// given number of days up to today, named when they still exist.
+func (p *Plugin) getLeaderboard(days, limit int) (*Leaderboard, error) {
+	total := newVolumeStats("")
+	today := time.Now().UTC()
+	for i := 0; i < days; i++ {
+		day := today.AddDate(0, 0, -i).Format(statsDayFormat)
+		statsBytes, appErr := p.API.KVGet(getVolumeStatsKey(day))
+		if appErr != nil {
+			return nil, appErr
+		}
+		if statsBytes == nil {
+			continue
+		}
+
+		stats := newVolumeStats(day)
+		if err := json.Unmarshal(statsBytes, stats); err != nil {
+			return nil, errors.Wrap(err, "unable to unmarshal translation volume")
+		}
+		total.add(stats)
+	}
+
+	leaderboard := newLeaderboard(total, days, limit)
+	for _, entry := range leaderboard.Channels {
+		if channel, appErr := p.API.GetChannel(entry.ID); appErr == nil {
+			entry.Name = channel.Name
+		}
+	}
+	for _, entry := range leaderboard.Users {
+		if user, appErr := p.API.GetUser(entry.ID); appErr == nil {
+			entry.Name = user.Username
+		}
+	}
+
+	return leaderboard, nil
+}
+
+func (p *Plugin) getLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	days := defaultStatsDays
+	if value := query.Get("days"); value != "" {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil || days <= 0 || days > maxStatsDays {
+			writeAPIError(w, newInvalidParameterError("days"))
+			return
+		}
+	}
+
+	limit := defaultLeaderboardLimit
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 || limit > maxLeaderboardLimit {
+			writeAPIError(w, newInvalidParameterError("limit"))
+			return
+		}
+	}
+
+	// Translations not saved yet are included so that the leaderboard is up to date.
+	p.flushVolumeStats()
+
+	leaderboard, err := p.getLeaderboard(days, limit)
+	if err != nil {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to get leaderboard", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	resp, _ := json.Marshal(leaderboard)
+	w.Write(resp)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLeaderboard(t *testing.T) {
+	stats := newVolumeStats("2020-06-11")
+	addVolume(stats.Channels, "support", 4, 1200)
+	addVolume(stats.Channels, "sales", 10, 800)
+	addVolume(stats.Users, "user1", 2, 500)
+
+	other := newVolumeStats("2020-06-12")
+	addVolume(other.Channels, "sales", 2, 400)
+	addVolume(other.Channels, "random", 1, 100)
+	addVolume(other.Users, "user1", 1, 100)
+	addVolume(other.Users, "user2", 3, 600)
+	addVolume(other.Users, "user3", 1, 600)
+	stats.add(other)
+
+	leaderboard := newLeaderboard(stats, 7, 2)
+	assert.Equal(t, 7, leaderboard.Days)
+
+	// Channels with as many characters translated are ranked by their requests.
+	assert.Equal(t, []*LeaderboardEntry{
+		{ID: "sales", Requests: 12, Characters: 1200},
+		{ID: "support", Requests: 4, Characters: 1200},
+	}, leaderboard.Channels)
+	assert.Equal(t, []*LeaderboardEntry{
+		{ID: "user1", Requests: 3, Characters: 600},
+		{ID: "user2", Requests: 3, Characters: 600},
+	}, leaderboard.Users)
+}
+
+func TestGetLeaderboardText(t *testing.T) {
+	assert.Equal(t, "No messages were translated over the last 7 days.", getLeaderboardText(&Leaderboard{Days: 7}))
+
+	assert.Equal(t, "Channels with the most characters translated over the last 30 days:\n"+
+		" * ~sales: 1200 characters in 12 requests\n"+
+		" * deleted: 100 characters in 1 requests\n"+
+		"\nUsers with the most characters translated over the last 30 days:\n"+
+		" * @alice: 600 characters in 3 requests", getLeaderboardText(&Leaderboard{
+		Days: 30,
+		Channels: []*LeaderboardEntry{
+			{ID: "sales", Name: "sales", Requests: 12, Characters: 1200},
+			{ID: "deleted", Requests: 1, Characters: 100},
+		},
+		Users: []*LeaderboardEntry{
+			{ID: "user1", Name: "alice", Requests: 3, Characters: 600},
+		},
+	}))
+}
@@ -39,7 +39,7 @@ const manifestStr = `
         "key": "AWSAccessKeyID",
         "display_name": "AWS Access Key ID:",
         "type": "text",
-        "help_text": "The access key ID from AWS.",
+        "help_text": "The access key ID from AWS, or a reference to it kept out of the settings: env:NAME for an environment variable of the server, awssm:secret-id#key for AWS Secrets Manager, read with the default AWS credentials of the server, or vault:path#key for Vault at VAULT_ADDR with VAULT_TOKEN.",
         "placeholder": "",
         "default": null
       },
@@ -47,7 +47,7 @@ const manifestStr = `
         "key": "AWSSecretAccessKey",
         "display_name": "AWS Secret Access Key:",
         "type": "text",
-        "help_text": "The secret access key from AWS.",
+        "help_text": "The secret access key from AWS, or a reference to it like for the access key ID.",
         "placeholder": "",
         "default": null
       },
@@ -58,6 +58,555 @@ const manifestStr = `
         "help_text": "The region from AWS.",
         "placeholder": "",
         "default": "us-east-1"
+      },
+      {
+        "key": "AWSEndpoint",
+        "display_name": "AWS Endpoint:",
+        "type": "text",
+        "help_text": "URL of the Amazon Translate endpoint, such as a VPC endpoint or a proxy. Leave empty to use the endpoint of the region. Changes are applied without restarting the plugin.",
+        "placeholder": "",
+        "default": null
+      },
+      {
+        "key": "AWSEndpointLocal",
+        "display_name": "Local AWS Endpoint:",
+        "type": "bool",
+        "help_text": "When true, the AWS Endpoint is an on-premises gateway keeping messages within the network, which may translate the messages of private channels and direct messages when Private Channel Providers only allows local providers.",
+        "placeholder": "",
+        "default": false
+      },
+      {
+        "key": "OfflineEndpoint",
+        "display_name": "Offline Endpoint:",
+        "type": "text",
+        "help_text": "URL of an on-premises endpoint compatible with Amazon Translate, such as a gateway in front of LibreTranslate or Argos Translate, translating messages while the translation provider is unreachable. Translations fall back to it from a failed provider health check until one succeeds again, and are marked as offline quality. Local providers don't fall back. No offline fallback takes place when empty.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "AWSCACertificates",
+        "display_name": "AWS CA Certificates:",
+        "type": "longtext",
+        "help_text": "PEM certificates of the CAs trusted by the AWS clients on top of the system ones, such as the private CA of an internal gateway set as AWS Endpoint. Changes are applied without restarting the plugin.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "AWSClientCertificate",
+        "display_name": "AWS Client Certificate:",
+        "type": "longtext",
+        "help_text": "PEM certificate the AWS clients present to the AWS endpoint for mutual TLS, along with the AWS Client Key. Leave empty unless the endpoint requires client certificates.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "AWSClientKey",
+        "display_name": "AWS Client Key:",
+        "type": "longtext",
+        "help_text": "PEM private key of the AWS Client Certificate, or a reference to it like for the access key ID.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "OutboundProxy",
+        "display_name": "Outbound Proxy:",
+        "type": "text",
+        "help_text": "URL of the proxy every request to Amazon Translate, Amazon Comprehend and the secret stores goes through, such as http://proxy.example.com:3128, possibly with the credentials of the proxy. Leave empty to use the proxy of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables of the server.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "OutboundNoProxy",
+        "display_name": "Outbound Proxy Exceptions:",
+        "type": "text",
+        "help_text": "Comma-separated hosts, domains and IP ranges reached without the outbound proxy, such as vault.internal, .example.com or 10.0.0.0/8, or * for all of them.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "TranslateMessages",
+        "display_name": "Translate Messages Automatically:",
+        "type": "bool",
+        "help_text": "When true, the messages of users who turned autotranslation on are translated into their target language as soon as they are posted. When false, messages are only translated from the post menu, and only the text file attachments of channels with file translation turned on are translated as they are posted.",
+        "placeholder": "",
+        "default": false
+      },
+      {
+        "key": "FileTranslationMaxSize",
+        "display_name": "File Translation Max Size (KB):",
+        "type": "text",
+        "help_text": "The maximum size in kilobytes of a .txt, .md or .csv attachment to translate. Larger files are skipped.",
+        "placeholder": "",
+        "default": "100"
+      },
+      {
+        "key": "CoalesceWindow",
+        "display_name": "Coalesce Window (seconds):",
+        "type": "text",
+        "help_text": "Messages posted by the same user in a channel within this many seconds of each other are translated together into a single post. Set to 0 to translate each message on its own.",
+        "placeholder": "",
+        "default": "0"
+      },
+      {
+        "key": "ProgressiveTranslationThreshold",
+        "display_name": "Progressive Translation Threshold (bytes):",
+        "type": "text",
+        "help_text": "Messages longer than this many bytes, as many characters in Latin scripts but fewer in others, only get their first part translated right away, with a Translate rest button continuing the translation in a thread. Set to 0 to always translate messages in full.",
+        "placeholder": "",
+        "default": "0"
+      },
+      {
+        "key": "InterceptionTimeout",
+        "display_name": "Interception Timeout (milliseconds):",
+        "type": "text",
+        "help_text": "How long a new message may be held back to be translated before it is posted in channels using the rewrite or annotate delivery modes, whose edits are not translated again. Messages taking longer are posted untranslated, as the provider may still process the abandoned request, while messages failing to be translated otherwise get a separate translation post instead.",
+        "placeholder": "",
+        "default": "2000"
+      },
+      {
+        "key": "MentionNotifications",
+        "display_name": "Notify Mentions in Translations:",
+        "type": "bool",
+        "help_text": "When true, users mentioned in a message are notified again by its translation post. When false, translation posts never trigger mention notifications.",
+        "placeholder": "",
+        "default": false
+      },
+      {
+        "key": "TranslatePermalinks",
+        "display_name": "Translate Permalinks:",
+        "type": "bool",
+        "help_text": "When true, translation posts also include a translation of the messages linked to by permalinks, as long as they are in the same channel or in a public channel.",
+        "placeholder": "",
+        "default": false
+      },
+      {
+        "key": "TranslateTeamNames",
+        "display_name": "Translate Names:",
+        "type": "bool",
+        "help_text": "When true, the names of the users, public channels and team of a channel and of custom emojis are translated like other words. When false, they are kept as is when written as in Mattermost, so that a user named Bill isn't translated as an invoice.",
+        "placeholder": "",
+        "default": false
+      },
+      {
+        "key": "ProtectedPatterns",
+        "display_name": "Protected Patterns:",
+        "type": "longtext",
+        "help_text": "Regular expressions matching text kept as is when translating, one per line, such as [A-Z]+-[0-9]+ for ticket IDs like PROJ-1234, or patterns of SKUs, IP addresses and file paths.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "RedactPersonalData",
+        "display_name": "Redact Personal Data:",
+        "type": "bool",
+        "help_text": "When true, email addresses, payment card numbers and phone numbers are masked from Amazon Translate and Amazon Comprehend and restored in translations, so that they never leave the server. Use Protected Patterns for other personal data, such as employee IDs.",
+        "placeholder": "",
+        "default": false
+      },
+      {
+        "key": "CheckOutputLanguage",
+        "display_name": "Check Output Language:",
+        "type": "bool",
+        "help_text": "When true, the language of translations is detected locally, and translations written in another language than their target, such as Chinese instead of Japanese, are translated again with another provider supporting the language pair, or the same one when none does. Translations still in the wrong language fail with the wrong_output_language error rather than being delivered. Only target languages the local detector tells apart are checked.",
+        "placeholder": "",
+        "default": false
+      },
+      {
+        "key": "PrivateChannelProviders",
+        "display_name": "Private Channel Providers:",
+        "type": "dropdown",
+        "help_text": "Which providers may receive the messages of private channels, direct messages and group messages, while public channels may use any provider. Messages there are only translated automatically for users who turn it on with /autotranslate private on, while translations on demand follow this setting alone. Amazon Translate is only local when the AWS Endpoint is marked as local, and Amazon Comprehend never is, so the language of those messages is then only detected locally.",
+        "placeholder": "",
+        "default": "any",
+        "options": [
+          {
+            "display_name": "Any provider",
+            "value": "any"
+          },
+          {
+            "display_name": "Local providers only",
+            "value": "local"
+          },
+          {
+            "display_name": "No translation",
+            "value": "off"
+          }
+        ]
+      },
+      {
+        "key": "ProviderRoutes",
+        "display_name": "Provider Routes:",
+        "type": "longtext",
+        "help_text": "Providers translating language pairs, one route per line such as ko:ja=aws, with * matching any language such as *:ja=aws. The first matching route applies, in the order written, and Amazon Translate translates the pairs no route matches. Routes may only name providers known to the plugin, currently aws.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "QualityRouting",
+        "display_name": "Enable Quality Routing:",
+        "type": "bool",
+        "help_text": "When true, each language pair is translated by the provider whose translations of it are rated best by users over the last 30 days, learned again every hour. Routes of a language pair in Provider Routes are still applied first, while the learned provider is preferred over routes with *. System admins review the learned routing at /plugins/autotranslate/api/v1/routing/learned.",
+        "placeholder": "",
+        "default": false
+      },
+      {
+        "key": "QualityRoutingMinRatings",
+        "display_name": "Quality Routing Minimum Ratings:",
+        "type": "text",
+        "help_text": "Number of ratings a provider needs for a language pair over the last 30 days to be compared by quality routing, so that a few ratings don't decide. Defaults to 20.",
+        "placeholder": "",
+        "default": "20"
+      },
+      {
+        "key": "CanaryProvider",
+        "display_name": "Canary Provider:",
+        "type": "text",
+        "help_text": "Provider receiving a share of translations, set by Canary Percent, while the others go to the current providers, to try out a new provider before migrating to it. The same posts always go to the canary provider, and providers forced through the API are kept. System admins compare the latency and ratings of providers at /plugins/autotranslate/api/v1/stats/providers. No canary rollout takes place when empty.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "CanaryPercent",
+        "display_name": "Canary Percent:",
+        "type": "text",
+        "help_text": "Percentage of translations sent to the Canary Provider, from 0 to 100. Defaults to 10.",
+        "placeholder": "",
+        "default": "10"
+      },
+      {
+        "key": "LongMessageProvider",
+        "display_name": "Long Message Provider:",
+        "type": "text",
+        "help_text": "Provider translating messages of at least Long Message Threshold characters, such as a cheaper or local provider, while shorter messages go to the routed providers, to control spend. Long messages go to the routed providers like others when empty.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "LongMessageThreshold",
+        "display_name": "Long Message Threshold:",
+        "type": "text",
+        "help_text": "Number of characters from which messages go to the Long Message Provider. Defaults to 1000.",
+        "placeholder": "",
+        "default": "1000"
+      },
+      {
+        "key": "VerificationProvider",
+        "display_name": "Verification Provider:",
+        "type": "text",
+        "help_text": "Provider translating back the translations of channels with verification on, which channel admins turn on with /autotranslate verify on. Must be a known provider. Only Amazon Translate (aws) is supported for now. Defaults to aws.",
+        "placeholder": "",
+        "default": "aws"
+      },
+      {
+        "key": "VerificationThreshold",
+        "display_name": "Verification Threshold:",
+        "type": "text",
+        "help_text": "Percentage of similarity between a message and its translation translated back, from 0 to 100, below which the translation fails verification. Defaults to 50.",
+        "placeholder": "",
+        "default": "50"
+      },
+      {
+        "key": "VerificationFailure",
+        "display_name": "Verification Failure:",
+        "type": "dropdown",
+        "help_text": "Whether translations failing verification are delivered with a warning, or translated again by the Verification Provider first, keeping the better translation and warning when it still fails.",
+        "placeholder": "",
+        "default": "flag",
+        "options": [
+          {
+            "display_name": "Flag",
+            "value": "flag"
+          },
+          {
+            "display_name": "Retry",
+            "value": "retry"
+          }
+        ]
+      },
+      {
+        "key": "AuditLog",
+        "display_name": "Audit Log:",
+        "type": "dropdown",
+        "help_text": "Whether a record of every request to Amazon Translate is kept, with the user, post, languages, number of characters and outcome but without any text, for compliance reviews by system admins through the API. Records can also be written to the server log.",
+        "placeholder": "",
+        "default": "off",
+        "options": [
+          {
+            "display_name": "Off",
+            "value": "off"
+          },
+          {
+            "display_name": "Kept by the plugin",
+            "value": "store"
+          },
+          {
+            "display_name": "Kept by the plugin and written to the server log",
+            "value": "server"
+          }
+        ]
+      },
+      {
+        "key": "AuditLogRetention",
+        "display_name": "Audit Log Retention (days):",
+        "type": "text",
+        "help_text": "Number of days records of the audit log are kept.",
+        "placeholder": "",
+        "default": "90"
+      },
+      {
+        "key": "DataRetention",
+        "display_name": "Data Retention (days):",
+        "type": "text",
+        "help_text": "Number of days cached translations, records of delivered translations, translations toggled in place, translation histories, ratings of translations, usage, volume and spend statistics and records of the audit log are kept at most before being purged by a daily cleanup. Leave empty or set to 0 to keep them until they expire on their own, after 7 days for translations.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "DeleteDeactivatedUsersData",
+        "display_name": "Delete Data of Deactivated Users:",
+        "type": "bool",
+        "help_text": "When true, the daily cleanup deletes the translation settings, language profile, translation history and translation ratings of deactivated users, and removes them from the audit log. Their data can't be restored if they are activated again.",
+        "placeholder": "",
+        "default": false
+      },
+      {
+        "key": "RequireConsent",
+        "display_name": "Require Consent:",
+        "type": "bool",
+        "help_text": "When true, users acknowledge that their messages are sent to the translation provider in a dialog before any of their messages are translated, and the time of their consent is recorded.",
+        "placeholder": "",
+        "default": false
+      },
+      {
+        "key": "ConsentVersion",
+        "display_name": "Consent Version:",
+        "type": "text",
+        "help_text": "Version of the consent users acknowledge, such as a date. Change it after changing the translation provider or its settings to have every user consent again before their messages are translated.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "TranslationRoles",
+        "display_name": "Translation Roles:",
+        "type": "text",
+        "help_text": "Comma-separated roles allowed to turn autotranslation on and to change the translation of channels, such as team_admin, channel_admin or custom roles. Roles are checked as system roles, and as team and channel roles in the channel where the command is run. System admins are always allowed. Leave empty to allow every user.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "DebugLogging",
+        "display_name": "Debug Logging:",
+        "type": "bool",
+        "help_text": "When true, every step of translations, from the hook receiving a post to language detection, the cache, the provider and the translation being posted, is logged at the info level with the request ID correlating them. Logged texts are shortened, with mentions and personal data masked.",
+        "placeholder": "",
+        "default": false
+      },
+      {
+        "key": "ProviderPrices",
+        "display_name": "Provider Prices:",
+        "type": "text",
+        "help_text": "Comma-separated prices in USD per million characters sent to each provider, such as aws=15, used to estimate the spend on translation per team. Defaults to aws=15, the price of Amazon Translate.",
+        "placeholder": "",
+        "default": "aws=15"
+      },
+      {
+        "key": "SpendReports",
+        "display_name": "Monthly Spend Reports:",
+        "type": "bool",
+        "help_text": "When true, the characters translated during the previous month and their estimated cost per team are reported at the beginning of every month.",
+        "placeholder": "",
+        "default": false
+      },
+      {
+        "key": "SpendReportChannel",
+        "display_name": "Spend Report Channel ID:",
+        "type": "text",
+        "help_text": "ID of the channel monthly spend reports are posted in by the bot. When empty, every system admin is sent them by direct message.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "AlertChannel",
+        "display_name": "Alert Channel ID:",
+        "type": "text",
+        "help_text": "ID of the channel alerts about the translation provider are posted in by the bot, such as when it keeps failing its health checks or too many of its requests fail. When empty, every system admin is sent them by direct message.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "FailureAlertThreshold",
+        "display_name": "Failure Alert Threshold (%):",
+        "type": "text",
+        "help_text": "Failure rate in percent of the requests to the translation provider at which admins are alerted in the Alert Channel, with the most frequent errors. Rates are checked over windows of the Failure Alert Window with 10 requests at least. Set to 0 to turn failure alerts off.",
+        "placeholder": "",
+        "default": "25"
+      },
+      {
+        "key": "FailureAlertWindow",
+        "display_name": "Failure Alert Window (minutes):",
+        "type": "text",
+        "help_text": "Length in minutes of the windows the failure rate of the translation provider is checked over.",
+        "placeholder": "",
+        "default": "15"
+      },
+      {
+        "key": "ProviderHealth",
+        "display_name": "Provider Health:",
+        "type": "custom",
+        "help_text": "Health of the translation provider as last checked in the background every 5 minutes. Admins are alerted after 3 consecutive failed checks and when the provider recovers.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "EnableTelemetry",
+        "display_name": "Enable Telemetry:",
+        "type": "bool",
+        "help_text": "When true, anonymous counts of the use of features, such as automatic and on-demand translations, their delivery modes and providers, are sent to help decide which features to improve. Telemetry is only sent when Error Reporting and Diagnostics of the server is enabled too, and never includes users, channels or messages.",
+        "placeholder": "",
+        "default": false
+      },
+      {
+        "key": "SlowTranslationThreshold",
+        "display_name": "Slow Translation Threshold (seconds):",
+        "type": "text",
+        "help_text": "Number of seconds after which a request to the translation provider is logged as slow with a warning. Set to 0 to turn the warnings off.",
+        "placeholder": "",
+        "default": "5"
+      },
+      {
+        "key": "NotifySlowTranslations",
+        "display_name": "Notify Users of Slow Translations:",
+        "type": "bool",
+        "help_text": "When true, users waiting for a translation longer than the Slow Translation Threshold are told that it is taking longer than usual with a message only they can see.",
+        "placeholder": "",
+        "default": false
+      },
+      {
+        "key": "TracingExporter",
+        "display_name": "Tracing Exporter:",
+        "type": "dropdown",
+        "help_text": "Where the spans of translations are exported, timing the provider calls, cache lookups and posting of translations along with the API requests. OTLP sends them to an OpenTelemetry collector, continuing the traces of the requests sending a traceparent header.",
+        "placeholder": "",
+        "default": "off",
+        "options": [
+          {
+            "display_name": "Off",
+            "value": "off"
+          },
+          {
+            "display_name": "OTLP over HTTP",
+            "value": "otlp"
+          },
+          {
+            "display_name": "Server log",
+            "value": "log"
+          }
+        ]
+      },
+      {
+        "key": "TracingEndpoint",
+        "display_name": "Tracing Endpoint:",
+        "type": "text",
+        "help_text": "URL of the OTLP/HTTP receiver of the OpenTelemetry collector spans are exported to, such as http://otel-collector:4318. Spans are sent to its /v1/traces path through the outbound proxy, if any.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "TracingHeaders",
+        "display_name": "Tracing Headers:",
+        "type": "text",
+        "help_text": "Comma-separated headers sent along with the spans exported over OTLP, such as api-key=secret for collectors requiring authentication.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "TranslatedBots",
+        "display_name": "Translated Bots and Webhooks:",
+        "type": "text",
+        "help_text": "Comma-separated usernames of bots and webhooks, such as rssbot or jira, whose posts are translated for everyone with autotranslation turned on. Posts of other bots and webhooks are only translated for users who included them with /autotranslate bots add.",
+        "placeholder": "",
+        "default": null
+      },
+      {
+        "key": "LanguageDetector",
+        "display_name": "Language Detector:",
+        "type": "dropdown",
+        "help_text": "How the language of messages is detected, such as to skip messages already written in the target language. Local detection is free and works offline but only tells apart some languages. Amazon Translate or Amazon Comprehend is asked when the local detection isn't confident, which is charged by AWS. Amazon Comprehend uses the same AWS credentials and region, which must be allowed comprehend:DetectDominantLanguage.",
+        "placeholder": "",
+        "default": "local",
+        "options": [
+          {
+            "display_name": "Local",
+            "value": "local"
+          },
+          {
+            "display_name": "Local, then Amazon Translate",
+            "value": "provider"
+          },
+          {
+            "display_name": "Local, then Amazon Comprehend",
+            "value": "comprehend"
+          }
+        ]
+      },
+      {
+        "key": "DetectionConfidenceThreshold",
+        "display_name": "Detection Confidence Threshold (%):",
+        "type": "text",
+        "help_text": "Messages are translated automatically from auto only when their language is detected with at least this confidence, from 0 to 100, so that short messages such as ok or si, which mean something in many languages, aren't translated into nonsense. Users can still translate them with the Translate option of the post menu. Set to 0 to translate every message.",
+        "placeholder": "",
+        "default": "50"
+      },
+      {
+        "key": "APISharedSecret",
+        "display_name": "API Shared Secret:",
+        "type": "generated",
+        "help_text": "Secret that trusted servers send in the X-Autotranslate-Secret header to call the HTTP API on behalf of the user given in the X-Autotranslate-User-Id header. Leave empty to only accept requests of logged in users.",
+        "regenerate_help_text": "Regenerates the API shared secret. Servers using the current one have to be updated.",
+        "placeholder": "",
+        "default": null
+      },
+      {
+        "key": "WebhookToken",
+        "display_name": "Webhook Token:",
+        "type": "generated",
+        "help_text": "Token that other systems, such as CI or ticketing systems, send in the X-Autotranslate-Token header or the token query parameter to translate text with the webhook at /plugins/autotranslate/api/v1/webhook, optionally posting the translation in a channel. Leave empty along with the Webhook Signing Secrets to turn the webhook off.",
+        "regenerate_help_text": "Regenerates the webhook token. Systems using the current one have to be updated.",
+        "placeholder": "",
+        "default": null
+      },
+      {
+        "key": "WebhookSigningSecrets",
+        "display_name": "Webhook Signing Secrets:",
+        "type": "longtext",
+        "help_text": "Secrets, one per line, that other systems sign webhook requests with. When set, requests must send the X-Autotranslate-Timestamp header with the current Unix time in seconds and the X-Autotranslate-Signature header with sha256= followed by the hex HMAC-SHA256 of the timestamp, a dot and the body, keyed with any of the secrets. Add a new secret before switching systems to it and remove the old one afterwards to rotate them. The webhook token is still required when set.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "EncryptionKey",
+        "display_name": "Encryption Key:",
+        "type": "generated",
+        "help_text": "Secret the key encrypting the translations cached in the KV store is derived from, so that a database dump doesn't expose the text of messages. Leave empty to store them in plain text. It can refer to a secret store like the AWS credentials, such as env:NAME. Only translations are encrypted, as credentials are never written to the KV store. Translations stored with a former key can't be read anymore, so they are dropped and translated again.",
+        "regenerate_help_text": "Regenerates the encryption key. Translations cached with the current one are translated again.",
+        "placeholder": "",
+        "default": null
+      },
+      {
+        "key": "UserRateLimit",
+        "display_name": "User Rate Limit (requests per minute):",
+        "type": "text",
+        "help_text": "Maximum number of requests per minute a user may send to the HTTP API of the plugin, such as to translate posts. Further requests are refused until the minute is over. Set to 0 for no limit.",
+        "placeholder": "",
+        "default": "60"
+      },
+      {
+        "key": "AddressRateLimit",
+        "display_name": "Address Rate Limit (requests per minute):",
+        "type": "text",
+        "help_text": "Maximum number of requests per minute the HTTP API of the plugin accepts from a single client address, as resolved by Mattermost with its Trusted Proxy IP Header setting, shared by all users behind the same proxy. Further requests are refused until the minute is over. Set to 0 for no limit.",
+        "placeholder": "",
+        "default": "300"
       }
     ]
   }
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/service/translate"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin"
+)
+
+const (
+	translationSourcePostIDProp   = "autotranslate_source_post_id"
+	translationSourceLanguageProp = "autotranslate_source_language"
+	translationTargetLanguageProp = "autotranslate_target_language"
+)
+
+// priorityProps are the props marking the priority of a post and requesting acknowledgement,
+// carried over to its translation so that urgency isn't lost in translation.
+var priorityProps = []string{"priority", "requested_ack", "persistent_notifications"}
+
+// MessageWillBePosted is invoked when a message is posted by a user before it is committed to the
+// database.
+//
+// Messages marked with !nt or #notranslate are opted out of translation, and messages posted in
+// channels using the rewrite or annotate delivery modes are translated before they are committed.
+// The props marking intercepted messages are only ever set here, never taken from clients, and
+// messages posted in channels delivering translations in place are marked for clients to fetch
+// their translations.
+func (p *Plugin) MessageWillBePosted(c *plugin.Context, post *model.Post) (*model.Post, string) {
+	post = p.markNoTranslatePost(stripInterceptProps(post))
+	return p.interceptPost(p.markInPlaceTranslationPost(post)), ""
+}
+
+// MessageWillBeUpdated is invoked when a message is updated by a user before it is committed to
+// the database, opting the post out of translation the same way as MessageWillBePosted, or back
+// in when the new message has no marker, keeping the props marking intercepted messages as they
+// were, and keeping the original message of posts merged with their translation up to date.
+func (p *Plugin) MessageWillBeUpdated(c *plugin.Context, newPost, oldPost *model.Post) (*model.Post, string) {
+	newPost = keepInterceptProps(newPost, oldPost)
+	return refreshOriginalMessage(p.updateNoTranslatePost(newPost, oldPost), oldPost), ""
+}
+
+// MessageHasBeenUpdated is invoked after a message is updated and has been updated in the database.
+//
+// Posts merged with their translation are translated again when their original message is
+// edited, and pinned posts get their translation posts pinned along with them.
+func (p *Plugin) MessageHasBeenUpdated(c *plugin.Context, newPost, oldPost *model.Post) {
+	p.translateEditedMergedPost(newPost, oldPost)
+	p.pinTranslations(newPost, oldPost)
+}
+
+// MessageHasBeenPosted is invoked after the message has been committed to the database.
+//
+// Posts of users are translated into the target language of their author, who has autotranslation
+// turned on: their text file attachments when file translation is enabled for the channel, and
+// when messages are translated automatically, their messages and message attachments too, posted
+// back to the channel by the bot. Posts of bots, webhooks and other plugins have no author target
+// language, so they are translated into the target languages of the channel members reading them
+// instead: posts with interactive actions, such as polls, ephemerally.
+//
+// Delayed posts, such as those of scheduling plugins, are only committed once published in the
+// channel, so they are translated then. Drafts and scheduled messages never reach this hook.
+func (p *Plugin) MessageHasBeenPosted(c *plugin.Context, post *model.Post) {
+	if post.UserId == p.botUserID || isNoTranslatePost(post) {
+		return
+	}
+
+	p.traceTranslation(context.Background(), "hook_received", "post_id", post.Id, "channel_id", post.ChannelId, "user_id", post.UserId)
+
+	translateMessages := p.getConfiguration().TranslateMessages
+
+	// Posts of bots, webhooks and other plugins have no author to translate them for, so they are
+	// translated for the channel members who asked for them instead.
+	if isBotPost(post) {
+		switch {
+		case !translateMessages:
+			// Only posts of users get their file attachments translated.
+		case !p.isProviderAllowed(post.ChannelId, providerAWS):
+			// The provider may not receive the messages of the channel.
+		case hasPostActions(post):
+			p.translateInteractivePost(post)
+		default:
+			p.translateBotPost(post)
+		}
+		return
+	}
+
+	if !p.shouldTranslatePost(post) {
+		return
+	}
+
+	// The languages of every user are recorded, even before they turn autotranslation on, so
+	// that their profile can suggest their language settings then, and admins can tell which
+	// channels would benefit from translation.
+	p.recordPostLanguage(post)
+
+	if !p.isProviderAllowed(post.ChannelId, providerAWS) {
+		return
+	}
+
+	if !translateMessages && len(post.FileIds) == 0 {
+		return
+	}
+
+	userInfo, apiErr := p.getUserInfo(post.UserId)
+	if apiErr != nil || !userInfo.Activated || !p.isTranslatedForAuthor(post.ChannelId, userInfo) {
+		return
+	}
+
+	if !p.hasConsent(post.UserId) {
+		p.promptConsent(post)
+		return
+	}
+
+	window := p.getConfiguration().getCoalesceWindow()
+	switch {
+	case !translateMessages:
+		// Only the text file attachments of the post are translated.
+	case post.GetProp(interceptedProp) != nil:
+		// The message has been translated before it was committed already.
+	case post.GetProp(interceptTimedOutProp) != nil:
+		// The provider was too slow to translate the message before it was committed, and may
+		// have processed the abandoned request anyway.
+	case p.shouldTranslateProgressively(post):
+		p.translateFirstPart(post, userInfo)
+	case window > 0 && p.canCoalescePost(post):
+		p.traceTranslation(context.Background(), "queued", "post_id", post.Id, "window", window.String())
+		p.queuePostBurst(post, userInfo, window)
+	default:
+		p.translatePosts([]*model.Post{post}, userInfo)
+	}
+
+	if len(post.FileIds) > 0 {
+		svc, err := p.getTranslateService()
+		if err != nil {
+			p.API.LogError("Failed to get translate service", "err", err.Error())
+			return
+		}
+
+		p.translatePostFiles(svc, post, userInfo)
+	}
+}
+
+// shouldTranslatePost filters out posts that must never be translated, most importantly the
+// bot's own translations which would otherwise be translated in a loop.
+func (p *Plugin) shouldTranslatePost(post *model.Post) bool {
+	if post.UserId == p.botUserID || post.Type != model.POST_DEFAULT {
+		return false
+	}
+
+	if isBotPost(post) {
+		return false
+	}
+
+	return true
+}
+
+// translatePosts translates the messages of one or more consecutive posts of the same author and
+// delivers them together as a single translation.
+func (p *Plugin) translatePosts(posts []*model.Post, userInfo *UserInfo) {
+	svc, err := p.getTranslateService()
+	if err != nil {
+		p.API.LogError("Failed to get translate service", "err", err.Error())
+		p.notifyTranslationFailure(posts, userInfo, err)
+		return
+	}
+
+	// The request ID of the translation shows in the logs and in the failure sent to the user.
+	ctx := newRequestContext(context.Background(), model.NewId())
+	if p.getConfiguration().DebugLogging {
+		var postIDs []string
+		for _, post := range posts {
+			postIDs = append(postIDs, post.Id)
+		}
+		p.traceTranslation(ctx, "translation_started", "post_ids", strings.Join(postIDs, ","), "user_id", userInfo.UserID, "source", userInfo.SourceLanguage, "target", userInfo.TargetLanguage)
+	}
+
+	var translatedMessages []string
+	var translatedAttachments []*model.SlackAttachment
+	var translatedPosts []*model.Post
+	var failedPosts []*model.Post
+	var failure error
+	sourceLanguage := ""
+
+	ctx, span := p.startSpan(ctx, "translate_posts", spanKindInternal, "request_id", getRequestID(ctx), "posts", len(posts), "source", userInfo.SourceLanguage, "target", userInfo.TargetLanguage)
+	defer func() {
+		span.finish(failure)
+	}()
+
+	for _, post := range posts {
+		// Posts are claimed before being translated, sparing the provider call of translations
+		// delivered already, such as when the hook is retried.
+		if !p.claimTranslation(post, userInfo.SourceLanguage, userInfo.TargetLanguage) {
+			continue
+		}
+
+		content, err := p.translatePostContent(ctx, svc, post, userInfo, true)
+		if err != nil {
+			p.API.LogError("Failed to translate post", "request_id", getRequestID(ctx), "post_id", post.Id, "err", err.Error())
+			p.releaseTranslation(post, userInfo.SourceLanguage, userInfo.TargetLanguage)
+			failedPosts = append(failedPosts, post)
+			failure = err
+			continue
+		}
+
+		if content.message != "" {
+			translatedMessages = append(translatedMessages, content.message)
+		}
+		translatedAttachments = append(translatedAttachments, content.attachments...)
+		translatedPosts = append(translatedPosts, post)
+
+		// The translation names the detected language only when the posts share it.
+		if content.isEmpty() {
+			continue
+		}
+		if sourceLanguage == "" {
+			sourceLanguage = content.sourceLanguage
+		} else if content.sourceLanguage != sourceLanguage {
+			sourceLanguage = userInfo.SourceLanguage
+		}
+	}
+
+	if len(failedPosts) > 0 {
+		p.notifyTranslationFailure(failedPosts, userInfo, failure)
+	}
+
+	if len(translatedMessages) == 0 && len(translatedAttachments) == 0 {
+		return
+	}
+
+	if p.getConfiguration().TranslatePermalinks && p.deliversTranslationPosts(posts[0].ChannelId) {
+		translatedAttachments = append(translatedAttachments, p.translatePermalinks(svc, posts, userInfo)...)
+	}
+
+	// The translation is anchored to the first post translated, as the others may be retried.
+	_, deliverySpan := p.startSpan(ctx, "deliver_translation", spanKindInternal, "post_id", translatedPosts[0].Id)
+	delivered := p.deliverTranslation(translatedPosts[0], userInfo.withSourceLanguage(sourceLanguage), strings.Join(translatedMessages, "\n\n"), translatedAttachments)
+	deliverySpan.setAttributes("delivered", delivered)
+	deliverySpan.finish(nil)
+	p.traceTranslation(ctx, "translation_posted", "post_id", translatedPosts[0].Id, "delivered", delivered)
+	if !delivered {
+		for _, post := range translatedPosts {
+			p.releaseTranslation(post, userInfo.SourceLanguage, userInfo.TargetLanguage)
+		}
+		return
+	}
+
+	for _, post := range translatedPosts {
+		p.recordTranslationHistory(userInfo.UserID, post, userInfo.SourceLanguage, userInfo.TargetLanguage)
+	}
+}
+
+// translatedContent is the translation of the message and message attachments of a post
+type translatedContent struct {
+	message     string
+	attachments []*model.SlackAttachment
+
+	// sourceLanguage is the language the post was translated from, which is the detected one when
+	// the source language of the user is auto, or auto when it couldn't be told.
+	sourceLanguage string
+}
+
+func (c *translatedContent) isEmpty() bool {
+	return c.message == "" && len(c.attachments) == 0
+}
+
+// translatePostContent translates the message and message attachments of a post. Both are empty
+// when the post is already written in the target language, or has no words to translate, which
+// is checked first to save the provider call whenever possible. Automatic translations from auto
+// also skip texts whose language isn't detected confidently enough.
+func (p *Plugin) translatePostContent(ctx context.Context, svc *translate.Translate, post *model.Post, userInfo *UserInfo, automatic bool) (*translatedContent, error) {
+	if err := p.checkAuthorConsent(post); err != nil {
+		return nil, err
+	}
+
+	ctx = newAuditContext(p.newGlossaryContext(ctx, post.ChannelId), userInfo.UserID, post.Id, post.ChannelId)
+	content := &translatedContent{sourceLanguage: userInfo.SourceLanguage}
+	if hasTranslatableText(post.Message) {
+		detected := p.detectPostLanguage(ctx, post, userInfo, post.Message)
+		p.traceTranslation(ctx, "language_detected", "post_id", post.Id, "language", detected.Language, "confidence", detected.Confidence, "detector", detected.Detector)
+		if detected.Language != userInfo.TargetLanguage && !p.isUncertainDetection(ctx, post, userInfo, detected, automatic) {
+			translated, source, err := p.translateLongTextWithSource(ctx, svc, resolveSourceLanguage(userInfo.SourceLanguage, detected), userInfo.TargetLanguage, post.Message)
+			if err != nil {
+				return nil, err
+			}
+
+			if translated != post.Message {
+				if source != autoLanguage && p.verifiesTranslations(post.ChannelId) {
+					translated = p.verifyTranslation(ctx, svc, source, userInfo.TargetLanguage, post.Message, translated)
+				}
+				if p.translatedOffline(post.Id, userInfo.SourceLanguage, userInfo.TargetLanguage, utf8.RuneCountInString(post.Message)) {
+					translated += "\n\n" + offlineQualityNote
+				}
+				content.message = translated
+				content.sourceLanguage = source
+			}
+		}
+	}
+
+	attachments := post.Attachments()
+	if len(attachments) == 0 || !hasTranslatableText(getAttachmentsText(attachments)) {
+		return content, nil
+	}
+
+	detected := p.detectPostLanguage(ctx, post, userInfo, getAttachmentsText(attachments))
+	if detected.Language == userInfo.TargetLanguage || p.isUncertainDetection(ctx, post, userInfo, detected, automatic) {
+		return content, nil
+	}
+
+	source := resolveSourceLanguage(userInfo.SourceLanguage, detected)
+	translatedAttachments, err := p.translateAttachments(ctx, svc, source, userInfo.TargetLanguage, attachments)
+	if err != nil {
+		return nil, err
+	}
+
+	content.attachments = translatedAttachments
+	if content.message == "" {
+		content.sourceLanguage = source
+	}
+
+	return content, nil
+}
+
+// detectPostLanguage detects the language of text of a post, supported by the language profile of
+// its author when the source language is auto.
+func (p *Plugin) detectPostLanguage(ctx context.Context, post *model.Post, userInfo *UserInfo, text string) *DetectedLanguage {
+	detected := p.detectTextLanguage(ctx, text)
+	if userInfo.SourceLanguage != autoLanguage || detected.isConfident() {
+		return detected
+	}
+
+	return p.getLanguageProfile(post.UserId).support(detected)
+}
+
+// isUncertainDetection reports whether an automatic translation from auto is skipped as the
+// language of the text isn't detected confidently enough, such as for "no" or "ok", whose
+// translations would be nonsense. Translations asked for by users are never skipped.
+func (p *Plugin) isUncertainDetection(ctx context.Context, post *model.Post, userInfo *UserInfo, detected *DetectedLanguage, automatic bool) bool {
+	if !automatic || userInfo.SourceLanguage != autoLanguage {
+		return false
+	}
+
+	if detected.reaches(p.getConfiguration().getDetectionConfidenceThreshold()) {
+		return false
+	}
+
+	p.API.LogDebug("Skipped translation of uncertain language", "request_id", getRequestID(ctx), "post_id", post.Id, "language", detected.Language, "confidence", detected.Confidence)
+	return true
+}
+
+// newTranslationPost returns a bot post in the channel of the given post, referencing it in props.
+func (p *Plugin) newTranslationPost(post *model.Post, userInfo *UserInfo, rootID string) *model.Post {
+	translationPost := &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: post.ChannelId,
+		RootId:    rootID,
+		ParentId:  rootID,
+	}
+	translationPost.AddProp(translationSourcePostIDProp, post.Id)
+	translationPost.AddProp(translationSourceLanguageProp, userInfo.SourceLanguage)
+	translationPost.AddProp(translationTargetLanguageProp, userInfo.TargetLanguage)
+
+	for _, key := range priorityProps {
+		if value := post.GetProp(key); value != nil {
+			translationPost.AddProp(key, value)
+		}
+	}
+
+	return translationPost
+}
+
+// getTranslationHeader names the languages of a translation, such as "Japanese → English", the
+// source language being only "detected" when it couldn't be told.
+func getTranslationHeader(source, target string) string {
+	sourceName := languageCodes[source]
+	if source == autoLanguage || sourceName == "" {
+		sourceName = "detected"
+	}
+
+	return fmt.Sprintf("%s → %s", sourceName, languageCodes[target])
+}
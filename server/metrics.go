@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	metricsNamespace = "autotranslate"
+
+	// metricsContentType is the content type of the Prometheus text format.
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// translationMetrics counts the requests to translation providers and the lookups of cached
+// translations since the plugin started, for Prometheus to scrape. Unlike the usage statistics,
+// they are neither saved nor shared between cluster nodes, as Prometheus scrapes every node.
+type translationMetrics struct {
+	lock sync.Mutex
+
+	requests   map[string]int64
+	characters map[string]int64
+
+	// errors counts the failed requests keyed by provider and error ID.
+	errors map[[2]string]int64
+
+	// latencyBuckets counts the requests of providers per bucket of latencyBucketBounds, the
+	// last bucket holding any longer latency.
+	latencyBuckets map[string][]int64
+
+	// latencySums holds the total latency of the requests of providers in seconds.
+	latencySums map[string]float64
+
+	// pairRequests, pairLatencyBuckets and pairLatencySums are the successful requests and their
+	// latency per provider and language pair.
+	pairRequests       map[[3]string]int64
+	pairLatencyBuckets map[[3]string][]int64
+	pairLatencySums    map[[3]string]float64
+
+	// feedback counts the ratings of translations keyed by provider, language pair and rating.
+	feedback map[[4]string]int64
+
+	cacheHits   int64
+	cacheMisses int64
+}
+
+// observeRequest counts a request to a translation provider.
+func (m *translationMetrics) observeRequest(provider string, characters int, latency time.Duration, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.requests == nil {
+		m.requests = map[string]int64{}
+		m.characters = map[string]int64{}
+		m.errors = map[[2]string]int64{}
+		m.latencyBuckets = map[string][]int64{}
+		m.latencySums = map[string]float64{}
+	}
+
+	m.requests[provider]++
+	m.characters[provider] += int64(characters)
+	if err != nil {
+		m.errors[[2]string{provider, getProviderErrorID(err)}]++
+	}
+
+	buckets, ok := m.latencyBuckets[provider]
+	if !ok {
+		buckets = make([]int64, len(latencyBucketBounds)+1)
+		m.latencyBuckets[provider] = buckets
+	}
+
+	buckets[getLatencyBucket(latency)]++
+	m.latencySums[provider] += latency.Seconds()
+}
+
+// observePairRequest counts a successful request to a translation provider for a language pair.
+func (m *translationMetrics) observePairRequest(provider, source, target string, latency time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.pairRequests == nil {
+		m.pairRequests = map[[3]string]int64{}
+		m.pairLatencyBuckets = map[[3]string][]int64{}
+		m.pairLatencySums = map[[3]string]float64{}
+	}
+
+	pair := [3]string{provider, source, target}
+	buckets, ok := m.pairLatencyBuckets[pair]
+	if !ok {
+		buckets = make([]int64, len(latencyBucketBounds)+1)
+		m.pairLatencyBuckets[pair] = buckets
+	}
+
+	m.pairRequests[pair]++
+	buckets[getLatencyBucket(latency)]++
+	m.pairLatencySums[pair] += latency.Seconds()
+}
+
+// observeFeedback counts a rating of a translation.
+func (m *translationMetrics) observeFeedback(provider, source, target, rating string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.feedback == nil {
+		m.feedback = map[[4]string]int64{}
+	}
+	m.feedback[[4]string{provider, source, target, rating}]++
+}
+
+// observeCacheLookup counts a lookup of a cached translation.
+func (m *translationMetrics) observeCacheLookup(hit bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if hit {
+		m.cacheHits++
+	} else {
+		m.cacheMisses++
+	}
+}
+
+// metricSample is a sample of a metric, histograms having samples of several series told apart by
+// their suffix.
+type metricSample struct {
+	suffix string
+	labels string
+	value  float64
+}
+
+// writeMetric writes a metric in the Prometheus text format.
+func writeMetric(buf *bytes.Buffer, name, metricType, help string, samples []metricSample) {
+	name = metricsNamespace + "_" + name
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+
+	for _, sample := range samples {
+		fmt.Fprintf(buf, "%s%s%s %s\n", name, sample.suffix, sample.labels, strconv.FormatFloat(sample.value, 'g', -1, 64))
+	}
+}
+
+// formatLabels returns the labels of a sample from pairs of names and values.
+func formatLabels(pairs ...string) string {
+	var labels []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		labels = append(labels, fmt.Sprintf("%s=%q", pairs[i], pairs[i+1]))
+	}
+
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+// write writes the metrics in the Prometheus text format, along with the number of posts waiting
+// to be translated.
+func (m *translationMetrics) write(buf *bytes.Buffer, pendingPosts int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	providers := make([]string, 0, len(m.requests))
+	for provider := range m.requests {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	var requests, characters, latencies []metricSample
+	for _, provider := range providers {
+		labels := formatLabels("provider", provider)
+		requests = append(requests, metricSample{labels: labels, value: float64(m.requests[provider])})
+		characters = append(characters, metricSample{labels: labels, value: float64(m.characters[provider])})
+
+		var cumulative int64
+		for i, bucket := range m.latencyBuckets[provider] {
+			cumulative += bucket
+			le := "+Inf"
+			if i < len(latencyBucketBounds) {
+				le = strconv.FormatFloat(float64(latencyBucketBounds[i])/1000, 'g', -1, 64)
+			}
+			latencies = append(latencies, metricSample{suffix: "_bucket", labels: formatLabels("provider", provider, "le", le), value: float64(cumulative)})
+		}
+		latencies = append(latencies,
+			metricSample{suffix: "_sum", labels: labels, value: m.latencySums[provider]},
+			metricSample{suffix: "_count", labels: labels, value: float64(m.requests[provider])},
+		)
+	}
+
+	errorKeys := make([][2]string, 0, len(m.errors))
+	for key := range m.errors {
+		errorKeys = append(errorKeys, key)
+	}
+	sort.Slice(errorKeys, func(i, j int) bool {
+		if errorKeys[i][0] != errorKeys[j][0] {
+			return errorKeys[i][0] < errorKeys[j][0]
+		}
+		return errorKeys[i][1] < errorKeys[j][1]
+	})
+
+	var errors []metricSample
+	for _, key := range errorKeys {
+		errors = append(errors, metricSample{labels: formatLabels("provider", key[0], "error", key[1]), value: float64(m.errors[key])})
+	}
+
+	pairs := make([][3]string, 0, len(m.pairRequests))
+	for pair := range m.pairRequests {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return strings.Join(pairs[i][:], ":") < strings.Join(pairs[j][:], ":")
+	})
+
+	var pairLatencies []metricSample
+	for _, pair := range pairs {
+		labels := formatLabels("provider", pair[0], "source", pair[1], "target", pair[2])
+
+		var cumulative int64
+		for i, bucket := range m.pairLatencyBuckets[pair] {
+			cumulative += bucket
+			le := "+Inf"
+			if i < len(latencyBucketBounds) {
+				le = strconv.FormatFloat(float64(latencyBucketBounds[i])/1000, 'g', -1, 64)
+			}
+			pairLatencies = append(pairLatencies, metricSample{suffix: "_bucket", labels: formatLabels("provider", pair[0], "source", pair[1], "target", pair[2], "le", le), value: float64(cumulative)})
+		}
+		pairLatencies = append(pairLatencies,
+			metricSample{suffix: "_sum", labels: labels, value: m.pairLatencySums[pair]},
+			metricSample{suffix: "_count", labels: labels, value: float64(m.pairRequests[pair])},
+		)
+	}
+
+	feedbackKeys := make([][4]string, 0, len(m.feedback))
+	for key := range m.feedback {
+		feedbackKeys = append(feedbackKeys, key)
+	}
+	sort.Slice(feedbackKeys, func(i, j int) bool {
+		return strings.Join(feedbackKeys[i][:], ":") < strings.Join(feedbackKeys[j][:], ":")
+	})
+
+	var feedback []metricSample
+	for _, key := range feedbackKeys {
+		feedback = append(feedback, metricSample{labels: formatLabels("provider", key[0], "source", key[1], "target", key[2], "rating", key[3]), value: float64(m.feedback[key])})
+	}
+
+	writeMetric(buf, "provider_requests_total", "counter", "Requests to translation providers.", requests)
+	writeMetric(buf, "provider_errors_total", "counter", "Failed requests to translation providers, by error ID.", errors)
+	writeMetric(buf, "provider_characters_total", "counter", "Characters sent to translation providers.", characters)
+	writeMetric(buf, "provider_request_duration_seconds", "histogram", "Latency of the requests to translation providers.", latencies)
+	writeMetric(buf, "language_pair_request_duration_seconds", "histogram", "Latency of the successful requests to translation providers per language pair.", pairLatencies)
+	writeMetric(buf, "feedback_total", "counter", "Ratings of translations by users per language pair.", feedback)
+	writeMetric(buf, "cache_hits_total", "counter", "Translations found in the cache.", []metricSample{{value: float64(m.cacheHits)}})
+	writeMetric(buf, "cache_misses_total", "counter", "Translations not found in the cache.", []metricSample{{value: float64(m.cacheMisses)}})
+	writeMetric(buf, "pending_posts", "gauge", "Posts waiting to be translated together with the next ones of their author.", []metricSample{{value: float64(pendingPosts)}})
+}
+
+// getMetrics reports the metrics of this server in the Prometheus text format, for Prometheus to
+// scrape with the personal access token of a system admin.
+func (p *Plugin) getMetrics(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	p.metrics.write(&buf, p.countPendingPosts())
+
+	w.Header().Set("Content-Type", metricsContentType)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslationMetrics(t *testing.T) {
+	var m translationMetrics
+	m.observeRequest(providerAWS, 10, 80*time.Millisecond, nil)
+	m.observeRequest(providerAWS, 5, 3*time.Second, errors.New("failed"))
+	m.observeRequest(providerAWS, 20, 20*time.Second, nil)
+	m.observeCacheLookup(true)
+	m.observeCacheLookup(false)
+	m.observeCacheLookup(true)
+	m.observePairRequest(providerAWS, "ja", "en", 300*time.Millisecond)
+	m.observePairRequest(providerAWS, "ja", "en", 1500*time.Millisecond)
+	m.observeFeedback(providerAWS, "ja", "en", feedbackRatingBad)
+
+	var buf bytes.Buffer
+	m.write(&buf, 4)
+	output := buf.String()
+
+	for _, line := range []string{
+		"# TYPE autotranslate_provider_requests_total counter",
+		`autotranslate_provider_requests_total{provider="aws"} 3`,
+		`autotranslate_provider_errors_total{provider="aws",error="unable_to_translate"} 1`,
+		`autotranslate_provider_characters_total{provider="aws"} 35`,
+		"# TYPE autotranslate_provider_request_duration_seconds histogram",
+		`autotranslate_provider_request_duration_seconds_bucket{provider="aws",le="0.05"} 0`,
+		`autotranslate_provider_request_duration_seconds_bucket{provider="aws",le="0.1"} 1`,
+		`autotranslate_provider_request_duration_seconds_bucket{provider="aws",le="5"} 2`,
+		`autotranslate_provider_request_duration_seconds_bucket{provider="aws",le="10"} 2`,
+		`autotranslate_provider_request_duration_seconds_bucket{provider="aws",le="+Inf"} 3`,
+		`autotranslate_provider_request_duration_seconds_sum{provider="aws"} 23.08`,
+		`autotranslate_provider_request_duration_seconds_count{provider="aws"} 3`,
+		`autotranslate_language_pair_request_duration_seconds_bucket{provider="aws",source="ja",target="en",le="0.5"} 1`,
+		`autotranslate_language_pair_request_duration_seconds_bucket{provider="aws",source="ja",target="en",le="2"} 2`,
+		`autotranslate_language_pair_request_duration_seconds_sum{provider="aws",source="ja",target="en"} 1.8`,
+		`autotranslate_language_pair_request_duration_seconds_count{provider="aws",source="ja",target="en"} 2`,
+		`autotranslate_feedback_total{provider="aws",source="ja",target="en",rating="bad"} 1`,
+		"autotranslate_cache_hits_total 2",
+		"autotranslate_cache_misses_total 1",
+		"# TYPE autotranslate_pending_posts gauge",
+		"autotranslate_pending_posts 4",
+	} {
+		assert.Contains(t, output, line+"\n")
+	}
+
+	// Buckets are listed by increasing bound.
+	assert.Less(t, strings.Index(output, `le="0.05"`), strings.Index(output, `le="10"`))
+	assert.Less(t, strings.Index(output, `le="10"`), strings.Index(output, `le="+Inf"`))
+}
+
+func TestTranslationMetricsEmpty(t *testing.T) {
+	var m translationMetrics
+
+	var buf bytes.Buffer
+	m.write(&buf, 0)
+
+	assert.Contains(t, buf.String(), "# TYPE autotranslate_provider_requests_total counter\n# HELP autotranslate_provider_errors_total")
+	assert.Contains(t, buf.String(), "autotranslate_cache_hits_total 0\n")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// ngramVocabularySize smooths the probabilities of trigrams, standing for the number of
+	// trigrams a language may use besides the ones of its sample.
+	ngramVocabularySize = 5000
+
+	// minReliableTrigrams is the number of trigrams from which a text is long enough for its
+	// language to be told with full confidence, shorter texts getting a lower confidence.
+	minReliableTrigrams = 15
+
+	// minTrigrams is the number of trigrams below which the language of a text isn't told at all,
+	// such as for "ok" or "si", which mean the same in many languages.
+	minTrigrams = 5
+)
+
+// ngramProfile holds the log probabilities of the trigrams of a language.
+type ngramProfile struct {
+	language      string
+	logProbs      map[string]float64
+	unseenLogProb float64
+}
+
+// ngramProfiles are built from the samples of languages when the plugin starts, so that local
+// detection needs neither a request nor any file.
+var ngramProfiles []*ngramProfile
+
+func init() {
+	for language, sample := range ngramSamples {
+		ngramProfiles = append(ngramProfiles, newNgramProfile(language, sample))
+	}
+
+	sort.Slice(ngramProfiles, func(i, j int) bool {
+		return ngramProfiles[i].language < ngramProfiles[j].language
+	})
+}
+
+func newNgramProfile(language, sample string) *ngramProfile {
+	counts := map[string]int{}
+	total := 0
+	for _, trigram := range getTrigrams(sample) {
+		counts[trigram]++
+		total++
+	}
+
+	profile := &ngramProfile{
+		language:      language,
+		logProbs:      make(map[string]float64, len(counts)),
+		unseenLogProb: math.Log(1 / float64(total+ngramVocabularySize)),
+	}
+	for trigram, count := range counts {
+		profile.logProbs[trigram] = math.Log(float64(count+1) / float64(total+ngramVocabularySize))
+	}
+
+	return profile
+}
+
+// getTrigrams returns the trigrams of the lowercased words of text, each word being padded with
+// spaces so that its beginning and end count.
+func getTrigrams(text string) []string {
+	var trigrams []string
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, word := range words {
+		runes := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			trigrams = append(trigrams, string(runes[i:i+3]))
+		}
+	}
+
+	return trigrams
+}
+
+// detectNgramLanguage guesses the language of Latin script text from the likelihood of its
+// trigrams in each language profile. The confidence is the probability of the guessed language
+// among the profiled ones, lowered for texts too short to be told apart reliably.
+func detectNgramLanguage(text string) (string, float64) {
+	trigrams := getTrigrams(text)
+	if len(trigrams) < minTrigrams {
+		return "", 0
+	}
+
+	scores := make([]float64, len(ngramProfiles))
+	best := 0
+	for i, profile := range ngramProfiles {
+		for _, trigram := range trigrams {
+			if logProb, ok := profile.logProbs[trigram]; ok {
+				scores[i] += logProb
+			} else {
+				scores[i] += profile.unseenLogProb
+			}
+		}
+
+		if scores[i] > scores[best] {
+			best = i
+		}
+	}
+
+	sum := 0.0
+	for _, score := range scores {
+		sum += math.Exp(score - scores[best])
+	}
+
+	confidence := 1 / sum
+	if len(trigrams) < minReliableTrigrams {
+		confidence *= float64(len(trigrams)) / minReliableTrigrams
+	}
+
+	return ngramProfiles[best].language, confidence
+}
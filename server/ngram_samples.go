@@ -0,0 +1,146 @@
+package main
+
+// ngramSamples holds sample texts of languages written in the Latin script, from which their
+// trigram profiles are built when the plugin starts. They mix formal text with the kind of short
+// messages posted in chats, which are the ones detected the most.
+var ngramSamples = map[string]string{
+	"en": `All human beings are born free and equal in dignity and rights. They are endowed with reason and conscience and should act towards one another in a spirit of brotherhood.
+Everyone has the right to life, liberty and security of person. No one shall be held in slavery or servitude.
+Hi everyone, the meeting has been moved to three o'clock this afternoon. Could you please send me the report before the end of the day?
+I think we should wait until tomorrow before we deploy the new version. What do you think about it?
+Thanks for your help, that was really useful. Let me know when you are back from lunch.
+The build is failing again because of a missing dependency. I will take a look at it right now.
+We are going to have a short call with the customer next week to talk about their requirements.
+Where did you put the notes from yesterday? I can't find them anywhere in the shared folder.
+Good morning! Does anyone know why the server is so slow today? It was working fine last night.`,
+
+	"es": `Todos los seres humanos nacen libres e iguales en dignidad y derechos y, dotados como están de razón y conciencia, deben comportarse fraternalmente los unos con los otros.
+Todo individuo tiene derecho a la vida, a la libertad y a la seguridad de su persona. Nadie estará sometido a esclavitud ni a servidumbre.
+Hola a todos, la reunión se ha movido a las tres de la tarde. ¿Podrías enviarme el informe antes del final del día, por favor?
+Creo que deberíamos esperar hasta mañana antes de desplegar la nueva versión. ¿Qué te parece?
+Gracias por tu ayuda, fue muy útil. Avísame cuando vuelvas de comer.
+La compilación está fallando otra vez porque falta una dependencia. Ahora mismo lo reviso.
+Vamos a tener una llamada corta con el cliente la semana que viene para hablar de sus necesidades.
+¿Dónde pusiste las notas de ayer? No las encuentro en ninguna parte de la carpeta compartida.
+¡Buenos días! ¿Alguien sabe por qué el servidor está tan lento hoy? Anoche funcionaba bien.`,
+
+	"fr": `Tous les êtres humains naissent libres et égaux en dignité et en droits. Ils sont doués de raison et de conscience et doivent agir les uns envers les autres dans un esprit de fraternité.
+Tout individu a droit à la vie, à la liberté et à la sûreté de sa personne. Nul ne sera tenu en esclavage ni en servitude.
+Bonjour à tous, la réunion a été déplacée à quinze heures cet après-midi. Pourrais-tu m'envoyer le rapport avant la fin de la journée ?
+Je pense qu'on devrait attendre demain avant de déployer la nouvelle version. Qu'est-ce que tu en penses ?
+Merci pour ton aide, c'était vraiment utile. Dis-moi quand tu reviens de déjeuner.
+Le build échoue encore à cause d'une dépendance manquante. Je vais regarder ça tout de suite.
+Nous allons avoir un court appel avec le client la semaine prochaine pour parler de leurs besoins.
+Où as-tu mis les notes d'hier ? Je ne les trouve nulle part dans le dossier partagé.
+Bonjour ! Quelqu'un sait pourquoi le serveur est si lent aujourd'hui ? Il marchait bien hier soir.`,
+
+	"de": `Alle Menschen sind frei und gleich an Würde und Rechten geboren. Sie sind mit Vernunft und Gewissen begabt und sollen einander im Geist der Brüderlichkeit begegnen.
+Jeder hat das Recht auf Leben, Freiheit und Sicherheit der Person. Niemand darf in Sklaverei oder Leibeigenschaft gehalten werden.
+Hallo zusammen, das Meeting wurde auf drei Uhr heute Nachmittag verschoben. Könntest du mir bitte den Bericht vor Feierabend schicken?
+Ich denke, wir sollten bis morgen warten, bevor wir die neue Version ausrollen. Was meinst du dazu?
+Danke für deine Hilfe, das war wirklich nützlich. Sag mir Bescheid, wenn du vom Mittagessen zurück bist.
+Der Build schlägt schon wieder fehl, weil eine Abhängigkeit fehlt. Ich schaue mir das gleich an.
+Wir haben nächste Woche ein kurzes Gespräch mit dem Kunden, um über seine Anforderungen zu sprechen.
+Wo hast du die Notizen von gestern hingelegt? Ich kann sie nirgendwo im gemeinsamen Ordner finden.
+Guten Morgen! Weiß jemand, warum der Server heute so langsam ist? Gestern Abend hat er noch gut funktioniert.`,
+
+	"pt": `Todos os seres humanos nascem livres e iguais em dignidade e em direitos. Dotados de razão e de consciência, devem agir uns para com os outros em espírito de fraternidade.
+Todo o indivíduo tem direito à vida, à liberdade e à segurança pessoal. Ninguém será mantido em escravatura ou em servidão.
+Olá a todos, a reunião foi mudada para as três horas da tarde. Você pode me mandar o relatório antes do fim do dia, por favor?
+Acho que devemos esperar até amanhã antes de publicar a nova versão. O que você acha?
+Obrigado pela ajuda, foi muito útil. Me avisa quando voltar do almoço.
+A compilação está falhando de novo porque falta uma dependência. Vou dar uma olhada agora mesmo.
+Vamos ter uma chamada rápida com o cliente na próxima semana para falar sobre as necessidades deles.
+Onde você colocou as anotações de ontem? Não consigo encontrá-las em nenhum lugar da pasta compartilhada.
+Bom dia! Alguém sabe por que o servidor está tão lento hoje? Ontem à noite estava funcionando bem.`,
+
+	"it": `Tutti gli esseri umani nascono liberi ed eguali in dignità e diritti. Essi sono dotati di ragione e di coscienza e devono agire gli uni verso gli altri in spirito di fratellanza.
+Ogni individuo ha diritto alla vita, alla libertà ed alla sicurezza della propria persona. Nessun individuo potrà essere tenuto in stato di schiavitù o di servitù.
+Ciao a tutti, la riunione è stata spostata alle tre di questo pomeriggio. Potresti mandarmi il rapporto prima della fine della giornata?
+Penso che dovremmo aspettare fino a domani prima di rilasciare la nuova versione. Cosa ne pensi?
+Grazie per l'aiuto, è stato davvero utile. Fammi sapere quando torni dal pranzo.
+La build sta fallendo di nuovo perché manca una dipendenza. Ci do un'occhiata subito.
+Faremo una breve chiamata con il cliente la settimana prossima per parlare delle loro esigenze.
+Dove hai messo gli appunti di ieri? Non riesco a trovarli da nessuna parte nella cartella condivisa.
+Buongiorno! Qualcuno sa perché il server è così lento oggi? Ieri sera funzionava bene.`,
+
+	"nl": `Alle mensen worden vrij en gelijk in waardigheid en rechten geboren. Zij zijn begiftigd met verstand en geweten, en behoren zich jegens elkander in een geest van broederschap te gedragen.
+Een ieder heeft recht op leven, vrijheid en onschendbaarheid van zijn persoon. Niemand zal in slavernij of dienstbaarheid gehouden worden.
+Hallo allemaal, de vergadering is verplaatst naar drie uur vanmiddag. Kun je me het rapport voor het einde van de dag sturen?
+Ik denk dat we tot morgen moeten wachten voordat we de nieuwe versie uitrollen. Wat vind jij ervan?
+Bedankt voor je hulp, dat was echt nuttig. Laat het me weten als je terug bent van de lunch.
+De build faalt weer omdat er een afhankelijkheid ontbreekt. Ik kijk er meteen naar.
+We hebben volgende week een kort gesprek met de klant om over hun wensen te praten.
+Waar heb je de aantekeningen van gisteren gelaten? Ik kan ze nergens in de gedeelde map vinden.
+Goedemorgen! Weet iemand waarom de server vandaag zo traag is? Gisteravond werkte hij nog prima.`,
+
+	"sv": `Alla människor är födda fria och lika i värde och rättigheter. De har utrustats med förnuft och samvete och bör handla gentemot varandra i en anda av broderskap.
+Var och en har rätt till liv, frihet och personlig säkerhet. Ingen får hållas i slaveri eller träldom.
+Hej allihop, mötet har flyttats till klockan tre i eftermiddag. Kan du skicka rapporten till mig innan dagen är slut?
+Jag tycker att vi ska vänta till i morgon innan vi släpper den nya versionen. Vad tycker du?
+Tack för hjälpen, det var verkligen användbart. Säg till när du är tillbaka från lunchen.
+Bygget misslyckas igen eftersom ett beroende saknas. Jag tittar på det direkt.
+Vi ska ha ett kort samtal med kunden nästa vecka för att prata om deras behov.
+Var lade du anteckningarna från i går? Jag hittar dem inte någonstans i den delade mappen.
+God morgon! Vet någon varför servern är så långsam i dag? Den fungerade bra i går kväll.`,
+
+	"da": `Alle mennesker er født frie og lige i værdighed og rettigheder. De er udstyret med fornuft og samvittighed, og de bør handle mod hverandre i en broderskabets ånd.
+Enhver har ret til liv, frihed og personlig sikkerhed. Ingen må holdes i slaveri eller trældom.
+Hej alle sammen, mødet er blevet flyttet til klokken tre i eftermiddag. Kan du sende mig rapporten inden dagen er omme?
+Jeg synes, vi skal vente til i morgen, før vi udgiver den nye version. Hvad synes du?
+Tak for hjælpen, det var virkelig nyttigt. Sig til, når du er tilbage fra frokost.
+Bygget fejler igen, fordi der mangler en afhængighed. Jeg kigger på det med det samme.
+Vi skal have et kort opkald med kunden i næste uge for at tale om deres behov.
+Hvor lagde du noterne fra i går? Jeg kan ikke finde dem nogen steder i den delte mappe.
+Godmorgen! Er der nogen, der ved, hvorfor serveren er så langsom i dag? Den virkede fint i går aftes.`,
+
+	"pl": `Wszyscy ludzie rodzą się wolni i równi pod względem swej godności i swych praw. Są oni obdarzeni rozumem i sumieniem i powinni postępować wobec innych w duchu braterstwa.
+Każdy człowiek ma prawo do życia, wolności i bezpieczeństwa swojej osoby. Nikt nie może być trzymany w niewolnictwie ani w poddaństwie.
+Cześć wszystkim, spotkanie zostało przeniesione na trzecią po południu. Czy możesz mi wysłać raport przed końcem dnia?
+Myślę, że powinniśmy poczekać do jutra, zanim wdrożymy nową wersję. Co o tym myślisz?
+Dzięki za pomoc, to było naprawdę przydatne. Daj mi znać, kiedy wrócisz z obiadu.
+Kompilacja znowu się nie udaje, bo brakuje jednej zależności. Zaraz się temu przyjrzę.
+W przyszłym tygodniu mamy krótką rozmowę z klientem, żeby porozmawiać o jego wymaganiach.
+Gdzie położyłeś wczorajsze notatki? Nie mogę ich nigdzie znaleźć we wspólnym folderze.
+Dzień dobry! Czy ktoś wie, dlaczego serwer jest dzisiaj taki wolny? Wczoraj wieczorem działał dobrze.`,
+
+	"tr": `Bütün insanlar hür, haysiyet ve haklar bakımından eşit doğarlar. Akıl ve vicdana sahiptirler ve birbirlerine karşı kardeşlik zihniyeti ile hareket etmelidirler.
+Yaşamak, hürriyet ve kişi emniyeti her ferdin hakkıdır. Hiç kimse kölelik veya kulluk altında bulundurulamaz.
+Herkese merhaba, toplantı bugün öğleden sonra saat üçe alındı. Raporu gün bitmeden bana gönderebilir misin?
+Bence yeni sürümü yayınlamadan önce yarına kadar beklemeliyiz. Sen ne düşünüyorsun?
+Yardımın için teşekkürler, gerçekten çok işe yaradı. Öğle yemeğinden döndüğünde bana haber ver.
+Derleme yine başarısız oluyor çünkü bir bağımlılık eksik. Hemen bakıyorum.
+Gelecek hafta müşteriyle ihtiyaçlarını konuşmak için kısa bir görüşme yapacağız.
+Dünkü notları nereye koydun? Paylaşılan klasörde hiçbir yerde bulamıyorum.
+Günaydın! Sunucunun bugün neden bu kadar yavaş olduğunu bilen var mı? Dün akşam gayet iyi çalışıyordu.`,
+
+	"id": `Semua orang dilahirkan merdeka dan mempunyai martabat dan hak-hak yang sama. Mereka dikaruniai akal dan hati nurani dan hendaknya bergaul satu sama lain dalam semangat persaudaraan.
+Setiap orang berhak atas kehidupan, kebebasan dan keselamatan sebagai individu. Tidak seorang pun boleh diperbudak atau diperhambakan.
+Halo semuanya, rapatnya dipindah ke jam tiga sore ini. Bisa tolong kirimkan laporannya sebelum akhir hari?
+Saya pikir kita sebaiknya menunggu sampai besok sebelum merilis versi yang baru. Bagaimana menurutmu?
+Terima kasih atas bantuannya, itu sangat berguna. Kabari saya kalau kamu sudah kembali dari makan siang.
+Build-nya gagal lagi karena ada dependensi yang hilang. Saya akan segera memeriksanya.
+Kita akan mengadakan panggilan singkat dengan pelanggan minggu depan untuk membahas kebutuhan mereka.
+Di mana kamu menaruh catatan kemarin? Saya tidak bisa menemukannya di folder bersama.
+Selamat pagi! Ada yang tahu kenapa servernya lambat sekali hari ini? Tadi malam masih berjalan dengan baik.`,
+
+	"ro": `Toate ființele umane se nasc libere și egale în demnitate și în drepturi. Ele sunt înzestrate cu rațiune și conștiință și trebuie să se comporte unele față de altele în spiritul fraternității.
+Orice ființă umană are dreptul la viață, la libertate și la securitatea persoanei sale. Nimeni nu va fi ținut în sclavie, nici în servitute.
+Salut tuturor, ședința a fost mutată la ora trei după-amiază. Poți să-mi trimiți raportul înainte de sfârșitul zilei?
+Cred că ar trebui să așteptăm până mâine înainte să lansăm noua versiune. Ce părere ai?
+Mulțumesc pentru ajutor, a fost foarte util. Spune-mi când te întorci de la prânz.
+Compilarea eșuează din nou pentru că lipsește o dependență. Mă uit imediat.
+Săptămâna viitoare vom avea o discuție scurtă cu clientul ca să vorbim despre nevoile lor.
+Unde ai pus notițele de ieri? Nu le găsesc nicăieri în dosarul comun.
+Bună dimineața! Știe cineva de ce serverul este atât de lent astăzi? Aseară funcționa bine.`,
+
+	"fi": `Kaikki ihmiset syntyvät vapaina ja tasavertaisina arvoltaan ja oikeuksiltaan. Heille on annettu järki ja omatunto, ja heidän on toimittava toisiaan kohtaan veljeyden hengessä.
+Jokaisella on oikeus elämään, vapauteen ja henkilökohtaiseen turvallisuuteen. Ketään ei saa pitää orjuudessa tai orjan kaltaisessa asemassa.
+Hei kaikki, palaveri on siirretty kello kolmeen iltapäivällä. Voisitko lähettää minulle raportin ennen päivän loppua?
+Minusta meidän pitäisi odottaa huomiseen ennen kuin julkaisemme uuden version. Mitä mieltä sinä olet?
+Kiitos avusta, se oli todella hyödyllistä. Kerro minulle, kun olet palannut lounaalta.
+Käännös epäonnistuu taas, koska yksi riippuvuus puuttuu. Katson sitä heti.
+Meillä on ensi viikolla lyhyt puhelu asiakkaan kanssa heidän tarpeistaan.
+Mihin laitoit eilisen muistiinpanot? En löydä niitä mistään jaetusta kansiosta.
+Hyvää huomenta! Tietääkö joku, miksi palvelin on tänään niin hidas? Eilen illalla se toimi hyvin.`,
+}
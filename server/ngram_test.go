@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectNgramLanguage(t *testing.T) {
+	for text, language := range map[string]string{
+		"The meeting is moved to the afternoon because of the holiday": "en",
+		"Je suis en retard, désolé":                                    "fr",
+		"Ich komme gleich":                                             "de",
+		"Voy a llegar tarde hoy":                                       "es",
+		"Vou chegar atrasado hoje":                                     "pt",
+		"Jeg kommer snart":                                             "da",
+		"Birazdan geliyorum":                                           "tr",
+		"Saya akan segera datang":                                      "id",
+	} {
+		detected, confidence := detectNgramLanguage(text)
+		assert.Equal(t, language, detected, text)
+		assert.Greater(t, confidence, 0.5, text)
+	}
+
+	detected, confidence := detectNgramLanguage("si")
+	assert.Empty(t, detected, "too short to tell")
+	assert.Zero(t, confidence)
+
+	_, short := detectNgramLanguage("let's ship it")
+	_, long := detectNgramLanguage("let's ship it as soon as the build of the new version is green")
+	assert.Less(t, short, long, "short texts get a lower confidence")
+}
+
+func TestHasTranslatableText(t *testing.T) {
+	assert.True(t, hasTranslatableText("See https://example.com for details"))
+	assert.False(t, hasTranslatableText("@john ~town-square https://example.com :thumbsup: `make test` 12:30"))
+	assert.False(t, hasTranslatableText(""))
+}
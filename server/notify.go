@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// adminsPerPage is the number of system admins fetched at once to send them a message.
+const adminsPerPage = 100
+
+// notifyAdmins posts a message of the bot in the channel set by admins for it, or sends it to
+// every active system admin by direct message when none is set.
+func (p *Plugin) notifyAdmins(channelID, message string) error {
+	if channelID != "" {
+		if _, appErr := p.API.CreatePost(&model.Post{UserId: p.botUserID, ChannelId: channelID, Message: message}); appErr != nil {
+			return appErr
+		}
+		return nil
+	}
+
+	for page := 0; ; page++ {
+		admins, appErr := p.API.GetUsers(&model.UserGetOptions{Role: model.SYSTEM_ADMIN_ROLE_ID, Page: page, PerPage: adminsPerPage})
+		if appErr != nil {
+			return appErr
+		}
+
+		for _, admin := range admins {
+			if admin.DeleteAt != 0 {
+				continue
+			}
+
+			channel, appErr := p.API.GetDirectChannel(p.botUserID, admin.Id)
+			if appErr != nil {
+				return appErr
+			}
+			if _, appErr := p.API.CreatePost(&model.Post{UserId: p.botUserID, ChannelId: channel.Id, Message: message}); appErr != nil {
+				return appErr
+			}
+		}
+
+		if len(admins) < adminsPerPage {
+			return nil
+		}
+	}
+}
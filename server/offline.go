@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/translate"
+	"github.com/pkg/errors"
+)
+
+// providerOffline is the on-premises provider translating messages while the other providers
+// can't be reached, through an Amazon Translate compatible gateway such as one in front of
+// LibreTranslate or Argos Translate.
+const providerOffline = "offline"
+
+// offlineQualityNote marks the translations of the offline provider, which are usually of lower
+// quality than the ones of the usual providers.
+const offlineQualityNote = ":electric_plug: _Translated offline while the translation provider is unreachable, so quality may be lower._"
+
+// newOfflineTranslateService returns the client of the offline provider of a configuration, or nil
+// when admins set no offline endpoint.
+func newOfflineTranslateService(configuration *configuration, sess *session.Session) *translate.Translate {
+	if configuration.OfflineEndpoint == "" {
+		return nil
+	}
+
+	return translate.New(sess, aws.NewConfig().WithEndpoint(configuration.OfflineEndpoint))
+}
+
+// getOfflineTranslateService returns the client of the offline provider built from the last valid
+// configuration.
+func (p *Plugin) getOfflineTranslateService() (*translate.Translate, error) {
+	p.translateServiceLock.RLock()
+	defer p.translateServiceLock.RUnlock()
+
+	if p.offlineTranslateService == nil {
+		return nil, errors.New("offline translation provider not configured")
+	}
+
+	return p.offlineTranslateService, nil
+}
+
+// isOffline reports whether translations fall back to the offline provider, which they do when
+// admins set an offline endpoint, from the last probe of the provider failing until one succeeds
+// again.
+func (p *Plugin) isOffline() bool {
+	if p.getConfiguration().OfflineEndpoint == "" {
+		return false
+	}
+
+	probe := p.getLastProviderProbe()
+	return probe != nil && !probe.Reachable
+}
+
+// translatedOffline reports whether the translation of a post of the given number of characters
+// falls back to the offline provider.
+func (p *Plugin) translatedOffline(postID, source, target string, characters int) bool {
+	return p.isOffline() && p.getPairProvider(postID, source, target, characters) == providerOffline
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"regexp"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// noTranslateProp marks a post whose author opted out of its translation.
+const noTranslateProp = "autotranslate_no_translate"
+
+var (
+	// noTranslatePrefixPattern matches a leading !nt marker, along with the whitespace around it.
+	noTranslatePrefixPattern = regexp.MustCompile(`(?i)^\s*!nt(\s+|$)`)
+
+	// noTranslateLeadingHashtagPattern matches a #notranslate hashtag starting the message, along
+	// with the whitespace around it.
+	noTranslateLeadingHashtagPattern = regexp.MustCompile(`(?i)^\s*#notranslate(\s+|$)`)
+
+	// noTranslateHashtagPattern matches a #notranslate hashtag anywhere else in the message, along
+	// with the whitespace preceding it and the character following it, which hashtags such as
+	// #notranslate-docs don't end at.
+	noTranslateHashtagPattern = regexp.MustCompile(`(?i)\s+#notranslate([^\w-]|$)`)
+)
+
+// stripNoTranslateMarker removes the opt-out markers from a message, reporting whether any was
+// found. Only the whitespace around the markers goes with them.
+func stripNoTranslateMarker(message string) (string, bool) {
+	stripped := noTranslatePrefixPattern.ReplaceAllString(message, "")
+	stripped = noTranslateLeadingHashtagPattern.ReplaceAllString(stripped, "")
+	stripped = noTranslateHashtagPattern.ReplaceAllString(stripped, "$1")
+
+	return stripped, stripped != message
+}
+
+func isNoTranslatePost(post *model.Post) bool {
+	return post.GetProp(noTranslateProp) == true
+}
+
+// markNoTranslatePost opts posts starting with !nt or containing the #notranslate hashtag out of
+// translation, stripping the marker from the message and remembering it in props instead.
+func (p *Plugin) markNoTranslatePost(post *model.Post) *model.Post {
+	message, found := stripNoTranslateMarker(post.Message)
+	if !found {
+		return post
+	}
+
+	// A message made of the marker only is kept as is rather than posted empty.
+	if message != "" || len(post.FileIds) > 0 {
+		post.Message = message
+	}
+	post.AddProp(noTranslateProp, true)
+
+	return post
+}
+
+// updateNoTranslatePost opts edited posts out of translation like markNoTranslatePost, as long as
+// their new message carries a marker again. The markers being removed from the message shown,
+// editing it without adding one back opts the post into translation again.
+func (p *Plugin) updateNoTranslatePost(newPost, oldPost *model.Post) *model.Post {
+	if newPost.Message != oldPost.Message {
+		delete(newPost.Props, noTranslateProp)
+	}
+
+	return p.markNoTranslatePost(newPost)
+}
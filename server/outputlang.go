@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// minKanjiOnlyLength is the number of characters from which a translation into Japanese written
+// without kana is taken for Chinese, as short Japanese texts may be written in kanji only.
+const minKanjiOnlyLength = 20
+
+// wrongOutputLanguageError is a translation a provider answered in another language than its
+// target, such as Chinese instead of Japanese, even after translating it again.
+type wrongOutputLanguageError struct {
+	provider string
+	target   string
+	detected string
+}
+
+func (e *wrongOutputLanguageError) Error() string {
+	return fmt.Sprintf("the translation by provider %s is in %s instead of %s", e.provider, e.detected, e.target)
+}
+
+// outputLanguageRetryContextKey marks the translations made again after one came back in the wrong
+// language, so that they aren't made a third time.
+type outputLanguageRetryContextKey struct{}
+
+// isLocallyDetectable reports whether the local detector tells a language apart, from its script
+// or from the trigrams of its sample.
+func isLocallyDetectable(language string) bool {
+	if language == "ja" || language == "zh" {
+		return true
+	}
+
+	for _, scriptLanguage := range scriptLanguages {
+		if scriptLanguage.language == language {
+			return true
+		}
+	}
+
+	for _, profile := range ngramProfiles {
+		if profile.language == language {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getBaseLanguage returns a language code without its region or script, such as zh for zh-TW.
+func getBaseLanguage(language string) string {
+	return strings.SplitN(language, "-", 2)[0]
+}
+
+// getWrongOutputLanguage returns the language a translation is confidently written in when it
+// isn't its target language, or an empty string. Only target languages the local detector tells
+// apart are checked, so that a language it can't detect isn't mistaken for a close one.
+func getWrongOutputLanguage(target, translated string) string {
+	target = getBaseLanguage(target)
+	if !isLocallyDetectable(target) {
+		return ""
+	}
+
+	language, confidence := detectLanguageConfidence(translated)
+	if language == "" || confidence < minDetectionConfidence || language == target {
+		return ""
+	}
+
+	if target == "ja" && language == "zh" && utf8.RuneCountInString(translated) < minKanjiOnlyLength {
+		return ""
+	}
+
+	return language
+}
+
+// retryOutputLanguage translates text again after its translation by a provider came back in the
+// wrong language, with another available provider supporting the language pair or else the same
+// one, failing rather than delivering the wrong language twice.
+func (p *Plugin) retryOutputLanguage(ctx context.Context, provider, source, target, text, detected string) (string, string, error) {
+	p.API.LogWarn("Translation in the wrong language", "request_id", getRequestID(ctx), "provider", provider, "target", target, "detected", detected)
+	if retried, _ := ctx.Value(outputLanguageRetryContextKey{}).(bool); retried {
+		return "", "", &wrongOutputLanguageError{provider: provider, target: target, detected: detected}
+	}
+
+	retryProvider := provider
+	for _, alternative := range getLanguagePairProviders(source, target) {
+		if alternative == provider {
+			continue
+		}
+		if _, err := translationProviders[alternative](p); err == nil {
+			retryProvider = alternative
+			break
+		}
+	}
+
+	// The client given for the translation may be the one of the routed provider, so the one of
+	// Amazon Translate is taken again.
+	svc, err := p.getTranslateService()
+	if err != nil {
+		return "", "", err
+	}
+
+	ctx = newProviderContext(context.WithValue(ctx, outputLanguageRetryContextKey{}, true), retryProvider)
+	return p.translateTextWithSource(ctx, svc, source, target, text)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetWrongOutputLanguage(t *testing.T) {
+	for name, test := range map[string]struct {
+		target     string
+		translated string
+		expected   string
+	}{
+		"japanese":               {target: "ja", translated: "明日の会議は午後三時から始まります。資料を事前に確認してください。", expected: ""},
+		"chinese instead of ja":  {target: "ja", translated: "明天的会议从下午三点开始。请提前查看资料，并准备好你的问题和建议。", expected: "zh"},
+		"short kanji only ja":    {target: "ja", translated: "会議室予約", expected: ""},
+		"japanese instead of zh": {target: "zh-TW", translated: "明日の会議は午後三時から始まります。", expected: "ja"},
+		"korean instead of ja":   {target: "ja", translated: "내일 회의는 오후 세 시에 시작합니다.", expected: "ko"},
+		"korean":                 {target: "ko", translated: "내일 회의는 오후 세 시에 시작합니다.", expected: ""},
+		"not locally detectable": {target: "sw", translated: "明天的会议从下午三点开始。请提前查看资料，并准备好你的问题和建议。", expected: ""},
+		"no letters":             {target: "ja", translated: "12:30 :+1:", expected: ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, getWrongOutputLanguage(test.target, test.translated))
+		})
+	}
+}
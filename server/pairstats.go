@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const pairStatsKeyPrefix = "pairstats_"
+
+// PairUsage is the number of successful requests to a provider for a language pair and their
+// latency histogram
+type PairUsage struct {
+	Requests       int64   `json:"requests"`
+	LatencyBuckets []int64 `json:"latency_buckets"`
+}
+
+// PairStats is the usage of providers per language pair during a day, keyed by provider, source
+// and target language joined by colons
+type PairStats struct {
+	Day   string                `json:"day"`
+	Pairs map[string]*PairUsage `json:"pairs"`
+}
+
+func newPairStats(day string) *PairStats {
+	return &PairStats{
+		Day:   day,
+		Pairs: map[string]*PairUsage{},
+	}
+}
+
+func (s *PairStats) getUsage(pair string) *PairUsage {
+	usage, ok := s.Pairs[pair]
+	if !ok {
+		usage = &PairUsage{LatencyBuckets: make([]int64, len(latencyBucketBounds)+1)}
+		s.Pairs[pair] = usage
+	}
+
+	return usage
+}
+
+func (s *PairStats) add(other *PairStats) {
+	for pair, otherUsage := range other.Pairs {
+		usage := s.getUsage(pair)
+		usage.Requests += otherUsage.Requests
+		for i := range usage.LatencyBuckets {
+			if i < len(otherUsage.LatencyBuckets) {
+				usage.LatencyBuckets[i] += otherUsage.LatencyBuckets[i]
+			}
+		}
+	}
+}
+
+// LanguagePairStats is the latency and the ratings of the translations of a language pair by a
+// provider as reported by the API, the score being the share of good ratings
+type LanguagePairStats struct {
+	Provider       string  `json:"provider"`
+	SourceLanguage string  `json:"source_lang"`
+	TargetLanguage string  `json:"target_lang"`
+	Requests       int64   `json:"requests"`
+	LatencyP50Ms   int64   `json:"latency_p50_ms"`
+	LatencyP90Ms   int64   `json:"latency_p90_ms"`
+	LatencyP99Ms   int64   `json:"latency_p99_ms"`
+	GoodRatings    int64   `json:"good_ratings"`
+	BadRatings     int64   `json:"bad_ratings"`
+	Score          float64 `json:"score"`
+}
+
+func getPairKey(provider, source, target string) string {
+	return provider + ":" + source + ":" + target
+}
+
+func getPairStatsKey(day string) string {
+	return pairStatsKeyPrefix + day
+}
+
+// newLanguagePairStats reports the latency of the requests of language pairs along with the
+// ratings of their translations, the pairs with the most requests first. Pairs only rated, such
+// as after their requests expired, are reported as well.
+func newLanguagePairStats(stats *PairStats, feedbacks []*TranslationFeedback) []*LanguagePairStats {
+	reports := map[string]*LanguagePairStats{}
+	getReport := func(pair string) *LanguagePairStats {
+		report, ok := reports[pair]
+		if !ok {
+			parts := strings.SplitN(pair, ":", 3)
+			report = &LanguagePairStats{Provider: parts[0], SourceLanguage: parts[1], TargetLanguage: parts[2]}
+			reports[pair] = report
+		}
+		return report
+	}
+
+	for pair, usage := range stats.Pairs {
+		report := getReport(pair)
+		report.Requests = usage.Requests
+		report.LatencyP50Ms = getLatencyPercentile(usage.LatencyBuckets, usage.Requests, 50)
+		report.LatencyP90Ms = getLatencyPercentile(usage.LatencyBuckets, usage.Requests, 90)
+		report.LatencyP99Ms = getLatencyPercentile(usage.LatencyBuckets, usage.Requests, 99)
+	}
+
+	for _, feedback := range feedbacks {
+		report := getReport(getPairKey(feedback.Provider, feedback.SourceLanguage, feedback.TargetLanguage))
+		if feedback.Rating == feedbackRatingGood {
+			report.GoodRatings++
+		} else {
+			report.BadRatings++
+		}
+	}
+
+	list := make([]*LanguagePairStats, 0, len(reports))
+	for _, report := range reports {
+		if ratings := report.GoodRatings + report.BadRatings; ratings > 0 {
+			report.Score = float64(report.GoodRatings) / float64(ratings)
+		}
+		list = append(list, report)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Requests != list[j].Requests {
+			return list[i].Requests > list[j].Requests
+		}
+		return getPairKey(list[i].Provider, list[i].SourceLanguage, list[i].TargetLanguage) < getPairKey(list[j].Provider, list[j].SourceLanguage, list[j].TargetLanguage)
+	})
+
+	return list
+}
+
+// recordPairLatency counts a successful request to a provider for a language pair in memory, to
+// be saved along with the usage statistics, and in the metrics. Translations from auto are counted
+// with the language the provider detected.
+func (p *Plugin) recordPairLatency(provider, source, target string, latency time.Duration) {
+	p.metrics.observePairRequest(provider, source, target, latency)
+
+	day := time.Now().UTC().Format(statsDayFormat)
+
+	p.pairStatsLock.Lock()
+	defer p.pairStatsLock.Unlock()
+
+	if p.pairStats == nil {
+		p.pairStats = map[string]*PairStats{}
+	}
+
+	stats, ok := p.pairStats[day]
+	if !ok {
+		stats = newPairStats(day)
+		p.pairStats[day] = stats
+	}
+
+	usage := stats.getUsage(getPairKey(provider, source, target))
+	usage.Requests++
+	usage.LatencyBuckets[getLatencyBucket(latency)]++
+}
+
+// flushPairStats adds the language pairs counted in memory to the saved ones.
+func (p *Plugin) flushPairStats() {
+	p.pairStatsLock.Lock()
+	pending := p.pairStats
+	p.pairStats = nil
+	p.pairStatsLock.Unlock()
+
+	for day, stats := range pending {
+		if err := p.savePairStats(stats); err != nil {
+			p.API.LogError("Failed to save language pair statistics", "day", day, "err", err.Error())
+		}
+	}
+}
+
+// savePairStats adds statistics to the saved ones with a compare and set, as other cluster nodes
+// may be saving theirs at the same time.
+func (p *Plugin) savePairStats(stats *PairStats) error {
+	key := getPairStatsKey(stats.Day)
+	for attempt := 0; attempt < maxStatsSaveAttempts; attempt++ {
+		oldBytes, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return appErr
+		}
+
+		saved := newPairStats(stats.Day)
+		if oldBytes != nil {
+			if err := json.Unmarshal(oldBytes, saved); err != nil {
+				return errors.Wrap(err, "unable to unmarshal language pair statistics")
+			}
+		}
+		saved.add(stats)
+
+		newBytes, err := json.Marshal(saved)
+		if err != nil {
+			return errors.Wrap(err, "unable to marshal language pair statistics")
+		}
+
+		updated, appErr := p.API.KVCompareAndSet(key, oldBytes, newBytes)
+		if appErr != nil {
+			return appErr
+		}
+		if updated {
+			return nil
+		}
+	}
+
+	return errors.New("language pair statistics kept changing concurrently")
+}
+
+// getLanguagePairStats returns the latency and the ratings of the language pairs for the given
+// number of days up to today.
+func (p *Plugin) getLanguagePairStats(days int) ([]*LanguagePairStats, error) {
+	total, feedbacks, err := p.getPairStatsAndFeedbacks(days)
+	if err != nil {
+		return nil, err
+	}
+
+	return newLanguagePairStats(total, feedbacks), nil
+}
+
+// getPairStatsAndFeedbacks returns the usage of providers per language pair for the given number
+// of days up to today, along with the ratings of the translations made during these days.
+func (p *Plugin) getPairStatsAndFeedbacks(days int) (*PairStats, []*TranslationFeedback, error) {
+	total := newPairStats("")
+	today := time.Now().UTC()
+	for i := 0; i < days; i++ {
+		day := today.AddDate(0, 0, -i).Format(statsDayFormat)
+		statsBytes, appErr := p.API.KVGet(getPairStatsKey(day))
+		if appErr != nil {
+			return nil, nil, appErr
+		}
+		if statsBytes == nil {
+			continue
+		}
+
+		stats := newPairStats(day)
+		if err := json.Unmarshal(statsBytes, stats); err != nil {
+			return nil, nil, errors.Wrap(err, "unable to unmarshal language pair statistics")
+		}
+		total.add(stats)
+	}
+
+	since := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -(days - 1))
+	feedbacks, err := p.getFeedbacksSince(since.UnixNano() / int64(time.Millisecond))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return total, feedbacks, nil
+}
+
+// getFeedbacksSince returns the ratings of translations made since the given time in milliseconds.
+func (p *Plugin) getFeedbacksSince(since int64) ([]*TranslationFeedback, error) {
+	// Keys are collected first, so that the pages don't change while the ratings are read.
+	var keys []string
+	for page := 0; ; page++ {
+		pageKeys, appErr := p.API.KVList(page, keysPerPage)
+		if appErr != nil {
+			return nil, appErr
+		}
+
+		for _, key := range pageKeys {
+			if strings.HasPrefix(key, feedbackKeyPrefix) {
+				keys = append(keys, key)
+			}
+		}
+
+		if len(pageKeys) < keysPerPage {
+			break
+		}
+	}
+
+	var feedbacks []*TranslationFeedback
+	for _, key := range keys {
+		data, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return nil, appErr
+		}
+		if data == nil {
+			continue
+		}
+
+		var feedback *TranslationFeedback
+		if err := json.Unmarshal(data, &feedback); err != nil {
+			return nil, errors.Wrap(err, "unable to unmarshal feedback")
+		}
+		if feedback.CreateAt >= since {
+			feedbacks = append(feedbacks, feedback)
+		}
+	}
+
+	return feedbacks, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPairStatsAdd(t *testing.T) {
+	stats := newPairStats("2020-06-12")
+	stats.getUsage("aws:en:ja").Requests = 2
+	stats.getUsage("aws:en:ja").LatencyBuckets[1] = 2
+
+	other := newPairStats("2020-06-12")
+	other.getUsage("aws:en:ja").Requests = 1
+	other.getUsage("aws:en:ja").LatencyBuckets[3] = 1
+	other.getUsage("aws:ja:en").Requests = 4
+
+	stats.add(other)
+	assert.Equal(t, int64(3), stats.Pairs["aws:en:ja"].Requests)
+	assert.Equal(t, []int64{0, 2, 0, 1, 0, 0, 0, 0, 0}, stats.Pairs["aws:en:ja"].LatencyBuckets)
+	assert.Equal(t, int64(4), stats.Pairs["aws:ja:en"].Requests)
+}
+
+func TestNewLanguagePairStats(t *testing.T) {
+	stats := newPairStats("")
+	enJa := stats.getUsage(getPairKey(providerAWS, "en", "ja"))
+	enJa.Requests = 10
+	enJa.LatencyBuckets[1] = 9
+	enJa.LatencyBuckets[3] = 1
+	jaEn := stats.getUsage(getPairKey(providerAWS, "ja", "en"))
+	jaEn.Requests = 4
+	jaEn.LatencyBuckets[4] = 4
+
+	reports := newLanguagePairStats(stats, []*TranslationFeedback{
+		{Provider: providerAWS, SourceLanguage: "en", TargetLanguage: "ja", Rating: feedbackRatingGood},
+		{Provider: providerAWS, SourceLanguage: "en", TargetLanguage: "ja", Rating: feedbackRatingGood},
+		{Provider: providerAWS, SourceLanguage: "en", TargetLanguage: "ja", Rating: feedbackRatingGood},
+		{Provider: providerAWS, SourceLanguage: "ja", TargetLanguage: "en", Rating: feedbackRatingBad},
+		{Provider: providerAWS, SourceLanguage: "ja", TargetLanguage: "en", Rating: feedbackRatingGood},
+		{Provider: providerAWS, SourceLanguage: "fr", TargetLanguage: "en", Rating: feedbackRatingBad},
+	})
+
+	require.Len(t, reports, 3)
+	assert.Equal(t, &LanguagePairStats{
+		Provider:       providerAWS,
+		SourceLanguage: "en",
+		TargetLanguage: "ja",
+		Requests:       10,
+		LatencyP50Ms:   100,
+		LatencyP90Ms:   100,
+		LatencyP99Ms:   500,
+		GoodRatings:    3,
+		Score:          1,
+	}, reports[0])
+	assert.Equal(t, &LanguagePairStats{
+		Provider:       providerAWS,
+		SourceLanguage: "ja",
+		TargetLanguage: "en",
+		Requests:       4,
+		LatencyP50Ms:   1000,
+		LatencyP90Ms:   1000,
+		LatencyP99Ms:   1000,
+		GoodRatings:    1,
+		BadRatings:     1,
+		Score:          0.5,
+	}, reports[1])
+
+	// Pairs rated without requests in the period are reported as well.
+	assert.Equal(t, &LanguagePairStats{Provider: providerAWS, SourceLanguage: "fr", TargetLanguage: "en", BadRatings: 1}, reports[2])
+}
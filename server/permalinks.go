@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/translate"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// maxTranslatedPermalinks bounds how many linked posts are translated along with a translation.
+const maxTranslatedPermalinks = 3
+
+// getPermalinkPattern returns a pattern matching permalinks to posts of this server, the first
+// group holding the ID of the linked post, or nil when the site URL isn't configured.
+func (p *Plugin) getPermalinkPattern() *regexp.Regexp {
+	siteURL := p.API.GetConfig().ServiceSettings.SiteURL
+	if siteURL == nil || *siteURL == "" {
+		return nil
+	}
+
+	return regexp.MustCompile(regexp.QuoteMeta(strings.TrimSuffix(*siteURL, "/")) + `/[A-Za-z0-9_-]+/pl/([a-z0-9]{26})`)
+}
+
+// translatePermalinks translates the posts linked to by permalinks in the given posts, so that
+// the context they embed isn't lost in translation.
+func (p *Plugin) translatePermalinks(svc *translate.Translate, posts []*model.Post, userInfo *UserInfo) []*model.SlackAttachment {
+	pattern := p.getPermalinkPattern()
+	if pattern == nil {
+		return nil
+	}
+
+	var attachments []*model.SlackAttachment
+	seen := map[string]bool{}
+	for _, post := range posts {
+		for _, match := range pattern.FindAllStringSubmatch(post.Message, -1) {
+			if seen[match[1]] || len(seen) >= maxTranslatedPermalinks {
+				continue
+			}
+			seen[match[1]] = true
+
+			if attachment := p.translateLinkedPost(svc, post, match[1], userInfo); attachment != nil {
+				attachments = append(attachments, attachment)
+			}
+		}
+	}
+
+	return attachments
+}
+
+// translateLinkedPost translates a post linked to from another one. Only posts of the same
+// channel or of a public channel of the same team are translated, as the translation is shown
+// to everyone in the channel.
+func (p *Plugin) translateLinkedPost(svc *translate.Translate, post *model.Post, linkedPostID string, userInfo *UserInfo) *model.SlackAttachment {
+	linkedPost, appErr := p.API.GetPost(linkedPostID)
+	if appErr != nil || linkedPost.DeleteAt != 0 || isNoTranslatePost(linkedPost) || strings.TrimSpace(linkedPost.Message) == "" {
+		return nil
+	}
+
+	if p.checkAuthorConsent(linkedPost) != nil {
+		return nil
+	}
+
+	if linkedPost.ChannelId != post.ChannelId {
+		channel, appErr := p.API.GetChannel(post.ChannelId)
+		if appErr != nil {
+			return nil
+		}
+
+		linkedChannel, appErr := p.API.GetChannel(linkedPost.ChannelId)
+		if appErr != nil || linkedChannel.Type != model.CHANNEL_OPEN || linkedChannel.TeamId != channel.TeamId {
+			return nil
+		}
+	}
+
+	ctx := newAuditContext(p.newGlossaryContext(context.Background(), linkedPost.ChannelId), userInfo.UserID, linkedPost.Id, linkedPost.ChannelId)
+	detected := p.detectTextLanguage(ctx, linkedPost.Message)
+	if detected.Language == userInfo.TargetLanguage {
+		return nil
+	}
+
+	translated, err := p.translateTextWithContext(ctx, svc, resolveSourceLanguage(autoLanguage, detected), userInfo.TargetLanguage, linkedPost.Message)
+	if err != nil {
+		p.API.LogError("Failed to translate linked post", "post_id", linkedPost.Id, "err", err.Error())
+		return nil
+	}
+
+	attachment := &model.SlackAttachment{
+		Title: "Linked message",
+		Text:  translated,
+	}
+	if author, appErr := p.API.GetUser(linkedPost.UserId); appErr == nil {
+		attachment.AuthorName = author.Username
+	}
+
+	return attachment
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// getTranslationRoles returns the roles allowed to turn autotranslation and the translation of
+// channels on, or nil when every user is.
+func (c *configuration) getTranslationRoles() map[string]bool {
+	var roles map[string]bool
+	for _, role := range strings.Split(c.TranslationRoles, ",") {
+		if role = strings.ToLower(strings.TrimSpace(role)); role != "" {
+			if roles == nil {
+				roles = map[string]bool{}
+			}
+			roles[role] = true
+		}
+	}
+
+	return roles
+}
+
+// hasAnyRole reports whether any of the space separated roles of a user, team member or channel
+// member is one of the given roles.
+func hasAnyRole(memberRoles string, roles map[string]bool) bool {
+	for _, role := range strings.Fields(memberRoles) {
+		if roles[role] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// canTurnTranslationOn reports whether a user may turn autotranslation or the translation of a
+// channel on, holding one of the translation roles set by admins either as system role, or as
+// team or channel role in the channel when one is given. System admins always may. Users whose
+// roles can't be read may not, as they may not have any.
+func (p *Plugin) canTurnTranslationOn(userID, channelID string) bool {
+	roles := p.getConfiguration().getTranslationRoles()
+	if roles == nil {
+		return true
+	}
+
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		p.API.LogWarn("Failed to get user to check the translation roles", "user_id", userID, "err", appErr.Error())
+		return false
+	}
+	if user.IsInRole(model.SYSTEM_ADMIN_ROLE_ID) || hasAnyRole(user.Roles, roles) {
+		return true
+	}
+
+	if channelID == "" {
+		return false
+	}
+
+	if channelMember, appErr := p.API.GetChannelMember(channelID, userID); appErr == nil && hasAnyRole(channelMember.Roles, roles) {
+		return true
+	}
+
+	channel, appErr := p.API.GetChannel(channelID)
+	if appErr != nil || channel.TeamId == "" {
+		return false
+	}
+
+	teamMember, appErr := p.API.GetTeamMember(channel.TeamId, userID)
+	return appErr == nil && hasAnyRole(teamMember.Roles, roles)
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTranslationRoles(t *testing.T) {
+	assert.Nil(t, (&configuration{}).getTranslationRoles())
+	assert.Nil(t, (&configuration{TranslationRoles: " , "}).getTranslationRoles())
+	assert.Equal(t, map[string]bool{"team_admin": true, "translators": true}, (&configuration{TranslationRoles: "team_admin, Translators,"}).getTranslationRoles())
+}
+
+func TestHasAnyRole(t *testing.T) {
+	roles := map[string]bool{"team_admin": true, "translators": true}
+
+	assert.True(t, hasAnyRole("team_user team_admin", roles))
+	assert.True(t, hasAnyRole("system_user translators", roles))
+	assert.False(t, hasAnyRole("channel_user channel_admin", roles))
+	assert.False(t, hasAnyRole("", roles))
+}
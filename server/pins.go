@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// maxPinLookupPosts bounds how many posts following a pinned post are searched for its translations.
+const maxPinLookupPosts = 100
+
+// pinTranslations pins the translations of a post getting pinned along with it, translating the
+// post first if needed, as pinned posts are the content foreign-language members need the most.
+func (p *Plugin) pinTranslations(newPost, oldPost *model.Post) {
+	if !newPost.IsPinned || oldPost.IsPinned {
+		return
+	}
+
+	if !p.getConfiguration().TranslateMessages || newPost.UserId == p.botUserID || isNoTranslatePost(newPost) || newPost.Type != model.POST_DEFAULT {
+		return
+	}
+
+	// Translations stored in or merged into the post are pinned with it already.
+	if !p.deliversTranslationPosts(newPost.ChannelId) {
+		return
+	}
+
+	translationPosts := p.findTranslationPosts(newPost)
+	if len(translationPosts) == 0 {
+		translationPosts = p.createPinnedPostTranslations(newPost)
+	}
+
+	for _, translationPost := range translationPosts {
+		if translationPost.IsPinned {
+			continue
+		}
+
+		translationPost.IsPinned = true
+		if _, appErr := p.API.UpdatePost(translationPost); appErr != nil {
+			p.API.LogError("Failed to pin translation post", "post_id", translationPost.Id, "err", appErr.Error())
+		}
+	}
+}
+
+// findTranslationPosts looks up the translation posts of a post, either in its thread or among
+// the posts following it in its channel.
+func (p *Plugin) findTranslationPosts(post *model.Post) []*model.Post {
+	var postList *model.PostList
+	var appErr *model.AppError
+	if channelInfo, _ := p.getChannelInfo(post.ChannelId); post.RootId != "" || (channelInfo != nil && channelInfo.getDeliveryMode() == deliveryModeThread) {
+		postList, appErr = p.API.GetPostThread(getThreadRootID(post))
+	} else {
+		postList, appErr = p.API.GetPostsAfter(post.ChannelId, post.Id, 0, maxPinLookupPosts)
+	}
+
+	if appErr != nil {
+		p.API.LogError("Failed to look up translation posts", "post_id", post.Id, "err", appErr.Error())
+		return nil
+	}
+
+	var translationPosts []*model.Post
+	for _, candidate := range postList.ToSlice() {
+		if candidate.UserId == p.botUserID && candidate.GetProp(translationSourcePostIDProp) == post.Id {
+			translationPosts = append(translationPosts, candidate)
+		}
+	}
+
+	return translationPosts
+}
+
+// createPinnedPostTranslations translates a pinned post into the target language of its author,
+// as it would have been when posted, unless the author doesn't use autotranslation. Posts of bots
+// and webhooks have no author to translate them for, and are pinned alone.
+func (p *Plugin) createPinnedPostTranslations(post *model.Post) []*model.Post {
+	if isBotPost(post) || !p.isProviderAllowed(post.ChannelId, providerAWS) {
+		return nil
+	}
+
+	userInfo, apiErr := p.getUserInfo(post.UserId)
+	if apiErr != nil || !userInfo.Activated {
+		return nil
+	}
+
+	svc, err := p.getTranslateService()
+	if err != nil {
+		p.API.LogError("Failed to get translate service", "err", err.Error())
+		return nil
+	}
+
+	rootID := post.RootId
+	if channelInfo, _ := p.getChannelInfo(post.ChannelId); channelInfo != nil && channelInfo.getDeliveryMode() == deliveryModeThread {
+		rootID = getThreadRootID(post)
+	}
+
+	ctx := newRequestContext(context.Background(), model.NewId())
+	content, err := p.translatePostContent(ctx, svc, post, userInfo, true)
+	if err != nil {
+		p.API.LogError("Failed to translate pinned post", "request_id", getRequestID(ctx), "post_id", post.Id, "err", err.Error())
+		return nil
+	}
+
+	if content.isEmpty() {
+		return nil
+	}
+
+	translationPost := p.createTranslationPost(post, userInfo.withSourceLanguage(content.sourceLanguage), rootID, content.message, content.attachments)
+	if translationPost == nil {
+		return nil
+	}
+
+	return []*model.Post{translationPost}
+}
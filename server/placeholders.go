@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	placeholderPattern = regexp.MustCompile(`\{\{\s*(\d+)\s*\}\}`)
+
+	// mentionPattern matches @mentions and ~channel references, the second group holding the
+	// reference itself. Email addresses are not matched as their @ follows a word character.
+	mentionPattern = regexp.MustCompile(`(^|[^\w@~])([@~][A-Za-z0-9][A-Za-z0-9._-]*)`)
+
+	// emojiShortcodePattern matches :emoji_name: shortcodes of system and custom emojis.
+	emojiShortcodePattern = regexp.MustCompile(`:[A-Za-z0-9_+-]+:`)
+
+	// emojiPattern matches runs of unicode emojis, along with the joiners, variation selectors and
+	// tags combining them into a single emoji.
+	emojiPattern = regexp.MustCompile(
+		`[\x{1F000}-\x{1FAFF}\x{2300}-\x{23FF}\x{2600}-\x{27BF}\x{2B00}-\x{2BFF}]` +
+			`[\x{1F000}-\x{1FAFF}\x{2300}-\x{23FF}\x{2600}-\x{27BF}\x{2B00}-\x{2BFF}\x{200D}\x{20E3}\x{FE0F}\x{E0020}-\x{E007F}]*`,
+	)
+)
+
+// placeholders masks parts of a text which must survive translation untouched, to be restored
+// in the translated text afterwards.
+type placeholders struct {
+	values []string
+}
+
+func (ph *placeholders) add(value string) string {
+	ph.values = append(ph.values, value)
+	return fmt.Sprintf("{{%d}}", len(ph.values)-1)
+}
+
+// maskLiteralPlaceholders replaces the parts of a text written like placeholders, such as {{0}} in
+// a template, with placeholders of their own, so that they aren't restored as masked values. It
+// must come before any other mask.
+func (ph *placeholders) maskLiteralPlaceholders(text string) string {
+	return placeholderPattern.ReplaceAllStringFunc(text, ph.add)
+}
+
+// maskMentions replaces @mentions and ~channel references with placeholders.
+func (ph *placeholders) maskMentions(text string) string {
+	return mentionPattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := mentionPattern.FindStringSubmatch(match)
+		mention := strings.TrimRight(groups[2], "._-")
+		return groups[1] + ph.add(mention) + strings.TrimPrefix(groups[2], mention)
+	})
+}
+
+// maskEmojis replaces emoji shortcodes and unicode emojis with placeholders, as providers tend to
+// translate the words of shortcodes or mangle emoji sequences.
+func (ph *placeholders) maskEmojis(text string) string {
+	var masked strings.Builder
+	last := 0
+	for _, loc := range emojiShortcodePattern.FindAllStringIndex(text, -1) {
+		// Colons following a letter or digit are rather part of times like 10:30:45.
+		if loc[0] > 0 {
+			if r, _ := utf8.DecodeLastRuneInString(text[:loc[0]]); unicode.IsLetter(r) || unicode.IsDigit(r) {
+				continue
+			}
+		}
+
+		masked.WriteString(text[last:loc[0]])
+		masked.WriteString(ph.add(text[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	masked.WriteString(text[last:])
+
+	return emojiPattern.ReplaceAllStringFunc(masked.String(), ph.add)
+}
+
+// maskPatterns replaces the matches of patterns set by admins, such as ticket IDs or file paths,
+// with placeholders.
+func (ph *placeholders) maskPatterns(patterns []*regexp.Regexp, text string) string {
+	for _, pattern := range patterns {
+		text = ph.maskMatches(pattern, nil, text)
+	}
+
+	return text
+}
+
+// maskMatches replaces the matches of pattern accepted by valid, if given, with placeholders.
+// Matches overlapping the placeholders of values masked already are skipped.
+func (ph *placeholders) maskMatches(pattern *regexp.Regexp, valid func(match string) bool, text string) string {
+	masked := placeholderPattern.FindAllStringIndex(text, -1)
+
+	var replaced strings.Builder
+	last := 0
+	for _, loc := range pattern.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] || overlapsAny(loc, masked) || (valid != nil && !valid(text[loc[0]:loc[1]])) {
+			continue
+		}
+
+		replaced.WriteString(text[last:loc[0]])
+		replaced.WriteString(ph.add(text[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	replaced.WriteString(text[last:])
+
+	return replaced.String()
+}
+
+func overlapsAny(loc []int, locs [][]int) bool {
+	for _, other := range locs {
+		if loc[0] < other[1] && other[0] < loc[1] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// restore puts the masked values back into the translated text. Values whose placeholder got
+// lost in translation are appended so that no mention is ever dropped.
+func (ph *placeholders) restore(text string) string {
+	restored := make([]bool, len(ph.values))
+	text = placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		i, err := strconv.Atoi(placeholderPattern.FindStringSubmatch(match)[1])
+		if err != nil || i >= len(ph.values) {
+			return match
+		}
+
+		restored[i] = true
+		return ph.values[i]
+	})
+
+	var missing []string
+	for i, value := range ph.values {
+		if !restored[i] {
+			missing = append(missing, value)
+		}
+	}
+
+	if len(missing) > 0 {
+		text += " " + strings.Join(missing, " ")
+	}
+
+	return text
+}
+
+// silenceMentions keeps @mentions of a text from triggering notifications by formatting them as
+// inline code, which Mattermost doesn't look for mentions in, while leaving them readable.
+func silenceMentions(text string) string {
+	return mentionPattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := mentionPattern.FindStringSubmatch(match)
+		if !strings.HasPrefix(groups[2], "@") {
+			return match
+		}
+
+		mention := strings.TrimRight(groups[2], "._-")
+		return groups[1] + "`" + mention + "`" + strings.TrimPrefix(groups[2], mention)
+	})
+}
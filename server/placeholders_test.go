@@ -0,0 +1,118 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaceholdersMask(t *testing.T) {
+	for name, tc := range map[string]struct {
+		mask     func(ph *placeholders, text string) string
+		text     string
+		expected string
+		values   []string
+	}{
+		"mentions": {
+			mask:     (*placeholders).maskMentions,
+			text:     "Ask @john.doe. in ~town-square",
+			expected: "Ask {{0}}. in {{1}}",
+			values:   []string{"@john.doe", "~town-square"},
+		},
+		"email addresses are no mentions": {
+			mask:     (*placeholders).maskMentions,
+			text:     "Write to john@example.com",
+			expected: "Write to john@example.com",
+		},
+		"emojis": {
+			mask:     (*placeholders).maskEmojis,
+			text:     "Great :thumbsup: 🎉",
+			expected: "Great {{0}} {{1}}",
+			values:   []string{":thumbsup:", "🎉"},
+		},
+		"times are no emojis": {
+			mask:     (*placeholders).maskEmojis,
+			text:     "See you at 10:30:45",
+			expected: "See you at 10:30:45",
+		},
+		"patterns": {
+			mask: func(ph *placeholders, text string) string {
+				return ph.maskPatterns([]*regexp.Regexp{regexp.MustCompile(`[A-Z]+-[0-9]+`), regexp.MustCompile(`[0-9]+(\.[0-9]+){3}`)}, text)
+			},
+			text:     "PROJ-1234 fails on 10.0.0.1 and PROJ-7",
+			expected: "{{0}} fails on {{2}} and {{1}}",
+			values:   []string{"PROJ-1234", "PROJ-7", "10.0.0.1"},
+		},
+		"patterns skip placeholders": {
+			mask: func(ph *placeholders, text string) string {
+				return ph.maskPatterns([]*regexp.Regexp{regexp.MustCompile(`[0-9]+`)}, ph.maskMentions(text))
+			},
+			text:     "Ask @john about order 42",
+			expected: "Ask {{0}} about order {{1}}",
+			values:   []string{"@john", "42"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ph := &placeholders{}
+			assert.Equal(t, tc.expected, tc.mask(ph, tc.text))
+			assert.Equal(t, tc.values, ph.values)
+		})
+	}
+}
+
+func TestPlaceholdersRestore(t *testing.T) {
+	ph := &placeholders{}
+	masked := ph.maskMentions("@alice ask @bob")
+	assert.Equal(t, "{{0}} ask {{1}}", masked)
+
+	for name, tc := range map[string]struct {
+		translated string
+		expected   string
+	}{
+		"all restored": {
+			translated: "{{0}} demande à {{1}}",
+			expected:   "@alice demande à @bob",
+		},
+		"spacing changed by the provider": {
+			translated: "{{ 0 }} demande à {{1 }}",
+			expected:   "@alice demande à @bob",
+		},
+		"lost mention appended": {
+			translated: "demande à {{1}}",
+			expected:   "demande à @bob @alice",
+		},
+		"unknown placeholder kept": {
+			translated: "{{0}} demande à {{1}} {{7}}",
+			expected:   "@alice demande à @bob {{7}}",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ph.restore(tc.translated))
+		})
+	}
+}
+
+func TestPlaceholdersRestoreLiteralPlaceholders(t *testing.T) {
+	ph := &placeholders{}
+	masked := ph.maskMentions(ph.maskLiteralPlaceholders("Set {{0}} and {{ 1 }} for @alice"))
+	assert.Equal(t, "Set {{0}} and {{1}} for {{2}}", masked)
+
+	assert.Equal(t, "{{0}} et {{ 1 }} pour @alice", ph.restore("{{0}} et {{1}} pour {{2}}"))
+}
+
+func TestSilenceMentions(t *testing.T) {
+	assert.Equal(t, "Ask `@john.doe`. in ~town-square", silenceMentions("Ask @john.doe. in ~town-square"))
+	assert.Equal(t, "Write to john@example.com", silenceMentions("Write to john@example.com"))
+}
+
+func TestCompileProtectedPatterns(t *testing.T) {
+	patterns := compileProtectedPatterns("[A-Z]+-[0-9]+\n\n  v[0-9]+  \n[unclosed\n.*")
+
+	var exprs []string
+	for _, pattern := range patterns {
+		exprs = append(exprs, pattern.String())
+	}
+	assert.Equal(t, []string{"[A-Z]+-[0-9]+", "v[0-9]+"}, exprs, "invalid patterns and ones matching everything are skipped")
+	assert.Nil(t, compileProtectedPatterns(""))
+}
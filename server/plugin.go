@@ -5,15 +5,16 @@ import (
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/comprehend"
+	"github.com/aws/aws-sdk-go/service/translate"
+	"github.com/gorilla/mux"
 
 	"github.com/mattermost/mattermost-server/v5/model"
 	"github.com/mattermost/mattermost-server/v5/plugin"
 )
 
-const (
-	apiErrorNoRecordFound = "no_record_found"
-)
-
 // Plugin is a collection of fields for plugin
 type Plugin struct {
 	plugin.MattermostPlugin
@@ -24,6 +25,153 @@ type Plugin struct {
 	// configuration is the active plugin configuration. Consult getConfiguration and
 	// setConfiguration for usage.
 	configuration *configuration
+
+	// router routes the requests of the HTTP API.
+	router *mux.Router
+
+	// botUserID is the user ID of the bot posting translations.
+	botUserID string
+
+	// channelMembersLock synchronizes access to the channel members cache.
+	channelMembersLock sync.Mutex
+
+	// channelMembersCache holds the activated members of channels, keyed by channel ID.
+	channelMembersCache map[string]channelMembersCacheEntry
+
+	// vocabulariesLock synchronizes access to the vocabularies.
+	vocabulariesLock sync.Mutex
+
+	// vocabularies holds the names of teams kept as is when translating, keyed by team ID.
+	vocabularies map[string]vocabularyCacheEntry
+
+	// burstsLock synchronizes access to the bursts.
+	burstsLock sync.Mutex
+
+	// bursts holds the posts waiting to be translated together, keyed by author, channel and thread.
+	bursts map[string]*postBurst
+
+	// statsLock synchronizes access to the stats.
+	statsLock sync.Mutex
+
+	// stats holds the usage statistics collected since they were last saved, keyed by KV key.
+	stats map[string]*UsageStats
+
+	// languageStatsLock synchronizes access to the languageStats.
+	languageStatsLock sync.Mutex
+
+	// languageStats holds the languages detected in channels since they were last saved, keyed
+	// by day.
+	languageStats map[string]*LanguageStats
+
+	// pairStatsLock synchronizes access to the pairStats.
+	pairStatsLock sync.Mutex
+
+	// pairStats holds the requests to providers per language pair since they were last saved,
+	// keyed by day.
+	pairStats map[string]*PairStats
+
+	// auditLock synchronizes access to the auditEntries.
+	auditLock sync.Mutex
+
+	// auditEntries holds the audit entries recorded since they were last saved.
+	auditEntries []*AuditEntry
+
+	// spendLock synchronizes access to the spend.
+	spendLock sync.Mutex
+
+	// spend holds the characters translated per channel since they were last saved, keyed by
+	// month.
+	spend map[string]*SpendStats
+
+	// volumeLock synchronizes access to the volume.
+	volumeLock sync.Mutex
+
+	// volume holds the translations per channel and user since they were last saved, keyed by
+	// day.
+	volume map[string]*VolumeStats
+
+	// statsStop stops saving the usage statistics periodically.
+	statsStop chan struct{}
+
+	// retentionStop stops purging the translation data older than the data retention.
+	retentionStop chan struct{}
+
+	// spendReportStop stops sending the monthly spend reports.
+	spendReportStop chan struct{}
+
+	// providerHealthStop stops probing the translation provider in the background.
+	providerHealthStop chan struct{}
+
+	// telemetryStop stops sending the telemetry periodically.
+	telemetryStop chan struct{}
+
+	// failureAlertStop stops checking the failure rate of the translation provider.
+	failureAlertStop chan struct{}
+
+	// tracingStop stops exporting the spans of translations periodically.
+	tracingStop chan struct{}
+
+	// qualityRoutingStop stops learning the providers preferred for language pairs periodically.
+	qualityRoutingStop chan struct{}
+
+	// learnedRoutesLock synchronizes access to the learnedRoutes.
+	learnedRoutesLock sync.Mutex
+
+	// learnedRoutes holds the providers preferred for language pairs from the ratings of their
+	// translations, keyed by source and target language, when quality routing is on.
+	learnedRoutes map[string]string
+
+	// rateLimitLock synchronizes access to the rate limit counts.
+	rateLimitLock sync.Mutex
+
+	// rateLimitStart is when the current rate limit window started.
+	rateLimitStart time.Time
+
+	// rateLimitCounts holds the requests of users and client addresses in the current rate limit
+	// window, keyed by client.
+	rateLimitCounts map[string]int
+
+	// metrics counts the requests to translation providers for Prometheus.
+	metrics translationMetrics
+
+	// failures counts the requests to translation providers and their errors in the current
+	// window of the failure alerts.
+	failures failureCounter
+
+	// recentErrors keeps the last errors of translation providers for the diagnostics.
+	recentErrors errorLog
+
+	// telemetry counts the use of features since it was last sent, when admins opted in.
+	telemetry telemetryCounter
+
+	// spans holds the spans of translations ended since they were last exported, when tracing is
+	// on.
+	spans spanRecorder
+
+	// consentPromptsLock synchronizes access to the consent prompts.
+	consentPromptsLock sync.Mutex
+
+	// consentPrompts holds when users posting without their consent were last reminded of it.
+	consentPrompts map[string]time.Time
+
+	// providerProbeLock synchronizes access to the provider probe.
+	providerProbeLock sync.Mutex
+
+	// providerProbe is the result of the last check of the translation provider.
+	providerProbe *ProviderProbe
+
+	// translateServiceLock synchronizes access to the AWS clients.
+	translateServiceLock sync.RWMutex
+
+	// translateService is the Amazon Translate client built from the last valid configuration.
+	translateService *translate.Translate
+
+	// comprehendService is the Amazon Comprehend client built from the last valid configuration.
+	comprehendService *comprehend.Comprehend
+
+	// offlineTranslateService is the client of the offline provider built from the last valid
+	// configuration, nil without an offline endpoint.
+	offlineTranslateService *translate.Translate
 }
 
 // TranslatedMessage is a collection of fields for translated message
@@ -35,14 +183,23 @@ type TranslatedMessage struct {
 	TargetLanguage string `json:"target_lang"`
 	TranslatedText string `json:"translated_text"`
 	UpdateAt       int64  `json:"update_at"`
+	Provider       string `json:"provider,omitempty"`
+
+	// DetectedLanguage is the language the message was translated from when the source is auto.
+	DetectedLanguage string `json:"detected_lang,omitempty"`
 }
 
 // UserInfo is a collection of fields for user info
 type UserInfo struct {
-	UserID         string `json:"user_id"`
-	Activated      bool   `json:"activated"`
-	SourceLanguage string `json:"source_language"`
-	TargetLanguage string `json:"target_language"`
+	UserID         string   `json:"user_id"`
+	Activated      bool     `json:"activated"`
+	SourceLanguage string   `json:"source_language"`
+	TargetLanguage string   `json:"target_language"`
+	TranslatedBots []string `json:"translated_bots,omitempty"`
+
+	// TranslatePrivateChannels tells whether the messages of the user in private channels, direct
+	// messages and group messages are translated, when admins allow it.
+	TranslatePrivateChannels bool `json:"translate_private_channels,omitempty"`
 }
 
 // NewUserInfo returns new user info
@@ -91,10 +248,12 @@ func (u *UserInfo) IsValid() error {
 func (p *Plugin) getUserInfo(userID string) (*UserInfo, *APIErrorResponse) {
 	var userInfo UserInfo
 
-	if infoBytes, err := p.API.KVGet(userID); err != nil || infoBytes == nil {
-		return nil, &APIErrorResponse{ID: apiErrorNoRecordFound, Message: "No record found.", StatusCode: http.StatusBadRequest}
+	if infoBytes, appErr := p.API.KVGet(userID); appErr != nil {
+		return nil, &APIErrorResponse{ID: apiErrorInternal, Message: "Unable to get user info.", StatusCode: http.StatusInternalServerError}
+	} else if infoBytes == nil {
+		return nil, &APIErrorResponse{ID: apiErrorNoRecordFound, Message: "No record found.", StatusCode: http.StatusNotFound}
 	} else if err := json.Unmarshal(infoBytes, &userInfo); err != nil {
-		return nil, &APIErrorResponse{ID: "unable_to_unmarshal", Message: "Unable to unmarshal json.", StatusCode: http.StatusBadRequest}
+		return nil, &APIErrorResponse{ID: apiErrorUnableToUnmarshal, Message: "Unable to unmarshal json.", StatusCode: http.StatusBadRequest}
 	}
 
 	return &userInfo, nil
@@ -102,16 +261,20 @@ func (p *Plugin) getUserInfo(userID string) (*UserInfo, *APIErrorResponse) {
 
 func (p *Plugin) setUserInfo(userInfo *UserInfo) *APIErrorResponse {
 	if err := userInfo.IsValid(); err != nil {
-		return &APIErrorResponse{ID: "invalid_user_info", Message: err.Error(), StatusCode: http.StatusBadRequest}
+		return &APIErrorResponse{ID: apiErrorInvalidUserInfo, Message: err.Error(), StatusCode: http.StatusBadRequest}
 	}
 
 	jsonUserInfo, err := json.Marshal(userInfo)
 	if err != nil {
-		return &APIErrorResponse{ID: "unable_to_unmarshal", Message: "Unable to marshal json.", StatusCode: http.StatusBadRequest}
+		return &APIErrorResponse{ID: apiErrorUnableToUnmarshal, Message: "Unable to marshal json.", StatusCode: http.StatusBadRequest}
 	}
 
 	if err := p.API.KVSet(userInfo.UserID, jsonUserInfo); err != nil {
-		return &APIErrorResponse{ID: "unable_to_save", Message: "Unable to save user info.", StatusCode: http.StatusBadRequest}
+		return &APIErrorResponse{ID: apiErrorUnableToSave, Message: "Unable to save user info.", StatusCode: http.StatusBadRequest}
+	}
+
+	if err := p.updateActivatedUsers(userInfo.UserID, userInfo.Activated); err != nil {
+		p.API.LogError("Failed to update activated users", "user_id", userInfo.UserID, "err", err.Error())
 	}
 
 	p.emitUserInfoChange(userInfo)
@@ -119,6 +282,14 @@ func (p *Plugin) setUserInfo(userInfo *UserInfo) *APIErrorResponse {
 	return nil
 }
 
+// withSourceLanguage returns a copy of the user info translating from the given language, such as
+// the language detected in a post when the source language of the user is auto.
+func (u *UserInfo) withSourceLanguage(source string) *UserInfo {
+	userInfo := *u
+	userInfo.SourceLanguage = source
+	return &userInfo
+}
+
 func (u *UserInfo) getActivatedString() string {
 	activated := "off"
 	if u.Activated {
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	privateChannelProvidersAny   = "any"
+	privateChannelProvidersLocal = "local"
+	privateChannelProvidersOff   = "off"
+)
+
+// errProviderNotAllowed reports a translation refused as the provider may not receive the
+// messages of the channel it is made for.
+var errProviderNotAllowed = errors.New("the translation provider is not allowed in private channels and direct messages")
+
+// isLocalProvider reports whether a provider keeps messages on premises, which the offline provider
+// always does, Amazon Translate being local only when admins designate the AWS Endpoint it is
+// reached at as such. Amazon Comprehend, reached at its regional endpoint, never is.
+func (c *configuration) isLocalProvider(provider string) bool {
+	if provider == providerOffline {
+		return true
+	}
+
+	return provider == providerAWS && c.AWSEndpoint != "" && c.AWSEndpointLocal
+}
+
+// allowsProvider reports whether a provider may receive the messages of a channel of the given
+// type, public channels allowing every provider.
+func (c *configuration) allowsProvider(channelType, provider string) bool {
+	if channelType == model.CHANNEL_OPEN {
+		return true
+	}
+
+	switch c.getPrivateChannelProviders() {
+	case privateChannelProvidersOff:
+		return false
+	case privateChannelProvidersLocal:
+		return c.isLocalProvider(provider)
+	}
+
+	return true
+}
+
+// isProviderAllowed reports whether a provider may receive the messages of a channel, a missing
+// channel being public. Channels which can't be read are not allowed, as they may be private.
+func (p *Plugin) isProviderAllowed(channelID, provider string) bool {
+	config := p.getConfiguration()
+	if channelID == "" || config.getPrivateChannelProviders() == privateChannelProvidersAny {
+		return true
+	}
+
+	channel, appErr := p.API.GetChannel(channelID)
+	if appErr != nil {
+		p.API.LogWarn("Failed to get channel to check the provider policy", "channel_id", channelID, "err", appErr.Error())
+		return false
+	}
+
+	return config.allowsProvider(channel.Type, provider)
+}
+
+// isTranslatedForAuthor reports whether the messages of a user in a channel are translated
+// automatically, the ones of private channels, direct messages and group messages only being
+// translated when the user turned it on for them. Channels which can't be read are not, as they
+// may be private.
+func (p *Plugin) isTranslatedForAuthor(channelID string, userInfo *UserInfo) bool {
+	if userInfo.TranslatePrivateChannels {
+		return true
+	}
+
+	channel, appErr := p.API.GetChannel(channelID)
+	if appErr != nil {
+		p.API.LogWarn("Failed to get channel to check its translation", "channel_id", channelID, "err", appErr.Error())
+		return false
+	}
+
+	return channel.Type == model.CHANNEL_OPEN
+}
+
+// checkProviderPolicy returns errProviderNotAllowed when a provider may not receive the messages
+// of the channel a translation is made for. Translations must tell what they are made for, so the
+// ones whose context doesn't aren't allowed either.
+func (p *Plugin) checkProviderPolicy(ctx context.Context, provider string) error {
+	subject := getAuditSubject(ctx)
+	if subject != nil && p.isProviderAllowed(subject.channelID, provider) {
+		return nil
+	}
+
+	return errProviderNotAllowed
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+)
+
+func TestAllowsProvider(t *testing.T) {
+	endpoint := "https://translate.gateway.internal"
+
+	for name, test := range map[string]struct {
+		config   *configuration
+		private  bool
+		expected bool
+	}{
+		"no policy in public channel":         {config: &configuration{}, expected: true},
+		"no policy in private channel":        {config: &configuration{}, private: true, expected: true},
+		"any provider":                        {config: &configuration{PrivateChannelProviders: privateChannelProvidersAny}, private: true, expected: true},
+		"no translation in public channel":    {config: &configuration{PrivateChannelProviders: privateChannelProvidersOff}, expected: true},
+		"no translation in private channel":   {config: &configuration{PrivateChannelProviders: privateChannelProvidersOff, AWSEndpoint: endpoint, AWSEndpointLocal: true}, private: true, expected: false},
+		"local only in public channel":        {config: &configuration{PrivateChannelProviders: privateChannelProvidersLocal}, expected: true},
+		"local only with regional endpoint":   {config: &configuration{PrivateChannelProviders: privateChannelProvidersLocal}, private: true, expected: false},
+		"local only with endpoint not local":  {config: &configuration{PrivateChannelProviders: privateChannelProvidersLocal, AWSEndpoint: endpoint}, private: true, expected: false},
+		"local only with local endpoint":      {config: &configuration{PrivateChannelProviders: privateChannelProvidersLocal, AWSEndpoint: endpoint, AWSEndpointLocal: true}, private: true, expected: true},
+		"local flag without endpoint ignored": {config: &configuration{PrivateChannelProviders: privateChannelProvidersLocal, AWSEndpointLocal: true}, private: true, expected: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			channelTypes := []string{model.CHANNEL_OPEN}
+			if test.private {
+				channelTypes = []string{model.CHANNEL_PRIVATE, model.CHANNEL_DIRECT, model.CHANNEL_GROUP}
+			}
+
+			for _, channelType := range channelTypes {
+				assert.Equal(t, test.expected, test.config.allowsProvider(channelType, providerAWS), channelType)
+			}
+		})
+	}
+}
+
+func TestAllowsOfflineProvider(t *testing.T) {
+	config := &configuration{PrivateChannelProviders: privateChannelProvidersLocal, OfflineEndpoint: "https://libretranslate.internal"}
+
+	assert.True(t, config.allowsProvider(model.CHANNEL_PRIVATE, providerOffline))
+	assert.False(t, config.allowsProvider(model.CHANNEL_PRIVATE, providerAWS))
+}
+
+func TestAllowsDetectionProvider(t *testing.T) {
+	config := &configuration{PrivateChannelProviders: privateChannelProvidersLocal, AWSEndpoint: "https://translate.gateway.internal", AWSEndpointLocal: true, LanguageDetector: detectorComprehend}
+
+	assert.False(t, config.allowsProvider(model.CHANNEL_PRIVATE, config.getDetectionProvider()))
+
+	config.LanguageDetector = detectorProvider
+	assert.True(t, config.allowsProvider(model.CHANNEL_PRIVATE, config.getDetectionProvider()))
+}
+
+func TestDirectMessagesWithDefaultConfiguration(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("GetChannel", "channel1").Return(&model.Channel{Id: "channel1", Type: model.CHANNEL_DIRECT}, nil)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	p.setConfiguration(&configuration{})
+
+	// Translations on demand of direct messages go to the provider.
+	ctx := newAuditContext(context.Background(), "user1", "post1", "channel1")
+	assert.NoError(t, p.checkProviderPolicy(ctx, providerAWS))
+
+	// Direct messages are only translated automatically for authors who turned it on.
+	assert.False(t, p.isTranslatedForAuthor("channel1", &UserInfo{UserID: "user1"}))
+	assert.True(t, p.isTranslatedForAuthor("channel1", &UserInfo{UserID: "user1", TranslatePrivateChannels: true}))
+}
+
+func TestCheckProviderPolicyWithoutSubject(t *testing.T) {
+	p := &Plugin{}
+	p.setConfiguration(&configuration{})
+
+	assert.Equal(t, errProviderNotAllowed, p.checkProviderPolicy(context.Background(), providerAWS))
+	assert.NoError(t, p.checkProviderPolicy(newAuditContext(context.Background(), "user1", "", ""), providerAWS))
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	translatedFirstPartText = "_Only the first part of this message has been translated._"
+	translatedRestText      = "_The rest of this message is translated in the thread._"
+)
+
+// shouldTranslateProgressively reports whether a post is long enough to only get its first part
+// translated right away, which needs translations to be delivered as separate posts. Its length
+// is measured in bytes like the parts it is split into.
+func (p *Plugin) shouldTranslateProgressively(post *model.Post) bool {
+	threshold := p.getConfiguration().getProgressiveTranslationThreshold()
+	if threshold == 0 || len(post.Message) <= threshold {
+		return false
+	}
+
+	return p.deliversTranslationPosts(post.ChannelId)
+}
+
+// translateFirstPart translates the first part of a long post, delivering it right away with a
+// button to translate the rest, so that readers don't wait for the whole post to be translated.
+func (p *Plugin) translateFirstPart(post *model.Post, userInfo *UserInfo) {
+	svc, err := p.getTranslateService()
+	if err != nil {
+		p.API.LogError("Failed to get translate service", "err", err.Error())
+		p.notifyTranslationFailure([]*model.Post{post}, userInfo, err)
+		return
+	}
+
+	if !p.claimTranslation(post, userInfo.SourceLanguage, userInfo.TargetLanguage) {
+		return
+	}
+
+	firstPart := post.Clone()
+	firstPart.Message = splitText(post.Message, p.getConfiguration().getProgressiveTranslationThreshold())[0].text
+
+	// Both parts go to the provider of the whole post.
+	ctx := newMessageSizeContext(newRequestContext(context.Background(), model.NewId()), post.Message)
+	content, err := p.translatePostContent(ctx, svc, firstPart, userInfo, true)
+	if err != nil {
+		p.API.LogError("Failed to translate post", "request_id", getRequestID(ctx), "post_id", post.Id, "err", err.Error())
+		p.releaseTranslation(post, userInfo.SourceLanguage, userInfo.TargetLanguage)
+		p.notifyTranslationFailure([]*model.Post{post}, userInfo, err)
+		return
+	}
+
+	if content.isEmpty() {
+		return
+	}
+
+	translateRest := newPostAction("Translate rest", actionTranslateRest, post.Id)
+	translateRest.Integration.Context["offset"] = len(firstPart.Message)
+	translatedAttachments := append(content.attachments, &model.SlackAttachment{
+		Text:    translatedFirstPartText,
+		Actions: []*model.PostAction{translateRest},
+	})
+
+	if !p.deliverTranslation(post, userInfo.withSourceLanguage(content.sourceLanguage), content.message, translatedAttachments) {
+		p.releaseTranslation(post, userInfo.SourceLanguage, userInfo.TargetLanguage)
+	}
+}
+
+// translateRest translates the rest of a long post into the target language of its author,
+// replying in its thread. It returns the translation post of the first part, without its
+// Translate rest button, to update it with. Only the first of concurrent clicks on the button
+// translates the rest, the others failing until the translation does.
+func (p *Plugin) translateRest(ctx context.Context, post *model.Post, offset int, translationPostID string) (*model.Post, *APIErrorResponse) {
+	if offset <= 0 || offset >= len(post.Message) || !utf8.RuneStart(post.Message[offset]) {
+		return nil, &APIErrorResponse{ID: apiErrorInvalidOffset, Message: "Nothing left to translate", StatusCode: http.StatusBadRequest}
+	}
+
+	userInfo, apiErr := p.getUserInfo(post.UserId)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	if err := p.checkAuthorConsent(post); err != nil {
+		return nil, newTranslationError(err)
+	}
+
+	svc, err := p.getTranslateService()
+	if err != nil {
+		return nil, &APIErrorResponse{ID: apiErrorBadCredentials, Message: "Bad credentials", StatusCode: http.StatusForbidden}
+	}
+
+	if !p.claimTranslateRest(post.Id, offset, userInfo.TargetLanguage) {
+		return nil, &APIErrorResponse{ID: apiErrorAlreadyTranslated, Message: "The rest of this message is translated already", StatusCode: http.StatusConflict}
+	}
+
+	ctx = newMessageSizeContext(newAuditContext(p.newGlossaryContext(ctx, post.ChannelId), "", post.Id, post.ChannelId), post.Message)
+	translated, err := p.translateLongText(ctx, svc, userInfo.SourceLanguage, userInfo.TargetLanguage, post.Message[offset:])
+	if err != nil {
+		p.releaseTranslateRest(post.Id, offset, userInfo.TargetLanguage)
+		return nil, newTranslationError(err)
+	}
+
+	if p.createTranslationPost(post, userInfo, getThreadRootID(post), translated, nil) == nil {
+		p.releaseTranslateRest(post.Id, offset, userInfo.TargetLanguage)
+		return nil, &APIErrorResponse{ID: apiErrorUnableToSave, Message: "Failed to post the translation", StatusCode: http.StatusInternalServerError}
+	}
+
+	translationPost, appErr := p.API.GetPost(translationPostID)
+	if appErr != nil {
+		return nil, nil
+	}
+
+	attachments := translationPost.Attachments()
+	for _, attachment := range attachments {
+		var actions []*model.PostAction
+		for _, action := range attachment.Actions {
+			if action.Integration == nil || action.Integration.URL != getActionURL(actionTranslateRest) {
+				actions = append(actions, action)
+			}
+		}
+
+		if len(actions) != len(attachment.Actions) {
+			attachment.Text = translatedRestText
+			attachment.Actions = actions
+		}
+	}
+	model.ParseSlackAttachment(translationPost, attachments)
+
+	return translationPost, nil
+}
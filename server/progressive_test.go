@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+)
+
+// newTestProvider returns a server answering every request to Amazon Translate with the given
+// translation, counting the requests.
+func newTestProvider(translated string) (*httptest.Server, *int32) {
+	var requests int32
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"SourceLanguageCode": "ko",
+			"TargetLanguageCode": "en",
+			"TranslatedText":     translated,
+		})
+	}))
+
+	return provider, &requests
+}
+
+func TestTranslateRestReleasesClaimWhenPostingFails(t *testing.T) {
+	provider, requests := newTestProvider("The rest.")
+	defer provider.Close()
+
+	userInfo := &UserInfo{UserID: model.NewId(), Activated: true, SourceLanguage: "ko", TargetLanguage: "en"}
+	userInfoBytes, _ := json.Marshal(userInfo)
+	post := &model.Post{Id: model.NewId(), UserId: userInfo.UserID, ChannelId: model.NewId(), Message: "첫 부분입니다. 나머지 부분입니다."}
+	offset := len("첫 부분입니다. ")
+	claimKey := getTranslateRestKey(post.Id, offset, userInfo.TargetLanguage)
+	appErr := model.NewAppError("CreatePost", "app.post.save.app_error", nil, "", http.StatusInternalServerError)
+
+	setup := func(claimed bool) (*plugintest.API, *Plugin) {
+		api := &plugintest.API{}
+		api.On("KVGet", userInfo.UserID).Return(userInfoBytes, nil)
+		api.On("KVSetWithOptions", claimKey, mock.Anything, mock.Anything).Return(claimed, nil)
+
+		p := &Plugin{botUserID: model.NewId()}
+		p.SetAPI(api)
+		p.setConfiguration(&configuration{AWSAccessKeyID: "id", AWSSecretAccessKey: "secret", AWSEndpoint: provider.URL})
+		require.NoError(t, p.reloadTranslateService())
+
+		return api, p
+	}
+
+	t.Run("failed translation post releases the claim", func(t *testing.T) {
+		api, p := setup(true)
+		api.On("GetChannel", post.ChannelId).Return(nil, model.NewAppError("GetChannel", "app.channel.get.existing.app_error", nil, "", http.StatusNotFound))
+		api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(nil, appErr)
+		api.On("LogError", "Failed to create translation post", "post_id", post.Id, "err", mock.Anything)
+		api.On("KVDelete", claimKey).Return(nil)
+
+		before := atomic.LoadInt32(requests)
+
+		_, apiErr := p.translateRest(context.Background(), post, offset, model.NewId())
+		require.NotNil(t, apiErr)
+		assert.Equal(t, apiErrorUnableToSave, apiErr.ID)
+		assert.Equal(t, before+1, atomic.LoadInt32(requests))
+		api.AssertExpectations(t)
+	})
+
+	t.Run("rest claimed already isn't translated again", func(t *testing.T) {
+		api, p := setup(false)
+		before := atomic.LoadInt32(requests)
+
+		_, apiErr := p.translateRest(context.Background(), post, offset, model.NewId())
+		require.NotNil(t, apiErr)
+		assert.Equal(t, apiErrorAlreadyTranslated, apiErr.ID)
+		assert.Equal(t, before, atomic.LoadInt32(requests), "the provider isn't asked again")
+		api.AssertNotCalled(t, "KVDelete", claimKey)
+	})
+}
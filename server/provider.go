@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/comprehend"
+	"github.com/aws/aws-sdk-go/service/translate"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// defaultAWSRegion is the region of Amazon Translate when admins set none.
+const defaultAWSRegion = "us-east-1"
+
+// translationProviders return the clients of the translation providers which system admins can
+// force for a request with the provider parameter, keyed by name.
+var translationProviders = map[string]func(p *Plugin) (*translate.Translate, error){
+	providerAWS:     (*Plugin).getTranslateService,
+	providerOffline: (*Plugin).getOfflineTranslateService,
+}
+
+// newAWSSession returns an AWS session with the credentials, region and TLS settings of a
+// configuration, checking its credentials first.
+func newAWSSession(configuration *configuration) (*session.Session, error) {
+	creds := credentials.NewStaticCredentials(configuration.AWSAccessKeyID, configuration.AWSSecretAccessKey, "")
+	if _, err := creds.Get(); err != nil {
+		return nil, errors.Wrap(err, "bad credentials")
+	}
+
+	config := aws.NewConfig().WithCredentials(creds).WithRegion(configuration.AWSRegion)
+
+	httpClient, err := newProviderHTTPClient(configuration)
+	if err != nil {
+		return nil, err
+	}
+	if httpClient != nil {
+		config = config.WithHTTPClient(httpClient)
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create AWS session")
+	}
+
+	return sess, nil
+}
+
+// newTranslateService returns an Amazon Translate client for a configuration.
+func newTranslateService(configuration *configuration, sess *session.Session) *translate.Translate {
+	config := aws.NewConfig()
+	if configuration.AWSEndpoint != "" {
+		config = config.WithEndpoint(configuration.AWSEndpoint)
+	}
+
+	return translate.New(sess, config)
+}
+
+// reloadTranslateService validates the current configuration and swaps the AWS clients for ones
+// built from it, so that changes to the provider take effect without restarting the plugin. The
+// previous clients are kept when the configuration is invalid.
+func (p *Plugin) reloadTranslateService() error {
+	configuration := p.getConfiguration().Clone()
+	if err := configuration.IsValid(); err != nil {
+		return err
+	}
+
+	sess, err := newAWSSession(configuration)
+	if err != nil {
+		return err
+	}
+
+	p.translateServiceLock.Lock()
+	p.translateService = newTranslateService(configuration, sess)
+	p.comprehendService = comprehend.New(sess)
+	p.offlineTranslateService = newOfflineTranslateService(configuration, sess)
+	p.translateServiceLock.Unlock()
+
+	p.providerProbeLock.Lock()
+	p.providerProbe = nil
+	p.providerProbeLock.Unlock()
+
+	return nil
+}
+
+// getTranslateService returns the Amazon Translate client built from the last valid configuration.
+func (p *Plugin) getTranslateService() (*translate.Translate, error) {
+	p.translateServiceLock.RLock()
+	defer p.translateServiceLock.RUnlock()
+
+	if p.translateService == nil {
+		return nil, errors.New("translation provider not configured")
+	}
+
+	return p.translateService, nil
+}
+
+// getComprehendService returns the Amazon Comprehend client built from the last valid
+// configuration, sharing the credentials and region of Amazon Translate.
+func (p *Plugin) getComprehendService() (*comprehend.Comprehend, error) {
+	p.translateServiceLock.RLock()
+	defer p.translateServiceLock.RUnlock()
+
+	if p.comprehendService == nil {
+		return nil, errors.New("language detection provider not configured")
+	}
+
+	return p.comprehendService, nil
+}
+
+// reloadProvider applies the current configuration to the translation provider right away and
+// checks the provider with it, answering with the result of the check.
+func (p *Plugin) reloadProvider(w http.ResponseWriter, r *http.Request) {
+	// Credentials referring to a secret store are read again, such as after rotating them.
+	if err := p.loadConfiguration(); err != nil {
+		p.API.LogError("Failed to load configuration", "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to load configuration", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	if err := p.reloadTranslateService(); err != nil {
+		p.API.LogWarn("Failed to reload translation provider", "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInvalidConfiguration, Message: "Invalid provider configuration: " + err.Error(), StatusCode: http.StatusBadRequest})
+		return
+	}
+
+	p.API.LogInfo("Reloaded translation provider", "user_id", r.Header.Get("Mattermost-User-ID"))
+
+	resp, _ := json.Marshal(p.getProviderProbe())
+	w.Write(resp)
+}
+
+// getProviderParam returns the translation provider forced for a request with the provider query
+// parameter, or an empty string when the provider isn't forced. Only system admins can force a
+// provider, such as to compare the translations of providers on real messages.
+func (p *Plugin) getProviderParam(r *http.Request) (string, *APIErrorResponse) {
+	provider := r.URL.Query().Get("provider")
+	if provider == "" {
+		return "", nil
+	}
+
+	if _, ok := translationProviders[provider]; !ok {
+		return "", newInvalidParameterError("provider")
+	}
+
+	if !p.API.HasPermissionTo(r.Header.Get("Mattermost-User-ID"), model.PERMISSION_MANAGE_SYSTEM) {
+		return "", &APIErrorResponse{ID: apiErrorForbidden, Message: "Only system admins can choose the provider", StatusCode: http.StatusForbidden}
+	}
+
+	return provider, nil
+}
+
+// providerError is a failed request to the translation provider, along with the IDs of the request
+// it was made for and of the request to the provider, as known by the provider's support.
+type providerError struct {
+	err               error
+	requestID         string
+	providerRequestID string
+}
+
+func (e *providerError) Error() string {
+	message := e.err.Error()
+	if e.requestID != "" {
+		message += " (request ID " + e.requestID + ")"
+	}
+
+	return message
+}
+
+func (e *providerError) Cause() error {
+	return e.err
+}
+
+func (e *providerError) Unwrap() error {
+	return e.err
+}
+
+// newProviderError wraps an error of the provider with the request IDs known about it.
+func newProviderError(ctx context.Context, err error) *providerError {
+	providerErr := &providerError{err: err, requestID: getRequestID(ctx)}
+
+	var requestFailure awserr.RequestFailure
+	if errors.As(err, &requestFailure) {
+		providerErr.providerRequestID = requestFailure.RequestID()
+	}
+
+	return providerErr
+}
+
+// getProviderErrorID returns the stable ID of the API error reporting a failed translation.
+func getProviderErrorID(err error) string {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return apiErrorProviderCanceled
+		}
+		if errors.Is(err, errProviderNotAllowed) {
+			return apiErrorProviderNotAllowed
+		}
+		if errors.Is(err, errConsentRequired) {
+			return apiErrorConsentRequired
+		}
+		var pairErr *unsupportedLanguagePairError
+		if errors.As(err, &pairErr) {
+			return apiErrorUnsupportedLanguagePair
+		}
+		var outputErr *wrongOutputLanguageError
+		if errors.As(err, &outputErr) {
+			return apiErrorWrongOutputLanguage
+		}
+
+		return apiErrorUnableToTranslate
+	}
+
+	switch awsErr.Code() {
+	case translate.ErrCodeTooManyRequestsException:
+		return apiErrorProviderThrottled
+	case translate.ErrCodeServiceUnavailableException, translate.ErrCodeInternalServerException:
+		return apiErrorProviderUnavailable
+	case translate.ErrCodeUnsupportedLanguagePairException:
+		return apiErrorUnsupportedLanguagePair
+	case translate.ErrCodeTextSizeLimitExceededException:
+		return apiErrorTextTooLong
+	case translate.ErrCodeDetectedLanguageLowConfidenceException:
+		return apiErrorLanguageNotDetected
+	case "RequestCanceled":
+		return apiErrorProviderCanceled
+	}
+
+	return apiErrorUnableToTranslate
+}
+
+// newTranslationError returns the API error reporting a failed translation, with the status
+// telling whether the request or the provider is at fault.
+func newTranslationError(err error) *APIErrorResponse {
+	id := getProviderErrorID(err)
+
+	status := http.StatusBadGateway
+	switch id {
+	case apiErrorUnsupportedLanguagePair, apiErrorTextTooLong, apiErrorLanguageNotDetected:
+		status = http.StatusBadRequest
+	case apiErrorProviderThrottled:
+		status = http.StatusTooManyRequests
+	case apiErrorProviderUnavailable:
+		status = http.StatusServiceUnavailable
+	case apiErrorProviderNotAllowed, apiErrorConsentRequired:
+		status = http.StatusForbidden
+	}
+
+	return &APIErrorResponse{ID: id, Message: "Failed to translate: " + err.Error(), StatusCode: status}
+}
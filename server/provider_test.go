@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/translate"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTranslationError(t *testing.T) {
+	ctx := newRequestContext(context.Background(), "request1")
+
+	throttled := newProviderError(ctx, awserr.NewRequestFailure(awserr.New(translate.ErrCodeTooManyRequestsException, "slow down", nil), http.StatusTooManyRequests, "aws1"))
+	assert.Equal(t, "request1", throttled.requestID)
+	assert.Equal(t, "aws1", throttled.providerRequestID)
+	assert.Contains(t, throttled.Error(), "request ID request1")
+
+	apiErr := newTranslationError(throttled)
+	assert.Equal(t, apiErrorProviderThrottled, apiErr.ID)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+
+	apiErr = newTranslationError(newProviderError(ctx, awserr.New(translate.ErrCodeUnsupportedLanguagePairException, "no", nil)))
+	assert.Equal(t, apiErrorUnsupportedLanguagePair, apiErr.ID)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+
+	apiErr = newTranslationError(newProviderError(ctx, context.DeadlineExceeded))
+	assert.Equal(t, apiErrorProviderCanceled, apiErr.ID)
+
+	apiErr = newTranslationError(errProviderNotAllowed)
+	assert.Equal(t, apiErrorProviderNotAllowed, apiErr.ID)
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+
+	apiErr = newTranslationError(errors.New("unknown"))
+	assert.Equal(t, apiErrorUnableToTranslate, apiErr.ID)
+	assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+}
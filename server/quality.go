@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const (
+	// qualityRoutingInterval is how often the providers preferred for language pairs are learned
+	// again from the ratings of translations.
+	qualityRoutingInterval = time.Hour
+
+	// qualityRoutingDays is the number of days of ratings providers are compared over.
+	qualityRoutingDays = 30
+
+	defaultQualityRoutingMinRatings = 20
+)
+
+// ProviderScore is the share of good ratings of the translations of a language pair by a provider
+type ProviderScore struct {
+	Provider string  `json:"provider"`
+	Ratings  int64   `json:"ratings"`
+	Score    float64 `json:"score"`
+}
+
+// LearnedRoute is the provider preferred for a language pair from the ratings of its translations,
+// along with the scores of the providers compared
+type LearnedRoute struct {
+	SourceLanguage string           `json:"source_lang"`
+	TargetLanguage string           `json:"target_lang"`
+	Provider       string           `json:"provider"`
+	Providers      []*ProviderScore `json:"providers"`
+}
+
+// LearnedRoutingReport is the routing learned from the ratings of translations as reported to
+// admins, only applied when quality routing is on
+type LearnedRoutingReport struct {
+	Enabled    bool            `json:"enabled"`
+	Days       int             `json:"days"`
+	MinRatings int             `json:"min_ratings"`
+	Routes     []*LearnedRoute `json:"routes"`
+}
+
+func getLanguagePairRouteKey(source, target string) string {
+	return source + ":" + target
+}
+
+// newLearnedRoutes returns the provider with the best score for each language pair among the ones
+// with at least minRatings ratings, the one with the most ratings on a tie. Pairs without such a
+// provider aren't routed.
+func newLearnedRoutes(stats []*LanguagePairStats, minRatings int64) []*LearnedRoute {
+	routes := map[string]*LearnedRoute{}
+	for _, s := range stats {
+		ratings := s.GoodRatings + s.BadRatings
+		if ratings < minRatings || ratings == 0 {
+			continue
+		}
+
+		key := getLanguagePairRouteKey(s.SourceLanguage, s.TargetLanguage)
+		route, ok := routes[key]
+		if !ok {
+			route = &LearnedRoute{SourceLanguage: s.SourceLanguage, TargetLanguage: s.TargetLanguage}
+			routes[key] = route
+		}
+		route.Providers = append(route.Providers, &ProviderScore{Provider: s.Provider, Ratings: ratings, Score: s.Score})
+	}
+
+	list := make([]*LearnedRoute, 0, len(routes))
+	for _, route := range routes {
+		sort.Slice(route.Providers, func(i, j int) bool {
+			a, b := route.Providers[i], route.Providers[j]
+			if a.Score != b.Score {
+				return a.Score > b.Score
+			}
+			if a.Ratings != b.Ratings {
+				return a.Ratings > b.Ratings
+			}
+			return a.Provider < b.Provider
+		})
+		route.Provider = route.Providers[0].Provider
+		list = append(list, route)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return getLanguagePairRouteKey(list[i].SourceLanguage, list[i].TargetLanguage) < getLanguagePairRouteKey(list[j].SourceLanguage, list[j].TargetLanguage)
+	})
+
+	return list
+}
+
+// learnRoutes learns the providers preferred for language pairs from the ratings of their
+// translations, among the providers known to the plugin.
+func (p *Plugin) learnRoutes() ([]*LearnedRoute, error) {
+	// Requests not saved yet are included so that the scores are up to date.
+	p.flushPairStats()
+
+	stats, err := p.getLanguagePairStats(qualityRoutingDays)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make([]*LanguagePairStats, 0, len(stats))
+	for _, s := range stats {
+		if _, ok := translationProviders[s.Provider]; ok {
+			known = append(known, s)
+		}
+	}
+
+	return newLearnedRoutes(known, int64(p.getConfiguration().getQualityRoutingMinRatings())), nil
+}
+
+// refreshLearnedRoutes learns the providers preferred for language pairs again, applying them when
+// quality routing is on. Each server of a cluster learns them on its own from the same ratings.
+func (p *Plugin) refreshLearnedRoutes() ([]*LearnedRoute, error) {
+	var learned map[string]string
+	var routes []*LearnedRoute
+	if p.getConfiguration().QualityRouting {
+		var err error
+		if routes, err = p.learnRoutes(); err != nil {
+			return nil, err
+		}
+
+		learned = map[string]string{}
+		for _, route := range routes {
+			learned[getLanguagePairRouteKey(route.SourceLanguage, route.TargetLanguage)] = route.Provider
+		}
+	}
+
+	p.learnedRoutesLock.Lock()
+	p.learnedRoutes = learned
+	p.learnedRoutesLock.Unlock()
+
+	return routes, nil
+}
+
+// getLearnedRoutes returns the providers preferred for language pairs, keyed by source and target
+// language joined by a colon, or nil when quality routing is off.
+func (p *Plugin) getLearnedRoutes() map[string]string {
+	p.learnedRoutesLock.Lock()
+	defer p.learnedRoutesLock.Unlock()
+
+	return p.learnedRoutes
+}
+
+func (p *Plugin) startQualityRouting() {
+	p.qualityRoutingStop = make(chan struct{})
+	stop := p.qualityRoutingStop
+
+	go func() {
+		ticker := time.NewTicker(qualityRoutingInterval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := p.refreshLearnedRoutes(); err != nil {
+				p.API.LogError("Failed to learn provider routes from ratings", "err", err.Error())
+			}
+
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (p *Plugin) stopQualityRouting() {
+	if p.qualityRoutingStop != nil {
+		close(p.qualityRoutingStop)
+		p.qualityRoutingStop = nil
+	}
+}
+
+// getLearnedRoutingHandler reports the routing learned from the ratings of translations, learning
+// it again so that admins see the effect of the latest ratings. It is reported even when quality
+// routing is off, for admins to preview it.
+func (p *Plugin) getLearnedRoutingHandler(w http.ResponseWriter, r *http.Request) {
+	config := p.getConfiguration()
+	report := &LearnedRoutingReport{
+		Enabled:    config.QualityRouting,
+		Days:       qualityRoutingDays,
+		MinRatings: config.getQualityRoutingMinRatings(),
+	}
+
+	var err error
+	if report.Enabled {
+		report.Routes, err = p.refreshLearnedRoutes()
+	} else {
+		report.Routes, err = p.learnRoutes()
+	}
+	if err != nil {
+		p.API.LogError("Failed to learn provider routes from ratings", "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to get learned routing", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	resp, _ := json.Marshal(report)
+	w.Write(resp)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLearnedRoutes(t *testing.T) {
+	stats := []*LanguagePairStats{
+		{Provider: "papago", SourceLanguage: "ko", TargetLanguage: "ja", GoodRatings: 18, BadRatings: 2, Score: 0.9},
+		{Provider: providerAWS, SourceLanguage: "ko", TargetLanguage: "ja", GoodRatings: 30, BadRatings: 10, Score: 0.75},
+		// Too few ratings to be compared.
+		{Provider: "llm", SourceLanguage: "ko", TargetLanguage: "ja", GoodRatings: 5, Score: 1},
+		{Provider: providerAWS, SourceLanguage: "en", TargetLanguage: "de", GoodRatings: 8, BadRatings: 2, Score: 0.8},
+		{Provider: "deepl", SourceLanguage: "en", TargetLanguage: "de", GoodRatings: 16, BadRatings: 4, Score: 0.8},
+		{Provider: providerAWS, SourceLanguage: "en", TargetLanguage: "fr", Requests: 100},
+	}
+
+	routes := newLearnedRoutes(stats, 10)
+	require.Len(t, routes, 2)
+
+	assert.Equal(t, "en", routes[0].SourceLanguage)
+	assert.Equal(t, "de", routes[0].TargetLanguage)
+	// Ties go to the provider with the most ratings.
+	assert.Equal(t, "deepl", routes[0].Provider)
+	assert.Equal(t, []*ProviderScore{
+		{Provider: "deepl", Ratings: 20, Score: 0.8},
+		{Provider: providerAWS, Ratings: 10, Score: 0.8},
+	}, routes[0].Providers)
+
+	assert.Equal(t, "ko", routes[1].SourceLanguage)
+	assert.Equal(t, "papago", routes[1].Provider)
+	assert.Len(t, routes[1].Providers, 2)
+
+	assert.Empty(t, newLearnedRoutes(stats, 50))
+}
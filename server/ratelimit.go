@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// rateLimitWindow is the period within which the requests of a client are counted.
+	rateLimitWindow = time.Minute
+
+	defaultUserRateLimit    = 60
+	defaultAddressRateLimit = 300
+)
+
+// allowRequest counts a request of the client with the given key in the current rate limit
+// window, reporting whether it stays within the limit, zero meaning no limit. Requests which
+// don't are refused until the returned time, when the window ends.
+func (p *Plugin) allowRequest(key string, limit int, now time.Time) (bool, time.Time) {
+	p.rateLimitLock.Lock()
+	defer p.rateLimitLock.Unlock()
+
+	if p.rateLimitCounts == nil || !now.Before(p.rateLimitStart.Add(rateLimitWindow)) {
+		p.rateLimitStart = now.Truncate(rateLimitWindow)
+		p.rateLimitCounts = map[string]int{}
+	}
+
+	if limit <= 0 {
+		return true, time.Time{}
+	}
+
+	p.rateLimitCounts[key]++
+
+	return p.rateLimitCounts[key] <= limit, p.rateLimitStart.Add(rateLimitWindow)
+}
+
+// checkRateLimit counts a request of the client with the given key, refusing it with a too many
+// requests error when it goes over the limit.
+func (p *Plugin) checkRateLimit(w http.ResponseWriter, key string, limit int) bool {
+	now := time.Now()
+	allowed, retryAt := p.allowRequest(key, limit, now)
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAt.Sub(now).Seconds())+1))
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorTooManyRequests, Message: "Too many requests", StatusCode: http.StatusTooManyRequests})
+	}
+
+	return allowed
+}
+
+// withAddressRateLimit refuses the requests of client addresses going over the configured number
+// of requests per minute. It must come before authentication, so that requests failing it, such
+// as ones guessing the webhook token or signature, are counted too.
+func (p *Plugin) withAddressRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !p.checkRateLimit(w, "address_"+getClientAddress(r), p.getConfiguration().getAddressRateLimit()) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withUserRateLimit refuses the requests of users going over the configured number of requests
+// per minute, so that a misbehaving client can't use up the quota of the provider. It must follow
+// withAuth, which sets the ID of the user, and the address rate limit, which is checked first.
+func (p *Plugin) withUserRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !p.checkRateLimit(w, "user_"+r.Header.Get("Mattermost-User-ID"), p.getConfiguration().getUserRateLimit()) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientAddressContextKey holds the address of the client sending a request, as resolved by the
+// server from the proxy header admins trust in the Mattermost configuration.
+const clientAddressContextKey contextKey = "client_address"
+
+// getClientAddress returns the address of the client sending a request. Forwarding headers sent
+// by the client itself are ignored, as anyone can set them to get a new rate limit per request,
+// so the address resolved by the server is taken, or else the address of the connection.
+func getClientAddress(r *http.Request) string {
+	if address, _ := r.Context().Value(clientAddressContextKey).(string); address != "" {
+		return address
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
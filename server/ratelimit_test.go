@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowRequest(t *testing.T) {
+	p := &Plugin{}
+	start := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		allowed, _ := p.allowRequest("user_a", 3, start.Add(time.Duration(i)*time.Second))
+		assert.True(t, allowed)
+	}
+
+	allowed, retryAt := p.allowRequest("user_a", 3, start.Add(10*time.Second))
+	assert.False(t, allowed)
+	assert.Equal(t, start.Add(rateLimitWindow), retryAt)
+
+	allowed, _ = p.allowRequest("user_b", 3, start.Add(10*time.Second))
+	assert.True(t, allowed, "other clients have their own count")
+
+	allowed, _ = p.allowRequest("user_a", 0, start.Add(20*time.Second))
+	assert.True(t, allowed, "zero means no limit")
+
+	allowed, _ = p.allowRequest("user_a", 3, start.Add(rateLimitWindow))
+	assert.True(t, allowed, "counts are reset when the window ends")
+}
+
+func TestGetClientAddress(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/translate", nil)
+	r.RemoteAddr = "10.0.0.2:51234"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	r.Header.Set("X-Real-IP", "203.0.113.8")
+
+	assert.Equal(t, "10.0.0.2", getClientAddress(r), "forwarding headers of clients are ignored")
+
+	r = r.WithContext(context.WithValue(r.Context(), clientAddressContextKey, "198.51.100.4"))
+	assert.Equal(t, "198.51.100.4", getClientAddress(r))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// emailPattern matches email addresses, which mentionPattern leaves alone.
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+
+	// cardNumberPattern matches runs of 13 to 19 digits, optionally grouped by spaces or dashes,
+	// which are payment card numbers when they pass the Luhn check.
+	cardNumberPattern = regexp.MustCompile(`\b[0-9](?:[ -]?[0-9]){12,18}\b`)
+
+	// phoneNumberPattern matches digits grouped by spaces, dots, dashes or parentheses, optionally
+	// starting with an international prefix, such as +1 (555) 123-4567.
+	phoneNumberPattern = regexp.MustCompile(`(?:\+\(?|\()?\b[0-9][0-9 ().-]{5,}[0-9]\b`)
+)
+
+// countDigits returns the number of digits of text.
+func countDigits(text string) int {
+	digits := 0
+	for _, r := range text {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+
+	return digits
+}
+
+// isCardNumber reports whether the digits of text make a payment card number, as told by their
+// Luhn checksum.
+func isCardNumber(text string) bool {
+	sum := 0
+	double := false
+	for i := len(text) - 1; i >= 0; i-- {
+		if text[i] < '0' || text[i] > '9' {
+			continue
+		}
+
+		digit := int(text[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+
+	return sum%10 == 0
+}
+
+// isPhoneNumber reports whether text holds as many digits as a phone number, either written with
+// an international prefix or grouped, so that plain numbers such as amounts are left alone.
+func isPhoneNumber(text string) bool {
+	digits := countDigits(text)
+	if digits < 7 || digits > 15 {
+		return false
+	}
+
+	return strings.HasPrefix(text, "+") || digits < len(text)
+}
+
+// redactPersonalData replaces the email addresses, payment card numbers and phone numbers of text
+// with placeholders, so that they never reach the provider.
+func (ph *placeholders) redactPersonalData(text string) string {
+	text = ph.maskMatches(emailPattern, nil, text)
+	text = ph.maskMatches(cardNumberPattern, isCardNumber, text)
+	return ph.maskMatches(phoneNumberPattern, isPhoneNumber, text)
+}
+
+// redactForProvider removes personal data from a text sent to a provider without being restored
+// afterwards, such as to detect its language, when admins require it to be redacted.
+func (p *Plugin) redactForProvider(text string) string {
+	if !p.getConfiguration().RedactPersonalData {
+		return text
+	}
+
+	return (&placeholders{}).redactPersonalData(text)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaceholdersRedactPersonalData(t *testing.T) {
+	for name, tc := range map[string]struct {
+		text     string
+		expected string
+		values   []string
+	}{
+		"email": {
+			text:     "Write to john.doe@example.co.uk today",
+			expected: "Write to {{0}} today",
+			values:   []string{"john.doe@example.co.uk"},
+		},
+		"card number": {
+			text:     "Card 4111 1111 1111 1111 expired",
+			expected: "Card {{0}} expired",
+			values:   []string{"4111 1111 1111 1111"},
+		},
+		"not a card number": {
+			text:     "Order 1234567812345678 shipped",
+			expected: "Order 1234567812345678 shipped",
+		},
+		"phone numbers": {
+			text:     "Call +1 (555) 123-4567 or 03-1234-5678",
+			expected: "Call {{0}} or {{1}}",
+			values:   []string{"+1 (555) 123-4567", "03-1234-5678"},
+		},
+		"plain numbers": {
+			text:     "We sold 1500000 units in 2020",
+			expected: "We sold 1500000 units in 2020",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ph := &placeholders{}
+			assert.Equal(t, tc.expected, ph.redactPersonalData(tc.text))
+			assert.Equal(t, tc.values, ph.values)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+// requestIDHeader carries the ID of a request in its response, which also appears in the error
+// response and in the logs about the request, so that failures reported by users can be found.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDContextKey holds the ID correlating the logs and the errors of a request or hook call.
+const requestIDContextKey contextKey = "request_id"
+
+// newRequestContext returns a context carrying a request ID, to be passed down to the provider.
+func newRequestContext(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, requestID)
+}
+
+// getRequestID returns the request ID carried by a context, or an empty string.
+func getRequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey).(string)
+	return requestID
+}
+
+// getResponseRequestID returns the request ID set on a response.
+func getResponseRequestID(w http.ResponseWriter) string {
+	return w.Header().Get(requestIDHeader)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// retentionCleanupInterval is how often translation data older than the retention set by admins
+// is purged.
+const retentionCleanupInterval = 24 * time.Hour
+
+// getRetentionExpiry returns the expiry in seconds of data kept for expiry seconds at most, cut
+// down to the retention set by admins.
+func (c *configuration) getRetentionExpiry(expiry int64) int64 {
+	if retention := int64(c.getDataRetention()) * 24 * 60 * 60; retention > 0 && retention < expiry {
+		return retention
+	}
+
+	return expiry
+}
+
+// isAuditKeyExpired reports whether the audit entries of a key were recorded before cutoff.
+func isAuditKeyExpired(key string, cutoff time.Time) bool {
+	hour, err := time.Parse(auditHourFormat, strings.TrimPrefix(key, auditKeyPrefix))
+	if err != nil {
+		return false
+	}
+
+	// Entries recorded within the hour of the cutoff are kept until the whole hour is past it.
+	return hour.Add(time.Hour).Before(cutoff)
+}
+
+func (p *Plugin) startDataRetentionCleanup() {
+	p.retentionStop = make(chan struct{})
+	stop := p.retentionStop
+
+	go func() {
+		ticker := time.NewTicker(retentionCleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			p.runDataRetentionCleanup()
+
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (p *Plugin) stopDataRetentionCleanup() {
+	if p.retentionStop != nil {
+		close(p.retentionStop)
+		p.retentionStop = nil
+	}
+}
+
+func (p *Plugin) runDataRetentionCleanup() {
+	purged, err := p.purgeExpiredData(time.Now().UTC())
+	if err != nil {
+		p.API.LogError("Failed to purge expired translation data", "purged", purged, "err", err.Error())
+	} else if purged > 0 {
+		p.API.LogInfo("Purged expired translation data", "purged", purged)
+	}
+
+	deleted, err := p.deleteDeactivatedUsersData()
+	if err != nil {
+		p.API.LogError("Failed to delete data of deactivated users", "deleted", deleted, "err", err.Error())
+	} else if deleted > 0 {
+		p.API.LogInfo("Deleted data of deactivated users", "deleted", deleted)
+	}
+}
+
+// isDatedKeyExpired reports whether the statistics of a key ending with the day or month they
+// were gathered in, such as stats_aws_2020-06-10 or spend_2020-06, end before cutoff.
+func isDatedKeyExpired(key, layout string, cutoff time.Time) bool {
+	if len(key) < len(layout) {
+		return false
+	}
+
+	start, err := time.Parse(layout, key[len(key)-len(layout):])
+	if err != nil {
+		return false
+	}
+
+	end := start.AddDate(0, 0, 1)
+	if layout == spendMonthFormat {
+		end = start.AddDate(0, 1, 0)
+	}
+
+	return end.Before(cutoff)
+}
+
+// purgeExpiredData deletes the data older than the retention set by admins: cached translations,
+// delivered translation keys, translations delivered in place, ratings of translations, entries of
+// translation histories and the daily and monthly statistics, along with the audit entries older
+// than either retention, as the expiry they were stored with may be longer than a retention
+// shortened since. It returns the number of keys deleted.
+func (p *Plugin) purgeExpiredData(now time.Time) (int, error) {
+	config := p.getConfiguration()
+
+	auditRetention := config.getAuditLogRetention()
+	retention := config.getDataRetention()
+	if retention > 0 && retention < auditRetention {
+		auditRetention = retention
+	}
+	auditCutoff := now.AddDate(0, 0, -auditRetention)
+	cutoffTime := now.AddDate(0, 0, -retention)
+	cutoff := model.GetMillisForTime(cutoffTime)
+
+	// Keys are collected before being deleted, as deleting them would shift the pages. Expired
+	// keys are known from their name, while the others need their value to be read.
+	var expiredKeys, keys, historyKeys []string
+	for page := 0; ; page++ {
+		pageKeys, appErr := p.API.KVList(page, keysPerPage)
+		if appErr != nil {
+			return 0, appErr
+		}
+
+		for _, key := range pageKeys {
+			switch {
+			case strings.HasPrefix(key, auditKeyPrefix):
+				if isAuditKeyExpired(key, auditCutoff) {
+					expiredKeys = append(expiredKeys, key)
+				}
+			case retention <= 0:
+			case strings.HasPrefix(key, statsKeyPrefix) || strings.HasPrefix(key, volumeKeyPrefix) || strings.HasPrefix(key, pairStatsKeyPrefix):
+				if isDatedKeyExpired(key, statsDayFormat, cutoffTime) {
+					expiredKeys = append(expiredKeys, key)
+				}
+			case strings.HasPrefix(key, spendKeyPrefix) || strings.HasPrefix(key, spendReportKeyPrefix):
+				if isDatedKeyExpired(key, spendMonthFormat, cutoffTime) {
+					expiredKeys = append(expiredKeys, key)
+				}
+			case strings.HasPrefix(key, historyKeyPrefix):
+				historyKeys = append(historyKeys, key)
+			case strings.HasPrefix(key, translationCacheKeyPrefix) || strings.HasPrefix(key, translationKeyPrefix) || strings.HasPrefix(key, feedbackKeyPrefix) || strings.HasPrefix(key, storedTranslationsKeyPrefix):
+				keys = append(keys, key)
+			}
+		}
+
+		if len(pageKeys) < keysPerPage {
+			break
+		}
+	}
+
+	for _, key := range keys {
+		expired, err := p.isTranslationDataExpired(key, cutoff)
+		if err != nil {
+			return 0, err
+		}
+		if expired {
+			expiredKeys = append(expiredKeys, key)
+		}
+	}
+
+	purged := 0
+	for _, key := range expiredKeys {
+		if appErr := p.API.KVDelete(key); appErr != nil {
+			return purged, appErr
+		}
+		purged++
+	}
+
+	for _, key := range historyKeys {
+		deleted, err := p.purgeTranslationHistory(key, cutoff)
+		if err != nil {
+			return purged, err
+		}
+		if deleted {
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// isTranslationDataExpired reports whether a cached translation is of a revision of a post made
+// before cutoff, or whether a translation was delivered or rated before cutoff, translations
+// delivered in place being kept as long as one of them is recent. Translations which can't be
+// read anymore, such as after the encryption key changed, are expired as well.
+func (p *Plugin) isTranslationDataExpired(key string, cutoff int64) (bool, error) {
+	value, appErr := p.API.KVGet(key)
+	if appErr != nil {
+		return false, appErr
+	}
+	if value == nil {
+		return false, nil
+	}
+
+	if strings.HasPrefix(key, storedTranslationsKeyPrefix) {
+		translations, err := p.getStoredTranslations(value)
+		if err != nil {
+			return true, nil
+		}
+
+		for _, translation := range translations {
+			if translation.CreateAt >= cutoff {
+				return false, nil
+			}
+		}
+
+		return true, nil
+	}
+
+	if strings.HasPrefix(key, feedbackKeyPrefix) {
+		var feedback *TranslationFeedback
+		if err := json.Unmarshal(value, &feedback); err != nil || feedback == nil {
+			return false, nil
+		}
+
+		return feedback.CreateAt < cutoff, nil
+	}
+
+	if strings.HasPrefix(key, translationKeyPrefix) {
+		// Keys of translations delivered before their time was recorded expire on their own.
+		deliveredAt, err := strconv.ParseInt(string(value), 10, 64)
+		return err == nil && deliveredAt < cutoff, nil
+	}
+
+	decrypted, err := decryptValue(p.getConfiguration().EncryptionKey, value)
+	if err != nil {
+		return true, nil
+	}
+
+	var translated *TranslatedMessage
+	if err := json.Unmarshal(decrypted, &translated); err != nil || translated == nil {
+		return true, nil
+	}
+
+	return translated.UpdateAt < cutoff, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRetentionExpiry(t *testing.T) {
+	week := int64(7 * 24 * 60 * 60)
+
+	assert.Equal(t, week, (&configuration{}).getRetentionExpiry(week))
+	assert.Equal(t, week, (&configuration{DataRetention: "0"}).getRetentionExpiry(week))
+	assert.Equal(t, week, (&configuration{DataRetention: "30"}).getRetentionExpiry(week))
+	assert.Equal(t, int64(2*24*60*60), (&configuration{DataRetention: "2"}).getRetentionExpiry(week))
+}
+
+func TestIsAuditKeyExpired(t *testing.T) {
+	cutoff := time.Date(2020, 6, 10, 12, 30, 0, 0, time.UTC)
+
+	for name, test := range map[string]struct {
+		key      string
+		expected bool
+	}{
+		"before cutoff":      {key: getAuditKey("2020-06-09T23"), expected: true},
+		"hour before cutoff": {key: getAuditKey("2020-06-10T11"), expected: true},
+		"hour of cutoff":     {key: getAuditKey("2020-06-10T12"), expected: false},
+		"after cutoff":       {key: getAuditKey("2020-06-11T00"), expected: false},
+		"invalid hour":       {key: getAuditKey("latest"), expected: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, isAuditKeyExpired(test.key, cutoff))
+		})
+	}
+}
+
+func TestIsDatedKeyExpired(t *testing.T) {
+	cutoff := time.Date(2020, 6, 10, 12, 30, 0, 0, time.UTC)
+
+	for name, test := range map[string]struct {
+		key      string
+		layout   string
+		expected bool
+	}{
+		"day before cutoff":   {key: getUsageStatsKey(providerAWS, "2020-06-09"), layout: statsDayFormat, expected: true},
+		"day of cutoff":       {key: getVolumeStatsKey("2020-06-10"), layout: statsDayFormat, expected: false},
+		"month before cutoff": {key: getSpendKey("2020-05"), layout: spendMonthFormat, expected: true},
+		"month of cutoff":     {key: getSpendReportKey("2020-06"), layout: spendMonthFormat, expected: false},
+		"invalid day":         {key: getPairStatsKey("latest"), layout: statsDayFormat, expected: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, isDatedKeyExpired(test.key, test.layout, cutoff))
+		})
+	}
+}
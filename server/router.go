@@ -0,0 +1,251 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// initializeRouter sets up the routes of the HTTP API. Versioned routes live under /api/v1, while
+// the unversioned routes are kept for existing clients and for the buttons of existing posts.
+func (p *Plugin) initializeRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.Use(p.withRecovery, p.withLogging, p.withTracing)
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, newNotFoundError())
+	})
+	router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorMethodNotAllowed, Message: "Method not allowed", StatusCode: http.StatusMethodNotAllowed})
+	})
+
+	// The health of the plugin is reported to load balancers and monitoring without a session, as
+	// is the specification of the API to integrators.
+	router.HandleFunc("/api/v1/health", p.getHealth).Methods(http.MethodGet)
+	router.HandleFunc("/api/health", p.getHealth).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/spec", p.getSpec).Methods(http.MethodGet)
+	router.HandleFunc("/api/spec", p.getSpec).Methods(http.MethodGet)
+
+	// Prometheus scrapes the metrics at the path plugins serve them at by convention.
+	router.Handle("/metrics", p.withAuth(p.withAdmin(http.HandlerFunc(p.getMetrics)))).Methods(http.MethodGet)
+
+	// Other systems call the webhook with its own token rather than on behalf of a user.
+	router.Handle("/api/v1/webhook", p.withAddressRateLimit(p.withWebhookAuth(http.HandlerFunc(p.handleWebhook)))).Methods(http.MethodPost)
+
+	// Other plugins send the same requests as the webhook through the server.
+	router.Handle("/api/v1/plugin/translate", p.withSourcePlugin(http.HandlerFunc(p.handleWebhook))).Methods(http.MethodPost)
+
+	v1 := router.PathPrefix("/api/v1").Subrouter()
+	v1.Use(p.withAddressRateLimit, p.withAuth, p.withUserRateLimit)
+	v1.HandleFunc("/posts/{post_id:[a-z0-9]{26}}/translation", p.getGo).Methods(http.MethodGet)
+	v1.HandleFunc("/posts/{post_id:[a-z0-9]{26}}/translation/stream", p.startTranslationStream).Methods(http.MethodPost)
+	v1.HandleFunc("/translation/{post_id:[a-z0-9]{26}}", p.getTranslation).Methods(http.MethodGet)
+	v1.HandleFunc("/info", p.getInfo).Methods(http.MethodGet)
+	v1.HandleFunc("/info", p.setInfo).Methods(http.MethodPost)
+	v1.HandleFunc("/info", p.deleteInfo).Methods(http.MethodDelete)
+	v1.HandleFunc("/consent", p.getConsent).Methods(http.MethodGet)
+	v1.HandleFunc("/consent", p.submitConsentDialog).Methods(http.MethodPost)
+	v1.HandleFunc("/history", p.getHistory).Methods(http.MethodGet)
+	v1.HandleFunc("/languages", p.getLanguages).Methods(http.MethodGet)
+	v1.HandleFunc("/detect", p.detect).Methods(http.MethodPost)
+	v1.HandleFunc("/actions/{action}", p.handlePostAction).Methods(http.MethodPost)
+	v1.Handle("/stats", p.withAdmin(http.HandlerFunc(p.getStats))).Methods(http.MethodGet)
+	v1.Handle("/stats/languages", p.withAdmin(http.HandlerFunc(p.getLanguageStats))).Methods(http.MethodGet)
+	v1.Handle("/stats/pairs", p.withAdmin(http.HandlerFunc(p.getLanguagePairStatsHandler))).Methods(http.MethodGet)
+	v1.Handle("/stats/providers", p.withAdmin(http.HandlerFunc(p.getProviderStatsHandler))).Methods(http.MethodGet)
+	v1.Handle("/stats/spend", p.withAdmin(http.HandlerFunc(p.getSpend))).Methods(http.MethodGet)
+	v1.Handle("/stats/export", p.withAdmin(http.HandlerFunc(p.exportUsageStats))).Methods(http.MethodGet)
+	v1.Handle("/stats/leaderboard", p.withAdmin(http.HandlerFunc(p.getLeaderboardHandler))).Methods(http.MethodGet)
+	v1.Handle("/routing/learned", p.withAdmin(http.HandlerFunc(p.getLearnedRoutingHandler))).Methods(http.MethodGet)
+	v1.Handle("/metrics", p.withAdmin(http.HandlerFunc(p.getMetrics))).Methods(http.MethodGet)
+	v1.Handle("/audit", p.withAdmin(http.HandlerFunc(p.getAuditLog))).Methods(http.MethodGet)
+	v1.Handle("/audit/export", p.withAdmin(http.HandlerFunc(p.exportAuditLog))).Methods(http.MethodGet)
+	v1.Handle("/users/{user_id:[a-z0-9]{26}}/data", p.withAdmin(http.HandlerFunc(p.deleteUserDataHandler))).Methods(http.MethodDelete)
+	v1.Handle("/compliance/mappings", p.withAdmin(http.HandlerFunc(p.getTranslationMappingsHandler))).Methods(http.MethodGet)
+	v1.Handle("/cache/flush", p.withAdmin(http.HandlerFunc(p.flushCache))).Methods(http.MethodPost)
+	v1.Handle("/provider/reload", p.withAdmin(http.HandlerFunc(p.reloadProvider))).Methods(http.MethodPost)
+	v1.Handle("/diagnostics", p.withAdmin(http.HandlerFunc(p.getDiagnosticsHandler))).Methods(http.MethodGet)
+	v1.HandleFunc("/glossaries/{scope:team|channel}/{id:[a-z0-9]{26}}", p.getGlossaryHandler).Methods(http.MethodGet)
+	v1.HandleFunc("/glossaries/{scope:team|channel}/{id:[a-z0-9]{26}}", p.setGlossaryHandler).Methods(http.MethodPut)
+	v1.HandleFunc("/glossaries/{scope:team|channel}/{id:[a-z0-9]{26}}/terms", p.addGlossaryTermHandler).Methods(http.MethodPost)
+	v1.HandleFunc("/glossaries/{scope:team|channel}/{id:[a-z0-9]{26}}/terms", p.removeGlossaryTermHandler).Methods(http.MethodDelete)
+	v1.HandleFunc("/glossaries/{scope:team|channel}/{id:[a-z0-9]{26}}/import", p.importGlossaryHandler).Methods(http.MethodPost)
+	v1.HandleFunc("/glossaries/{scope:team|channel}/{id:[a-z0-9]{26}}/export", p.exportGlossaryHandler).Methods(http.MethodGet)
+
+	legacy := router.PathPrefix("/api").Subrouter()
+	legacy.Use(p.withAddressRateLimit, p.withAuth, p.withUserRateLimit)
+	legacy.HandleFunc("/go", p.getGo)
+	legacy.HandleFunc("/translation/{post_id:[a-z0-9]{26}}", p.getTranslation).Methods(http.MethodGet)
+	legacy.HandleFunc("/get_info", p.getInfo)
+	legacy.HandleFunc("/set_info", p.setInfo)
+	legacy.HandleFunc("/action/{action}", p.handlePostAction)
+	legacy.HandleFunc("/history", p.getHistory).Methods(http.MethodGet)
+	legacy.HandleFunc("/languages", p.getLanguages).Methods(http.MethodGet)
+	legacy.HandleFunc("/detect", p.detect).Methods(http.MethodPost)
+	legacy.Handle("/stats", p.withAdmin(http.HandlerFunc(p.getStats))).Methods(http.MethodGet)
+	legacy.Handle("/stats/languages", p.withAdmin(http.HandlerFunc(p.getLanguageStats))).Methods(http.MethodGet)
+	legacy.Handle("/cache/flush", p.withAdmin(http.HandlerFunc(p.flushCache))).Methods(http.MethodPost)
+	legacy.Handle("/provider/reload", p.withAdmin(http.HandlerFunc(p.reloadProvider))).Methods(http.MethodPost)
+
+	return router
+}
+
+// statusRecorder records the status code written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// withRecovery turns panics of handlers into internal server errors, as a panic would otherwise
+// take the whole plugin down. Only the path of the request is logged, as its query may carry the
+// webhook token.
+func (p *Plugin) withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if x := recover(); x != nil {
+				p.API.LogError("Recovered from a panic", "request_id", getRequestID(r.Context()), "path", r.URL.Path, "err", fmt.Sprint(x), "stack", string(debug.Stack()))
+				writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Internal server error", StatusCode: http.StatusInternalServerError})
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (p *Plugin) withLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		p.API.LogDebug("Handled request", "request_id", getRequestID(r.Context()), "method", r.Method, "path", r.URL.Path, "status", recorder.status, "duration", time.Since(start).String())
+	})
+}
+
+const (
+	// sharedSecretHeader carries the shared secret of servers trusted to call the API.
+	sharedSecretHeader = "X-Autotranslate-Secret"
+
+	// sharedSecretUserIDHeader carries the ID of the user trusted servers call the API for.
+	sharedSecretUserIDHeader = "X-Autotranslate-User-Id"
+)
+
+type contextKey string
+
+// sessionIDContextKey holds the ID of the session a request was authenticated with by the server.
+const sessionIDContextKey contextKey = "session_id"
+
+// withAuth only lets requests of logged in users through, whose ID is set by the server, along
+// with requests of trusted servers sending the shared secret. Mutating requests of sessions must
+// also prove they aren't forged by another site, as the server doesn't check it for plugins.
+func (p *Plugin) withAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(sharedSecretHeader) != "" {
+			if !p.isTrustedServerRequest(r) {
+				writeAPIError(w, &APIErrorResponse{ID: apiErrorNotAuthorized, Message: "Not authorized", StatusCode: http.StatusUnauthorized})
+				return
+			}
+
+			r.Header.Set("Mattermost-User-ID", r.Header.Get(sharedSecretUserIDHeader))
+			next.ServeHTTP(w, r.WithContext(newAuditContext(r.Context(), r.Header.Get("Mattermost-User-ID"), "", "")))
+			return
+		}
+
+		if r.Header.Get("Mattermost-User-ID") == "" {
+			writeAPIError(w, &APIErrorResponse{ID: apiErrorNotAuthorized, Message: "Not authorized", StatusCode: http.StatusUnauthorized})
+			return
+		}
+
+		if !p.hasValidCSRFToken(r) {
+			writeAPIError(w, &APIErrorResponse{ID: apiErrorInvalidCSRFToken, Message: "Invalid CSRF token", StatusCode: http.StatusForbidden})
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(newAuditContext(r.Context(), r.Header.Get("Mattermost-User-ID"), "", "")))
+	})
+}
+
+// isTrustedServerRequest reports whether a request sends the configured shared secret along with
+// the ID of an existing user.
+func (p *Plugin) isTrustedServerRequest(r *http.Request) bool {
+	secret := p.getConfiguration().APISharedSecret
+	if secret == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get(sharedSecretHeader)), []byte(secret)) != 1 {
+		return false
+	}
+
+	userID := r.Header.Get(sharedSecretUserIDHeader)
+	if !model.IsValidId(userID) {
+		return false
+	}
+
+	if _, appErr := p.API.GetUser(userID); appErr != nil {
+		return false
+	}
+
+	return true
+}
+
+// hasValidCSRFToken reports whether a request either doesn't need a CSRF token or sends the one of
+// its session. Mutating requests of sessions must send either the X-Requested-With header, which
+// other sites can't make browsers send, or the CSRF token of their session. Requests the server
+// makes itself, such as the ones of post actions and dialogs, have no session.
+func (p *Plugin) hasValidCSRFToken(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	sessionID, _ := r.Context().Value(sessionIDContextKey).(string)
+	if sessionID == "" {
+		return true
+	}
+
+	if r.Header.Get(model.HEADER_REQUESTED_WITH) == model.HEADER_REQUESTED_WITH_XML {
+		return true
+	}
+
+	token := r.Header.Get(model.HEADER_CSRF_TOKEN)
+	if token == "" {
+		return false
+	}
+
+	session, appErr := p.API.GetSession(sessionID)
+	if appErr != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(session.GetCSRF())) == 1
+}
+
+// withAdmin only lets requests of system admins through.
+func (p *Plugin) withAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !p.API.HasPermissionTo(r.Header.Get("Mattermost-User-ID"), model.PERMISSION_MANAGE_SYSTEM) {
+			writeAPIError(w, &APIErrorResponse{ID: apiErrorForbidden, Message: "Not authorized", StatusCode: http.StatusForbidden})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// getPostIDParam returns the post ID from the path of versioned routes or from the query of
+// unversioned ones.
+func getPostIDParam(r *http.Request) string {
+	if postID, ok := mux.Vars(r)["post_id"]; ok {
+		return postID
+	}
+
+	return r.URL.Query().Get("post_id")
+}
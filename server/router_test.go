@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+)
+
+// serveTestRequest serves a request with a handler, returning the response along with the ID of
+// the user the handler was called for, or an empty string when it wasn't called.
+func serveTestRequest(handler func(http.Handler) http.Handler, r *http.Request) (*httptest.ResponseRecorder, string) {
+	called := ""
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = r.Header.Get("Mattermost-User-ID")
+	})
+
+	w := httptest.NewRecorder()
+	handler(next).ServeHTTP(w, r)
+
+	return w, called
+}
+
+func getTestAPIError(t *testing.T, w *httptest.ResponseRecorder) string {
+	var apiErr APIErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+
+	return apiErr.ID
+}
+
+func TestWithAuth(t *testing.T) {
+	userID := model.NewId()
+
+	api := &plugintest.API{}
+	api.On("GetUser", userID).Return(&model.User{Id: userID}, nil)
+	api.On("GetUser", mock.Anything).Return(nil, model.NewAppError("GetUser", "app.user.missing_account.const", nil, "", http.StatusNotFound))
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	p.setConfiguration(&configuration{APISharedSecret: "secret"})
+
+	t.Run("no user", func(t *testing.T) {
+		w, called := serveTestRequest(p.withAuth, httptest.NewRequest(http.MethodGet, "/api/v1/go", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, apiErrorNotAuthorized, getTestAPIError(t, w))
+		assert.Empty(t, called)
+	})
+
+	t.Run("user", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/go", nil)
+		r.Header.Set("Mattermost-User-ID", userID)
+
+		w, called := serveTestRequest(p.withAuth, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, userID, called)
+	})
+
+	t.Run("shared secret", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/translate", nil)
+		r.Header.Set(sharedSecretHeader, "secret")
+		r.Header.Set(sharedSecretUserIDHeader, userID)
+
+		w, called := serveTestRequest(p.withAuth, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, userID, called)
+	})
+
+	t.Run("wrong shared secret", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/translate", nil)
+		r.Header.Set(sharedSecretHeader, "guess")
+		r.Header.Set(sharedSecretUserIDHeader, userID)
+		r.Header.Set("Mattermost-User-ID", userID)
+
+		w, called := serveTestRequest(p.withAuth, r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Empty(t, called, "the user set by the server doesn't stand in for the secret")
+	})
+
+	t.Run("shared secret for unknown user", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/translate", nil)
+		r.Header.Set(sharedSecretHeader, "secret")
+		r.Header.Set(sharedSecretUserIDHeader, model.NewId())
+
+		w, called := serveTestRequest(p.withAuth, r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Empty(t, called)
+	})
+
+	t.Run("shared secret not configured", func(t *testing.T) {
+		unconfigured := &Plugin{}
+		unconfigured.SetAPI(api)
+		unconfigured.setConfiguration(&configuration{})
+
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/translate", nil)
+		r.Header.Set(sharedSecretHeader, "secret")
+		r.Header.Set(sharedSecretUserIDHeader, userID)
+
+		w, called := serveTestRequest(unconfigured.withAuth, r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Empty(t, called)
+	})
+}
+
+func TestWithAuthCSRF(t *testing.T) {
+	userID := model.NewId()
+	session := &model.Session{Id: model.NewId(), UserId: userID}
+	token := session.GenerateCSRF()
+
+	api := &plugintest.API{}
+	api.On("GetSession", session.Id).Return(session, nil)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	p.setConfiguration(&configuration{})
+
+	newRequest := func(method, sessionID string) *http.Request {
+		r := httptest.NewRequest(method, "/api/v1/translate", nil)
+		r.Header.Set("Mattermost-User-ID", userID)
+		return r.WithContext(context.WithValue(r.Context(), sessionIDContextKey, sessionID))
+	}
+
+	t.Run("mutating request without token", func(t *testing.T) {
+		w, called := serveTestRequest(p.withAuth, newRequest(http.MethodPost, session.Id))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, apiErrorInvalidCSRFToken, getTestAPIError(t, w))
+		assert.Empty(t, called)
+	})
+
+	t.Run("mutating request with wrong token", func(t *testing.T) {
+		r := newRequest(http.MethodPost, session.Id)
+		r.Header.Set(model.HEADER_CSRF_TOKEN, "guess")
+
+		w, called := serveTestRequest(p.withAuth, r)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Empty(t, called)
+	})
+
+	t.Run("mutating request with token", func(t *testing.T) {
+		r := newRequest(http.MethodPost, session.Id)
+		r.Header.Set(model.HEADER_CSRF_TOKEN, token)
+
+		w, called := serveTestRequest(p.withAuth, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, userID, called)
+	})
+
+	t.Run("mutating request with requested with header", func(t *testing.T) {
+		r := newRequest(http.MethodPost, session.Id)
+		r.Header.Set(model.HEADER_REQUESTED_WITH, model.HEADER_REQUESTED_WITH_XML)
+
+		w, called := serveTestRequest(p.withAuth, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, userID, called)
+	})
+
+	t.Run("mutating request without session", func(t *testing.T) {
+		w, called := serveTestRequest(p.withAuth, newRequest(http.MethodPost, ""))
+		assert.Equal(t, http.StatusOK, w.Code, "requests of post actions and dialogs have no session")
+		assert.Equal(t, userID, called)
+	})
+
+	t.Run("reading request without token", func(t *testing.T) {
+		w, called := serveTestRequest(p.withAuth, newRequest(http.MethodGet, session.Id))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, userID, called)
+	})
+}
+
+func TestWithAdmin(t *testing.T) {
+	adminID := model.NewId()
+	userID := model.NewId()
+
+	api := &plugintest.API{}
+	api.On("HasPermissionTo", adminID, model.PERMISSION_MANAGE_SYSTEM).Return(true)
+	api.On("HasPermissionTo", userID, model.PERMISSION_MANAGE_SYSTEM).Return(false)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.Header.Set("Mattermost-User-ID", userID)
+
+	w, called := serveTestRequest(p.withAdmin, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, apiErrorForbidden, getTestAPIError(t, w))
+	assert.Empty(t, called)
+
+	r.Header.Set("Mattermost-User-ID", adminID)
+
+	w, called = serveTestRequest(p.withAdmin, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, adminID, called)
+}
+
+func TestWithRateLimit(t *testing.T) {
+	newRequest := func(userID, address string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/go", nil)
+		r.Header.Set("Mattermost-User-ID", userID)
+		return r.WithContext(context.WithValue(r.Context(), clientAddressContextKey, address))
+	}
+
+	t.Run("per user", func(t *testing.T) {
+		p := &Plugin{}
+		p.setConfiguration(&configuration{UserRateLimit: "1"})
+
+		w, called := serveTestRequest(p.withUserRateLimit, newRequest("user1", "10.0.0.1"))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "user1", called)
+
+		w, called = serveTestRequest(p.withUserRateLimit, newRequest("user1", "10.0.0.2"))
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, apiErrorTooManyRequests, getTestAPIError(t, w))
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+		assert.Empty(t, called)
+
+		w, _ = serveTestRequest(p.withUserRateLimit, newRequest("user2", "10.0.0.1"))
+		assert.Equal(t, http.StatusOK, w.Code, "other users have their own limit")
+	})
+
+	t.Run("per address", func(t *testing.T) {
+		p := &Plugin{}
+		p.setConfiguration(&configuration{AddressRateLimit: "2"})
+
+		for _, userID := range []string{"user1", "user2"} {
+			w, _ := serveTestRequest(p.withAddressRateLimit, newRequest(userID, "10.0.0.1"))
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+
+		w, called := serveTestRequest(p.withAddressRateLimit, newRequest("user3", "10.0.0.1"))
+		assert.Equal(t, http.StatusTooManyRequests, w.Code, "users share the limit of their address")
+		assert.Empty(t, called)
+
+		w, _ = serveTestRequest(p.withAddressRateLimit, newRequest("user3", "10.0.0.2"))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("address before user", func(t *testing.T) {
+		p := &Plugin{}
+		p.setConfiguration(&configuration{UserRateLimit: "2", AddressRateLimit: "1"})
+		limits := func(next http.Handler) http.Handler {
+			return p.withAddressRateLimit(p.withUserRateLimit(next))
+		}
+
+		w, _ := serveTestRequest(limits, newRequest("user1", "10.0.0.1"))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w, _ = serveTestRequest(limits, newRequest("user1", "10.0.0.1"))
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+		w, _ = serveTestRequest(limits, newRequest("user1", "10.0.0.2"))
+		assert.Equal(t, http.StatusOK, w.Code, "the refused request was only counted against its address")
+	})
+
+	t.Run("failed webhook authentication", func(t *testing.T) {
+		p := &Plugin{}
+		p.setConfiguration(&configuration{WebhookToken: "token", AddressRateLimit: "1"})
+		webhook := func(next http.Handler) http.Handler {
+			return p.withAddressRateLimit(p.withWebhookAuth(next))
+		}
+
+		r := newRequest("", "10.0.0.1")
+		r.Header.Set(webhookTokenHeader, "guess")
+
+		w, _ := serveTestRequest(webhook, r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w, _ = serveTestRequest(webhook, r)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code, "guesses count against the limit of their address")
+	})
+}
+
+func TestGetGoWithoutReadPermission(t *testing.T) {
+	userID := model.NewId()
+	post := &model.Post{Id: model.NewId(), ChannelId: model.NewId(), Message: "안녕하세요"}
+
+	api := &plugintest.API{}
+	api.On("GetPost", post.Id).Return(post, nil)
+	api.On("HasPermissionToChannel", userID, post.ChannelId, model.PERMISSION_READ_CHANNEL).Return(false)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	p.setConfiguration(&configuration{})
+
+	r := httptest.NewRequest(http.MethodGet, "/api/go?post_id="+post.Id+"&source=ko&target=en", nil)
+	r.Header.Set("Mattermost-User-ID", userID)
+	w := httptest.NewRecorder()
+	p.getGo(w, r)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, apiErrorForbidden, getTestAPIError(t, w))
+	assert.NotContains(t, w.Body.String(), post.Message)
+	api.AssertExpectations(t)
+}
+
+func TestServeHTTPHealthWithoutSession(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	p.setConfiguration(&configuration{AWSAccessKeyID: "AKIAEXAMPLE"})
+	p.router = p.initializeRouter()
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(&plugin.Context{}, w, httptest.NewRequest(http.MethodGet, "/api/v1/health", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	var health HealthResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &health))
+	assert.Equal(t, healthStatusUnavailable, health.Status)
+	assert.False(t, health.ConfigurationValid)
+	assert.Nil(t, health.Provider)
+	assert.False(t, strings.Contains(w.Body.String(), "AWS"), "the configuration error isn't reported to anonymous clients")
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/service/translate"
+	"github.com/pkg/errors"
+)
+
+const (
+	// providerRouteAny matches any language in a provider route.
+	providerRouteAny = "*"
+
+	defaultLongMessageThreshold = 1000
+)
+
+// providerRoute routes the translations from a source to a target language to a provider.
+type providerRoute struct {
+	source   string
+	target   string
+	provider string
+}
+
+func (r *providerRoute) matches(source, target string) bool {
+	return (r.source == providerRouteAny || r.source == source) && (r.target == providerRouteAny || r.target == target)
+}
+
+// messageSizeContextKey holds the number of characters of the whole message being translated, so
+// that the parts of a message translated separately go to the same provider.
+type messageSizeContextKey struct{}
+
+// newMessageSizeContext returns a context carrying the size of the message being translated,
+// unless ctx already carries the size of a message the text is part of.
+func newMessageSizeContext(ctx context.Context, text string) context.Context {
+	if getContextMessageSize(ctx) > 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, messageSizeContextKey{}, utf8.RuneCountInString(text))
+}
+
+func getContextMessageSize(ctx context.Context) int {
+	size, _ := ctx.Value(messageSizeContextKey{}).(int)
+	return size
+}
+
+// providerContextKey holds the provider forced for a request, which no route overrides.
+type providerContextKey struct{}
+
+func newProviderContext(ctx context.Context, provider string) context.Context {
+	return context.WithValue(ctx, providerContextKey{}, provider)
+}
+
+func getContextProvider(ctx context.Context) string {
+	provider, _ := ctx.Value(providerContextKey{}).(string)
+	return provider
+}
+
+// parseProviderRoutes parses provider routes, one per line such as ko:ja=aws or *:ja=aws, * matching
+// any language. Routes may only name the providers of translationProviders.
+func parseProviderRoutes(value string) ([]*providerRoute, error) {
+	var routes []*providerRoute
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		languages := strings.SplitN(parts[0], ":", 2)
+		if len(parts) != 2 || len(languages) != 2 {
+			return nil, fmt.Errorf("Provider route %s must be a source and a target language and a provider such as ko:ja=aws", line)
+		}
+
+		route := &providerRoute{provider: strings.ToLower(strings.TrimSpace(parts[1]))}
+		for i, language := range languages {
+			language = strings.TrimSpace(language)
+			if language != providerRouteAny {
+				if language = normalizeLanguageCode(language); language == "" {
+					return nil, fmt.Errorf("Provider route %s must have supported languages or *", line)
+				}
+			}
+
+			if i == 0 {
+				route.source = language
+			} else {
+				route.target = language
+			}
+		}
+
+		if _, ok := translationProviders[route.provider]; !ok {
+			return nil, fmt.Errorf("Provider route %s must have a known provider", line)
+		}
+
+		for _, language := range []string{route.source, route.target} {
+			if language != providerRouteAny && !supportsLanguagePair(route.provider, language, language) {
+				return nil, fmt.Errorf("Provider route %s must have languages supported by its provider", line)
+			}
+		}
+
+		routes = append(routes, route)
+	}
+
+	return routes, nil
+}
+
+// getRoutedProvider returns the provider of the first route matching a language pair. The
+// provider learned from the ratings of the pair, if any, is preferred over routes with *, and
+// Amazon Translate translates the pairs nothing routes.
+func getRoutedProvider(routes []*providerRoute, learned map[string]string, source, target string) string {
+	provider := providerAWS
+	for _, route := range routes {
+		if !route.matches(source, target) {
+			continue
+		}
+		if route.source != providerRouteAny && route.target != providerRouteAny {
+			return route.provider
+		}
+
+		provider = route.provider
+		break
+	}
+
+	if learnedProvider, ok := learned[getLanguagePairRouteKey(source, target)]; ok {
+		return learnedProvider
+	}
+
+	return provider
+}
+
+// getPairProvider returns the provider the translation of a post of the given number of characters
+// in a language pair goes to when no provider is forced. Long messages go to the provider set for
+// them, and the canary provider takes its share of the other posts from the routed ones, all of
+// them falling back to the offline provider while the provider is unreachable.
+func (p *Plugin) getPairProvider(postID, source, target string, characters int) string {
+	config := p.getConfiguration()
+	provider := config.LongMessageProvider
+	if provider == "" || characters < config.getLongMessageThreshold() {
+		provider = p.getCanaryProvider(postID)
+	}
+	if provider == "" {
+		provider = getRoutedProvider(config.getProviderRoutes(), p.getLearnedRoutes(), source, target)
+	}
+
+	// Only the providers beyond the network fall back to the offline provider.
+	if !config.isLocalProvider(provider) && p.isOffline() {
+		return providerOffline
+	}
+
+	return provider
+}
+
+// getRoutedService returns the provider translating from source to target along with its client,
+// evaluated before each translation of text: the provider forced for the request, or else the
+// provider of long messages, the canary provider or the one the provider routes or the ratings of
+// the language pair send it to. svc is the client of Amazon Translate.
+func (p *Plugin) getRoutedService(ctx context.Context, svc *translate.Translate, source, target, text string) (string, *translate.Translate, error) {
+	provider := getContextProvider(ctx)
+	if provider == "" {
+		var postID string
+		if subject := getAuditSubject(ctx); subject != nil {
+			postID = subject.postID
+		}
+		characters := getContextMessageSize(ctx)
+		if characters == 0 {
+			characters = utf8.RuneCountInString(text)
+		}
+		provider = p.getPairProvider(postID, source, target, characters)
+	}
+	if provider == providerAWS {
+		return provider, svc, nil
+	}
+
+	getService, ok := translationProviders[provider]
+	if !ok {
+		return provider, nil, errors.Errorf("unknown translation provider %s", provider)
+	}
+
+	routed, err := getService(p)
+	if err != nil {
+		return provider, nil, err
+	}
+
+	return provider, routed, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProviderRoutes(t *testing.T) {
+	routes, err := parseProviderRoutes("")
+	require.NoError(t, err)
+	assert.Empty(t, routes)
+
+	routes, err = parseProviderRoutes(" ko : ja = AWS \n\n*:zh_tw=aws\n")
+	require.NoError(t, err)
+	assert.Equal(t, []*providerRoute{
+		{source: "ko", target: "ja", provider: providerAWS},
+		{source: providerRouteAny, target: "zh-TW", provider: providerAWS},
+	}, routes)
+
+	for _, value := range []string{
+		"ko:ja",
+		"ko=aws",
+		"ko:xx=aws",
+		"ko:ja=papago",
+	} {
+		_, err = parseProviderRoutes(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestGetRoutedProvider(t *testing.T) {
+	routes := []*providerRoute{
+		{source: "ko", target: "ja", provider: "papago"},
+		{source: providerRouteAny, target: "de", provider: "deepl"},
+		{source: providerRouteAny, target: providerRouteAny, provider: "llm"},
+	}
+
+	assert.Equal(t, "papago", getRoutedProvider(routes, nil, "ko", "ja"))
+	assert.Equal(t, "deepl", getRoutedProvider(routes, nil, "fr", "de"))
+	assert.Equal(t, "llm", getRoutedProvider(routes, nil, "ja", "ko"))
+	assert.Equal(t, providerAWS, getRoutedProvider(routes[:2], nil, "ja", "ko"))
+	assert.Equal(t, providerAWS, getRoutedProvider(nil, nil, "ko", "ja"))
+
+	// Learned providers are preferred over routes with *, but not over routes of the pair.
+	learned := map[string]string{"ko:ja": "llm", "fr:de": "llm", "ja:ko": "papago"}
+	assert.Equal(t, "papago", getRoutedProvider(routes, learned, "ko", "ja"))
+	assert.Equal(t, "llm", getRoutedProvider(routes, learned, "fr", "de"))
+	assert.Equal(t, "papago", getRoutedProvider(routes, learned, "ja", "ko"))
+	assert.Equal(t, "papago", getRoutedProvider(nil, learned, "ja", "ko"))
+}
+
+func TestProviderContext(t *testing.T) {
+	assert.Equal(t, "", getContextProvider(context.Background()))
+	assert.Equal(t, providerAWS, getContextProvider(newProviderContext(context.Background(), providerAWS)))
+}
+
+func TestMessageSizeContext(t *testing.T) {
+	assert.Equal(t, 0, getContextMessageSize(context.Background()))
+
+	ctx := newMessageSizeContext(context.Background(), "こんにちは")
+	assert.Equal(t, 5, getContextMessageSize(ctx))
+
+	// Parts of a message keep the size of the whole message.
+	assert.Equal(t, 5, getContextMessageSize(newMessageSizeContext(ctx, "こん")))
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Prefixes of the settings referring to a secret kept outside of the plugin settings, such as
+	// env:AUTOTRANSLATE_AWS_SECRET, awssm:prod/autotranslate#secret_access_key or
+	// vault:secret/data/autotranslate#secret_access_key.
+	secretRefEnv               = "env:"
+	secretRefAWSSecretsManager = "awssm:"
+	secretRefVault             = "vault:"
+
+	// Environment variables of the server telling where Vault is and how to authenticate with it.
+	vaultAddrEnv  = "VAULT_ADDR"
+	vaultTokenEnv = "VAULT_TOKEN"
+
+	// secretResolveTimeout bounds the time taken to read a secret from a secret store.
+	secretResolveTimeout = 10 * time.Second
+)
+
+// resolveSecrets replaces the AWS credentials, the AWS client key, the encryption key and the
+// webhook signing secrets referring to a secret kept outside of the plugin settings with the
+// secret itself, which is only kept in memory. Failures are kept to be reported by IsValid, as the
+// secrets can't be used then.
+func (c *configuration) resolveSecrets() {
+	ctx, cancel := context.WithTimeout(context.Background(), secretResolveTimeout)
+	defer cancel()
+
+	region := c.AWSRegion
+	if region == "" {
+		region = defaultAWSRegion
+	}
+
+	// Secret stores are reached through the outbound proxy like the provider.
+	client, err := newOutboundHTTPClient(c)
+	if err != nil {
+		c.secretsErr = err
+		return
+	}
+
+	for name, value := range map[string]*string{
+		"AWS Access Key ID":       &c.AWSAccessKeyID,
+		"AWS Secret Access Key":   &c.AWSSecretAccessKey,
+		"AWS Client Key":          &c.AWSClientKey,
+		"Encryption Key":          &c.EncryptionKey,
+		"Webhook Signing Secrets": &c.WebhookSigningSecrets,
+	} {
+		secret, err := resolveSecret(ctx, client, *value, region)
+		if err != nil {
+			c.secretsErr = errors.Wrapf(err, "unable to read the %s", name)
+			return
+		}
+		*value = secret
+	}
+}
+
+// resolveSecret returns the secret a setting refers to, or the setting itself when it refers to
+// none. Secrets of Secrets Manager and Vault holding several values, such as JSON secrets, name the
+// value to return after a #.
+func resolveSecret(ctx context.Context, client *http.Client, value, region string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, secretRefEnv):
+		name := strings.TrimPrefix(value, secretRefEnv)
+		secret, ok := os.LookupEnv(name)
+		if !ok || secret == "" {
+			return "", errors.Errorf("environment variable %s is not set", name)
+		}
+		return secret, nil
+
+	case strings.HasPrefix(value, secretRefAWSSecretsManager):
+		id, key := splitSecretRef(strings.TrimPrefix(value, secretRefAWSSecretsManager))
+		return getAWSSecret(ctx, client, region, id, key)
+
+	case strings.HasPrefix(value, secretRefVault):
+		path, key := splitSecretRef(strings.TrimPrefix(value, secretRefVault))
+		return getVaultSecret(ctx, client, path, key)
+	}
+
+	return value, nil
+}
+
+// splitSecretRef splits a reference to a secret into the ID of the secret and the name of the
+// value to return, which is empty when not given.
+func splitSecretRef(ref string) (string, string) {
+	if i := strings.LastIndex(ref, "#"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+
+	return ref, ""
+}
+
+// getAWSSecret reads a secret of AWS Secrets Manager, authenticating with the default credentials
+// of the server, such as the role of its instance, as the plugin credentials may be the secret.
+func getAWSSecret(ctx context.Context, client *http.Client, region, id, key string) (string, error) {
+	sess, err := session.NewSession(aws.NewConfig().WithRegion(region).WithHTTPClient(client))
+	if err != nil {
+		return "", errors.Wrap(err, "unable to create AWS session")
+	}
+
+	output, err := secretsmanager.New(sess).GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(id)})
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get secret %s from AWS Secrets Manager", id)
+	}
+	if output.SecretString == nil {
+		return "", errors.Errorf("secret %s of AWS Secrets Manager is not a string", id)
+	}
+
+	if key == "" {
+		return *output.SecretString, nil
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(*output.SecretString), &values); err != nil {
+		return "", errors.Wrapf(err, "secret %s of AWS Secrets Manager is not JSON", id)
+	}
+
+	return getSecretValue(values, id, key)
+}
+
+// getVaultSecret reads a secret of a Vault KV secrets engine at the address and with the token of
+// the VAULT_ADDR and VAULT_TOKEN environment variables. Both versions of the engine are supported,
+// the path of version 2 secrets holding data/ such as secret/data/autotranslate.
+func getVaultSecret(ctx context.Context, client *http.Client, path, key string) (string, error) {
+	if key == "" {
+		return "", errors.Errorf("no value named after # in the reference to Vault secret %s", path)
+	}
+
+	addr := os.Getenv(vaultAddrEnv)
+	if addr == "" {
+		return "", errors.Errorf("environment variable %s is not set", vaultAddrEnv)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(addr, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid Vault address")
+	}
+	req.Header.Set("X-Vault-Token", os.Getenv(vaultTokenEnv))
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", errors.Wrap(err, "unable to reach Vault")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("unable to get secret %s from Vault: %s", path, resp.Status)
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", errors.Wrapf(err, "unable to decode secret %s of Vault", path)
+	}
+
+	// Version 2 of the KV secrets engine nests the values along with their metadata.
+	values := secret.Data
+	if data, ok := values["data"].(map[string]interface{}); ok {
+		if _, ok := values["metadata"]; ok {
+			values = data
+		}
+	}
+
+	return getSecretValue(values, path, key)
+}
+
+func getSecretValue(values map[string]interface{}, id, key string) (string, error) {
+	value, ok := values[key].(string)
+	if !ok || value == "" {
+		return "", errors.Errorf("secret %s has no value named %s", id, key)
+	}
+
+	return value, nil
+}
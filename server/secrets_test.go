@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSecret(t *testing.T) {
+	ctx := context.Background()
+
+	secret, err := resolveSecret(ctx, http.DefaultClient, "AKIAEXAMPLE", "")
+	require.NoError(t, err)
+	assert.Equal(t, "AKIAEXAMPLE", secret)
+
+	os.Setenv("AUTOTRANSLATE_TEST_SECRET", "from env")
+	defer os.Unsetenv("AUTOTRANSLATE_TEST_SECRET")
+	secret, err = resolveSecret(ctx, http.DefaultClient, "env:AUTOTRANSLATE_TEST_SECRET", "")
+	require.NoError(t, err)
+	assert.Equal(t, "from env", secret)
+
+	_, err = resolveSecret(ctx, http.DefaultClient, "env:AUTOTRANSLATE_TEST_MISSING", "")
+	assert.Error(t, err)
+}
+
+func TestResolveVaultSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/autotranslate":
+			w.Write([]byte(`{"data": {"data": {"secret_access_key": "v2 secret"}, "metadata": {"version": 3}}}`))
+		case "/v1/kv/autotranslate":
+			w.Write([]byte(`{"data": {"secret_access_key": "v1 secret"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	os.Setenv(vaultAddrEnv, server.URL)
+	os.Setenv(vaultTokenEnv, "token")
+	defer os.Unsetenv(vaultAddrEnv)
+	defer os.Unsetenv(vaultTokenEnv)
+
+	ctx := context.Background()
+	secret, err := resolveSecret(ctx, http.DefaultClient, "vault:secret/data/autotranslate#secret_access_key", "")
+	require.NoError(t, err)
+	assert.Equal(t, "v2 secret", secret)
+
+	secret, err = resolveSecret(ctx, http.DefaultClient, "vault:kv/autotranslate#secret_access_key", "")
+	require.NoError(t, err)
+	assert.Equal(t, "v1 secret", secret)
+
+	for _, ref := range []string{
+		"vault:kv/autotranslate",
+		"vault:kv/autotranslate#access_key_id",
+		"vault:kv/missing#secret_access_key",
+	} {
+		_, err = resolveSecret(ctx, http.DefaultClient, ref, "")
+		assert.Error(t, err, ref)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	defaultSlowTranslationThreshold = 5
+
+	slowTranslationMessage = "Your translation is taking longer than usual, it will show up as soon as the translation provider answers."
+)
+
+// watchSlowTranslation watches a request to the provider from the moment it is sent, returning the
+// function to call once it is answered. Requests lasting longer than the slow translation threshold
+// are logged with a warning, and the user who asked for the translation is told as soon as the
+// threshold is reached when admins turned it on, so that translations don't look silently broken.
+func (p *Plugin) watchSlowTranslation(ctx context.Context, provider string) func() {
+	config := p.getConfiguration()
+	threshold := config.getSlowTranslationThreshold()
+	if threshold <= 0 {
+		return func() {}
+	}
+
+	start := time.Now()
+	var timer *time.Timer
+	if subject := getAuditSubject(ctx); config.NotifySlowTranslations && subject != nil && subject.userID != "" && subject.channelID != "" {
+		timer = time.AfterFunc(threshold, func() {
+			p.notifySlowTranslation(subject.userID, subject.channelID, subject.postID)
+		})
+	}
+
+	return func() {
+		if timer != nil {
+			timer.Stop()
+		}
+
+		if latency := time.Since(start); latency >= threshold {
+			p.API.LogWarn("Translation provider request was slow", "request_id", getRequestID(ctx), "provider", provider, "duration", latency.String(), "threshold", threshold.String())
+		}
+	}
+}
+
+// notifySlowTranslation tells a user that their translation is taking longer than usual, in the
+// thread of the post being translated when it is a reply.
+func (p *Plugin) notifySlowTranslation(userID, channelID, postID string) {
+	var rootID string
+	if postID != "" {
+		if post, appErr := p.API.GetPost(postID); appErr == nil {
+			rootID = post.RootId
+		}
+	}
+
+	p.API.SendEphemeralPost(userID, &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: channelID,
+		RootId:    rootID,
+		ParentId:  rootID,
+		Message:   slowTranslationMessage,
+	})
+}
@@ -0,0 +1,655 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// apiSchemas are the types described in the components of the API specification, keyed by name.
+var apiSchemas = map[string]reflect.Type{
+	"APIErrorResponse":           reflect.TypeOf(APIErrorResponse{}),
+	"AuditEntry":                 reflect.TypeOf(AuditEntry{}),
+	"AuditLogResponse":           reflect.TypeOf(AuditLogResponse{}),
+	"CacheFlushResponse":         reflect.TypeOf(CacheFlushResponse{}),
+	"ChannelLanguageStats":       reflect.TypeOf(ChannelLanguageStats{}),
+	"ConsentStatus":              reflect.TypeOf(ConsentStatus{}),
+	"DetectRequest":              reflect.TypeOf(DetectRequest{}),
+	"DetectResponse":             reflect.TypeOf(DetectResponse{}),
+	"Diagnostics":                reflect.TypeOf(Diagnostics{}),
+	"DiagnosticsQueues":          reflect.TypeOf(DiagnosticsQueues{}),
+	"DiagnosticsVersions":        reflect.TypeOf(DiagnosticsVersions{}),
+	"Glossary":                   reflect.TypeOf(Glossary{}),
+	"GlossaryTerm":               reflect.TypeOf(GlossaryTerm{}),
+	"HealthResponse":             reflect.TypeOf(HealthResponse{}),
+	"Language":                   reflect.TypeOf(Language{}),
+	"LanguagePairStats":          reflect.TypeOf(LanguagePairStats{}),
+	"LanguageShare":              reflect.TypeOf(LanguageShare{}),
+	"LanguagesResponse":          reflect.TypeOf(LanguagesResponse{}),
+	"LearnedRoute":               reflect.TypeOf(LearnedRoute{}),
+	"LearnedRoutingReport":       reflect.TypeOf(LearnedRoutingReport{}),
+	"Leaderboard":                reflect.TypeOf(Leaderboard{}),
+	"LeaderboardEntry":           reflect.TypeOf(LeaderboardEntry{}),
+	"ProviderProbe":              reflect.TypeOf(ProviderProbe{}),
+	"ProviderScore":              reflect.TypeOf(ProviderScore{}),
+	"ProviderStats":              reflect.TypeOf(ProviderStats{}),
+	"RecentError":                reflect.TypeOf(RecentError{}),
+	"SpendReport":                reflect.TypeOf(SpendReport{}),
+	"TeamSpend":                  reflect.TypeOf(TeamSpend{}),
+	"TranslatedMessage":          reflect.TypeOf(TranslatedMessage{}),
+	"TranslationHistoryEntry":    reflect.TypeOf(TranslationHistoryEntry{}),
+	"TranslationHistoryResponse": reflect.TypeOf(TranslationHistoryResponse{}),
+	"TranslationMapping":         reflect.TypeOf(TranslationMapping{}),
+	"TranslationStream":          reflect.TypeOf(TranslationStream{}),
+	"UsageStatsReport":           reflect.TypeOf(UsageStatsReport{}),
+	"UserDataDeleteResponse":     reflect.TypeOf(UserDataDeleteResponse{}),
+	"UserInfo":                   reflect.TypeOf(UserInfo{}),
+	"WebhookRequest":             reflect.TypeOf(WebhookRequest{}),
+	"WebhookResponse":            reflect.TypeOf(WebhookResponse{}),
+}
+
+// apiParameter is a path or query parameter of an operation.
+type apiParameter struct {
+	name        string
+	in          string
+	description string
+	required    bool
+}
+
+// apiOperation describes an endpoint of the HTTP API. Request and response bodies name a schema
+// of apiSchemas, prefixed with "[]" for an array of it, the response coming with status 200 unless
+// another one is given. Operations accept the session of a user or the shared secret unless they
+// name another security scheme, or securityNone when public.
+type apiOperation struct {
+	method     string
+	path       string
+	summary    string
+	parameters []apiParameter
+	request    string
+	response   string
+	status     int
+	adminOnly  bool
+	security   string
+
+	// textFormats tells whether the response may also be plain text or Markdown.
+	textFormats bool
+}
+
+const (
+	securityNone         = "none"
+	securityWebhookToken = "webhookToken"
+	securityPlugin       = "plugin"
+)
+
+// apiOperations are the operations of the versioned HTTP API. Every route of the router under
+// /api/v1 must be described here.
+var apiOperations = []apiOperation{
+	{
+		method:   http.MethodGet,
+		path:     "/api/v1/health",
+		summary:  "Report the health of the plugin, answering with status 503 when messages can't be translated. Errors are left out, system admins finding them in the diagnostics.",
+		response: "HealthResponse",
+		security: securityNone,
+	},
+	{
+		method:   http.MethodGet,
+		path:     "/api/v1/spec",
+		summary:  "Get this OpenAPI document.",
+		security: securityNone,
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/api/v1/posts/{post_id}/translation",
+		summary: "Translate the message of a post.",
+		parameters: []apiParameter{
+			{name: "post_id", in: "path", description: "ID of the post to translate.", required: true},
+			{name: "source", in: "query", description: "Language code of the message, or auto to detect it.", required: true},
+			{name: "target", in: "query", description: "Language code to translate the message into.", required: true},
+			{name: "format", in: "query", description: "Set to text for only the translated text, or markdown for the translated text below a header naming the languages. Also chosen with the Accept header, JSON being the default."},
+			{name: "provider", in: "query", description: "Name of the provider to translate with, such as aws, skipping the cached translations. System admins only."},
+		},
+		response:    "TranslatedMessage",
+		textFormats: true,
+	},
+	{
+		method:  http.MethodPost,
+		path:    "/api/v1/posts/{post_id}/translation/stream",
+		summary: "Translate the message of a post part by part, answering with status 202 right away. Each part is then sent to the current user as a custom_autotranslate_translation_chunk websocket event with the stream_id, the index, the separator joining it to the previous part and its text, the last event being marked done with the whole translated_text or an error.",
+		parameters: []apiParameter{
+			{name: "post_id", in: "path", description: "ID of the post to translate.", required: true},
+			{name: "source", in: "query", description: "Language code of the message, auto by default."},
+			{name: "target", in: "query", description: "Language code to translate the message into.", required: true},
+		},
+		response: "TranslationStream",
+		status:   http.StatusAccepted,
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/api/v1/translation/{post_id}",
+		summary: "Get the stored translation of a post the current user can read, without translating it again unless requested. Answers with status 404 when there is none.",
+		parameters: []apiParameter{
+			{name: "post_id", in: "path", description: "ID of the translated post.", required: true},
+			{name: "target", in: "query", description: "Language code of the translation.", required: true},
+			{name: "translate", in: "query", description: "Set to true to translate the post when it has no translation yet."},
+			{name: "source", in: "query", description: "Language code of the message when translating it, auto by default."},
+		},
+		response: "TranslatedMessage",
+	},
+	{
+		method:   http.MethodGet,
+		path:     "/api/v1/info",
+		summary:  "Get the autotranslation settings of the current user, answering no_record_found when they have none.",
+		response: "UserInfo",
+	},
+	{
+		method:   http.MethodPost,
+		path:     "/api/v1/info",
+		summary:  "Save the autotranslation settings of the current user.",
+		request:  "UserInfo",
+		response: "UserInfo",
+	},
+	{
+		method:   http.MethodDelete,
+		path:     "/api/v1/info",
+		summary:  "Delete the data the plugin keeps about the current user: autotranslation settings, language profile, translation history, consent, translation ratings and the stored translations of their posts, removing the user from the audit log as well.",
+		response: "UserDataDeleteResponse",
+	},
+	{
+		method:   http.MethodGet,
+		path:     "/api/v1/consent",
+		summary:  "Tell whether the current user must consent to their messages being sent to the translation provider, and whether they did.",
+		response: "ConsentStatus",
+	},
+	{
+		method:  http.MethodPost,
+		path:    "/api/v1/consent",
+		summary: "Record the consent of the current user, taking the submission of the consent dialog as body with its agree element set to true.",
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/api/v1/history",
+		summary: "List the recent translations made for the current user, newest first.",
+		parameters: []apiParameter{
+			{name: "page", in: "query", description: "Page to list, starting at 0."},
+			{name: "per_page", in: "query", description: "Number of translations per page, 20 by default and 100 at most."},
+		},
+		response: "TranslationHistoryResponse",
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/api/v1/languages",
+		summary: "List the languages supported by a translation provider, to tell which language pairs it translates before asking. Translations of pairs their provider doesn't support fail with unsupported_language_pair, naming the providers to try instead.",
+		parameters: []apiParameter{
+			{name: "provider", in: "query", description: "Provider whose languages to list, aws by default."},
+		},
+		response: "LanguagesResponse",
+	},
+	{
+		method:   http.MethodPost,
+		path:     "/api/v1/detect",
+		summary:  "Detect the language of a text or of a post the current user can read with the configured language detector.",
+		request:  "DetectRequest",
+		response: "DetectResponse",
+	},
+	{
+		method:  http.MethodPost,
+		path:    "/api/v1/actions/{action}",
+		summary: "Answer the buttons of translation posts, taking the integration request of a post action as body.",
+		parameters: []apiParameter{
+			{name: "action", in: "path", description: "One of show_original, show_translation, feedback_good, feedback_bad, retry, translate_rest or consent.", required: true},
+		},
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/api/v1/stats",
+		summary: "Report the usage of the translation providers per day. System admins only.",
+		parameters: []apiParameter{
+			{name: "days", in: "query", description: "Number of days up to today to report, 7 by default and 90 at most."},
+		},
+		response:  "[]UsageStatsReport",
+		adminOnly: true,
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/api/v1/stats/languages",
+		summary: "Report the distribution of the languages detected in the messages of users per channel, channels with the most messages first, to tell where translation is needed. System admins only.",
+		parameters: []apiParameter{
+			{name: "days", in: "query", description: "Number of days up to today to report, 7 by default and 90 at most."},
+			{name: "channel_id", in: "query", description: "Only report the channel with this ID."},
+		},
+		response:  "[]ChannelLanguageStats",
+		adminOnly: true,
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/api/v1/stats/pairs",
+		summary: "Report the latency of the translations of each language pair along with the ratings users gave them, the pairs with the most requests first, to tell which pairs are slow or poorly translated. System admins only.",
+		parameters: []apiParameter{
+			{name: "days", in: "query", description: "Number of days up to today to report, 7 by default and 90 at most."},
+		},
+		response:  "[]LanguagePairStats",
+		adminOnly: true,
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/api/v1/stats/providers",
+		summary: "Compare the latency of the requests to each provider across language pairs and the ratings of their translations over the last days, such as during the canary rollout of a provider, which is flagged. System admins only.",
+		parameters: []apiParameter{
+			{name: "days", in: "query", description: "Number of days up to today to report, 7 by default and 90 at most."},
+		},
+		response:  "[]ProviderStats",
+		adminOnly: true,
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/api/v1/stats/spend",
+		summary: "Report the characters translated during a month and their estimated cost per team and provider, with the prices set by admins, the teams spending the most first. System admins only.",
+		parameters: []apiParameter{
+			{name: "month", in: "query", description: "Month to report as YYYY-MM, the current one by default."},
+		},
+		response:  "SpendReport",
+		adminOnly: true,
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/api/v1/stats/export",
+		summary: "Export the daily usage of the translation providers as CSV, one row per provider and day with the requests, errors, characters, estimated cost and latency, for chargeback and capacity planning spreadsheets. System admins only.",
+		parameters: []apiParameter{
+			{name: "from", in: "query", description: "First day to export as YYYY-MM-DD, the first day of the month of to by default."},
+			{name: "to", in: "query", description: "Last day to export as YYYY-MM-DD, today by default. The range spans 366 days at most."},
+		},
+		adminOnly: true,
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/api/v1/stats/leaderboard",
+		summary: "Report the channels and users with the most characters translated over the last days, to tell where channel translation or a dedicated glossary would pay off. Users are the ones translations are made for. System admins only.",
+		parameters: []apiParameter{
+			{name: "days", in: "query", description: "Number of days up to today to report, 7 by default and 90 at most."},
+			{name: "limit", in: "query", description: "Number of channels and of users to report, 10 by default and 100 at most."},
+		},
+		response:  "Leaderboard",
+		adminOnly: true,
+	},
+	{
+		method:    http.MethodGet,
+		path:      "/api/v1/routing/learned",
+		summary:   "Report the provider preferred for each language pair from the ratings of its translations over the last 30 days, among the providers with at least Quality Routing Minimum Ratings ratings, along with their scores. Reported even when quality routing is off, to preview it. System admins only.",
+		response:  "LearnedRoutingReport",
+		adminOnly: true,
+	},
+	{
+		method:    http.MethodGet,
+		path:      "/api/v1/metrics",
+		summary:   "Report the requests, errors, characters and latency of the translation providers, the cache hits and misses and the posts waiting to be translated on this server since the plugin started, in the Prometheus text format. Also served at /plugins/autotranslate/metrics. System admins only.",
+		adminOnly: true,
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/api/v1/audit",
+		summary: "List the requests to the provider recorded in the audit log, newest first, with the user and post they were made for but without any text. Requests are only recorded while the audit log is enabled. System admins only.",
+		parameters: []apiParameter{
+			{name: "days", in: "query", description: "Number of days up to now to list, 1 by default and 31 at most."},
+			{name: "user_id", in: "query", description: "Only list the requests made for the user with this ID."},
+			{name: "post_id", in: "query", description: "Only list the requests made for the post with this ID."},
+			{name: "page", in: "query", description: "Page to list, starting at 0."},
+			{name: "per_page", in: "query", description: "Number of entries per page, 50 by default and 500 at most."},
+		},
+		response:  "AuditLogResponse",
+		adminOnly: true,
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/api/v1/audit/export",
+		summary: "Export the requests to the provider recorded in the audit log as CSV, oldest first, without any text. Requests older than the audit log retention are gone. System admins only.",
+		parameters: []apiParameter{
+			{name: "from", in: "query", description: "First day to export as YYYY-MM-DD, the first day of the month of to by default."},
+			{name: "to", in: "query", description: "Last day to export as YYYY-MM-DD, today by default. The range spans 366 days at most."},
+		},
+		adminOnly: true,
+	},
+	{
+		method:  http.MethodDelete,
+		path:    "/api/v1/users/{user_id}/data",
+		summary: "Delete the data the plugin keeps about a user like DELETE /api/v1/info, such as on their request for erasure. System admins only.",
+		parameters: []apiParameter{
+			{name: "user_id", in: "path", description: "ID of the user.", required: true},
+		},
+		response:  "UserDataDeleteResponse",
+		adminOnly: true,
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/api/v1/compliance/mappings",
+		summary: "List the translations of the posts of a channel created or updated in a period, except deleted ones, with the original post each one translates, for compliance exports and legal requests. Translations delivered in the original post have no translation_post_id. System admins only.",
+		parameters: []apiParameter{
+			{name: "channel_id", in: "query", description: "ID of the channel.", required: true},
+			{name: "since", in: "query", description: "Time in milliseconds the period starts at, the creation of the channel by default."},
+			{name: "until", in: "query", description: "Time in milliseconds the period ends at, now by default."},
+			{name: "format", in: "query", description: "Either json, the default, or csv."},
+		},
+		response:  "[]TranslationMapping",
+		adminOnly: true,
+	},
+	{
+		method:    http.MethodPost,
+		path:      "/api/v1/cache/flush",
+		summary:   "Delete the cached translations and the state kept about the provider, such as after changing it. System admins only.",
+		response:  "CacheFlushResponse",
+		adminOnly: true,
+	},
+	{
+		method:    http.MethodPost,
+		path:      "/api/v1/provider/reload",
+		summary:   "Apply the provider configuration, such as new credentials or endpoint, right away and check the provider with it, reading credentials kept in secret stores again. The previous configuration is kept when the new one is invalid. System admins only.",
+		response:  "ProviderProbe",
+		adminOnly: true,
+	},
+	{
+		method:    http.MethodGet,
+		path:      "/api/v1/diagnostics",
+		summary:   "Download the diagnostics of this server to attach to support issues: the versions, the effective configuration without its secrets, the provider health, the last errors of the provider and the work waiting to be done. System admins only.",
+		response:  "Diagnostics",
+		adminOnly: true,
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/api/v1/glossaries/{scope}/{id}",
+		summary: "Get the glossary of a team or channel the current user is a member of.",
+		parameters: []apiParameter{
+			{name: "scope", in: "path", description: "Either team or channel.", required: true},
+			{name: "id", in: "path", description: "ID of the team or channel.", required: true},
+		},
+		response: "Glossary",
+	},
+	{
+		method:  http.MethodPut,
+		path:    "/api/v1/glossaries/{scope}/{id}",
+		summary: "Replace the glossary of a team or channel, such as to sync it from a terminology management system. Team or channel admins only.",
+		parameters: []apiParameter{
+			{name: "scope", in: "path", description: "Either team or channel.", required: true},
+			{name: "id", in: "path", description: "ID of the team or channel.", required: true},
+		},
+		request:  "Glossary",
+		response: "Glossary",
+	},
+	{
+		method:  http.MethodPost,
+		path:    "/api/v1/glossaries/{scope}/{id}/terms",
+		summary: "Add a term to the glossary of a team or channel, replacing the translations of the term if it is in it already. Team or channel admins only.",
+		parameters: []apiParameter{
+			{name: "scope", in: "path", description: "Either team or channel.", required: true},
+			{name: "id", in: "path", description: "ID of the team or channel.", required: true},
+		},
+		request:  "GlossaryTerm",
+		response: "Glossary",
+	},
+	{
+		method:  http.MethodDelete,
+		path:    "/api/v1/glossaries/{scope}/{id}/terms",
+		summary: "Remove a term from the glossary of a team or channel. Team or channel admins only.",
+		parameters: []apiParameter{
+			{name: "scope", in: "path", description: "Either team or channel.", required: true},
+			{name: "id", in: "path", description: "ID of the team or channel.", required: true},
+			{name: "term", in: "query", description: "Term to remove, regardless of case.", required: true},
+		},
+		response: "Glossary",
+	},
+	{
+		method:  http.MethodPost,
+		path:    "/api/v1/glossaries/{scope}/{id}/import",
+		summary: "Import the terms of a CSV, TBX or TMX file sent as body into the glossary of a team or channel, such as one exported from a CAT tool. CSV files have a term column followed by a column of translations per language code. Team or channel admins only.",
+		parameters: []apiParameter{
+			{name: "scope", in: "path", description: "Either team or channel.", required: true},
+			{name: "id", in: "path", description: "ID of the team or channel.", required: true},
+			{name: "format", in: "query", description: "Either csv, tbx or tmx.", required: true},
+			{name: "mode", in: "query", description: "Either merge, the default, to add the terms to the glossary, or replace to replace its terms."},
+			{name: "source_lang", in: "query", description: "Language code of the terms of TBX and TMX files, defaulting to the language of the file."},
+		},
+		response: "Glossary",
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/api/v1/glossaries/{scope}/{id}/export",
+		summary: "Export the glossary of a team or channel the current user is a member of as a CSV, TBX or TMX file.",
+		parameters: []apiParameter{
+			{name: "scope", in: "path", description: "Either team or channel.", required: true},
+			{name: "id", in: "path", description: "ID of the team or channel.", required: true},
+			{name: "format", in: "query", description: "Either csv, tbx or tmx.", required: true},
+			{name: "source_lang", in: "query", description: "Language code of the terms in TBX and TMX files, defaulting to en."},
+		},
+	},
+	{
+		method:  http.MethodPost,
+		path:    "/api/v1/webhook",
+		summary: "Translate a text sent by another system, posting the translation as the bot in the given channel if any, which must not be archived and must have the bot as a member. The source language is detected unless given.",
+		parameters: []apiParameter{
+			{name: webhookSignatureHeader, in: "header", description: "sha256= followed by the hex HMAC-SHA256 of the timestamp, a dot and the body, keyed with one of the Webhook Signing Secrets. Required when any is set."},
+			{name: webhookTimestampHeader, in: "header", description: "Unix time in seconds the request was signed at, within 5 minutes of the server time. Required when any Webhook Signing Secret is set."},
+		},
+		request:  "WebhookRequest",
+		response: "WebhookResponse",
+		security: securityWebhookToken,
+	},
+	{
+		method:   http.MethodPost,
+		path:     "/api/v1/plugin/translate",
+		summary:  "Translate a text sent by another plugin with PluginHTTP, like the webhook does, posting the translation as the bot in the given channel if any.",
+		request:  "WebhookRequest",
+		response: "WebhookResponse",
+		security: securityPlugin,
+	},
+}
+
+// getAPISpec builds the OpenAPI document describing the versioned HTTP API.
+func getAPISpec() map[string]interface{} {
+	paths := map[string]interface{}{}
+	for _, operation := range apiOperations {
+		item, ok := paths[operation.path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[operation.path] = item
+		}
+		item[strings.ToLower(operation.method)] = operation.toSpec()
+	}
+
+	schemas := map[string]interface{}{}
+	for name, t := range apiSchemas {
+		schemas[name] = getTypeSchema(t, false)
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       manifest.Name + " plugin API",
+			"description": manifest.Description,
+			"version":     manifest.Version,
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"url": "/plugins/" + manifest.Id},
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"session": map[string]interface{}{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "Mattermost session or personal access token. Browsers use the session cookie instead. Requests other than GET must send either the X-Requested-With: XMLHttpRequest header or the X-CSRF-Token header of the session.",
+				},
+				"sharedSecret": map[string]interface{}{
+					"type":        "apiKey",
+					"in":          "header",
+					"name":        sharedSecretHeader,
+					"description": "API Shared Secret setting of trusted servers, sent along with the ID of the user to act for in the " + sharedSecretUserIDHeader + " header.",
+				},
+				securityWebhookToken: map[string]interface{}{
+					"type":        "apiKey",
+					"in":          "header",
+					"name":        webhookTokenHeader,
+					"description": "Webhook Token setting, which may also be sent in the token query parameter. Not required when only Webhook Signing Secrets are set.",
+				},
+				securityPlugin: map[string]interface{}{
+					"type":        "apiKey",
+					"in":          "header",
+					"name":        "Mattermost-Plugin-ID",
+					"description": "ID of the plugin sending the request, set by the server for requests of other plugins made with PluginHTTP.",
+				},
+			},
+		},
+		"security": []interface{}{
+			map[string]interface{}{"session": []string{}},
+			map[string]interface{}{"sharedSecret": []string{}},
+		},
+	}
+}
+
+func (o *apiOperation) toSpec() map[string]interface{} {
+	operation := map[string]interface{}{
+		"summary": o.summary,
+	}
+
+	if o.security == securityNone {
+		operation["security"] = []interface{}{}
+	} else if o.security != "" {
+		operation["security"] = []interface{}{
+			map[string]interface{}{o.security: []string{}},
+		}
+	}
+
+	var parameters []interface{}
+	for _, parameter := range o.parameters {
+		parameters = append(parameters, map[string]interface{}{
+			"name":        parameter.name,
+			"in":          parameter.in,
+			"description": parameter.description,
+			"required":    parameter.required,
+			"schema":      map[string]interface{}{"type": "string"},
+		})
+	}
+	if parameters != nil {
+		operation["parameters"] = parameters
+	}
+
+	if o.request != "" {
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  getContentSpec("application/json", o.request),
+		}
+	}
+
+	success := map[string]interface{}{"description": "Success"}
+	if o.response != "" {
+		success["content"] = getContentSpec("application/json", o.response)
+	}
+	if o.textFormats {
+		content := success["content"].(map[string]interface{})
+		content["text/plain"] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+		content["text/markdown"] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+	}
+
+	status := o.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	responses := map[string]interface{}{
+		strconv.Itoa(status): success,
+		"default":            getErrorResponseSpec("Error, identified by the id of the response"),
+	}
+	if o.parameters != nil || o.request != "" {
+		responses["400"] = getErrorResponseSpec("Invalid request")
+	}
+	if o.security != securityNone {
+		responses["401"] = getErrorResponseSpec("Not authenticated")
+		if o.security != securityPlugin {
+			responses["429"] = getErrorResponseSpec("Too many requests, to be retried after the number of seconds of the Retry-After header")
+		}
+	}
+	if o.adminOnly {
+		responses["403"] = getErrorResponseSpec("Not a system admin")
+	}
+	operation["responses"] = responses
+
+	return operation
+}
+
+// getErrorResponseSpec describes an error response, which always holds an APIErrorResponse.
+func getErrorResponseSpec(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content":     getContentSpec("application/json", "APIErrorResponse"),
+	}
+}
+
+func getContentSpec(contentType, schema string) map[string]interface{} {
+	spec := getSchemaRef(strings.TrimPrefix(schema, "[]"))
+	if strings.HasPrefix(schema, "[]") {
+		spec = map[string]interface{}{"type": "array", "items": spec}
+	}
+
+	return map[string]interface{}{
+		contentType: map[string]interface{}{"schema": spec},
+	}
+}
+
+func getSchemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// getTypeSchema returns the JSON schema of a type from its JSON encoding, referring to the types
+// of apiSchemas by name unless describing the type itself.
+func getTypeSchema(t reflect.Type, allowRef bool) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if allowRef {
+		for name, schemaType := range apiSchemas {
+			if schemaType == t {
+				return getSchemaRef(name)
+			}
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": getTypeSchema(t.Elem(), true)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": getTypeSchema(t.Elem(), true)}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if field.PkgPath != "" || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = getTypeSchema(field.Type, true)
+		}
+
+		return map[string]interface{}{"type": "object", "properties": properties}
+	}
+
+	return map[string]interface{}{}
+}
+
+func (p *Plugin) getSpec(w http.ResponseWriter, r *http.Request) {
+	resp, _ := json.Marshal(getAPISpec())
+	w.Write(resp)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAPISpecCoversRoutes checks that every versioned route is described by the API specification.
+func TestAPISpecCoversRoutes(t *testing.T) {
+	documented := map[string]bool{}
+	for _, operation := range apiOperations {
+		documented[operation.method+" "+operation.path] = true
+	}
+
+	variablePattern := regexp.MustCompile(`\{([a-z_]+):[^/]*\}`)
+	router := (&Plugin{}).initializeRouter()
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(template, "/api/v1/") {
+			return nil
+		}
+
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+
+		path := variablePattern.ReplaceAllString(template, "{$1}")
+		for _, method := range methods {
+			assert.True(t, documented[method+" "+path], "%s %s is not described", method, path)
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestAPISpecSchemaRefs(t *testing.T) {
+	for _, operation := range apiOperations {
+		for _, schema := range []string{operation.request, operation.response} {
+			if schema == "" {
+				continue
+			}
+
+			_, ok := apiSchemas[strings.TrimPrefix(schema, "[]")]
+			assert.True(t, ok, "%s %s refers to unknown schema %s", operation.method, operation.path, schema)
+		}
+	}
+}
+
+func TestGetTypeSchema(t *testing.T) {
+	schema := getTypeSchema(reflect.TypeOf(LanguagesResponse{}), false)
+
+	assert.Equal(t, map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"provider":    map[string]interface{}{"type": "string"},
+			"auto_detect": map[string]interface{}{"type": "boolean"},
+			"languages": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"$ref": "#/components/schemas/Language"},
+			},
+		},
+	}, schema)
+}
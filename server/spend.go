@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	spendKeyPrefix = "spend_"
+
+	// spendReportKeyPrefix prefixes the keys recording the months whose spend report was sent, so
+	// that a single server of a cluster sends it.
+	spendReportKeyPrefix = "spendreport_"
+
+	spendMonthFormat = "2006-01"
+
+	// spendReportInterval is how often the spend report of the previous month is checked to have
+	// been sent.
+	spendReportInterval = time.Hour
+
+	spendCurrency = "USD"
+
+	// defaultProviderPrices is the price of Amazon Translate in USD per million characters.
+	defaultProviderPrices = "aws=15"
+)
+
+// SpendStats counts the characters translated by each provider per channel during a month, keyed
+// by channel ID and then by provider, channels being told apart so that they can be charged to
+// their team.
+type SpendStats struct {
+	Month    string                      `json:"month"`
+	Channels map[string]map[string]int64 `json:"channels"`
+}
+
+func newSpendStats(month string) *SpendStats {
+	return &SpendStats{
+		Month:    month,
+		Channels: map[string]map[string]int64{},
+	}
+}
+
+func (s *SpendStats) add(other *SpendStats) {
+	for channelID, providers := range other.Channels {
+		if s.Channels[channelID] == nil {
+			s.Channels[channelID] = map[string]int64{}
+		}
+		for provider, characters := range providers {
+			s.Channels[channelID][provider] += characters
+		}
+	}
+}
+
+// TeamSpend is the characters translated by a provider for a team during a month and their
+// estimated cost
+type TeamSpend struct {
+	TeamID        string  `json:"team_id"`
+	TeamName      string  `json:"team_name"`
+	Provider      string  `json:"provider"`
+	Characters    int64   `json:"characters"`
+	EstimatedCost float64 `json:"estimated_cost"`
+}
+
+// SpendReport is the estimated spend on translation providers during a month per team, the teams
+// spending the most first. Direct messages, group messages and translations made outside of a
+// channel are reported without a team.
+type SpendReport struct {
+	Month         string       `json:"month"`
+	Currency      string       `json:"currency"`
+	Characters    int64        `json:"characters"`
+	EstimatedCost float64      `json:"estimated_cost"`
+	Teams         []*TeamSpend `json:"teams"`
+}
+
+// spendTeam is the team the translations of a channel are charged to.
+type spendTeam struct {
+	id   string
+	name string
+}
+
+// newSpendReport adds up the characters translated in the channels of a month per team and
+// provider, estimating their cost from the prices per million characters of the providers.
+func newSpendReport(stats *SpendStats, prices map[string]float64, getTeam func(channelID string) spendTeam) *SpendReport {
+	report := &SpendReport{
+		Month:    stats.Month,
+		Currency: spendCurrency,
+		Teams:    []*TeamSpend{},
+	}
+
+	spends := map[[2]string]*TeamSpend{}
+	for channelID, providers := range stats.Channels {
+		team := getTeam(channelID)
+		for provider, characters := range providers {
+			spend, ok := spends[[2]string{team.id, provider}]
+			if !ok {
+				spend = &TeamSpend{TeamID: team.id, TeamName: team.name, Provider: provider}
+				spends[[2]string{team.id, provider}] = spend
+				report.Teams = append(report.Teams, spend)
+			}
+			spend.Characters += characters
+		}
+	}
+
+	for _, spend := range report.Teams {
+		spend.EstimatedCost = float64(spend.Characters) * prices[spend.Provider] / 1000000
+		report.Characters += spend.Characters
+		report.EstimatedCost += spend.EstimatedCost
+	}
+
+	sort.Slice(report.Teams, func(i, j int) bool {
+		a, b := report.Teams[i], report.Teams[j]
+		if a.EstimatedCost != b.EstimatedCost {
+			return a.EstimatedCost > b.EstimatedCost
+		}
+		if a.Characters != b.Characters {
+			return a.Characters > b.Characters
+		}
+		if a.TeamName != b.TeamName {
+			return a.TeamName < b.TeamName
+		}
+		return a.Provider < b.Provider
+	})
+
+	return report
+}
+
+// formatSpendReport returns the message posting a spend report.
+func formatSpendReport(report *SpendReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#### Translation spend for %s\n", report.Month)
+	fmt.Fprintf(&b, "Estimated cost: **%.2f %s** for %d characters.\n", report.EstimatedCost, report.Currency, report.Characters)
+	if len(report.Teams) == 0 {
+		b.WriteString("\nNo messages were translated.")
+		return b.String()
+	}
+
+	b.WriteString("\n| Team | Provider | Characters | Estimated cost |\n|:---|:---|---:|---:|\n")
+	for _, spend := range report.Teams {
+		team := spend.TeamName
+		if team == "" {
+			team = "No team"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %d | %.2f %s |\n", team, spend.Provider, spend.Characters, spend.EstimatedCost, report.Currency)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// parseProviderPrices parses comma-separated prices in USD per million characters of providers,
+// such as aws=15, returning the default prices when empty.
+func parseProviderPrices(value string) (map[string]float64, error) {
+	if strings.TrimSpace(value) == "" {
+		value = defaultProviderPrices
+	}
+
+	prices := map[string]float64{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Provider price %s must be a provider and a price such as aws=15", entry)
+		}
+
+		price, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || price < 0 {
+			return nil, fmt.Errorf("Provider price %s must be zero or a positive number", entry)
+		}
+		prices[strings.ToLower(strings.TrimSpace(parts[0]))] = price
+	}
+
+	return prices, nil
+}
+
+// getProviderPrices returns the prices in USD per million characters of providers, keyed by
+// provider. Invalid prices are ignored, as they are rejected before being saved.
+func (c *configuration) getProviderPrices() map[string]float64 {
+	prices, err := parseProviderPrices(c.ProviderPrices)
+	if err != nil {
+		prices, _ = parseProviderPrices("")
+	}
+
+	return prices
+}
+
+func getSpendKey(month string) string {
+	return spendKeyPrefix + month
+}
+
+func getSpendReportKey(month string) string {
+	return spendReportKeyPrefix + month
+}
+
+// recordSpend counts the characters translated by a provider for the channel of ctx in memory, to
+// be saved along with the usage statistics.
+func (p *Plugin) recordSpend(ctx context.Context, provider string, characters int) {
+	channelID := ""
+	if subject := getAuditSubject(ctx); subject != nil {
+		channelID = subject.channelID
+	}
+	month := time.Now().UTC().Format(spendMonthFormat)
+
+	p.spendLock.Lock()
+	defer p.spendLock.Unlock()
+
+	if p.spend == nil {
+		p.spend = map[string]*SpendStats{}
+	}
+
+	stats, ok := p.spend[month]
+	if !ok {
+		stats = newSpendStats(month)
+		p.spend[month] = stats
+	}
+
+	if stats.Channels[channelID] == nil {
+		stats.Channels[channelID] = map[string]int64{}
+	}
+	stats.Channels[channelID][provider] += int64(characters)
+}
+
+// flushSpendStats adds the characters counted in memory to the saved ones.
+func (p *Plugin) flushSpendStats() {
+	p.spendLock.Lock()
+	pending := p.spend
+	p.spend = nil
+	p.spendLock.Unlock()
+
+	for month, stats := range pending {
+		if err := p.saveSpendStats(stats); err != nil {
+			p.API.LogError("Failed to save spend statistics", "month", month, "err", err.Error())
+		}
+	}
+}
+
+// saveSpendStats adds statistics to the saved ones with a compare and set, as other cluster nodes
+// may be saving theirs at the same time.
+func (p *Plugin) saveSpendStats(stats *SpendStats) error {
+	key := getSpendKey(stats.Month)
+	for attempt := 0; attempt < maxStatsSaveAttempts; attempt++ {
+		oldBytes, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return appErr
+		}
+
+		saved := newSpendStats(stats.Month)
+		if oldBytes != nil {
+			if err := json.Unmarshal(oldBytes, saved); err != nil {
+				return errors.Wrap(err, "unable to unmarshal spend statistics")
+			}
+		}
+		saved.add(stats)
+
+		newBytes, err := json.Marshal(saved)
+		if err != nil {
+			return errors.Wrap(err, "unable to marshal spend statistics")
+		}
+
+		updated, appErr := p.API.KVCompareAndSet(key, oldBytes, newBytes)
+		if appErr != nil {
+			return appErr
+		}
+		if updated {
+			return nil
+		}
+	}
+
+	return errors.New("spend statistics kept changing concurrently")
+}
+
+// getSpendReport returns the estimated spend of a month per team, with the prices set by admins.
+func (p *Plugin) getSpendReport(month string) (*SpendReport, error) {
+	stats := newSpendStats(month)
+	statsBytes, appErr := p.API.KVGet(getSpendKey(month))
+	if appErr != nil {
+		return nil, appErr
+	}
+	if statsBytes != nil {
+		if err := json.Unmarshal(statsBytes, stats); err != nil {
+			return nil, errors.Wrap(err, "unable to unmarshal spend statistics")
+		}
+	}
+
+	teams := map[string]spendTeam{}
+	getTeam := func(channelID string) spendTeam {
+		if channelID == "" {
+			return spendTeam{}
+		}
+
+		channel, appErr := p.API.GetChannel(channelID)
+		if appErr != nil || channel.TeamId == "" {
+			return spendTeam{}
+		}
+
+		team, ok := teams[channel.TeamId]
+		if !ok {
+			team = spendTeam{id: channel.TeamId, name: channel.TeamId}
+			if t, appErr := p.API.GetTeam(channel.TeamId); appErr == nil {
+				team.name = t.DisplayName
+			}
+			teams[channel.TeamId] = team
+		}
+
+		return team
+	}
+
+	return newSpendReport(stats, p.getConfiguration().getProviderPrices(), getTeam), nil
+}
+
+func (p *Plugin) startSpendReports() {
+	p.spendReportStop = make(chan struct{})
+	stop := p.spendReportStop
+
+	go func() {
+		ticker := time.NewTicker(spendReportInterval)
+		defer ticker.Stop()
+
+		for {
+			p.runSpendReports(time.Now().UTC())
+
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (p *Plugin) stopSpendReports() {
+	if p.spendReportStop != nil {
+		close(p.spendReportStop)
+		p.spendReportStop = nil
+	}
+}
+
+// runSpendReports sends the spend report of the month before now when admins turned monthly
+// reports on and no server of the cluster sent it yet.
+func (p *Plugin) runSpendReports(now time.Time) {
+	if !p.getConfiguration().SpendReports {
+		return
+	}
+
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0).Format(spendMonthFormat)
+
+	// Claiming the month first keeps other servers from sending the report as well, at the cost
+	// of a report not sent when this server fails to.
+	claimed, appErr := p.API.KVSetWithOptions(getSpendReportKey(month), []byte(strconv.FormatInt(model.GetMillis(), 10)), model.PluginKVSetOptions{
+		Atomic:   true,
+		OldValue: nil,
+	})
+	if appErr != nil {
+		p.API.LogError("Failed to claim spend report", "month", month, "err", appErr.Error())
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	// Usage of the last hours of the month not saved yet is included.
+	p.flushSpendStats()
+
+	report, err := p.getSpendReport(month)
+	if err != nil {
+		p.API.LogError("Failed to get spend report", "month", month, "err", err.Error())
+		return
+	}
+
+	if err := p.notifyAdmins(p.getConfiguration().SpendReportChannel, formatSpendReport(report)); err != nil {
+		p.API.LogError("Failed to send spend report", "month", month, "err", err.Error())
+		return
+	}
+
+	p.API.LogInfo("Sent spend report", "month", month, "estimated_cost", report.EstimatedCost)
+}
+
+func (p *Plugin) getSpend(w http.ResponseWriter, r *http.Request) {
+	month := r.URL.Query().Get("month")
+	if month == "" {
+		month = time.Now().UTC().Format(spendMonthFormat)
+	} else if _, err := time.Parse(spendMonthFormat, month); err != nil {
+		writeAPIError(w, newInvalidParameterError("month"))
+		return
+	}
+
+	// Usage not saved yet is included so that the report is up to date.
+	p.flushSpendStats()
+
+	report, err := p.getSpendReport(month)
+	if err != nil {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to get spend report", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	resp, _ := json.Marshal(report)
+	w.Write(resp)
+}
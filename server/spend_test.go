@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProviderPrices(t *testing.T) {
+	for name, test := range map[string]struct {
+		value    string
+		expected map[string]float64
+		err      bool
+	}{
+		"empty":             {value: "", expected: map[string]float64{"aws": 15}},
+		"single":            {value: "aws=20", expected: map[string]float64{"aws": 20}},
+		"several":           {value: " aws = 15.5 ,deepl=25,", expected: map[string]float64{"aws": 15.5, "deepl": 25}},
+		"free":              {value: "aws=0", expected: map[string]float64{"aws": 0}},
+		"provider in caps":  {value: "AWS=15", expected: map[string]float64{"aws": 15}},
+		"missing price":     {value: "aws", err: true},
+		"price not numeric": {value: "aws=cheap", err: true},
+		"negative price":    {value: "aws=-1", err: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			prices, err := parseProviderPrices(test.value)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, prices)
+		})
+	}
+}
+
+func TestNewSpendReport(t *testing.T) {
+	stats := &SpendStats{
+		Month: "2020-06",
+		Channels: map[string]map[string]int64{
+			"sales1":  {"aws": 1000000},
+			"sales2":  {"aws": 500000},
+			"support": {"aws": 2000000, "deepl": 100000},
+			"direct":  {"aws": 200000},
+			"":        {"aws": 100000},
+		},
+	}
+	teams := map[string]spendTeam{
+		"sales1":  {id: "team1", name: "Sales"},
+		"sales2":  {id: "team1", name: "Sales"},
+		"support": {id: "team2", name: "Support"},
+	}
+
+	report := newSpendReport(stats, map[string]float64{"aws": 15}, func(channelID string) spendTeam {
+		return teams[channelID]
+	})
+
+	assert.Equal(t, "2020-06", report.Month)
+	assert.Equal(t, "USD", report.Currency)
+	assert.Equal(t, int64(3900000), report.Characters)
+	assert.InDelta(t, 57, report.EstimatedCost, 0.0001)
+
+	require.Len(t, report.Teams, 4)
+	assert.Equal(t, "Support", report.Teams[0].TeamName)
+	assert.Equal(t, "aws", report.Teams[0].Provider)
+	assert.InDelta(t, 30, report.Teams[0].EstimatedCost, 0.0001)
+	assert.Equal(t, &TeamSpend{TeamID: "team1", TeamName: "Sales", Provider: "aws", Characters: 1500000, EstimatedCost: 22.5}, report.Teams[1])
+	assert.Equal(t, &TeamSpend{Provider: "aws", Characters: 300000, EstimatedCost: 4.5}, report.Teams[2])
+
+	// Providers without a price are reported without cost.
+	assert.Equal(t, &TeamSpend{TeamID: "team2", TeamName: "Support", Provider: "deepl", Characters: 100000}, report.Teams[3])
+}
+
+func TestFormatSpendReport(t *testing.T) {
+	report := &SpendReport{
+		Month:         "2020-06",
+		Currency:      "USD",
+		Characters:    1500000,
+		EstimatedCost: 22.5,
+		Teams: []*TeamSpend{
+			{TeamID: "team1", TeamName: "Sales", Provider: "aws", Characters: 1000000, EstimatedCost: 15},
+			{Provider: "aws", Characters: 500000, EstimatedCost: 7.5},
+		},
+	}
+
+	assert.Equal(t, "#### Translation spend for 2020-06\n"+
+		"Estimated cost: **22.50 USD** for 1500000 characters.\n"+
+		"\n| Team | Provider | Characters | Estimated cost |\n|:---|:---|---:|---:|\n"+
+		"| Sales | aws | 1000000 | 15.00 USD |\n"+
+		"| No team | aws | 500000 | 7.50 USD |", formatSpendReport(report))
+
+	assert.Equal(t, "#### Translation spend for 2020-07\n"+
+		"Estimated cost: **0.00 USD** for 0 characters.\n"+
+		"\nNo messages were translated.", formatSpendReport(&SpendReport{Month: "2020-07", Currency: "USD"}))
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	statsKeyPrefix = "stats_"
+
+	// statsFlushInterval is how often usage statistics collected in memory are saved.
+	statsFlushInterval = time.Minute
+
+	statsDayFormat = "2006-01-02"
+
+	defaultStatsDays = 7
+	maxStatsDays     = 90
+
+	// maxStatsSaveAttempts bounds the retries of saving statistics updated concurrently by
+	// other cluster nodes.
+	maxStatsSaveAttempts = 5
+)
+
+// latencyBucketBounds are the upper bounds in milliseconds of the latency histogram buckets, the
+// last bucket holding any longer latency.
+var latencyBucketBounds = []int64{50, 100, 200, 500, 1000, 2000, 5000, 10000}
+
+// usageProviders are the translation providers whose usage is reported.
+var usageProviders = []string{providerAWS}
+
+// UsageStats is the usage of a translation provider during a day
+type UsageStats struct {
+	Provider       string  `json:"provider"`
+	Day            string  `json:"day"`
+	Requests       int64   `json:"requests"`
+	Errors         int64   `json:"errors"`
+	Characters     int64   `json:"characters"`
+	LatencyBuckets []int64 `json:"latency_buckets"`
+}
+
+func newUsageStats(provider, day string) *UsageStats {
+	return &UsageStats{
+		Provider:       provider,
+		Day:            day,
+		LatencyBuckets: make([]int64, len(latencyBucketBounds)+1),
+	}
+}
+
+func (s *UsageStats) add(other *UsageStats) {
+	s.Requests += other.Requests
+	s.Errors += other.Errors
+	s.Characters += other.Characters
+
+	for i := range s.LatencyBuckets {
+		if i < len(other.LatencyBuckets) {
+			s.LatencyBuckets[i] += other.LatencyBuckets[i]
+		}
+	}
+}
+
+// getLatencyPercentile returns the upper bound in milliseconds of the latency bucket holding the
+// given percentile of requests, or -1 when it lies beyond the last bound.
+func (s *UsageStats) getLatencyPercentile(percentile int64) int64 {
+	return getLatencyPercentile(s.LatencyBuckets, s.Requests, percentile)
+}
+
+// getLatencyBucket returns the index of the latency bucket of a request.
+func getLatencyBucket(latency time.Duration) int {
+	for i, bound := range latencyBucketBounds {
+		if latency.Milliseconds() <= bound {
+			return i
+		}
+	}
+
+	return len(latencyBucketBounds)
+}
+
+// getLatencyPercentile returns the upper bound in milliseconds of the bucket of a latency
+// histogram of requests holding the given percentile of them, or -1 when it lies beyond the last
+// bound.
+func getLatencyPercentile(buckets []int64, requests, percentile int64) int64 {
+	if requests == 0 {
+		return 0
+	}
+
+	rank := (requests*percentile + 99) / 100
+	var count int64
+	for i, bucket := range buckets {
+		count += bucket
+		if count >= rank {
+			if i < len(latencyBucketBounds) {
+				return latencyBucketBounds[i]
+			}
+			break
+		}
+	}
+
+	return -1
+}
+
+// UsageStatsReport is the usage of a translation provider during a day as reported by the API
+type UsageStatsReport struct {
+	Provider     string  `json:"provider"`
+	Day          string  `json:"day"`
+	Requests     int64   `json:"requests"`
+	Errors       int64   `json:"errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	Characters   int64   `json:"characters"`
+	LatencyP50Ms int64   `json:"latency_p50_ms"`
+	LatencyP90Ms int64   `json:"latency_p90_ms"`
+	LatencyP99Ms int64   `json:"latency_p99_ms"`
+}
+
+func (s *UsageStats) toReport() *UsageStatsReport {
+	report := &UsageStatsReport{
+		Provider:     s.Provider,
+		Day:          s.Day,
+		Requests:     s.Requests,
+		Errors:       s.Errors,
+		Characters:   s.Characters,
+		LatencyP50Ms: s.getLatencyPercentile(50),
+		LatencyP90Ms: s.getLatencyPercentile(90),
+		LatencyP99Ms: s.getLatencyPercentile(99),
+	}
+	if s.Requests > 0 {
+		report.ErrorRate = float64(s.Errors) / float64(s.Requests)
+	}
+
+	return report
+}
+
+func getUsageStatsKey(provider, day string) string {
+	return statsKeyPrefix + provider + "_" + day
+}
+
+// recordUsage counts a request to a translation provider in memory, to be saved periodically, and
+// in the metrics, the failure alerts and the diagnostics. Successful requests are charged to the
+// channel of ctx and counted for its channel and user.
+func (p *Plugin) recordUsage(ctx context.Context, provider string, characters int, latency time.Duration, err error) {
+	p.metrics.observeRequest(provider, characters, latency, err)
+	p.failures.observe(err)
+	if err != nil {
+		p.recordError(provider, err)
+	} else {
+		p.recordSpend(ctx, provider, characters)
+		p.recordVolume(ctx, characters)
+	}
+
+	day := time.Now().UTC().Format(statsDayFormat)
+	key := getUsageStatsKey(provider, day)
+
+	p.statsLock.Lock()
+	defer p.statsLock.Unlock()
+
+	if p.stats == nil {
+		p.stats = map[string]*UsageStats{}
+	}
+
+	stats, ok := p.stats[key]
+	if !ok {
+		stats = newUsageStats(provider, day)
+		p.stats[key] = stats
+	}
+
+	stats.Requests++
+	stats.Characters += int64(characters)
+	if err != nil {
+		stats.Errors++
+	}
+
+	stats.LatencyBuckets[getLatencyBucket(latency)]++
+}
+
+// startUsageStatsFlush saves the usage, language, language pair, spend and volume statistics and
+// the audit entries collected in memory periodically until stopUsageStatsFlush is called.
+func (p *Plugin) startUsageStatsFlush() {
+	p.statsStop = make(chan struct{})
+	stop := p.statsStop
+
+	go func() {
+		ticker := time.NewTicker(statsFlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.flushUsageStats()
+				p.flushLanguageStats()
+				p.flushPairStats()
+				p.flushSpendStats()
+				p.flushVolumeStats()
+				p.flushAuditEntries()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (p *Plugin) stopUsageStatsFlush() {
+	if p.statsStop != nil {
+		close(p.statsStop)
+		p.statsStop = nil
+	}
+
+	p.flushUsageStats()
+	p.flushLanguageStats()
+	p.flushPairStats()
+	p.flushSpendStats()
+	p.flushVolumeStats()
+	p.flushAuditEntries()
+}
+
+// flushUsageStats adds the usage statistics collected in memory to the saved ones.
+func (p *Plugin) flushUsageStats() {
+	p.statsLock.Lock()
+	pending := p.stats
+	p.stats = nil
+	p.statsLock.Unlock()
+
+	for key, stats := range pending {
+		if err := p.saveUsageStats(key, stats); err != nil {
+			p.API.LogError("Failed to save usage statistics", "key", key, "err", err.Error())
+		}
+	}
+}
+
+// saveUsageStats adds statistics to the saved ones with a compare and set, as other cluster nodes
+// may be saving theirs at the same time.
+func (p *Plugin) saveUsageStats(key string, stats *UsageStats) error {
+	for attempt := 0; attempt < maxStatsSaveAttempts; attempt++ {
+		oldBytes, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return appErr
+		}
+
+		saved := newUsageStats(stats.Provider, stats.Day)
+		if oldBytes != nil {
+			if err := json.Unmarshal(oldBytes, saved); err != nil {
+				return errors.Wrap(err, "unable to unmarshal usage statistics")
+			}
+		}
+		saved.add(stats)
+
+		newBytes, err := json.Marshal(saved)
+		if err != nil {
+			return errors.Wrap(err, "unable to marshal usage statistics")
+		}
+
+		updated, appErr := p.API.KVCompareAndSet(key, oldBytes, newBytes)
+		if appErr != nil {
+			return appErr
+		}
+		if updated {
+			return nil
+		}
+	}
+
+	return errors.New("usage statistics kept changing concurrently")
+}
+
+// getUsageStatsReports returns the saved usage statistics of every provider for the given
+// number of days up to today, skipping days without any request.
+func (p *Plugin) getUsageStatsReports(days int) ([]*UsageStatsReport, error) {
+	reports := []*UsageStatsReport{}
+	today := time.Now().UTC()
+	for i := 0; i < days; i++ {
+		day := today.AddDate(0, 0, -i).Format(statsDayFormat)
+		for _, provider := range usageProviders {
+			stats, err := p.getSavedUsageStats(provider, day)
+			if err != nil {
+				return nil, err
+			}
+			if stats != nil {
+				reports = append(reports, stats.toReport())
+			}
+		}
+	}
+
+	return reports, nil
+}
+
+// getSavedUsageStats returns the usage statistics saved for a provider and a day, or nil when it
+// wasn't used that day.
+func (p *Plugin) getSavedUsageStats(provider, day string) (*UsageStats, error) {
+	statsBytes, appErr := p.API.KVGet(getUsageStatsKey(provider, day))
+	if appErr != nil {
+		return nil, appErr
+	}
+	if statsBytes == nil {
+		return nil, nil
+	}
+
+	stats := newUsageStats(provider, day)
+	if err := json.Unmarshal(statsBytes, stats); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal usage statistics")
+	}
+
+	return stats, nil
+}
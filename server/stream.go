@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// wsEventTranslationChunk carries a part of a streamed translation as soon as it is translated.
+const wsEventTranslationChunk = "translation_chunk"
+
+// TranslationStream is a translation of a post whose parts are sent to the user as websocket
+// events while it is being made
+type TranslationStream struct {
+	StreamID       string `json:"stream_id"`
+	PostID         string `json:"post_id"`
+	SourceLanguage string `json:"source_lang"`
+	TargetLanguage string `json:"target_lang"`
+	Chunks         int    `json:"chunks"`
+}
+
+// startTranslationStream translates the message of a post part by part in the background,
+// answering right away with the ID of the stream, so that clients can render long translations
+// as they come instead of waiting for all of them.
+func (p *Plugin) startTranslationStream(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	postID := getPostIDParam(r)
+
+	source := r.URL.Query().Get("source")
+	if source == "" {
+		source = autoLanguage
+	}
+	if languageCodes[source] == "" {
+		writeAPIError(w, newInvalidParameterError("source"))
+		return
+	}
+
+	target := r.URL.Query().Get("target")
+	if target == autoLanguage || languageCodes[target] == "" {
+		writeAPIError(w, newInvalidParameterError("target"))
+		return
+	}
+
+	post, appErr := p.API.GetPost(postID)
+	if appErr != nil {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorPostNotFound, Message: "No post to translate", StatusCode: http.StatusBadRequest})
+		return
+	}
+
+	if !p.API.HasPermissionToChannel(userID, post.ChannelId, model.PERMISSION_READ_CHANNEL) {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorForbidden, Message: "Not authorized to read post", StatusCode: http.StatusForbidden})
+		return
+	}
+
+	if isNoTranslatePost(post) {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorOptedOut, Message: "Post is opted out of translation", StatusCode: http.StatusForbidden})
+		return
+	}
+
+	if err := p.checkAuthorConsent(post); err != nil {
+		writeAPIError(w, newTranslationError(err))
+		return
+	}
+
+	chunks := splitText(post.Message, maxTranslateTextBytes)
+	stream := &TranslationStream{
+		StreamID:       model.NewId(),
+		PostID:         post.Id,
+		SourceLanguage: source,
+		TargetLanguage: target,
+		Chunks:         len(chunks),
+	}
+
+	// The stream outlives the request, keeping only its ID.
+	ctx := newRequestContext(context.Background(), getRequestID(r.Context()))
+	ctx = newAuditContext(p.newGlossaryContext(ctx, post.ChannelId), userID, post.Id, post.ChannelId)
+	go p.streamTranslation(ctx, userID, post, stream, chunks)
+
+	resp, _ := json.Marshal(stream)
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(resp)
+}
+
+// streamTranslation translates the chunks of a message in order, sending each one to the user
+// along with the separator joining it to the previous one. The last event is marked done and
+// holds the whole translation, or the error which stopped the stream.
+func (p *Plugin) streamTranslation(ctx context.Context, userID string, post *model.Post, stream *TranslationStream, chunks []textChunk) {
+	publish := func(data map[string]interface{}) {
+		data["stream_id"] = stream.StreamID
+		data["post_id"] = stream.PostID
+		data["request_id"] = getRequestID(ctx)
+		p.API.PublishWebSocketEvent(wsEventTranslationChunk, data, &model.WebsocketBroadcast{UserId: userID})
+	}
+
+	svc, err := p.getTranslateService()
+	if err != nil {
+		publish(map[string]interface{}{"done": true, "error": "Bad credentials"})
+		return
+	}
+
+	for i, chunk := range chunks {
+		if strings.TrimSpace(chunk.text) != "" {
+			translated, err := p.translateTextWithContext(ctx, svc, stream.SourceLanguage, stream.TargetLanguage, chunk.text)
+			if err != nil {
+				p.API.LogError("Failed to stream translation", "request_id", getRequestID(ctx), "post_id", post.Id, "err", err.Error())
+				publish(map[string]interface{}{"done": true, "error": err.Error(), "error_id": getProviderErrorID(err)})
+				return
+			}
+			chunks[i].text = translated
+		}
+
+		publish(map[string]interface{}{
+			"index":     i,
+			"separator": chunks[i].separator,
+			"text":      chunks[i].text,
+			"done":      false,
+		})
+	}
+
+	translated := newTranslatedMessage(post, stream.SourceLanguage, stream.TargetLanguage, joinChunks(chunks))
+	if err := p.cacheTranslation(post, translated); err != nil {
+		p.API.LogWarn("Failed to cache translation", "post_id", post.Id, "err", err.Error())
+	}
+	p.recordTranslationHistory(userID, post, stream.SourceLanguage, stream.TargetLanguage)
+
+	publish(map[string]interface{}{
+		"done":            true,
+		"translated_text": translated.TranslatedText,
+	})
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// telemetryFlushInterval is how often the events counted in memory are sent, events being
+	// counted rather than sent one by one.
+	telemetryFlushInterval = time.Hour
+
+	telemetryTimeout = 10 * time.Second
+
+	telemetryEventTranslation = "translation"
+
+	telemetryTriggerAuto     = "auto"
+	telemetryTriggerOnDemand = "on_demand"
+)
+
+// rudderWriteKey and rudderDataplaneURL are set at build time, builds without them never sending
+// telemetry.
+var (
+	rudderWriteKey     string
+	rudderDataplaneURL string
+)
+
+// telemetryEventKey is a kind of event counted for telemetry. Events tell which features are
+// used, never which users, posts or channels used them.
+type telemetryEventKey struct {
+	event        string
+	trigger      string
+	deliveryMode string
+	provider     string
+}
+
+// telemetryCounter counts the events tracked since they were last sent.
+type telemetryCounter struct {
+	lock   sync.Mutex
+	counts map[telemetryEventKey]int64
+}
+
+func (c *telemetryCounter) track(key telemetryEventKey) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.counts == nil {
+		c.counts = map[telemetryEventKey]int64{}
+	}
+	c.counts[key]++
+}
+
+// drain returns the events counted since the last drain, counting from zero again.
+func (c *telemetryCounter) drain() map[telemetryEventKey]int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	counts := c.counts
+	c.counts = nil
+
+	return counts
+}
+
+// rudderEvent is a track event of the batch API of Rudder.
+type rudderEvent struct {
+	Type        string                 `json:"type"`
+	Event       string                 `json:"event"`
+	AnonymousID string                 `json:"anonymousId"`
+	Properties  map[string]interface{} `json:"properties"`
+	Timestamp   string                 `json:"timestamp"`
+}
+
+// newTelemetryBatch returns the body of a request to the batch API of Rudder sending counted
+// events on behalf of the server with the given diagnostic ID, one event per kind.
+func newTelemetryBatch(diagnosticID string, counts map[telemetryEventKey]int64, now time.Time) ([]byte, error) {
+	keys := make([]telemetryEventKey, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		return strings.Join([]string{a.event, a.trigger, a.deliveryMode, a.provider}, "\x00") < strings.Join([]string{b.event, b.trigger, b.deliveryMode, b.provider}, "\x00")
+	})
+
+	timestamp := now.UTC().Format(time.RFC3339)
+	events := make([]*rudderEvent, 0, len(keys))
+	for _, key := range keys {
+		properties := map[string]interface{}{
+			"plugin_id":      manifest.Id,
+			"plugin_version": manifest.Version,
+			"count":          counts[key],
+		}
+		if key.trigger != "" {
+			properties["trigger"] = key.trigger
+		}
+		if key.deliveryMode != "" {
+			properties["delivery_mode"] = key.deliveryMode
+		}
+		if key.provider != "" {
+			properties["provider"] = key.provider
+		}
+
+		events = append(events, &rudderEvent{
+			Type:        "track",
+			Event:       key.event,
+			AnonymousID: diagnosticID,
+			Properties:  properties,
+			Timestamp:   timestamp,
+		})
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"batch":  events,
+		"sentAt": timestamp,
+	})
+}
+
+// isTelemetryEnabled reports whether telemetry is sent, which requires admins to opt in with the
+// plugin setting on top of the diagnostics of the server.
+func (p *Plugin) isTelemetryEnabled() bool {
+	if rudderWriteKey == "" || rudderDataplaneURL == "" || !p.getConfiguration().EnableTelemetry {
+		return false
+	}
+
+	enableDiagnostics := p.API.GetConfig().LogSettings.EnableDiagnostics
+	return enableDiagnostics != nil && *enableDiagnostics
+}
+
+// trackTranslation counts a delivered translation for telemetry, telling automatic translations
+// from the ones users asked for, along with how they were delivered and which provider made them.
+func (p *Plugin) trackTranslation(trigger, deliveryMode, provider string) {
+	if !p.isTelemetryEnabled() {
+		return
+	}
+
+	if provider == "" {
+		provider = providerAWS
+	}
+
+	p.telemetry.track(telemetryEventKey{
+		event:        telemetryEventTranslation,
+		trigger:      trigger,
+		deliveryMode: deliveryMode,
+		provider:     provider,
+	})
+}
+
+// startTelemetryFlush sends the events counted in memory periodically until stopTelemetryFlush
+// is called.
+func (p *Plugin) startTelemetryFlush() {
+	p.telemetryStop = make(chan struct{})
+	stop := p.telemetryStop
+
+	go func() {
+		ticker := time.NewTicker(telemetryFlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.flushTelemetry()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (p *Plugin) stopTelemetryFlush() {
+	if p.telemetryStop != nil {
+		close(p.telemetryStop)
+		p.telemetryStop = nil
+	}
+
+	p.flushTelemetry()
+}
+
+// flushTelemetry sends the events counted in memory, dropping them when admins opted out since.
+// Events which fail to be sent are dropped as well, as telemetry isn't worth retrying.
+func (p *Plugin) flushTelemetry() {
+	counts := p.telemetry.drain()
+	if len(counts) == 0 || !p.isTelemetryEnabled() {
+		return
+	}
+
+	if err := p.sendTelemetry(counts); err != nil {
+		p.API.LogWarn("Failed to send telemetry", "err", err.Error())
+	}
+}
+
+func (p *Plugin) sendTelemetry(counts map[telemetryEventKey]int64) error {
+	body, err := newTelemetryBatch(p.API.GetDiagnosticId(), counts, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal telemetry")
+	}
+
+	client, err := newOutboundHTTPClient(p.getConfiguration())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(rudderDataplaneURL, "/")+"/v1/batch", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "invalid telemetry data plane URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(rudderWriteKey, "")
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "unable to reach the telemetry data plane")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("telemetry data plane answered %s", resp.Status)
+	}
+
+	return nil
+}
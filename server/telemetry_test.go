@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTelemetryCounter(t *testing.T) {
+	var counter telemetryCounter
+	assert.Nil(t, counter.drain())
+
+	auto := telemetryEventKey{event: telemetryEventTranslation, trigger: telemetryTriggerAuto, deliveryMode: deliveryModePost, provider: providerAWS}
+	onDemand := telemetryEventKey{event: telemetryEventTranslation, trigger: telemetryTriggerOnDemand, provider: providerAWS}
+	counter.track(auto)
+	counter.track(auto)
+	counter.track(onDemand)
+
+	assert.Equal(t, map[telemetryEventKey]int64{auto: 2, onDemand: 1}, counter.drain())
+	assert.Nil(t, counter.drain())
+}
+
+func TestNewTelemetryBatch(t *testing.T) {
+	counts := map[telemetryEventKey]int64{
+		{event: telemetryEventTranslation, trigger: telemetryTriggerOnDemand, provider: providerAWS}:                               1,
+		{event: telemetryEventTranslation, trigger: telemetryTriggerAuto, deliveryMode: deliveryModeThread, provider: providerAWS}: 5,
+	}
+
+	body, err := newTelemetryBatch("diagnostic1", counts, time.Date(2020, 6, 12, 10, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	var batch struct {
+		Batch  []*rudderEvent `json:"batch"`
+		SentAt string         `json:"sentAt"`
+	}
+	require.NoError(t, json.Unmarshal(body, &batch))
+	assert.Equal(t, "2020-06-12T10:00:00Z", batch.SentAt)
+	require.Len(t, batch.Batch, 2)
+
+	// Events are sorted, automatic translations coming first.
+	auto := batch.Batch[0]
+	assert.Equal(t, "track", auto.Type)
+	assert.Equal(t, telemetryEventTranslation, auto.Event)
+	assert.Equal(t, "diagnostic1", auto.AnonymousID)
+	assert.Equal(t, "2020-06-12T10:00:00Z", auto.Timestamp)
+	assert.Equal(t, telemetryTriggerAuto, auto.Properties["trigger"])
+	assert.Equal(t, deliveryModeThread, auto.Properties["delivery_mode"])
+	assert.Equal(t, providerAWS, auto.Properties["provider"])
+	assert.Equal(t, float64(5), auto.Properties["count"])
+	assert.Equal(t, manifest.Version, auto.Properties["plugin_version"])
+
+	onDemand := batch.Batch[1]
+	assert.Equal(t, telemetryTriggerOnDemand, onDemand.Properties["trigger"])
+	assert.NotContains(t, onDemand.Properties, "delivery_mode")
+	assert.Equal(t, float64(1), onDemand.Properties["count"])
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Formats of glossaries imported and exported as files, such as the termbases of terminology
+// management systems.
+const (
+	termbaseFormatCSV = "csv"
+	termbaseFormatTBX = "tbx"
+	termbaseFormatTMX = "tmx"
+)
+
+const (
+	// maxTermbaseBytes bounds the size of imported glossary files.
+	maxTermbaseBytes = 1024 * 1024
+
+	termbaseModeMerge   = "merge"
+	termbaseModeReplace = "replace"
+)
+
+// tbxDocument is a termbase in the TBX format, either TBX 2008 with its martif root and termEntry
+// elements, or TBX v3 with its tbx root and conceptEntry elements, hence its unnamed root.
+type tbxDocument struct {
+	XMLName        xml.Name
+	Type           string      `xml:"type,attr"`
+	Lang           string      `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Header         *tbxHeader  `xml:"martifHeader,omitempty"`
+	Entries        []*tbxEntry `xml:"text>body>termEntry"`
+	ConceptEntries []*tbxEntry `xml:"text>body>conceptEntry"`
+}
+
+type tbxHeader struct {
+	Title string `xml:"fileDesc>sourceDesc>p"`
+}
+
+type tbxEntry struct {
+	ID       string        `xml:"id,attr,omitempty"`
+	LangSets []*tbxLangSet `xml:"langSet"`
+	LangSecs []*tbxLangSet `xml:"langSec"`
+}
+
+type tbxLangSet struct {
+	Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+
+	// Terms of TBX 2008, in tig or ntig elements, and of TBX v3, in termSec elements
+	Terms     []string `xml:"tig>term"`
+	NTigTerms []string `xml:"ntig>termGrp>term"`
+	TermSecs  []string `xml:"termSec>term"`
+}
+
+func (l *tbxLangSet) getTerm() string {
+	for _, terms := range [][]string{l.Terms, l.NTigTerms, l.TermSecs} {
+		for _, term := range terms {
+			if term = strings.TrimSpace(term); term != "" {
+				return term
+			}
+		}
+	}
+
+	return ""
+}
+
+// tmxDocument is a translation memory in the TMX format, each translation unit holding a term.
+type tmxDocument struct {
+	XMLName xml.Name   `xml:"tmx"`
+	Version string     `xml:"version,attr"`
+	Header  tmxHeader  `xml:"header"`
+	Units   []*tmxUnit `xml:"body>tu"`
+}
+
+type tmxHeader struct {
+	CreationTool        string `xml:"creationtool,attr"`
+	CreationToolVersion string `xml:"creationtoolversion,attr"`
+	SegType             string `xml:"segtype,attr"`
+	OTMF                string `xml:"o-tmf,attr"`
+	AdminLang           string `xml:"adminlang,attr"`
+	SrcLang             string `xml:"srclang,attr"`
+	DataType            string `xml:"datatype,attr"`
+}
+
+type tmxUnit struct {
+	Variants []*tmxVariant `xml:"tuv"`
+}
+
+type tmxVariant struct {
+	Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Seg  string `xml:"seg"`
+}
+
+// normalizeLanguageCode returns the supported language code matching a code of a termbase, such
+// as "ja" for "ja-JP" or "zh-TW" for "zh_tw", or an empty code when it isn't supported.
+func normalizeLanguageCode(code string) string {
+	code = strings.Replace(strings.TrimSpace(code), "_", "-", -1)
+	for supported := range languageCodes {
+		if strings.EqualFold(supported, code) {
+			return supported
+		}
+	}
+
+	if i := strings.Index(code, "-"); i > 0 {
+		return normalizeLanguageCode(code[:i])
+	}
+
+	return ""
+}
+
+// newTermbaseTerm returns the glossary term made of the variants of a termbase entry keyed by
+// language, the one in the source language being the term itself. Entries without it are skipped.
+func newTermbaseTerm(source string, variants map[string]string) *GlossaryTerm {
+	term := variants[source]
+	if term == "" {
+		return nil
+	}
+
+	glossaryTerm := &GlossaryTerm{Term: term}
+	for language, variant := range variants {
+		if language == source || variant == "" {
+			continue
+		}
+
+		if glossaryTerm.Translations == nil {
+			glossaryTerm.Translations = map[string]string{}
+		}
+		glossaryTerm.Translations[language] = variant
+	}
+
+	return glossaryTerm
+}
+
+// parseGlossaryCSV reads a glossary from CSV, whose header names a "term" column followed by a
+// column of translations per language code. Empty cells keep the term as is.
+func parseGlossaryCSV(r io.Reader) (*Glossary, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 || len(records[0]) == 0 || !strings.EqualFold(strings.TrimSpace(records[0][0]), "term") {
+		return nil, fmt.Errorf("the first row must name the term column followed by language codes")
+	}
+
+	languages := make([]string, len(records[0]))
+	for i, code := range records[0][1:] {
+		languages[i+1] = normalizeLanguageCode(code)
+		if languages[i+1] == "" || languages[i+1] == autoLanguage {
+			return nil, fmt.Errorf("unsupported language code %q in the first row", code)
+		}
+	}
+
+	glossary := &Glossary{Terms: []*GlossaryTerm{}}
+	for _, record := range records[1:] {
+		term := strings.TrimSpace(record[0])
+		if term == "" {
+			continue
+		}
+
+		glossaryTerm := &GlossaryTerm{Term: term}
+		for i, translation := range record[1:] {
+			if i+1 >= len(languages) || strings.TrimSpace(translation) == "" {
+				continue
+			}
+
+			if glossaryTerm.Translations == nil {
+				glossaryTerm.Translations = map[string]string{}
+			}
+			glossaryTerm.Translations[languages[i+1]] = strings.TrimSpace(translation)
+		}
+		glossary.Terms = append(glossary.Terms, glossaryTerm)
+	}
+
+	return glossary, nil
+}
+
+// parseGlossaryTBX reads a glossary from a TBX termbase, each entry holding a term in the source
+// language, given by the request or by the termbase itself, along with its translations.
+func parseGlossaryTBX(r io.Reader, source string) (*Glossary, error) {
+	var document tbxDocument
+	if err := xml.NewDecoder(r).Decode(&document); err != nil {
+		return nil, err
+	}
+
+	if source == "" {
+		source = normalizeLanguageCode(document.Lang)
+	}
+	if source == "" {
+		source = enLanguage
+	}
+
+	glossary := &Glossary{Terms: []*GlossaryTerm{}}
+	for _, entry := range append(document.Entries, document.ConceptEntries...) {
+		variants := map[string]string{}
+		for _, langSet := range append(entry.LangSets, entry.LangSecs...) {
+			if language := normalizeLanguageCode(langSet.Lang); language != "" {
+				variants[language] = langSet.getTerm()
+			}
+		}
+
+		if term := newTermbaseTerm(source, variants); term != nil {
+			glossary.Terms = append(glossary.Terms, term)
+		}
+	}
+
+	return glossary, nil
+}
+
+// parseGlossaryTMX reads a glossary from a TMX translation memory, each translation unit holding a
+// term in the source language, given by the request or by the header, along with its translations.
+func parseGlossaryTMX(r io.Reader, source string) (*Glossary, error) {
+	var document tmxDocument
+	if err := xml.NewDecoder(r).Decode(&document); err != nil {
+		return nil, err
+	}
+
+	if source == "" {
+		source = normalizeLanguageCode(document.Header.SrcLang)
+	}
+	if source == "" {
+		source = enLanguage
+	}
+
+	glossary := &Glossary{Terms: []*GlossaryTerm{}}
+	for _, unit := range document.Units {
+		variants := map[string]string{}
+		for _, variant := range unit.Variants {
+			if language := normalizeLanguageCode(variant.Lang); language != "" {
+				variants[language] = strings.TrimSpace(variant.Seg)
+			}
+		}
+
+		if term := newTermbaseTerm(source, variants); term != nil {
+			glossary.Terms = append(glossary.Terms, term)
+		}
+	}
+
+	return glossary, nil
+}
+
+// getGlossaryLanguages returns the languages the terms of a glossary are translated into, sorted.
+func getGlossaryLanguages(glossary *Glossary) []string {
+	seen := map[string]bool{}
+	var languages []string
+	for _, term := range glossary.Terms {
+		for language := range term.Translations {
+			if !seen[language] {
+				seen[language] = true
+				languages = append(languages, language)
+			}
+		}
+	}
+	sort.Strings(languages)
+
+	return languages
+}
+
+// formatGlossaryCSV writes a glossary as CSV, with a column of translations per language.
+func formatGlossaryCSV(glossary *Glossary) ([]byte, error) {
+	languages := getGlossaryLanguages(glossary)
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(append([]string{"term"}, languages...)); err != nil {
+		return nil, err
+	}
+
+	for _, term := range glossary.Terms {
+		record := []string{term.Term}
+		for _, language := range languages {
+			record = append(record, term.Translations[language])
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+
+	return buf.Bytes(), writer.Error()
+}
+
+// formatGlossaryTBX writes a glossary as a TBX 2008 termbase, the terms being in the source
+// language.
+func formatGlossaryTBX(glossary *Glossary, source string) ([]byte, error) {
+	document := &tbxDocument{
+		XMLName: xml.Name{Local: "martif"},
+		Type:    "TBX",
+		Lang:    source,
+		Header:  &tbxHeader{Title: "Exported from the Mattermost Autotranslation Plugin"},
+	}
+
+	for i, term := range glossary.Terms {
+		entry := &tbxEntry{
+			ID:       fmt.Sprintf("term-%d", i+1),
+			LangSets: []*tbxLangSet{{Lang: source, Terms: []string{term.Term}}},
+		}
+		for _, language := range getGlossaryLanguages(&Glossary{Terms: []*GlossaryTerm{term}}) {
+			entry.LangSets = append(entry.LangSets, &tbxLangSet{Lang: language, Terms: []string{term.Translations[language]}})
+		}
+		document.Entries = append(document.Entries, entry)
+	}
+
+	return marshalTermbase(document)
+}
+
+// formatGlossaryTMX writes a glossary as a TMX 1.4 translation memory, the terms being in the
+// source language.
+func formatGlossaryTMX(glossary *Glossary, source string) ([]byte, error) {
+	document := &tmxDocument{
+		Version: "1.4",
+		Header: tmxHeader{
+			CreationTool:        manifest.Id,
+			CreationToolVersion: manifest.Version,
+			SegType:             "phrase",
+			OTMF:                "glossary",
+			AdminLang:           enLanguage,
+			SrcLang:             source,
+			DataType:            "plaintext",
+		},
+	}
+
+	for _, term := range glossary.Terms {
+		unit := &tmxUnit{Variants: []*tmxVariant{{Lang: source, Seg: term.Term}}}
+		for _, language := range getGlossaryLanguages(&Glossary{Terms: []*GlossaryTerm{term}}) {
+			unit.Variants = append(unit.Variants, &tmxVariant{Lang: language, Seg: term.Translations[language]})
+		}
+		document.Units = append(document.Units, unit)
+	}
+
+	return marshalTermbase(document)
+}
+
+func marshalTermbase(document interface{}) ([]byte, error) {
+	data, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
+
+// getTermbaseSource returns the source language of a glossary file from the source_lang parameter,
+// which is empty when not given, or false when it isn't supported.
+func getTermbaseSource(r *http.Request) (string, bool) {
+	value := r.URL.Query().Get("source_lang")
+	if value == "" {
+		return "", true
+	}
+
+	source := normalizeLanguageCode(value)
+	return source, source != "" && source != autoLanguage
+}
+
+// importGlossaryHandler reads the terms of a glossary file into a glossary, merging them with its
+// terms or replacing them.
+func (p *Plugin) importGlossaryHandler(w http.ResponseWriter, r *http.Request) {
+	scope, id, ok := p.getGlossaryParams(w, r, true)
+	if !ok {
+		return
+	}
+
+	source, ok := getTermbaseSource(r)
+	if !ok {
+		writeAPIError(w, newInvalidParameterError("source_lang"))
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = termbaseModeMerge
+	}
+	if mode != termbaseModeMerge && mode != termbaseModeReplace {
+		writeAPIError(w, newInvalidParameterError("mode"))
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxTermbaseBytes)
+	var imported *Glossary
+	var err error
+	switch r.URL.Query().Get("format") {
+	case termbaseFormatCSV:
+		imported, err = parseGlossaryCSV(body)
+	case termbaseFormatTBX:
+		imported, err = parseGlossaryTBX(body, source)
+	case termbaseFormatTMX:
+		imported, err = parseGlossaryTMX(body, source)
+	default:
+		writeAPIError(w, newInvalidParameterError("format"))
+		return
+	}
+	if err != nil {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInvalidGlossary, Message: fmt.Sprintf("Invalid glossary file: %s", err.Error()), StatusCode: http.StatusBadRequest})
+		return
+	}
+
+	var invalid error
+	err = p.updateGlossary(scope, id, func(glossary *Glossary) error {
+		merged := imported
+		if mode == termbaseModeMerge {
+			merged = glossary.merge(imported)
+		}
+
+		if invalid = merged.IsValid(); invalid != nil {
+			return invalid
+		}
+
+		glossary.Terms = merged.Terms
+		return nil
+	})
+	if invalid != nil {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInvalidGlossary, Message: fmt.Sprintf("Invalid glossary: %s", invalid.Error()), StatusCode: http.StatusBadRequest})
+		return
+	}
+	if err != nil {
+		p.API.LogError("Failed to save glossary", "scope", scope, "id", id, "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorUnableToSave, Message: "Failed to save glossary", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	p.writeGlossary(w, scope, id)
+}
+
+// exportGlossaryHandler writes a glossary as a file to be loaded into other systems.
+func (p *Plugin) exportGlossaryHandler(w http.ResponseWriter, r *http.Request) {
+	scope, id, ok := p.getGlossaryParams(w, r, false)
+	if !ok {
+		return
+	}
+
+	source, ok := getTermbaseSource(r)
+	if !ok {
+		writeAPIError(w, newInvalidParameterError("source_lang"))
+		return
+	}
+	if source == "" {
+		source = enLanguage
+	}
+
+	glossary := p.getGlossary(scope, id)
+	if glossary == nil {
+		glossary = &Glossary{}
+	}
+
+	format := r.URL.Query().Get("format")
+	var data []byte
+	var err error
+	contentType := "application/xml"
+	switch format {
+	case termbaseFormatCSV:
+		data, err = formatGlossaryCSV(glossary)
+		contentType = "text/csv"
+	case termbaseFormatTBX:
+		data, err = formatGlossaryTBX(glossary, source)
+	case termbaseFormatTMX:
+		data, err = formatGlossaryTMX(glossary, source)
+	default:
+		writeAPIError(w, newInvalidParameterError("format"))
+		return
+	}
+	if err != nil {
+		p.API.LogError("Failed to export glossary", "scope", scope, "id", id, "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to export glossary", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"glossary-%s-%s.%s\"", scope, id, format))
+	w.Write(data)
+}
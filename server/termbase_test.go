@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGlossaryCSV(t *testing.T) {
+	glossary, err := parseGlossaryCSV(strings.NewReader("term,ja,fr-FR\nMattermost,,\npull request,プルリク,\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []*GlossaryTerm{
+		{Term: "Mattermost"},
+		{Term: "pull request", Translations: map[string]string{"ja": "プルリク"}},
+	}, glossary.Terms)
+
+	_, err = parseGlossaryCSV(strings.NewReader("name,ja\nMattermost,\n"))
+	assert.Error(t, err)
+
+	_, err = parseGlossaryCSV(strings.NewReader("term,xx\nMattermost,\n"))
+	assert.Error(t, err)
+}
+
+func TestParseGlossaryTBX(t *testing.T) {
+	for name, document := range map[string]string{
+		"tbx 2008": `<?xml version="1.0"?>
+<martif type="TBX" xml:lang="en-US">
+  <text><body>
+    <termEntry id="1">
+      <langSet xml:lang="en-US"><tig><term>pull request</term></tig></langSet>
+      <langSet xml:lang="ja-JP"><ntig><termGrp><term>プルリク</term></termGrp></ntig></langSet>
+    </termEntry>
+    <termEntry id="2">
+      <langSet xml:lang="en"><tig><term>Mattermost</term></tig></langSet>
+    </termEntry>
+  </body></text>
+</martif>`,
+		"tbx v3": `<?xml version="1.0"?>
+<tbx type="TBX-Basic" style="dca" xml:lang="en" xmlns="urn:iso:std:iso:30042:ed-2">
+  <tbxHeader><fileDesc><sourceDesc><p>Termbase</p></sourceDesc></fileDesc></tbxHeader>
+  <text><body>
+    <conceptEntry id="1">
+      <langSec xml:lang="en"><termSec><term>pull request</term></termSec></langSec>
+      <langSec xml:lang="ja"><termSec><term>プルリク</term></termSec></langSec>
+    </conceptEntry>
+    <conceptEntry id="2">
+      <langSec xml:lang="en"><termSec><term>Mattermost</term></termSec></langSec>
+    </conceptEntry>
+  </body></text>
+</tbx>`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			glossary, err := parseGlossaryTBX(strings.NewReader(document), "")
+			require.NoError(t, err)
+			assert.Equal(t, []*GlossaryTerm{
+				{Term: "pull request", Translations: map[string]string{"ja": "プルリク"}},
+				{Term: "Mattermost"},
+			}, glossary.Terms)
+		})
+	}
+}
+
+func TestParseGlossaryTMX(t *testing.T) {
+	glossary, err := parseGlossaryTMX(strings.NewReader(`<?xml version="1.0"?>
+<tmx version="1.4">
+  <header srclang="ja" segtype="phrase"/>
+  <body>
+    <tu>
+      <tuv xml:lang="ja"><seg>会議</seg></tuv>
+      <tuv xml:lang="en"><seg>meeting</seg></tuv>
+    </tu>
+    <tu>
+      <tuv xml:lang="en"><seg>no source</seg></tuv>
+    </tu>
+  </body>
+</tmx>`), "")
+	require.NoError(t, err)
+	assert.Equal(t, []*GlossaryTerm{
+		{Term: "会議", Translations: map[string]string{"en": "meeting"}},
+	}, glossary.Terms)
+}
+
+func TestGlossaryFormatsRoundTrip(t *testing.T) {
+	glossary := &Glossary{Terms: []*GlossaryTerm{
+		{Term: "Mattermost"},
+		{Term: "pull request", Translations: map[string]string{"ja": "プルリク", "fr": "demande de fusion"}},
+	}}
+
+	data, err := formatGlossaryCSV(glossary)
+	require.NoError(t, err)
+	assert.Equal(t, "term,fr,ja\nMattermost,,\npull request,demande de fusion,プルリク\n", string(data))
+	parsed, err := parseGlossaryCSV(strings.NewReader(string(data)))
+	require.NoError(t, err)
+	assert.Equal(t, glossary.Terms, parsed.Terms)
+
+	data, err = formatGlossaryTBX(glossary, "en")
+	require.NoError(t, err)
+	parsed, err = parseGlossaryTBX(strings.NewReader(string(data)), "")
+	require.NoError(t, err)
+	assert.Equal(t, glossary.Terms, parsed.Terms)
+
+	data, err = formatGlossaryTMX(glossary, "en")
+	require.NoError(t, err)
+	parsed, err = parseGlossaryTMX(strings.NewReader(string(data)), "")
+	require.NoError(t, err)
+	assert.Equal(t, glossary.Terms, parsed.Terms)
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+const (
+	tracingExporterOff  = "off"
+	tracingExporterOTLP = "otlp"
+	tracingExporterLog  = "log"
+
+	// tracingFlushInterval is how often the spans ended since are exported, spans being exported
+	// in batches rather than one by one.
+	tracingFlushInterval = 5 * time.Second
+
+	tracingTimeout = 10 * time.Second
+
+	// maxPendingSpans bounds the spans waiting to be exported, spans above it being dropped when
+	// the exporter can't keep up.
+	maxPendingSpans = 2048
+
+	// traceparentHeader carries the trace of a request as defined by W3C Trace Context.
+	traceparentHeader = "traceparent"
+
+	// Kinds of spans, as numbered by OTLP.
+	spanKindInternal = 1
+	spanKindServer   = 2
+	spanKindClient   = 3
+
+	// spanStatusError is the OTLP status of failed spans.
+	spanStatusError = 2
+)
+
+// spanContextKey holds the span the spans started with a context are children of.
+const spanContextKey contextKey = "span"
+
+// traceSpan times an operation of a translation for tracing. The spans of remote parents, told by
+// the traceparent header of requests, are only kept to continue their trace.
+type traceSpan struct {
+	recorder *spanRecorder
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	sampled  bool
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+	err      string
+
+	// attributes are pairs of names and values, like the ones of log entries.
+	attributes []interface{}
+}
+
+// setAttributes adds attributes to a span, known once the operation is done.
+func (s *traceSpan) setAttributes(keyValuePairs ...interface{}) {
+	if s == nil {
+		return
+	}
+
+	s.attributes = append(s.attributes, keyValuePairs...)
+}
+
+// finish ends a span, failed when err isn't nil, and queues it to be exported. Spans are nil when
+// tracing is off.
+func (s *traceSpan) finish(err error) {
+	if s == nil || !s.sampled {
+		return
+	}
+
+	s.end = time.Now()
+	if err != nil {
+		s.err = err.Error()
+	}
+	s.recorder.record(s)
+}
+
+// spanRecorder holds the spans ended since they were last exported.
+type spanRecorder struct {
+	lock    sync.Mutex
+	spans   []*traceSpan
+	dropped int64
+}
+
+func (r *spanRecorder) record(s *traceSpan) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if len(r.spans) >= maxPendingSpans {
+		r.dropped++
+		return
+	}
+	r.spans = append(r.spans, s)
+}
+
+// drain returns the spans ended since the last drain along with the number of spans dropped.
+func (r *spanRecorder) drain() ([]*traceSpan, int64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	spans, dropped := r.spans, r.dropped
+	r.spans = nil
+	r.dropped = 0
+
+	return spans, dropped
+}
+
+// count returns the number of spans waiting to be exported and of spans dropped since the last
+// export.
+func (r *spanRecorder) count() (int, int64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return len(r.spans), r.dropped
+}
+
+func getSpan(ctx context.Context) *traceSpan {
+	s, _ := ctx.Value(spanContextKey).(*traceSpan)
+	return s
+}
+
+// parseTraceparent returns the remote parent told by a traceparent header, or nil when the header
+// is missing or invalid.
+func parseTraceparent(value string) *traceSpan {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return nil
+	}
+
+	traceID, err := hex.DecodeString(parts[1])
+	if err != nil || len(traceID) != 16 || bytes.Equal(traceID, make([]byte, 16)) {
+		return nil
+	}
+	spanID, err := hex.DecodeString(parts[2])
+	if err != nil || len(spanID) != 8 || bytes.Equal(spanID, make([]byte, 8)) {
+		return nil
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 {
+		return nil
+	}
+
+	parent := &traceSpan{sampled: flags[0]&1 == 1}
+	copy(parent.traceID[:], traceID)
+	copy(parent.spanID[:], spanID)
+
+	return parent
+}
+
+// startSpan starts a span as a child of the span of ctx, if any, returning the context carrying
+// it. The span is nil when tracing is off.
+func (p *Plugin) startSpan(ctx context.Context, name string, kind int, keyValuePairs ...interface{}) (context.Context, *traceSpan) {
+	if p.getConfiguration().getTracingExporter() == tracingExporterOff {
+		return ctx, nil
+	}
+
+	s := &traceSpan{
+		recorder:   &p.spans,
+		sampled:    true,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: keyValuePairs,
+	}
+	if parent := getSpan(ctx); parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+		s.sampled = parent.sampled
+	} else {
+		_, _ = rand.Read(s.traceID[:])
+	}
+	_, _ = rand.Read(s.spanID[:])
+
+	return context.WithValue(ctx, spanContextKey, s), s
+}
+
+// withTracing starts a span for every request to the API, continuing the trace of the traceparent
+// header of the request, if any.
+func (p *Plugin) withTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if parent := parseTraceparent(r.Header.Get(traceparentHeader)); parent != nil {
+			ctx = context.WithValue(ctx, spanContextKey, parent)
+		}
+
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		ctx, span := p.startSpan(ctx, r.Method+" "+route, spanKindServer, "http.method", r.Method, "http.route", route, "request_id", getRequestID(ctx))
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.setAttributes("http.status_code", recorder.status)
+		var err error
+		if recorder.status >= http.StatusInternalServerError {
+			err = errors.New(http.StatusText(recorder.status))
+		}
+		span.finish(err)
+	})
+}
+
+// parseTracingHeaders parses the comma-separated name=value headers sent along with exported spans.
+func parseTracingHeaders(value string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, header := range strings.Split(value, ",") {
+		if strings.TrimSpace(header) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(header, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" {
+			return nil, errors.Errorf("Tracing headers must be name=value pairs, not \"%s\"", strings.TrimSpace(header))
+		}
+		headers[name] = strings.TrimSpace(parts[1])
+	}
+
+	return headers, nil
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+// getOTLPAttributes returns pairs of names and values as OTLP attributes, values of other types
+// than strings, booleans and numbers being formatted as strings.
+func getOTLPAttributes(keyValuePairs []interface{}) []otlpAttribute {
+	var attributes []otlpAttribute
+	for i := 0; i+1 < len(keyValuePairs); i += 2 {
+		var value map[string]interface{}
+		switch v := keyValuePairs[i+1].(type) {
+		case string:
+			value = map[string]interface{}{"stringValue": v}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		attributes = append(attributes, otlpAttribute{Key: fmt.Sprint(keyValuePairs[i]), Value: value})
+	}
+
+	return attributes
+}
+
+// newOTLPTraceRequest returns the body of an OTLP/HTTP request exporting spans in JSON.
+func newOTLPTraceRequest(spans []*traceSpan) ([]byte, error) {
+	otlpSpans := make([]*otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		exported := &otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        getOTLPAttributes(s.attributes),
+		}
+		if s.parentID != [8]byte{} {
+			exported.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		if s.err != "" {
+			exported.Status = otlpStatus{Code: spanStatusError, Message: s.err}
+		}
+		otlpSpans = append(otlpSpans, exported)
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": getOTLPAttributes([]interface{}{"service.name", manifest.Id, "service.version", manifest.Version}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": manifest.Id, "version": manifest.Version},
+				"spans": otlpSpans,
+			}},
+		}},
+	})
+}
+
+// startTracing exports the spans ended since periodically until stopTracing is called.
+func (p *Plugin) startTracing() {
+	p.tracingStop = make(chan struct{})
+	stop := p.tracingStop
+
+	go func() {
+		ticker := time.NewTicker(tracingFlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.flushSpans()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (p *Plugin) stopTracing() {
+	if p.tracingStop != nil {
+		close(p.tracingStop)
+		p.tracingStop = nil
+	}
+
+	p.flushSpans()
+}
+
+// flushSpans exports the spans ended since they were last exported. Spans which fail to be
+// exported are dropped, as tracing isn't worth retrying.
+func (p *Plugin) flushSpans() {
+	spans, dropped := p.spans.drain()
+	if dropped > 0 {
+		p.API.LogWarn("Dropped spans above the limit of pending spans", "dropped", dropped)
+	}
+	if len(spans) == 0 {
+		return
+	}
+
+	config := p.getConfiguration()
+	switch config.getTracingExporter() {
+	case tracingExporterLog:
+		for _, s := range spans {
+			keyValuePairs := []interface{}{
+				"trace_id", hex.EncodeToString(s.traceID[:]),
+				"span_id", hex.EncodeToString(s.spanID[:]),
+				"parent_span_id", hex.EncodeToString(s.parentID[:]),
+				"name", s.name,
+				"duration", s.end.Sub(s.start).String(),
+				"error", s.err,
+			}
+			p.API.LogInfo("Trace span", append(keyValuePairs, s.attributes...)...)
+		}
+	case tracingExporterOTLP:
+		if err := p.exportSpans(config, spans); err != nil {
+			p.API.LogWarn("Failed to export spans", "spans", len(spans), "err", err.Error())
+		}
+	}
+}
+
+func (p *Plugin) exportSpans(config *configuration, spans []*traceSpan) error {
+	body, err := newOTLPTraceRequest(spans)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal spans")
+	}
+
+	headers, err := parseTracingHeaders(config.TracingHeaders)
+	if err != nil {
+		return err
+	}
+
+	client, err := newOutboundHTTPClient(config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tracingTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(config.TracingEndpoint, "/")+"/v1/traces", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "invalid tracing endpoint")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "unable to reach the tracing endpoint")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return errors.Errorf("tracing endpoint answered %s", resp.Status)
+	}
+
+	return nil
+}
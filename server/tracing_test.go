@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	parent := parseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NotNil(t, parent)
+	assert.Equal(t, [16]byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}, parent.traceID)
+	assert.Equal(t, [8]byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}, parent.spanID)
+	assert.True(t, parent.sampled)
+
+	parent = parseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	require.NotNil(t, parent)
+	assert.False(t, parent.sampled)
+
+	// Later versions may add fields after the flags.
+	assert.NotNil(t, parseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"))
+
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz",
+	} {
+		assert.Nil(t, parseTraceparent(value), value)
+	}
+}
+
+func TestParseTracingHeaders(t *testing.T) {
+	headers, err := parseTracingHeaders(" api-key = secret ,X-Tenant=team=1,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"api-key": "secret", "X-Tenant": "team=1"}, headers)
+
+	headers, err = parseTracingHeaders("")
+	require.NoError(t, err)
+	assert.Empty(t, headers)
+
+	_, err = parseTracingHeaders("api-key")
+	assert.Error(t, err)
+	_, err = parseTracingHeaders("=secret")
+	assert.Error(t, err)
+}
+
+func TestSpanRecorder(t *testing.T) {
+	var recorder spanRecorder
+	for i := 0; i < maxPendingSpans+2; i++ {
+		recorder.record(&traceSpan{})
+	}
+
+	spans, dropped := recorder.drain()
+	assert.Len(t, spans, maxPendingSpans)
+	assert.Equal(t, int64(2), dropped)
+
+	spans, dropped = recorder.drain()
+	assert.Empty(t, spans)
+	assert.Zero(t, dropped)
+
+	// Spans of unsampled traces and of tracing turned off aren't recorded.
+	(&traceSpan{recorder: &recorder}).finish(nil)
+	var off *traceSpan
+	off.setAttributes("hit", true)
+	off.finish(nil)
+	spans, _ = recorder.drain()
+	assert.Empty(t, spans)
+}
+
+func TestNewOTLPTraceRequest(t *testing.T) {
+	start := time.Unix(1591963200, 0)
+	parent := &traceSpan{
+		traceID:    [16]byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		spanID:     [8]byte{1, 2, 3, 4, 5, 6, 7, 8},
+		name:       "GET /api/v1/posts/{post_id}/translation",
+		kind:       spanKindServer,
+		start:      start,
+		end:        start.Add(300 * time.Millisecond),
+		attributes: []interface{}{"http.status_code", 200, "hit", false},
+	}
+	child := &traceSpan{
+		traceID:    parent.traceID,
+		spanID:     [8]byte{9, 10, 11, 12, 13, 14, 15, 16},
+		parentID:   parent.spanID,
+		name:       "provider_request",
+		kind:       spanKindClient,
+		start:      start.Add(100 * time.Millisecond),
+		end:        start.Add(200 * time.Millisecond),
+		err:        "throttled",
+		attributes: []interface{}{"provider", "aws", "latency", 1.5},
+	}
+
+	body, err := newOTLPTraceRequest([]*traceSpan{parent, child})
+	require.NoError(t, err)
+
+	var request struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []otlpAttribute `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []*otlpSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	require.NoError(t, json.Unmarshal(body, &request))
+	require.Len(t, request.ResourceSpans, 1)
+	assert.Equal(t, otlpAttribute{Key: "service.name", Value: map[string]interface{}{"stringValue": manifest.Id}}, request.ResourceSpans[0].Resource.Attributes[0])
+
+	require.Len(t, request.ResourceSpans[0].ScopeSpans, 1)
+	assert.Equal(t, []*otlpSpan{
+		{
+			TraceID:           "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanID:            "0102030405060708",
+			Name:              "GET /api/v1/posts/{post_id}/translation",
+			Kind:              spanKindServer,
+			StartTimeUnixNano: "1591963200000000000",
+			EndTimeUnixNano:   "1591963200300000000",
+			Attributes: []otlpAttribute{
+				{Key: "http.status_code", Value: map[string]interface{}{"intValue": "200"}},
+				{Key: "hit", Value: map[string]interface{}{"boolValue": false}},
+			},
+		},
+		{
+			TraceID:           "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanID:            "090a0b0c0d0e0f10",
+			ParentSpanID:      "0102030405060708",
+			Name:              "provider_request",
+			Kind:              spanKindClient,
+			StartTimeUnixNano: "1591963200100000000",
+			EndTimeUnixNano:   "1591963200200000000",
+			Attributes: []otlpAttribute{
+				{Key: "provider", Value: map[string]interface{}{"stringValue": "aws"}},
+				{Key: "latency", Value: map[string]interface{}{"doubleValue": 1.5}},
+			},
+			Status: otlpStatus{Code: spanStatusError, Message: "throttled"},
+		},
+	}, request.ResourceSpans[0].ScopeSpans[0].Spans)
+}
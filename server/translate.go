@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/translate"
+)
+
+const providerAWS = "aws"
+
+// getSupportedLanguages returns the languages supported by a translation provider, sorted by name.
+func getSupportedLanguages(provider string) []*Language {
+	var languages []*Language
+	for code, name := range languageCodes {
+		if supportsLanguagePair(provider, code, code) {
+			languages = append(languages, &Language{Code: code, Name: name})
+		}
+	}
+
+	sort.Slice(languages, func(i, j int) bool {
+		return languages[i].Name < languages[j].Name
+	})
+
+	return languages
+}
+
+// translateTextWithContext translates text from source to target language, abandoning the
+// request to the provider once ctx is done. Mentions, channel references, personal data when
+// redacted, protected patterns and emojis are masked from the provider so that they come back
+// untouched, as are the glossary terms and the names of the team carried by ctx.
+func (p *Plugin) translateTextWithContext(ctx context.Context, svc *translate.Translate, source, target, text string) (string, error) {
+	translated, _, err := p.translateTextWithSource(ctx, svc, source, target, text)
+	return translated, err
+}
+
+// translateTextWithSource translates text like translateTextWithContext, also returning the
+// language it was translated from, which the provider detects when the source is auto. The
+// provider routes may send the language pair to another provider than the one of svc.
+func (p *Plugin) translateTextWithSource(ctx context.Context, svc *translate.Translate, source, target, text string) (string, string, error) {
+	provider, svc, err := p.getRoutedService(ctx, svc, source, target, text)
+	if err != nil {
+		p.API.LogWarn("Failed to get routed translation provider", "provider", provider, "err", err.Error())
+		return "", "", err
+	}
+
+	if err = p.checkProviderPolicy(ctx, provider); err != nil {
+		p.recordAudit(ctx, provider, source, target, utf8.RuneCountInString(text), err)
+		return "", "", err
+	}
+
+	if err = checkLanguagePair(provider, source, target); err != nil {
+		p.API.LogDebug("Unsupported language pair", "request_id", getRequestID(ctx), "err", err.Error())
+		return "", "", err
+	}
+
+	ph := &placeholders{}
+	config := p.getConfiguration()
+	masked := ph.maskMentions(ph.maskLiteralPlaceholders(text))
+	if config.RedactPersonalData {
+		masked = ph.redactPersonalData(masked)
+	}
+	masked = ph.maskPatterns(config.getProtectedPatterns(), masked)
+	masked = ph.maskTerms(getContextGlossary(ctx), target, ph.maskEmojis(masked))
+	masked = ph.maskVocabulary(getContextVocabulary(ctx), masked)
+
+	input := translate.TextInput{
+		SourceLanguageCode: &source,
+		TargetLanguageCode: &target,
+		Text:               &masked,
+	}
+
+	characters := utf8.RuneCountInString(text)
+	// The text is only redacted when it is logged, as redacting it takes several passes over it.
+	if config.DebugLogging {
+		p.traceTranslation(ctx, "provider_request", "provider", provider, "source", source, "target", target, "characters", characters, "text", redactForLog(text))
+	}
+
+	providerCtx, span := p.startSpan(ctx, "provider_request", spanKindClient, "provider", provider, "source", source, "target", target, "characters", characters)
+	start := time.Now()
+	done := p.watchSlowTranslation(ctx, provider)
+	output, err := svc.TextWithContext(providerCtx, &input)
+	done()
+	latency := time.Since(start)
+	span.finish(err)
+	p.recordUsage(ctx, provider, characters, latency, err)
+	p.traceTranslation(ctx, "provider_response", "provider", provider, "duration", latency.String(), "failed", err != nil)
+	if err != nil {
+		providerErr := newProviderError(ctx, err)
+		p.API.LogWarn("Translation provider request failed", "request_id", providerErr.requestID, "provider_request_id", providerErr.providerRequestID, "err", err.Error())
+		p.recordAudit(ctx, provider, source, target, characters, providerErr)
+		return "", "", providerErr
+	}
+
+	if output.SourceLanguageCode != nil && *output.SourceLanguageCode != "" {
+		source = *output.SourceLanguageCode
+	}
+	p.recordPairLatency(provider, source, target, latency)
+	p.recordAudit(ctx, provider, source, target, characters, nil)
+
+	translated := ph.restore(*output.TranslatedText)
+	if config.CheckOutputLanguage {
+		if detected := getWrongOutputLanguage(target, translated); detected != "" {
+			return p.retryOutputLanguage(ctx, provider, source, target, text, detected)
+		}
+	}
+
+	return translated, source, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// newOutboundHTTPClient returns an HTTP client going through the outbound proxy of a
+// configuration, for every request the plugin makes outside of the Mattermost server.
+func newOutboundHTTPClient(configuration *configuration) (*http.Client, error) {
+	transport, err := newOutboundTransport(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// newOutboundTransport returns a copy of the default transport going through the outbound proxy
+// set by admins, or through the proxy of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment
+// variables of the server when none is set, as the default transport does.
+func newOutboundTransport(configuration *configuration) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if configuration.OutboundProxy == "" {
+		return transport, nil
+	}
+
+	proxyURL, err := parseProxyURL(configuration.OutboundProxy)
+	if err != nil {
+		return nil, err
+	}
+	noProxy := splitNoProxy(configuration.OutboundNoProxy)
+
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if bypassesProxy(req.URL.Hostname(), noProxy) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}
+
+	return transport, nil
+}
+
+// parseProxyURL parses the URL of an outbound proxy, which may hold the credentials of the proxy.
+func parseProxyURL(value string) (*url.URL, error) {
+	proxyURL, err := url.Parse(value)
+	if err != nil || proxyURL.Host == "" {
+		return nil, errors.New("outbound proxy must be a URL like http://proxy.example.com:3128")
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, errors.New("outbound proxy must be an http, https or socks5 URL")
+	}
+
+	return proxyURL, nil
+}
+
+// splitNoProxy returns the hosts of a comma-separated list of hosts reached without the outbound
+// proxy, lowercased.
+func splitNoProxy(value string) []string {
+	var hosts []string
+	for _, host := range strings.Split(value, ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
+// bypassesProxy reports whether a host is reached without the outbound proxy, listed as is, as a
+// parent domain, such as example.com or .example.com for api.example.com, or within an IP range,
+// * listing every host.
+func bypassesProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+
+	for _, entry := range noProxy {
+		if entry == "*" || entry == host {
+			return true
+		}
+
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && ipNet.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		if ip == nil && strings.HasSuffix(host, "."+strings.TrimPrefix(entry, ".")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newProviderHTTPClient returns the HTTP client the AWS clients of a configuration make their
+// requests with, going through the outbound proxy, trusting the CA certificates set by admins on
+// top of the system ones and authenticating with the client certificate set by admins, such as
+// for an internal gateway behind the AWS endpoint using a private CA and mutual TLS. It returns
+// nil when there is nothing to change from the default client.
+func newProviderHTTPClient(configuration *configuration) (*http.Client, error) {
+	tlsConfig, err := newProviderTLSConfig(configuration)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil && configuration.OutboundProxy == "" {
+		return nil, nil
+	}
+
+	transport, err := newOutboundTransport(configuration)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// newProviderTLSConfig returns the TLS configuration of the CA certificates and the client
+// certificate of a configuration, or nil when it has none.
+func newProviderTLSConfig(configuration *configuration) (*tls.Config, error) {
+	if configuration.AWSCACertificates == "" && configuration.AWSClientCertificate == "" && configuration.AWSClientKey == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if configuration.AWSCACertificates != "" {
+		// The system pool isn't available on every platform, in which case only the CA
+		// certificates set by admins are trusted.
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(configuration.AWSCACertificates)) {
+			return nil, errors.New("no PEM certificate found in the AWS CA certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if configuration.AWSClientCertificate != "" || configuration.AWSClientKey != "" {
+		certificate, err := tls.X509KeyPair([]byte(configuration.AWSClientCertificate), []byte(configuration.AWSClientKey))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid AWS client certificate or key")
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
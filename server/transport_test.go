@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCertificate returns a PEM certificate and key signed by parent, or self-signed when
+// parent is nil.
+func newTestCertificate(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return certificate, key,
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestNewProviderHTTPClient(t *testing.T) {
+	ca, caKey, caPEM, _ := newTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+
+	server, serverKey, _, _ := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "gateway"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}, ca, caKey)
+
+	_, _, clientPEM, clientKeyPEM := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "mattermost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+
+	gateway := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	gateway.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{server.Raw}, PrivateKey: serverKey}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	gateway.StartTLS()
+	defer gateway.Close()
+
+	t.Run("no TLS settings", func(t *testing.T) {
+		client, err := newProviderHTTPClient(&configuration{})
+		assert.NoError(t, err)
+		assert.Nil(t, client)
+	})
+
+	t.Run("CA and client certificate", func(t *testing.T) {
+		client, err := newProviderHTTPClient(&configuration{AWSCACertificates: caPEM, AWSClientCertificate: clientPEM, AWSClientKey: clientKeyPEM})
+		require.NoError(t, err)
+
+		resp, err := client.Get(gateway.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("CA without client certificate", func(t *testing.T) {
+		client, err := newProviderHTTPClient(&configuration{AWSCACertificates: caPEM})
+		require.NoError(t, err)
+
+		_, err = client.Get(gateway.URL)
+		assert.Error(t, err)
+	})
+
+	t.Run("client certificate without CA", func(t *testing.T) {
+		client, err := newProviderHTTPClient(&configuration{AWSClientCertificate: clientPEM, AWSClientKey: clientKeyPEM})
+		require.NoError(t, err)
+
+		_, err = client.Get(gateway.URL)
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid CA certificates", func(t *testing.T) {
+		_, err := newProviderHTTPClient(&configuration{AWSCACertificates: "not a certificate"})
+		assert.Error(t, err)
+	})
+
+	t.Run("key not matching certificate", func(t *testing.T) {
+		_, _, _, otherKeyPEM := newTestCertificate(t, &x509.Certificate{SerialNumber: big.NewInt(4), NotAfter: time.Now().Add(time.Hour)}, ca, caKey)
+
+		_, err := newProviderHTTPClient(&configuration{AWSClientCertificate: clientPEM, AWSClientKey: otherKeyPEM})
+		assert.Error(t, err)
+	})
+}
+
+func TestBypassesProxy(t *testing.T) {
+	noProxy := splitNoProxy("vault.internal, .example.com,corp.test ,10.0.0.0/8,::1")
+
+	for host, expected := range map[string]bool{
+		"vault.internal":             true,
+		"VAULT.internal":             true,
+		"other.internal":             false,
+		"example.com":                false,
+		"api.example.com":            true,
+		"corp.test":                  true,
+		"gateway.corp.test":          true,
+		"notcorp.test":               false,
+		"10.1.2.3":                   true,
+		"11.1.2.3":                   false,
+		"::1":                        true,
+		"translate.us-east-1.aws.io": false,
+	} {
+		assert.Equal(t, expected, bypassesProxy(host, noProxy), host)
+	}
+
+	assert.True(t, bypassesProxy("anything.example.org", splitNoProxy("*")))
+	assert.False(t, bypassesProxy("anything.example.org", splitNoProxy("")))
+}
+
+func TestNewOutboundHTTPClient(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer direct.Close()
+
+	client, err := newOutboundHTTPClient(&configuration{OutboundProxy: proxy.URL, OutboundNoProxy: "127.0.0.0/8"})
+	require.NoError(t, err)
+
+	resp, err := client.Get("http://translate.example.com/translate")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, []string{"http://translate.example.com/translate"}, proxied)
+
+	resp, err = client.Get(direct.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, proxied, 1)
+
+	_, err = newOutboundHTTPClient(&configuration{OutboundProxy: "ftp://proxy.example.com"})
+	assert.Error(t, err)
+
+	_, err = newOutboundHTTPClient(&configuration{OutboundProxy: "proxy.example.com:3128"})
+	assert.Error(t, err)
+}
+
+func TestNewProviderHTTPClientWithProxy(t *testing.T) {
+	client, err := newProviderHTTPClient(&configuration{OutboundProxy: "http://proxy.example.com:3128"})
+	require.NoError(t, err)
+	require.NotNil(t, client)
+
+	proxyURL, err := client.Transport.(*http.Transport).Proxy(httptest.NewRequest(http.MethodPost, "https://translate.us-east-1.amazonaws.com/", nil))
+	require.NoError(t, err)
+	assert.Equal(t, "http://proxy.example.com:3128", proxyURL.String())
+}
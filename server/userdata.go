@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// UserDataDeleteResponse is the number of KV keys deleted or anonymized along with the data of
+// users
+type UserDataDeleteResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+// getKeyUserID returns the ID of the user whose settings, language profile, translation history or
+// consent a key holds, or an empty string for other keys.
+func getKeyUserID(key string) string {
+	switch {
+	case model.IsValidId(key):
+		// User infos are stored under the user ID.
+		return key
+	case strings.HasPrefix(key, languageProfileKeyPrefix):
+		key = strings.TrimPrefix(key, languageProfileKeyPrefix)
+	case strings.HasPrefix(key, historyKeyPrefix):
+		key = strings.TrimPrefix(key, historyKeyPrefix)
+	case strings.HasPrefix(key, consentKeyPrefix):
+		key = strings.TrimPrefix(key, consentKeyPrefix)
+	default:
+		return ""
+	}
+
+	if !model.IsValidId(key) {
+		return ""
+	}
+
+	return key
+}
+
+// anonymizeAuditEntries removes the users whose data is deleted from audit entries, returning
+// whether any entry was changed. The entries themselves are kept, as they record the use of the
+// provider rather than the users.
+func anonymizeAuditEntries(entries []*AuditEntry, userIDs map[string]bool) bool {
+	changed := false
+	for _, entry := range entries {
+		if userIDs[entry.UserID] {
+			entry.UserID = ""
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// deleteUserData deletes the settings, language profiles, translation histories, consents and
+// translation ratings of users along with the translations of their posts, and removes them from
+// the audit log and the translation volume, returning the number of KV keys deleted or anonymized.
+// Audit entries written to the server log are out of reach of the plugin.
+func (p *Plugin) deleteUserData(userIDs map[string]bool) (int, error) {
+	// Audit entries and volume not saved yet are saved first, so that they are anonymized along
+	// with the saved ones.
+	p.flushAuditEntries()
+	p.flushVolumeStats()
+
+	// Keys are collected before being deleted, as deleting them would shift the pages.
+	var keys []string
+	for page := 0; ; page++ {
+		pageKeys, appErr := p.API.KVList(page, keysPerPage)
+		if appErr != nil {
+			return 0, appErr
+		}
+
+		for _, key := range pageKeys {
+			if userIDs[getKeyUserID(key)] || strings.HasPrefix(key, feedbackKeyPrefix) || strings.HasPrefix(key, auditKeyPrefix) || strings.HasPrefix(key, volumeKeyPrefix) || isTranslationKey(key) {
+				keys = append(keys, key)
+			}
+		}
+
+		if len(pageKeys) < keysPerPage {
+			break
+		}
+	}
+
+	for userID := range userIDs {
+		if err := p.updateActivatedUsers(userID, false); err != nil {
+			return 0, errors.Wrap(err, "unable to remove user from activated users")
+		}
+	}
+
+	// Posts have many translations, so their authors are only looked up once.
+	authors := map[string]string{}
+
+	deleted := 0
+	for _, key := range keys {
+		var err error
+		switch {
+		case strings.HasPrefix(key, auditKeyPrefix):
+			var anonymized bool
+			if anonymized, err = p.anonymizeAuditKey(key, userIDs); anonymized {
+				deleted++
+			}
+		case strings.HasPrefix(key, volumeKeyPrefix):
+			var removed bool
+			if removed, err = p.removeVolumeUsers(key, userIDs); removed {
+				deleted++
+			}
+		case strings.HasPrefix(key, feedbackKeyPrefix):
+			var removed bool
+			if removed, err = p.deleteUserFeedback(key, userIDs); removed {
+				deleted++
+			}
+		case isTranslationKey(key):
+			var removed bool
+			if removed, err = p.deleteUserTranslations(key, userIDs, authors); removed {
+				deleted++
+			}
+		default:
+			if appErr := p.API.KVDelete(key); appErr != nil {
+				err = appErr
+			} else {
+				deleted++
+			}
+		}
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
+
+// deleteUserFeedback deletes the rating of a translation stored under a key when it was made by
+// one of the users whose data is deleted.
+func (p *Plugin) deleteUserFeedback(key string, userIDs map[string]bool) (bool, error) {
+	data, appErr := p.API.KVGet(key)
+	if appErr != nil {
+		return false, appErr
+	}
+	if data == nil {
+		return false, nil
+	}
+
+	var feedback TranslationFeedback
+	if err := json.Unmarshal(data, &feedback); err != nil {
+		return false, errors.Wrap(err, "unable to unmarshal feedback")
+	}
+	if !userIDs[feedback.UserID] {
+		return false, nil
+	}
+
+	if appErr := p.API.KVDelete(key); appErr != nil {
+		return false, appErr
+	}
+
+	return true, nil
+}
+
+// isTranslationKey reports whether a key holds a cached translation or the translations of a post
+// delivered in place, which hold the text of the post.
+func isTranslationKey(key string) bool {
+	return strings.HasPrefix(key, translationCacheKeyPrefix) || strings.HasPrefix(key, storedTranslationsKeyPrefix)
+}
+
+// deleteUserTranslations deletes the translations stored under a key when they are of a post
+// written by one of the users whose data is deleted, looking up authors in and adding them to
+// authors, keyed by post ID. Cached translations which can't be read anymore, such as after the
+// encryption key changed, are deleted too, as whose text they hold can't be told. Translations of
+// posts deleted since are left to the retention.
+func (p *Plugin) deleteUserTranslations(key string, userIDs map[string]bool, authors map[string]string) (bool, error) {
+	postID := strings.TrimPrefix(key, storedTranslationsKeyPrefix)
+	if strings.HasPrefix(key, translationCacheKeyPrefix) {
+		data, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return false, appErr
+		}
+		if data == nil {
+			return false, nil
+		}
+
+		postID = ""
+		var translated *TranslatedMessage
+		if plain, err := decryptValue(p.getConfiguration().EncryptionKey, data); err == nil && json.Unmarshal(plain, &translated) == nil && translated != nil {
+			postID = translated.PostID
+		}
+	}
+
+	if postID != "" {
+		author, ok := authors[postID]
+		if !ok {
+			if post, appErr := p.API.GetPost(postID); appErr == nil {
+				author = post.UserId
+			}
+			authors[postID] = author
+		}
+		if !userIDs[author] {
+			return false, nil
+		}
+	}
+
+	if appErr := p.API.KVDelete(key); appErr != nil {
+		return false, appErr
+	}
+
+	return true, nil
+}
+
+// anonymizeAuditKey removes the users whose data is deleted from the audit entries of a key with a
+// compare and set, as entries may be saved at the same time.
+func (p *Plugin) anonymizeAuditKey(key string, userIDs map[string]bool) (bool, error) {
+	for attempt := 0; attempt < maxAuditSaveAttempts; attempt++ {
+		oldBytes, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return false, appErr
+		}
+		if oldBytes == nil {
+			return false, nil
+		}
+
+		var entries []*AuditEntry
+		if err := json.Unmarshal(oldBytes, &entries); err != nil {
+			return false, errors.Wrap(err, "unable to unmarshal audit entries")
+		}
+		if !anonymizeAuditEntries(entries, userIDs) {
+			return false, nil
+		}
+
+		newBytes, err := json.Marshal(entries)
+		if err != nil {
+			return false, errors.Wrap(err, "unable to marshal audit entries")
+		}
+
+		// The expiry of the entries is kept from the retention, as it can't be read back.
+		config := p.getConfiguration()
+		updated, appErr := p.API.KVSetWithOptions(key, newBytes, model.PluginKVSetOptions{
+			Atomic:          true,
+			OldValue:        oldBytes,
+			ExpireInSeconds: config.getRetentionExpiry(int64(config.getAuditLogRetention() * 24 * 60 * 60)),
+		})
+		if appErr != nil {
+			return false, appErr
+		}
+		if updated {
+			return true, nil
+		}
+	}
+
+	return false, errors.New("audit entries kept changing concurrently")
+}
+
+// deleteDeactivatedUsersData deletes the data of the users deactivated or deleted since, when
+// admins turned it on, as no hook tells the plugin about deactivated users.
+func (p *Plugin) deleteDeactivatedUsersData() (int, error) {
+	if !p.getConfiguration().DeleteDeactivatedUsersData {
+		return 0, nil
+	}
+
+	var userIDs []string
+	seen := map[string]bool{}
+	for page := 0; ; page++ {
+		pageKeys, appErr := p.API.KVList(page, keysPerPage)
+		if appErr != nil {
+			return 0, appErr
+		}
+
+		for _, key := range pageKeys {
+			if userID := getKeyUserID(key); userID != "" && !seen[userID] {
+				seen[userID] = true
+				userIDs = append(userIDs, userID)
+			}
+		}
+
+		if len(pageKeys) < keysPerPage {
+			break
+		}
+	}
+
+	deactivated := map[string]bool{}
+	for _, userID := range userIDs {
+		user, appErr := p.API.GetUser(userID)
+		if appErr != nil {
+			if appErr.StatusCode == http.StatusNotFound {
+				deactivated[userID] = true
+			}
+			continue
+		}
+		if user.DeleteAt != 0 {
+			deactivated[userID] = true
+		}
+	}
+
+	if len(deactivated) == 0 {
+		return 0, nil
+	}
+
+	return p.deleteUserData(deactivated)
+}
+
+func (p *Plugin) writeUserDataDeleteResponse(w http.ResponseWriter, userID string) {
+	deleted, err := p.deleteUserData(map[string]bool{userID: true})
+	if err != nil {
+		p.API.LogError("Failed to delete user data", "user_id", userID, "deleted", deleted, "err", err.Error())
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorInternal, Message: "Failed to delete user data", StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	p.API.LogInfo("Deleted user data", "user_id", userID, "deleted", deleted)
+
+	resp, _ := json.Marshal(&UserDataDeleteResponse{Deleted: deleted})
+	w.Write(resp)
+}
+
+// deleteInfo deletes the data of the current user, on their request.
+func (p *Plugin) deleteInfo(w http.ResponseWriter, r *http.Request) {
+	p.writeUserDataDeleteResponse(w, r.Header.Get("Mattermost-User-ID"))
+}
+
+// deleteUserDataHandler deletes the data of any user on the request of a system admin.
+func (p *Plugin) deleteUserDataHandler(w http.ResponseWriter, r *http.Request) {
+	p.writeUserDataDeleteResponse(w, mux.Vars(r)["user_id"])
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+)
+
+func TestGetKeyUserID(t *testing.T) {
+	userID := model.NewId()
+
+	assert.Equal(t, userID, getKeyUserID(userID))
+	assert.Equal(t, userID, getKeyUserID(getLanguageProfileKey(userID)))
+	assert.Equal(t, userID, getKeyUserID(getHistoryKey(userID)))
+	assert.Equal(t, userID, getKeyUserID(getConsentKey(userID)))
+
+	assert.Empty(t, getKeyUserID(activatedUsersKey))
+	assert.Empty(t, getKeyUserID(getFeedbackKey(model.NewId(), userID)))
+	assert.Empty(t, getKeyUserID(channelInfoKeyPrefix+model.NewId()))
+	assert.Empty(t, getKeyUserID(historyKeyPrefix+"not-an-id"))
+}
+
+func TestAnonymizeAuditEntries(t *testing.T) {
+	deleted := model.NewId()
+	other := model.NewId()
+	entries := []*AuditEntry{
+		{UserID: deleted, PostID: "post1"},
+		{UserID: other, PostID: "post2"},
+		{PostID: "post3"},
+	}
+
+	assert.False(t, anonymizeAuditEntries(entries, map[string]bool{model.NewId(): true}))
+	assert.True(t, anonymizeAuditEntries(entries, map[string]bool{deleted: true}))
+	assert.Equal(t, []*AuditEntry{
+		{PostID: "post1"},
+		{UserID: other, PostID: "post2"},
+		{PostID: "post3"},
+	}, entries)
+}
+
+func TestDeleteUserData(t *testing.T) {
+	userID := model.NewId()
+	otherID := model.NewId()
+	userPost := &model.Post{Id: model.NewId(), UserId: userID, Message: "안녕하세요", UpdateAt: 1000}
+	otherPost := &model.Post{Id: model.NewId(), UserId: otherID, Message: "감사합니다", UpdateAt: 1000}
+
+	newCachedTranslation := func(secret string, post *model.Post) []byte {
+		data, err := json.Marshal(newTranslatedMessage(post, "ko", "en", "Hello"))
+		require.NoError(t, err)
+		data, err = encryptValue(secret, data)
+		require.NoError(t, err)
+		return data
+	}
+
+	staleCacheKey := translationCacheKeyPrefix + "stale"
+	deletedKeys := []string{
+		userID,
+		getHistoryKey(userID),
+		getTranslationCacheKey(userPost, "en"),
+		getStoredTranslationsKey(userPost.Id),
+		staleCacheKey,
+	}
+	keptKeys := []string{
+		otherID,
+		getTranslationCacheKey(otherPost, "en"),
+		getStoredTranslationsKey(otherPost.Id),
+	}
+
+	activatedUsers, err := json.Marshal([]string{userID, otherID})
+	require.NoError(t, err)
+	remainingUsers, err := json.Marshal([]string{otherID})
+	require.NoError(t, err)
+
+	api := &plugintest.API{}
+	api.On("KVList", 0, keysPerPage).Return(append(append([]string{activatedUsersKey}, deletedKeys...), keptKeys...), nil)
+	api.On("KVGet", activatedUsersKey).Return(activatedUsers, nil)
+	api.On("KVSetWithOptions", activatedUsersKey, remainingUsers, model.PluginKVSetOptions{Atomic: true, OldValue: activatedUsers}).Return(true, nil)
+	api.On("KVGet", getTranslationCacheKey(userPost, "en")).Return(newCachedTranslation("secret", userPost), nil)
+	api.On("KVGet", getTranslationCacheKey(otherPost, "en")).Return(newCachedTranslation("secret", otherPost), nil)
+	api.On("KVGet", staleCacheKey).Return(newCachedTranslation("previous secret", otherPost), nil)
+	api.On("GetPost", userPost.Id).Return(userPost, nil)
+	api.On("GetPost", otherPost.Id).Return(otherPost, nil)
+	for _, key := range deletedKeys {
+		api.On("KVDelete", key).Return(nil)
+	}
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	p.setConfiguration(&configuration{EncryptionKey: "secret"})
+
+	deleted, err := p.deleteUserData(map[string]bool{userID: true})
+	require.NoError(t, err)
+	assert.Equal(t, len(deletedKeys), deleted)
+
+	for _, key := range keptKeys {
+		api.AssertNotCalled(t, "KVDelete", key)
+	}
+	api.AssertNumberOfCalls(t, "GetPost", 2)
+	api.AssertExpectations(t)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/translate"
+)
+
+const (
+	// Whether translations failing verification are flagged, or translated again by the
+	// verification provider first.
+	verificationFailureFlag  = "flag"
+	verificationFailureRetry = "retry"
+
+	defaultVerificationThreshold = 50
+)
+
+// getTextSimilarity returns how similar two texts are, from 0 to 1, as the Dice coefficient of
+// their trigrams, so that it also applies to languages without spaces between words.
+func getTextSimilarity(a, b string) float64 {
+	trigramsA, trigramsB := getTrigrams(a), getTrigrams(b)
+	if len(trigramsA) == 0 && len(trigramsB) == 0 {
+		return 1
+	}
+
+	counts := map[string]int{}
+	for _, trigram := range trigramsA {
+		counts[trigram]++
+	}
+
+	shared := 0
+	for _, trigram := range trigramsB {
+		if counts[trigram] > 0 {
+			counts[trigram]--
+			shared++
+		}
+	}
+
+	return float64(2*shared) / float64(len(trigramsA)+len(trigramsB))
+}
+
+func getVerificationWarning(similarity float64) string {
+	return fmt.Sprintf(":warning: _This translation may be inaccurate, as translating it back matches %d%% of the original message._", int(similarity*100))
+}
+
+// verifiesTranslations reports whether the translations of the messages of a channel are verified
+// by translating them back.
+func (p *Plugin) verifiesTranslations(channelID string) bool {
+	channelInfo, _ := p.getChannelInfo(channelID)
+	return channelInfo != nil && channelInfo.VerifyTranslations
+}
+
+// getRoundTripSimilarity back-translates the translation of text with the verification provider,
+// returning how similar the back-translation is to text.
+func (p *Plugin) getRoundTripSimilarity(ctx context.Context, svc *translate.Translate, source, target, text, translated string) (float64, error) {
+	ctx = newProviderContext(ctx, p.getConfiguration().getVerificationProvider())
+	backTranslated, err := p.translateLongText(ctx, svc, target, source, translated)
+	if err != nil {
+		return 0, err
+	}
+
+	return getTextSimilarity(text, backTranslated), nil
+}
+
+// verifyTranslation checks the translation of text from a known source language by translating it
+// back, returning the translation to deliver: as is when the round-trip similarity reaches the
+// verification threshold, or else flagged with a warning, after translating text again with the
+// verification provider when admins chose to retry and keeping the better translation. Failing to
+// verify a translation doesn't hold it back.
+func (p *Plugin) verifyTranslation(ctx context.Context, svc *translate.Translate, source, target, text, translated string) string {
+	config := p.getConfiguration()
+	threshold := float64(config.getVerificationThreshold()) / 100
+
+	similarity, err := p.getRoundTripSimilarity(ctx, svc, source, target, text, translated)
+	if err != nil {
+		p.API.LogWarn("Failed to verify translation", "request_id", getRequestID(ctx), "err", err.Error())
+		return translated
+	}
+	p.traceTranslation(ctx, "translation_verified", "similarity", similarity)
+	if similarity >= threshold {
+		return translated
+	}
+
+	if config.getVerificationFailure() == verificationFailureRetry {
+		retryCtx := newProviderContext(ctx, config.getVerificationProvider())
+		retried, retryErr := p.translateLongText(retryCtx, svc, source, target, text)
+		var retrySimilarity float64
+		if retryErr == nil {
+			retrySimilarity, retryErr = p.getRoundTripSimilarity(ctx, svc, source, target, text, retried)
+		}
+		if retryErr != nil {
+			p.API.LogWarn("Failed to translate again after failed verification", "request_id", getRequestID(ctx), "err", retryErr.Error())
+		} else if retrySimilarity > similarity {
+			translated, similarity = retried, retrySimilarity
+		}
+		if similarity >= threshold {
+			return translated
+		}
+	}
+
+	p.API.LogInfo("Translation failed verification", "request_id", getRequestID(ctx), "similarity", similarity)
+
+	return translated + "\n\n" + getVerificationWarning(similarity)
+}
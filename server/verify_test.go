@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTextSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, getTextSimilarity("", ""))
+	assert.Equal(t, 1.0, getTextSimilarity("The meeting is at noon.", "The meeting is at noon."))
+	assert.Equal(t, 0.0, getTextSimilarity("The meeting is at noon.", ""))
+	assert.Equal(t, 0.0, getTextSimilarity("abc", "xyz"))
+
+	near := getTextSimilarity("The meeting is at noon.", "The meeting is at midday.")
+	far := getTextSimilarity("The meeting is at noon.", "Lunch was cancelled.")
+	assert.Greater(t, near, 0.5)
+	assert.Less(t, far, 0.5)
+
+	// Texts without spaces between words are compared too.
+	assert.Greater(t, getTextSimilarity("会議は正午です。", "会議は正午からです。"), 0.5)
+}
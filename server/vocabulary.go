@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	// vocabularyCacheTTL is how long the vocabulary of a team is reused, as building it reads every
+	// member and public channel of the team.
+	vocabularyCacheTTL = time.Hour
+
+	vocabularyPerPage = 200
+
+	// maxVocabularyPages bounds the pages of users, channels and emojis read into a vocabulary, so
+	// that huge teams don't hold up translations.
+	maxVocabularyPages = 50
+
+	// maxVocabularyWords is the number of words above which a name isn't protected, as long
+	// channel names are rather descriptions to be translated.
+	maxVocabularyWords = 4
+)
+
+type vocabularyContextKey struct{}
+
+// vocabulary holds the names of a team, such as the names of its users, channels and custom
+// emojis, which are kept as is when translating so that "Bill" doesn't become an invoice.
+type vocabulary struct {
+	names    map[string]bool
+	maxWords int
+}
+
+type vocabularyCacheEntry struct {
+	vocabulary *vocabulary
+	expireAt   time.Time
+}
+
+func newVocabulary() *vocabulary {
+	return &vocabulary{names: map[string]bool{}}
+}
+
+// add adds a name to the vocabulary. Names without letters, single letters and names of too many
+// words are skipped, as they would rather match plain text.
+func (v *vocabulary) add(name string) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) < 2 || strings.IndexFunc(name, unicode.IsLetter) < 0 {
+		return
+	}
+
+	words := len(strings.Fields(name))
+	if words > maxVocabularyWords {
+		return
+	}
+
+	v.names[strings.Join(strings.Fields(name), " ")] = true
+	if words > v.maxWords {
+		v.maxWords = words
+	}
+}
+
+// isVocabularyWordRune reports whether r belongs to a word that may be a name, names such as
+// usernames holding dots, dashes and underscores.
+func isVocabularyWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_' || r == '-' || r == '.'
+}
+
+// maskVocabulary replaces the names of a vocabulary found in text with placeholders. Names are
+// matched as written, so that "Bill" is kept while "bill" is still translated, and as whole words,
+// the names of the most words first. Scripts written without spaces have no words to match.
+func (ph *placeholders) maskVocabulary(v *vocabulary, text string) string {
+	if v == nil || len(v.names) == 0 {
+		return text
+	}
+
+	// Words are located first, trailing punctuation such as the dot ending a sentence being left
+	// out of them.
+	var words [][2]int
+	for start := 0; start < len(text); {
+		r, size := utf8.DecodeRuneInString(text[start:])
+		if !isVocabularyWordRune(r) {
+			start += size
+			continue
+		}
+
+		end := start + size
+		for end < len(text) {
+			r, size = utf8.DecodeRuneInString(text[end:])
+			if !isVocabularyWordRune(r) {
+				break
+			}
+			end += size
+		}
+
+		word := strings.TrimRight(text[start:end], "._-")
+		if word != "" {
+			words = append(words, [2]int{start, start + len(word)})
+		}
+		start = end
+	}
+
+	var masked strings.Builder
+	last := 0
+	for i := 0; i < len(words); {
+		matched := 0
+		for n := v.maxWords; n > 0 && matched == 0; n-- {
+			if i+n > len(words) {
+				continue
+			}
+
+			name := text[words[i][0]:words[i+n-1][1]]
+			if v.names[name] {
+				matched = n
+			}
+		}
+
+		if matched == 0 {
+			i++
+			continue
+		}
+
+		start, end := words[i][0], words[i+matched-1][1]
+		masked.WriteString(text[last:start])
+		masked.WriteString(ph.add(text[start:end]))
+		last = end
+		i += matched
+	}
+	masked.WriteString(text[last:])
+
+	return masked.String()
+}
+
+// getContextVocabulary returns the vocabulary carried by ctx, if any.
+func getContextVocabulary(ctx context.Context) *vocabulary {
+	v, _ := ctx.Value(vocabularyContextKey{}).(*vocabulary)
+	return v
+}
+
+// getTeamVocabulary returns the vocabulary of a team, made of the names of its members, its public
+// channels, the team itself and the custom emojis. It is cached for a while, names changing
+// rarely.
+func (p *Plugin) getTeamVocabulary(teamID string) *vocabulary {
+	p.vocabulariesLock.Lock()
+	entry, ok := p.vocabularies[teamID]
+	p.vocabulariesLock.Unlock()
+	if ok && time.Now().Before(entry.expireAt) {
+		return entry.vocabulary
+	}
+
+	v := p.buildTeamVocabulary(teamID)
+
+	p.vocabulariesLock.Lock()
+	if p.vocabularies == nil {
+		p.vocabularies = map[string]vocabularyCacheEntry{}
+	}
+	p.vocabularies[teamID] = vocabularyCacheEntry{vocabulary: v, expireAt: time.Now().Add(vocabularyCacheTTL)}
+	p.vocabulariesLock.Unlock()
+
+	return v
+}
+
+func (p *Plugin) buildTeamVocabulary(teamID string) *vocabulary {
+	v := newVocabulary()
+
+	if team, appErr := p.API.GetTeam(teamID); appErr == nil {
+		v.add(team.Name)
+		v.add(team.DisplayName)
+	}
+
+	for page := 0; page < maxVocabularyPages; page++ {
+		users, appErr := p.API.GetUsersInTeam(teamID, page, vocabularyPerPage)
+		if appErr != nil {
+			p.API.LogWarn("Failed to get team members for vocabulary", "team_id", teamID, "err", appErr.Error())
+			break
+		}
+
+		for _, user := range users {
+			v.add(user.Username)
+			v.add(user.Nickname)
+			v.add(user.FirstName)
+			v.add(user.LastName)
+			if user.FirstName != "" && user.LastName != "" {
+				v.add(user.FirstName + " " + user.LastName)
+			}
+		}
+
+		if len(users) < vocabularyPerPage {
+			break
+		}
+	}
+
+	for page := 0; page < maxVocabularyPages; page++ {
+		channels, appErr := p.API.GetPublicChannelsForTeam(teamID, page, vocabularyPerPage)
+		if appErr != nil {
+			p.API.LogWarn("Failed to get team channels for vocabulary", "team_id", teamID, "err", appErr.Error())
+			break
+		}
+
+		for _, channel := range channels {
+			v.add(channel.Name)
+			v.add(channel.DisplayName)
+		}
+
+		if len(channels) < vocabularyPerPage {
+			break
+		}
+	}
+
+	for page := 0; page < maxVocabularyPages; page++ {
+		emojis, appErr := p.API.GetEmojiList(model.EMOJI_SORT_BY_NAME, page, vocabularyPerPage)
+		if appErr != nil {
+			// Custom emojis may be disabled, leaving only the names of the team.
+			break
+		}
+
+		for _, emoji := range emojis {
+			v.add(emoji.Name)
+		}
+
+		if len(emojis) < vocabularyPerPage {
+			break
+		}
+	}
+
+	return v
+}
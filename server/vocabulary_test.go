@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaceholdersMaskVocabulary(t *testing.T) {
+	v := newVocabulary()
+	for _, name := range []string{"Bill", "bill.smith", "Town Square", "Town Square Archive Old Stuff Here", "x", "42", "partyparrot"} {
+		v.add(name)
+	}
+
+	for name, tc := range map[string]struct {
+		text     string
+		expected string
+		values   []string
+	}{
+		"name":                {"Ask Bill about it.", "Ask {{0}} about it.", []string{"Bill"}},
+		"other case":          {"The bill is due", "The bill is due", nil},
+		"possessive":          {"Bill's laptop", "{{0}}'s laptop", []string{"Bill"}},
+		"username":            {"ping bill.smith.", "ping {{0}}.", []string{"bill.smith"}},
+		"several words":       {"Post in Town Square now", "Post in {{0}} now", []string{"Town Square"}},
+		"separated words":     {"Town, Square", "Town, Square", nil},
+		"part of a word":      {"Billing and Billy", "Billing and Billy", nil},
+		"too many words":      {"Town Square Archive Old Stuff Here", "{{0}} Archive Old Stuff Here", []string{"Town Square"}},
+		"short and no letter": {"x marks 42", "x marks 42", nil},
+		"emoji name":          {"such partyparrot", "such {{0}}", []string{"partyparrot"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ph := &placeholders{}
+			assert.Equal(t, tc.expected, ph.maskVocabulary(v, tc.text))
+			assert.Equal(t, tc.values, ph.values)
+			assert.Equal(t, tc.text, ph.restore(tc.expected))
+		})
+	}
+
+	ph := &placeholders{}
+	assert.Equal(t, "Bill", ph.maskVocabulary(nil, "Bill"))
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	// webhookTokenHeader carries the token of systems calling the translation webhook.
+	webhookTokenHeader = "X-Autotranslate-Token"
+
+	// webhookSignatureHeader carries the HMAC-SHA256 signature of webhook requests, as
+	// sha256=<hex>, computed over the timestamp, a dot and the body.
+	webhookSignatureHeader = "X-Autotranslate-Signature"
+
+	// webhookTimestampHeader carries the Unix time in seconds a webhook request was signed at.
+	webhookTimestampHeader = "X-Autotranslate-Timestamp"
+
+	webhookSignaturePrefix = "sha256="
+
+	// webhookSignatureTolerance bounds how far from now a signed webhook request may have been
+	// signed, so that captured requests can't be replayed later.
+	webhookSignatureTolerance = 5 * time.Minute
+
+	// maxWebhookRequestBytes bounds the size of webhook requests.
+	maxWebhookRequestBytes = 256 * 1024
+)
+
+// WebhookRequest is a text to translate sent by another system, optionally to be posted in a channel
+type WebhookRequest struct {
+	Text           string `json:"text"`
+	SourceLanguage string `json:"source_lang"`
+	TargetLanguage string `json:"target_lang"`
+	ChannelID      string `json:"channel_id,omitempty"`
+	RootID         string `json:"root_id,omitempty"`
+}
+
+// WebhookResponse is the translation of a webhook request, along with the ID of its post if any
+type WebhookResponse struct {
+	SourceLanguage string `json:"source_lang"`
+	TargetLanguage string `json:"target_lang"`
+	TranslatedText string `json:"translated_text"`
+	PostID         string `json:"post_id,omitempty"`
+}
+
+// splitWebhookSigningSecrets returns the secrets of a comma or newline separated list of webhook
+// signing secrets.
+func splitWebhookSigningSecrets(value string) []string {
+	var secrets []string
+	for _, secret := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+
+	return secrets
+}
+
+// computeWebhookSignature returns the signature of a webhook request signed with a secret at the
+// given timestamp, without its sha256= prefix.
+func computeWebhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyWebhookSignature reports whether a webhook request was signed with any of the secrets
+// within webhookSignatureTolerance of now. Any secret is accepted so that they can be rotated:
+// the new one is added, systems switch to it, and the old one is removed.
+func verifyWebhookSignature(secrets []string, signature, timestamp string, body []byte, now time.Time) bool {
+	if !strings.HasPrefix(signature, webhookSignaturePrefix) {
+		return false
+	}
+	signature = strings.TrimPrefix(signature, webhookSignaturePrefix)
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > webhookSignatureTolerance || skew < -webhookSignatureTolerance {
+		return false
+	}
+
+	valid := false
+	for _, secret := range secrets {
+		// Every secret is checked so that the time taken doesn't tell which one matched.
+		if hmac.Equal([]byte(signature), []byte(computeWebhookSignature(secret, timestamp, body))) {
+			valid = true
+		}
+	}
+
+	return valid
+}
+
+// withWebhookAuth only lets requests sending the configured webhook token through, either in the
+// token header or in the token query parameter, and signed with one of the webhook signing secrets
+// when any is set. The webhook is disabled while neither is set.
+func (p *Plugin) withWebhookAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config := p.getConfiguration()
+		webhookToken := config.WebhookToken
+		secrets := splitWebhookSigningSecrets(config.WebhookSigningSecrets)
+		if webhookToken == "" && len(secrets) == 0 {
+			writeAPIError(w, newNotFoundError())
+			return
+		}
+
+		if webhookToken != "" {
+			token := r.Header.Get(webhookTokenHeader)
+			if token == "" {
+				token = r.URL.Query().Get("token")
+			}
+
+			if subtle.ConstantTimeCompare([]byte(token), []byte(webhookToken)) != 1 {
+				writeAPIError(w, &APIErrorResponse{ID: apiErrorNotAuthorized, Message: "Not authorized", StatusCode: http.StatusUnauthorized})
+				return
+			}
+		}
+
+		if len(secrets) > 0 {
+			// The body is read to be verified, then handed over again to the webhook.
+			body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookRequestBytes))
+			if err != nil {
+				writeAPIError(w, newInvalidParameterError("body"))
+				return
+			}
+
+			if !verifyWebhookSignature(secrets, r.Header.Get(webhookSignatureHeader), r.Header.Get(webhookTimestampHeader), body, time.Now()) {
+				writeAPIError(w, &APIErrorResponse{ID: apiErrorInvalidSignature, Message: "Invalid or expired signature", StatusCode: http.StatusUnauthorized})
+				return
+			}
+
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkWebhookChannel checks the channel and thread a webhook request posts its translation in, if
+// any. As anyone with the token may call the webhook, the bot only posts in channels that aren't
+// archived and that admins added it to, and only in threads of that channel.
+func (p *Plugin) checkWebhookChannel(request *WebhookRequest) *APIErrorResponse {
+	if request.ChannelID == "" {
+		if request.RootID != "" {
+			return newInvalidParameterError("root_id")
+		}
+		return nil
+	}
+
+	if !model.IsValidId(request.ChannelID) {
+		return newInvalidParameterError("channel_id")
+	}
+
+	channel, appErr := p.API.GetChannel(request.ChannelID)
+	if appErr != nil || channel.DeleteAt != 0 {
+		return &APIErrorResponse{ID: apiErrorChannelNotFound, Message: "No channel to post in", StatusCode: http.StatusBadRequest}
+	}
+
+	if _, appErr = p.API.GetChannelMember(request.ChannelID, p.botUserID); appErr != nil {
+		return &APIErrorResponse{ID: apiErrorForbidden, Message: "The bot is not a member of the channel", StatusCode: http.StatusForbidden}
+	}
+
+	if request.RootID != "" {
+		if !model.IsValidId(request.RootID) {
+			return newInvalidParameterError("root_id")
+		}
+
+		root, rootErr := p.API.GetPost(request.RootID)
+		if rootErr != nil || root.ChannelId != request.ChannelID || root.RootId != "" {
+			return newInvalidParameterError("root_id")
+		}
+	}
+
+	return nil
+}
+
+// handleWebhook translates the text sent by another system, such as a CI or ticketing system,
+// posting the translation as the bot in the given channel if any.
+func (p *Plugin) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	var request *WebhookRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookRequestBytes)).Decode(&request); err != nil || request == nil || request.Text == "" {
+		writeAPIError(w, newInvalidParameterError("text"))
+		return
+	}
+
+	if request.SourceLanguage == "" {
+		request.SourceLanguage = autoLanguage
+	}
+
+	if languageCodes[request.SourceLanguage] == "" {
+		writeAPIError(w, newInvalidParameterError("source_lang"))
+		return
+	}
+
+	if request.TargetLanguage == autoLanguage || languageCodes[request.TargetLanguage] == "" {
+		writeAPIError(w, newInvalidParameterError("target_lang"))
+		return
+	}
+
+	if apiErr := p.checkWebhookChannel(request); apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+
+	svc, err := p.getTranslateService()
+	if err != nil {
+		writeAPIError(w, &APIErrorResponse{ID: apiErrorBadCredentials, Message: "Bad credentials", StatusCode: http.StatusForbidden})
+		return
+	}
+
+	// Texts posted in a channel follow its glossary and its provider policy like its posts.
+	ctx := newAuditContext(r.Context(), "", "", request.ChannelID)
+	if request.ChannelID != "" {
+		ctx = p.newGlossaryContext(ctx, request.ChannelID)
+	}
+
+	translatedText, err := p.translateLongText(ctx, svc, request.SourceLanguage, request.TargetLanguage, request.Text)
+	if err != nil {
+		p.API.LogError("Failed to translate webhook text", "request_id", getRequestID(r.Context()), "err", err.Error())
+		writeAPIError(w, newTranslationError(err))
+		return
+	}
+
+	response := &WebhookResponse{
+		SourceLanguage: request.SourceLanguage,
+		TargetLanguage: request.TargetLanguage,
+		TranslatedText: translatedText,
+	}
+
+	if request.ChannelID != "" {
+		post := &model.Post{
+			UserId:    p.botUserID,
+			ChannelId: request.ChannelID,
+			RootId:    request.RootID,
+			ParentId:  request.RootID,
+			Message:   translatedText,
+		}
+		post.AddProp(translationSourceLanguageProp, request.SourceLanguage)
+		post.AddProp(translationTargetLanguageProp, request.TargetLanguage)
+
+		post, appErr := p.API.CreatePost(post)
+		if appErr != nil {
+			p.API.LogError("Failed to create webhook translation post", "channel_id", request.ChannelID, "err", appErr.Error())
+			writeAPIError(w, &APIErrorResponse{ID: apiErrorUnableToSave, Message: "Failed to post translation", StatusCode: http.StatusInternalServerError})
+			return
+		}
+		response.PostID = post.Id
+	}
+
+	resp, _ := json.Marshal(response)
+	w.Write(resp)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+)
+
+func TestSplitWebhookSigningSecrets(t *testing.T) {
+	assert.Nil(t, splitWebhookSigningSecrets(""))
+	assert.Equal(t, []string{"old", "new"}, splitWebhookSigningSecrets(" old ,\nnew\n\n"))
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	body := []byte(`{"text":"Hello","target_lang":"fr"}`)
+	secrets := []string{"old-secret", "new-secret"}
+
+	sign := func(secret, timestamp string) string {
+		return webhookSignaturePrefix + computeWebhookSignature(secret, timestamp, body)
+	}
+
+	assert.True(t, verifyWebhookSignature(secrets, sign("old-secret", timestamp), timestamp, body, now))
+	assert.True(t, verifyWebhookSignature(secrets, sign("new-secret", timestamp), timestamp, body, now))
+	assert.True(t, verifyWebhookSignature(secrets, sign("new-secret", timestamp), timestamp, body, now.Add(webhookSignatureTolerance)))
+
+	assert.False(t, verifyWebhookSignature(secrets, sign("other-secret", timestamp), timestamp, body, now), "unknown secret")
+	assert.False(t, verifyWebhookSignature(secrets, computeWebhookSignature("new-secret", timestamp, body), timestamp, body, now), "missing prefix")
+	assert.False(t, verifyWebhookSignature(secrets, sign("new-secret", timestamp), timestamp, []byte(`{"text":"Bye"}`), now), "altered body")
+	assert.False(t, verifyWebhookSignature(secrets, sign("new-secret", timestamp), "1700000001", body, now), "altered timestamp")
+	assert.False(t, verifyWebhookSignature(secrets, sign("new-secret", timestamp), timestamp, body, now.Add(webhookSignatureTolerance+time.Second)), "expired")
+	assert.False(t, verifyWebhookSignature(secrets, sign("new-secret", timestamp), timestamp, body, now.Add(-webhookSignatureTolerance-time.Second)), "signed in the future")
+	assert.False(t, verifyWebhookSignature(secrets, sign("new-secret", "not-a-time"), "not-a-time", body, now), "invalid timestamp")
+	assert.False(t, verifyWebhookSignature(nil, sign("new-secret", timestamp), timestamp, body, now), "no secret")
+}
+
+func TestCheckWebhookChannel(t *testing.T) {
+	botUserID := model.NewId()
+	channel := &model.Channel{Id: model.NewId(), Type: model.CHANNEL_OPEN}
+	archived := &model.Channel{Id: model.NewId(), Type: model.CHANNEL_OPEN, DeleteAt: 1000}
+	private := &model.Channel{Id: model.NewId(), Type: model.CHANNEL_PRIVATE}
+	root := &model.Post{Id: model.NewId(), ChannelId: channel.Id}
+	reply := &model.Post{Id: model.NewId(), ChannelId: channel.Id, RootId: root.Id}
+	otherRoot := &model.Post{Id: model.NewId(), ChannelId: private.Id}
+
+	api := &plugintest.API{}
+	api.On("GetChannel", channel.Id).Return(channel, nil)
+	api.On("GetChannel", archived.Id).Return(archived, nil)
+	api.On("GetChannel", private.Id).Return(private, nil)
+	api.On("GetChannelMember", channel.Id, botUserID).Return(&model.ChannelMember{ChannelId: channel.Id, UserId: botUserID}, nil)
+	api.On("GetChannelMember", private.Id, botUserID).Return(nil, model.NewAppError("GetChannelMember", "app.channel.get_member.missing.app_error", nil, "", http.StatusNotFound))
+	api.On("GetPost", root.Id).Return(root, nil)
+	api.On("GetPost", reply.Id).Return(reply, nil)
+	api.On("GetPost", otherRoot.Id).Return(otherRoot, nil)
+
+	p := &Plugin{botUserID: botUserID}
+	p.SetAPI(api)
+
+	check := func(channelID, rootID string) string {
+		apiErr := p.checkWebhookChannel(&WebhookRequest{ChannelID: channelID, RootID: rootID})
+		if apiErr == nil {
+			return ""
+		}
+		return apiErr.ID
+	}
+
+	assert.Empty(t, check("", ""), "texts which aren't posted need no channel")
+	assert.Empty(t, check(channel.Id, ""))
+	assert.Empty(t, check(channel.Id, root.Id))
+
+	assert.Equal(t, apiErrorInvalidParameter, check("", root.Id), "threads need a channel")
+	assert.Equal(t, apiErrorInvalidParameter, check("not-an-id", ""))
+	assert.Equal(t, apiErrorChannelNotFound, check(archived.Id, ""), "archived channel")
+	assert.Equal(t, apiErrorForbidden, check(private.Id, ""), "channel the bot wasn't added to")
+	assert.Equal(t, apiErrorInvalidParameter, check(channel.Id, otherRoot.Id), "thread of another channel")
+	assert.Equal(t, apiErrorInvalidParameter, check(channel.Id, reply.Id), "reply instead of thread root")
+}
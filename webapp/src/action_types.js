@@ -4,3 +4,4 @@ import PluginId from './plugin_id';
 export const INFO_CHANGE = PluginId + '_info_change';
 export const SAVE_TRANSLATED_POST = PluginId + '_save_translated_post';
 export const SAVE_TRANSLATION = PluginId + '_save_translation';
+export const RECEIVED_POST_TRANSLATION = PluginId + '_received_post_translation';
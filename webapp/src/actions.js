@@ -9,6 +9,7 @@ import {
 
 import {
     INFO_CHANGE,
+    RECEIVED_POST_TRANSLATION,
     SAVE_TRANSLATED_POST,
     SAVE_TRANSLATION,
 } from './action_types';
@@ -80,6 +81,45 @@ export const getTranslatedMessage = (postId) => {
     };
 };
 
+// fetchedPostTranslations holds the posts whose translation into a language was fetched already,
+// so that posts without one aren't fetched again whenever they render.
+const fetchedPostTranslations = new Set();
+
+export const fetchPostTranslation = (postId) => {
+    return async (dispatch, getState) => {
+        const userInfo = getUserInfo(getState());
+        if (!userInfo || !userInfo.target_language) {
+            return {data: null};
+        }
+
+        const target = userInfo.target_language;
+        const key = `${postId}_${target}`;
+        if (fetchedPostTranslations.has(key)) {
+            return {data: null};
+        }
+        fetchedPostTranslations.add(key);
+
+        let result;
+        try {
+            result = await Client.getTranslation(postId, target);
+        } catch (error) {
+            return {error};
+        }
+
+        dispatch({
+            type: RECEIVED_POST_TRANSLATION,
+            data: {
+                post_id: postId,
+                source_language: result.source_lang,
+                target_language: target,
+                translated_text: result.translated_text,
+            },
+        });
+
+        return {data: result};
+    };
+};
+
 export const saveTranslatedPost = (data) => {
     return (dispatch) => {
         dispatch({type: SAVE_TRANSLATED_POST, data});
@@ -109,3 +149,9 @@ export const websocketInfoChange = (message) => {
         dispatch({type: INFO_CHANGE, data: message.data});
     };
 };
+
+export const websocketTranslationUpdate = (message) => {
+    return (dispatch) => {
+        dispatch({type: RECEIVED_POST_TRANSLATION, data: message.data});
+    };
+};
@@ -1,25 +1,37 @@
 import request from 'superagent';
 
 import PluginId from './plugin_id';
-import {buildQueryString} from './utils';
+import {buildQueryString, getCSRFFromCookie} from './utils';
 
 /* eslint-disable no-useless-catch */
 
 class ClientClass {
     constructor() {
-        this.url = `/plugins/${PluginId}/api`;
+        this.url = `/plugins/${PluginId}/api/v1`;
     }
 
     getGo = async (postId, source, target) => {
-        return this.doGet(this.url + '/go' + buildQueryString({post_id: postId, source, target}));
+        return this.doGet(`${this.url}/posts/${postId}/translation` + buildQueryString({source, target}));
+    }
+
+    getTranslation = async (postId, target) => {
+        return this.doGet(`${this.url}/translation/${postId}` + buildQueryString({target}));
     }
 
     getInfo = async () => {
-        return this.doGet(`${this.url}/get_info`);
+        return this.doGet(`${this.url}/info`);
     }
 
     postInfo = async (info) => {
-        return this.doPost(`${this.url}/set_info`, info);
+        return this.doPost(`${this.url}/info`, info);
+    }
+
+    getHealth = async () => {
+        return this.doGet(`${this.url}/health`);
+    }
+
+    getDiagnostics = async () => {
+        return this.doGet(`${this.url}/diagnostics`);
     }
 
     doGet = async (url, headers = {}) => {
@@ -39,6 +51,7 @@ class ClientClass {
 
     doPost = async (url, body, headers = {}) => {
         headers['X-Requested-With'] = 'XMLHttpRequest';
+        headers['X-CSRF-Token'] = getCSRFFromCookie();
 
         try {
             const response = await request.
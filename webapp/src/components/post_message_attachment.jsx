@@ -1,9 +1,11 @@
 import ErrorBoundary from './error_boundary';
+import PostTranslation from './post_translation';
 import TranslatedMessage from './translated_message';
 
 const PostMessageAttachment = (props = {}) => {
     return (
         <ErrorBoundary>
+            <PostTranslation {...props}/>
             <TranslatedMessage {...props}/>
         </ErrorBoundary>
     );
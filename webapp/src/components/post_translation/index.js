@@ -0,0 +1,25 @@
+import {connect} from 'react-redux';
+import {bindActionCreators} from 'redux';
+import {getPost} from 'mattermost-redux/selectors/entities/posts';
+
+import {getPostTranslation, getUserInfo} from 'selectors';
+import {fetchPostTranslation} from 'actions';
+
+import PostTranslation from './post_translation';
+
+const mapStateToProps = (state, ownProps) => {
+    const post = getPost(state, ownProps.postId);
+    const userInfo = getUserInfo(state);
+
+    return {
+        inPlace: Boolean(post && post.props && post.props.autotranslate_in_place),
+        targetLanguage: userInfo && userInfo.target_language,
+        translation: getPostTranslation(state, ownProps.postId),
+    };
+};
+
+const mapDispatchToProps = (dispatch) => bindActionCreators({
+    fetchPostTranslation,
+}, dispatch);
+
+export default connect(mapStateToProps, mapDispatchToProps)(PostTranslation);
@@ -0,0 +1,66 @@
+import React from 'react';
+import PropTypes from 'prop-types';
+
+export default class PostTranslation extends React.PureComponent {
+    static propTypes = {
+        postId: PropTypes.string,
+        inPlace: PropTypes.bool,
+        targetLanguage: PropTypes.string,
+        translation: PropTypes.object,
+        fetchPostTranslation: PropTypes.func,
+        onHeightChange: PropTypes.func,
+    }
+
+    state = {
+        showOriginal: false,
+    }
+
+    componentDidMount() {
+        this.fetchTranslation();
+    }
+
+    componentDidUpdate(prevProps) {
+        if (this.props.targetLanguage !== prevProps.targetLanguage) {
+            this.fetchTranslation();
+        }
+    }
+
+    // Translations delivered in place are kept by the plugin rather than in the post.
+    fetchTranslation() {
+        if (this.props.inPlace && this.props.targetLanguage && !this.props.translation && this.props.fetchPostTranslation) {
+            this.props.fetchPostTranslation(this.props.postId);
+        }
+    }
+
+    handleToggle = () => {
+        this.setState((state) => ({showOriginal: !state.showOriginal}));
+        if (this.props.onHeightChange) {
+            this.props.onHeightChange(1);
+        }
+    }
+
+    render() {
+        const {translation} = this.props;
+
+        if (!translation || !translation.translated_text) {
+            return null;
+        }
+
+        if (this.state.showOriginal) {
+            return (
+                <p>
+                    <i className='icon fa fa-language'/>
+                    <a onClick={this.handleToggle}>{'  Show translation'}</a>
+                </p>
+            );
+        }
+
+        return (
+            <p>
+                <i className='icon fa fa-language'/>
+                <span>{`  ${translation.translated_text}  `}</span>
+                <a onClick={this.handleToggle}>{'(show original)'}</a>
+            </p>
+        );
+    }
+}
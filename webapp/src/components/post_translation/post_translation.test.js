@@ -0,0 +1,38 @@
+import '@testing-library/jest-dom';
+import React from 'react';
+import {fireEvent, render, screen} from '@testing-library/react';
+
+import PostTranslation from './post_translation';
+
+test('should not render without translation', async () => {
+    const {container, rerender} = render(<PostTranslation/>);
+    expect(container).toMatchInlineSnapshot('<div />');
+
+    rerender(<PostTranslation translation={{translated_text: ''}}/>);
+    expect(container).toMatchInlineSnapshot('<div />');
+});
+
+test('should toggle between translation and original', async () => {
+    const onHeightChange = jest.fn();
+    const translation = {
+        source_language: 'ko',
+        target_language: 'en',
+        translated_text: 'Hello world',
+    };
+
+    render(
+        <PostTranslation
+            translation={translation}
+            onHeightChange={onHeightChange}
+        />,
+    );
+    expect(screen.getByText(translation.translated_text)).toBeInTheDocument();
+
+    fireEvent.click(screen.getByText(/show original/i));
+    expect(screen.queryByText(translation.translated_text)).toBeNull();
+    expect(onHeightChange).toHaveBeenCalledTimes(1);
+
+    fireEvent.click(screen.getByText(/show translation/i));
+    expect(screen.getByText(translation.translated_text)).toBeInTheDocument();
+    expect(onHeightChange).toHaveBeenCalledTimes(2);
+});
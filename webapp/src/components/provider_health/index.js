@@ -0,0 +1,3 @@
+import ProviderHealth from './provider_health';
+
+export default ProviderHealth;
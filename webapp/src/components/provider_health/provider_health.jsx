@@ -0,0 +1,101 @@
+import React from 'react';
+import PropTypes from 'prop-types';
+
+import Client from '../../clients';
+
+const formatTime = (millis) => new Date(millis).toLocaleString();
+
+// ProviderHealth shows the health of the translation provider in the System Console, as last
+// checked in the background by the server. It reads the diagnostics, as the health endpoint
+// answers without authentication and so leaves the errors out.
+export default class ProviderHealth extends React.PureComponent {
+    static propTypes = {
+        label: PropTypes.node,
+        helpText: PropTypes.node,
+        getHealth: PropTypes.func,
+    }
+
+    static defaultProps = {
+        getHealth: Client.getDiagnostics,
+    }
+
+    state = {
+        health: null,
+        error: null,
+    }
+
+    componentDidMount() {
+        this.loadHealth();
+    }
+
+    loadHealth = async () => {
+        try {
+            const health = await this.props.getHealth();
+            this.setState({health, error: null});
+        } catch (err) {
+            // The health is still reported when the plugin can't translate, with a 503 status.
+            if (err.response && err.response.body && err.response.body.status) {
+                this.setState({health: err.response.body, error: null});
+                return;
+            }
+            this.setState({health: null, error: err.message || 'Failed to get the provider health'});
+        }
+    }
+
+    renderStatus() {
+        const {health, error} = this.state;
+
+        if (error) {
+            return <span>{`Unknown: ${error}`}</span>;
+        }
+
+        if (!health) {
+            return <span>{'Loading...'}</span>;
+        }
+
+        if (!health.configuration_valid) {
+            return <span>{`Not configured: ${health.configuration_error}`}</span>;
+        }
+
+        const provider = health.provider;
+        if (!provider) {
+            return <span>{'Not checked yet.'}</span>;
+        }
+
+        if (provider.reachable) {
+            return <span>{`Available, answering in ${provider.latency_ms} ms when checked at ${formatTime(provider.checked_at)}.`}</span>;
+        }
+
+        return (
+            <span>
+                {`Unavailable since ${formatTime(provider.failing_since || provider.checked_at)}, last checked at ${formatTime(provider.checked_at)}.`}
+                {provider.error && <code>{provider.error}</code>}
+            </span>
+        );
+    }
+
+    render() {
+        return (
+            <div className='form-group'>
+                <label className='control-label col-sm-4'>
+                    {this.props.label}
+                </label>
+                <div className='col-sm-8'>
+                    <div className='help-text'>
+                        {this.renderStatus()}
+                    </div>
+                    <div className='help-text'>
+                        {this.props.helpText}
+                    </div>
+                    <button
+                        type='button'
+                        className='btn btn-default'
+                        onClick={this.loadHealth}
+                    >
+                        {'Refresh'}
+                    </button>
+                </div>
+            </div>
+        );
+    }
+}
@@ -0,0 +1,44 @@
+import '@testing-library/jest-dom';
+import React from 'react';
+import {render, screen} from '@testing-library/react';
+
+import ProviderHealth from './provider_health';
+
+test('should show the provider as available', async () => {
+    const getHealth = jest.fn().mockResolvedValue({
+        status: 'ok',
+        configuration_valid: true,
+        provider: {provider: 'aws', reachable: true, latency_ms: 120, checked_at: 1592000000000},
+    });
+
+    render(<ProviderHealth getHealth={getHealth}/>);
+    expect(await screen.findByText(/Available, answering in 120 ms/)).toBeInTheDocument();
+    expect(getHealth).toHaveBeenCalledTimes(1);
+});
+
+test('should show the provider error when unavailable', async () => {
+    const err = new Error('Service Unavailable');
+    err.response = {
+        body: {
+            status: 'unavailable',
+            configuration_valid: true,
+            provider: {provider: 'aws', reachable: false, error: 'UnrecognizedClientException', checked_at: 1592000000000, failing_since: 1591990000000},
+        },
+    };
+    const getHealth = jest.fn().mockRejectedValue(err);
+
+    render(<ProviderHealth getHealth={getHealth}/>);
+    expect(await screen.findByText(/Unavailable since/)).toBeInTheDocument();
+    expect(screen.getByText('UnrecognizedClientException')).toBeInTheDocument();
+});
+
+test('should show an invalid configuration', async () => {
+    const getHealth = jest.fn().mockResolvedValue({
+        status: 'unavailable',
+        configuration_valid: false,
+        configuration_error: 'Must have AWS Access Key ID',
+    });
+
+    render(<ProviderHealth getHealth={getHealth}/>);
+    expect(await screen.findByText(/Not configured: Must have AWS Access Key ID/)).toBeInTheDocument();
+});
@@ -3,6 +3,7 @@ import React from 'react';
 import {getPost} from 'mattermost-redux/selectors/entities/posts';
 
 import PostMessageAttachment from './components/post_message_attachment';
+import ProviderHealth from './components/provider_health';
 import TranslateMenuItem from './components/translate_menu_item';
 
 import PluginId from './plugin_id';
@@ -11,6 +12,7 @@ import {
     getTranslatedMessage,
     getInfo,
     websocketInfoChange,
+    websocketTranslationUpdate,
 } from './actions';
 import reducer from './reducer';
 import {getUserInfo} from './selectors';
@@ -31,7 +33,7 @@ export default class AWSTranslatePlugin {
                 const state = store.getState();
                 const post = getPost(state, postId);
                 const userInfo = getUserInfo(state);
-                return post && post.type === '' && userInfo && userInfo.activated;
+                return post && post.type === '' && !(post.props && post.props.autotranslate_no_translate) && userInfo && userInfo.activated;
             },
         );
 
@@ -42,6 +44,18 @@ export default class AWSTranslatePlugin {
             },
         );
 
+        registry.registerWebSocketEventHandler(
+            'custom_' + PluginId + '_translation_update',
+            (message) => {
+                store.dispatch(websocketTranslationUpdate(message));
+            },
+        );
+
+        // Custom System Console settings are only supported by recent servers.
+        if (registry.registerAdminConsoleCustomSetting) {
+            registry.registerAdminConsoleCustomSetting('ProviderHealth', ProviderHealth);
+        }
+
         // Fetch the current status whenever we recover an internet connection.
         registry.registerReconnectHandler(() => {
             store.dispatch(getInfo());
@@ -2,6 +2,7 @@ import {combineReducers} from 'redux';
 
 import {
     INFO_CHANGE,
+    RECEIVED_POST_TRANSLATION,
     SAVE_TRANSLATED_POST,
     SAVE_TRANSLATION,
 } from './action_types';
@@ -42,7 +43,22 @@ const translations = (state = {}, action) => {
     }
 };
 
+const postTranslations = (state = {}, action) => {
+    switch (action.type) {
+    case RECEIVED_POST_TRANSLATION: {
+        const {post_id: postId, target_language: target, ...translation} = action.data;
+        const nextState = {};
+        nextState[postId] = {...state[postId], [target]: translation};
+
+        return {...state, ...nextState};
+    }
+    default:
+        return state;
+    }
+};
+
 export default combineReducers({
+    postTranslations,
     translatedPosts,
     translations,
     userInfo,
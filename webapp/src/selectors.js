@@ -1,3 +1,5 @@
+import {getPost} from 'mattermost-redux/selectors/entities/posts';
+
 import PluginId from './plugin_id';
 
 const getPluginState = (state) => state['plugins-' + PluginId] || {};
@@ -5,3 +7,24 @@ const getPluginState = (state) => state['plugins-' + PluginId] || {};
 export const getUserInfo = (state) => getPluginState(state).userInfo;
 export const getTranslatedPosts = (state) => getPluginState(state).translatedPosts;
 export const getTranslations = (state) => getPluginState(state).translations;
+export const getPostTranslations = (state) => getPluginState(state).postTranslations;
+
+// getPostTranslation returns the translation of a post delivered in place into the target
+// language of the current user, either received from the plugin or stored in the props of posts
+// translated before translations were kept by the plugin, or null when there is none.
+export const getPostTranslation = (state, postId) => {
+    const userInfo = getUserInfo(state);
+    if (!userInfo || !userInfo.target_language) {
+        return null;
+    }
+
+    const target = userInfo.target_language;
+    const post = getPost(state, postId);
+    const translation = ((getPostTranslations(state) || {})[postId] || {})[target] ||
+        (post && post.props && post.props.autotranslate_translations && post.props.autotranslate_translations[target]);
+    if (!translation) {
+        return null;
+    }
+
+    return {...translation, post_id: postId, target_language: target};
+};
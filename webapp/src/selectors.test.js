@@ -0,0 +1,56 @@
+import PluginId from './plugin_id';
+import {getPostTranslation} from './selectors';
+
+const newState = (targetLanguage, postTranslations = {}, props = {}) => ({
+    entities: {
+        posts: {
+            posts: {
+                post1: {id: 'post1', props},
+            },
+        },
+    },
+    ['plugins-' + PluginId]: {
+        userInfo: {target_language: targetLanguage},
+        postTranslations,
+    },
+});
+
+test('should return the translation into the language of the user', () => {
+    const state = newState('ja', {
+        post1: {
+            en: {source_language: 'ko', translated_text: 'Hello'},
+            ja: {source_language: 'ko', translated_text: 'こんにちは'},
+        },
+    });
+
+    expect(getPostTranslation(state, 'post1')).toEqual({
+        post_id: 'post1',
+        source_language: 'ko',
+        target_language: 'ja',
+        translated_text: 'こんにちは',
+    });
+});
+
+test('should not return a translation into another language', () => {
+    const state = newState('fr', {
+        post1: {
+            en: {source_language: 'ko', translated_text: 'Hello'},
+        },
+    }, {
+        autotranslate_translations: {
+            ja: {source_language: 'ko', translated_text: 'こんにちは'},
+        },
+    });
+
+    expect(getPostTranslation(state, 'post1')).toBeNull();
+});
+
+test('should return the translation stored in the props of older posts', () => {
+    const state = newState('en', {}, {
+        autotranslate_translations: {
+            en: {source_language: 'ko', translated_text: 'Hello'},
+        },
+    });
+
+    expect(getPostTranslation(state, 'post1').translated_text).toBe('Hello');
+});
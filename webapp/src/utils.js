@@ -15,4 +15,20 @@ export function buildQueryString(parameters) {
     }
 
     return query;
-}
\ No newline at end of file
+}
+
+export function getCSRFFromCookie() {
+    if (typeof document === 'undefined' || typeof document.cookie === 'undefined') {
+        return '';
+    }
+
+    const cookies = document.cookie.split(';');
+    for (let i = 0; i < cookies.length; i++) {
+        const cookie = cookies[i].trim();
+        if (cookie.startsWith('MMCSRF=')) {
+            return cookie.replace('MMCSRF=', '');
+        }
+    }
+
+    return '';
+}